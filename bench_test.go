@@ -3,8 +3,12 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/amzn/ion-go/ion"
 	"github.com/danielgtaylor/restish/cli"
@@ -12,6 +16,7 @@ import (
 	"github.com/fxamacker/cbor/v2"
 	"github.com/shamaton/msgpack/v2"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func BenchmarkFormats(b *testing.B) {
@@ -131,3 +136,116 @@ func BenchmarkFormats(b *testing.B) {
 		})
 	}
 }
+
+// benchmarkSpec generates an OpenAPI document with the given number of paths,
+// each with a few operations, parameters, and schemas.
+func benchmarkSpec(paths int) []byte {
+	item := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id":      map[string]interface{}{"type": "string"},
+			"name":    map[string]interface{}{"type": "string", "description": "Name of the item"},
+			"created": map[string]interface{}{"type": "string", "format": "date-time"},
+			"tags":    map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+	}
+
+	doc := map[string]interface{}{
+		"openapi": "3.0.0",
+		"info":    map[string]interface{}{"title": "Benchmark", "version": "1.0.0"},
+		"paths":   map[string]interface{}{},
+	}
+
+	for i := 0; i < paths; i++ {
+		response := map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "Success",
+				"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": item}},
+			},
+		}
+
+		doc["paths"].(map[string]interface{})[fmt.Sprintf("/resource%d/{id}", i)] = map[string]interface{}{
+			"parameters": []interface{}{
+				map[string]interface{}{"name": "id", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}},
+			},
+			"get": map[string]interface{}{
+				"operationId": fmt.Sprintf("get-resource%d", i),
+				"parameters": []interface{}{
+					map[string]interface{}{"name": "fields", "in": "query", "schema": map[string]interface{}{"type": "string"}},
+				},
+				"responses": response,
+			},
+			"put": map[string]interface{}{
+				"operationId": fmt.Sprintf("put-resource%d", i),
+				"requestBody": map[string]interface{}{
+					"content": map[string]interface{}{"application/json": map[string]interface{}{"schema": item}},
+				},
+				"responses": response,
+			},
+		}
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// BenchmarkAPICache compares loading an API without the cache, from a cache
+// which has expired but whose API description is unchanged, and from a fresh
+// cache.
+func BenchmarkAPICache(b *testing.B) {
+	dir := b.TempDir()
+	spec := filepath.Join(dir, "openapi.json")
+	if err := ioutil.WriteFile(spec, benchmarkSpec(200), 0600); err != nil {
+		panic(err)
+	}
+
+	apis := fmt.Sprintf(`{"bench": {"base": "https://bench.example.com", "spec_files": [%q]}}`, spec)
+	if err := ioutil.WriteFile(filepath.Join(dir, "apis.json"), []byte(apis), 0600); err != nil {
+		panic(err)
+	}
+
+	os.Setenv("RSH_CONFIG_DIR", dir)
+	defer os.Unsetenv("RSH_CONFIG_DIR")
+
+	cli.Init("benchmark", "1.0.0")
+	cli.Defaults()
+	cli.AddLoader(openapi.New())
+
+	load := func() {
+		if _, err := cli.Load("https://bench.example.com", &cobra.Command{}); err != nil {
+			panic(err)
+		}
+	}
+
+	b.Run("cold", func(b *testing.B) {
+		viper.Set("rsh-no-cache", true)
+		defer viper.Set("rsh-no-cache", false)
+
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			load()
+		}
+	})
+
+	b.Run("unchanged", func(b *testing.B) {
+		load()
+
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			cli.Cache.Set("apis.bench.expires", time.Now().Add(-time.Hour))
+			load()
+		}
+	})
+
+	b.Run("fresh", func(b *testing.B) {
+		load()
+
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			load()
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	jmespath "github.com/danielgtaylor/go-jmespath-plus"
+)
+
+// numberValue converts a decoded number to an exact rational and reports
+// whether it is an integer.
+func numberValue(value interface{}) (*big.Rat, bool, error) {
+	switch v := value.(type) {
+	case json.Number:
+		r, ok := new(big.Rat).SetString(v.String())
+		if !ok {
+			return nil, false, fmt.Errorf("%v is not a number", v)
+		}
+		return r, !strings.ContainsAny(v.String(), ".eE"), nil
+	case *big.Int:
+		return new(big.Rat).SetInt(v), true, nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, false, fmt.Errorf("%v is not a number", v)
+		}
+		return new(big.Rat).SetFloat64(v), v == math.Trunc(v), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(rv.Int()), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(rv.Uint())), true, nil
+	case reflect.Float32:
+		return numberValue(rv.Float())
+	}
+
+	encoded, _ := json.Marshal(makeJSONSafe(value, true))
+	return nil, false, fmt.Errorf("%s is not a number", encoded)
+}
+
+// numbersTotal adds up the numbers in an array, or the JMESPath field
+// expression like `.price` applied to each item. Null or missing values are
+// skipped rather than counted as zero. It returns the exact total, the count
+// of numbers added, and whether they were all integers.
+func numbersTotal(data interface{}, field string) (*big.Rat, int, bool, error) {
+	items, ok := data.([]interface{})
+	if !ok {
+		return nil, 0, false, errors.New("input must be an array")
+	}
+
+	var compiled *jmespath.JMESPath
+	if field != "" {
+		var err error
+		if compiled, err = jmespath.Compile(strings.TrimPrefix(field, ".")); err != nil {
+			return nil, 0, false, fmt.Errorf("field %q: %w", field, err)
+		}
+	}
+
+	total := new(big.Rat)
+	count := 0
+	integers := true
+	for i, item := range items {
+		if compiled != nil {
+			var err error
+			if item, err = compiled.Search(item); err != nil {
+				return nil, 0, false, fmt.Errorf("field %q: %w", field, err)
+			}
+		}
+
+		if item == nil {
+			continue
+		}
+
+		n, isInt, err := numberValue(item)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("item %d: %w", i, err)
+		}
+
+		total.Add(total, n)
+		count++
+		integers = integers && isInt
+	}
+
+	return total, count, integers, nil
+}
+
+// formatNumber prints a number as a plain integer when it is one and the
+// inputs were all integers, otherwise as a decimal without an exponent.
+func formatNumber(n *big.Rat, integer bool) string {
+	if integer && n.IsInt() {
+		return n.Num().String()
+	}
+
+	f, _ := n.Float64()
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// sumNumbers returns the formatted sum of the numbers in an array.
+func sumNumbers(data interface{}, field string) (string, error) {
+	total, _, integers, err := numbersTotal(data, field)
+	if err != nil {
+		return "", err
+	}
+
+	return formatNumber(total, integers), nil
+}
+
+// avgNumbers returns the formatted mean of the numbers in an array.
+func avgNumbers(data interface{}, field string) (string, error) {
+	total, count, integers, err := numbersTotal(data, field)
+	if err != nil {
+		return "", err
+	}
+
+	if count == 0 {
+		return "", errors.New("no numbers to average")
+	}
+
+	mean := total.Quo(total, new(big.Rat).SetInt64(int64(count)))
+	return formatNumber(mean, integers), nil
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSumAvgNumbers(t *testing.T) {
+	for _, tc := range []struct {
+		data  interface{}
+		field string
+		sum   string
+		avg   string
+	}{
+		{[]interface{}{1.0, 2.0, nil, 4.0}, "", "7", "2.3333333333333335"},
+		{[]interface{}{1, int64(2), uint(3)}, "", "6", "2"},
+		{[]interface{}{1.5, 2.0}, "", "3.5", "1.75"},
+		{[]interface{}{json.Number("9007199254740993"), json.Number("1")}, "", "9007199254740994", "4503599627370497"},
+		{[]interface{}{
+			map[string]interface{}{"price": 2.0},
+			map[string]interface{}{"price": nil},
+			map[string]interface{}{"name": "free"},
+			map[string]interface{}{"price": 4.0},
+		}, ".price", "6", "3"},
+	} {
+		sum, err := sumNumbers(tc.data, tc.field)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.sum, sum)
+
+		avg, err := avgNumbers(tc.data, tc.field)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.avg, avg)
+	}
+
+	sum, err := sumNumbers([]interface{}{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "0", sum)
+
+	_, err = avgNumbers([]interface{}{nil}, "")
+	assert.EqualError(t, err, "no numbers to average")
+
+	_, err = sumNumbers([]interface{}{1.0, "two"}, "")
+	assert.EqualError(t, err, `item 1: "two" is not a number`)
+
+	_, err = sumNumbers(map[string]interface{}{}, "")
+	assert.EqualError(t, err, "input must be an array")
+}
+
+func TestFormatSumAvg(t *testing.T) {
+	filename := path.Join(t.TempDir(), "items.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`[{"price": 10}, {"price": 5}, {"price": null}]`), 0600))
+
+	assert.Equal(t, "15\n", run("format sum --rsh-field .price "+filename))
+	assert.Equal(t, "7.5\n", run("format avg --rsh-field .price "+filename))
+
+	WithFakeStdin([]byte("- 1\n- 2\n"), 0, func() {
+		assert.Equal(t, "3\n", run("format sum"))
+	})
+
+	assert.Contains(t, run("format avg --rsh-field .missing "+filename), "no numbers to average")
+	assert.Equal(t, 1, GetExitCode())
+}
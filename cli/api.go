@@ -2,6 +2,8 @@ package cli
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -79,24 +81,65 @@ func load(root *cobra.Command, entrypoint, spec url.URL, resp *http.Response, na
 	return api, nil
 }
 
-func cacheAPI(name string, api *API) {
+// cacheFormatVersion must be incremented whenever the cached `API` structure
+// or how it is loaded changes, so that existing caches are reloaded.
+const cacheFormatVersion = 1
+
+// specFingerprint identifies a set of API description documents so that a
+// cached API can be reused when they have not changed. Strong ETags are used
+// when available, otherwise the body is hashed. The CLI version and cache
+// format are included so that upgrades which change loading discard the
+// cache.
+func specFingerprint(specs []apiSpec) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "restish:%s:%d\n", Root.Version, cacheFormatVersion)
+	for _, spec := range specs {
+		h.Write([]byte(spec.location.String() + "\n"))
+		if etag := spec.header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Write([]byte("etag:" + etag + "\n"))
+			continue
+		}
+		h.Write(spec.body)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// touchAPICache marks the cached API as valid for another 24 hours.
+func touchAPICache(name string, fingerprint string) {
+	Cache.Set(apiCacheKey(name, "expires"), time.Now().Add(24*time.Hour))
+	Cache.Set(apiCacheKey(name, "spec-hash"), fingerprint)
+	SaveCache()
+}
+
+func cacheAPI(name string, api *API, fingerprint string) {
 	if name == "" {
 		return
 	}
 
-	Cache.Set(name+".expires", time.Now().Add(24*time.Hour))
-	Cache.WriteConfig()
+	touchAPICache(name, fingerprint)
 
 	b, err := cbor.Marshal(api)
 	if err != nil {
 		LogError("Could not marshal API cache %s", err)
 	}
-	filename := path.Join(viper.GetString("config-directory"), name+".cbor")
-	if err := ioutil.WriteFile(filename, b, 0o600); err != nil {
+	filename := path.Join(cacheDir(), name+".cbor")
+	if err := writeFileAtomic(filename, b, 0o600); err != nil {
 		LogError("Could not write API cache %s", err)
 	}
 }
 
+// loadCachedAPI loads a previously parsed API from the cache, if present.
+func loadCachedAPI(name string) (API, bool) {
+	var cached API
+	filename := path.Join(cacheDir(), name+".cbor")
+	if data, err := ioutil.ReadFile(filename); err == nil {
+		if err := cbor.Unmarshal(data, &cached); err == nil {
+			return cached, true
+		}
+	}
+	return cached, false
+}
+
 // Load will hydrate the command tree for an API, possibly refreshing the
 // API spec if the cache is out of date.
 func Load(entrypoint string, root *cobra.Command) (API, error) {
@@ -104,7 +147,6 @@ func Load(entrypoint string, root *cobra.Command) (API, error) {
 	defer func() {
 		LogDebug("API loading took %s", time.Now().Sub(start))
 	}()
-	uris := []string{}
 
 	if !strings.HasSuffix(entrypoint, "/") {
 		entrypoint += "/"
@@ -117,73 +159,177 @@ func Load(entrypoint string, root *cobra.Command) (API, error) {
 
 	name, config := findAPI(entrypoint)
 	desc := API{}
-	found := false
 
 	// See if there is a cache we can quickly load.
-	expires := Cache.GetTime(name + ".expires")
+	expires := Cache.GetTime(apiCacheKey(name, "expires"))
 	if !viper.GetBool("rsh-no-cache") && !expires.IsZero() && expires.After(time.Now()) {
-		var cached API
-		filename := path.Join(viper.GetString("config-directory"), name+".cbor")
-		if data, err := ioutil.ReadFile(filename); err == nil {
-			if err := cbor.Unmarshal(data, &cached); err == nil {
-				setupRootFromAPI(root, &cached)
-				return cached, nil
-			}
+		if cached, ok := loadCachedAPI(name); ok {
+			setupRootFromAPI(root, &cached)
+			return cached, nil
 		}
 	}
 
-	fromFileOrUrl := func(uri string) ([]byte, error) {
-		uriLower := strings.ToLower(uri)
-		if strings.Index(uriLower, "http") == 0 {
-			resp, err := http.Get(uri)
-			if err != nil {
-				return []byte{}, err
-			}
-			return ioutil.ReadAll(resp.Body)
+	specs, err := findSpecs(name, config, uri, 0)
+	if err != nil {
+		return API{}, err
+	}
+
+	// The cache has expired, but if the API description hasn't changed then
+	// the cached API is still valid and we can skip parsing, which can be slow
+	// for large documents. Fetching the specs above uses conditional requests
+	// so this is usually cheap.
+	fingerprint := specFingerprint(specs)
+	if name != "" && !viper.GetBool("rsh-no-cache") && fingerprint == Cache.GetString(apiCacheKey(name, "spec-hash")) {
+		if cached, ok := loadCachedAPI(name); ok {
+			LogDebug("API description unchanged, using cached API")
+			touchAPICache(name, fingerprint)
+			setupRootFromAPI(root, &cached)
+			return cached, nil
+		}
+	}
+
+	for i, spec := range specs {
+		tmp, err := load(root, *uri, spec.location, spec.response(), name, spec.loader)
+		if err != nil {
+			return API{}, err
+		}
+
+		if i == 0 {
+			desc = tmp
 		} else {
-			return ioutil.ReadFile(os.ExpandEnv(uri))
+			desc.Merge(tmp)
+		}
+	}
+
+	cacheAPI(name, &desc, fingerprint)
+	return desc, nil
+}
+
+// apiSpec is a raw API description document along with the loader which
+// detected it and the location it was loaded from.
+type apiSpec struct {
+	loader   Loader
+	location url.URL
+	header   http.Header
+	body     []byte
+}
+
+// response returns a response for the spec with a fresh body reader, suitable
+// for passing to loaders or exporters.
+func (s apiSpec) response() *http.Response {
+	return &http.Response{
+		Proto:      "HTTP/1.1",
+		StatusCode: 200,
+		Header:     s.header,
+		Body:       ioutil.NopCloser(bytes.NewReader(s.body)),
+	}
+}
+
+// detectLoader returns the first registered loader able to handle the given
+// response and body, or nil if there is none.
+func detectLoader(header http.Header, body []byte) Loader {
+	for _, l := range loaders {
+		resp := apiSpec{header: header, body: body}.response()
+		if l.Detect(resp) {
+			return l
+		}
+	}
+
+	return nil
+}
+
+// ReadURL fetches a document related to an API description, like a spec file
+// or an external `$ref`. Hosts which are configured as APIs get the headers
+// and auth of their selected profile, so documents split across
+// authenticated servers can be loaded. Other hosts are fetched with a plain
+// client, so they are sent no credentials or other custom headers, like
+// those set via `-H` or the global config.
+func ReadURL(uri *url.URL) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, uri.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *http.Response
+	if _, config := findAPI(uri.String()); config != nil {
+		options := []requestOption{}
+		if p, _ := config.resolveProfile(selectedProfile(config)); p == nil {
+			// The selected profile is for the API being loaded, not this one.
+			options = append(options, WithProfile("default"))
+		}
+		resp, err = MakeRequest(req, options...)
+	} else {
+		LogDebug("No API configured for %s, fetching without auth", uri)
+		LogDebugRequest(req)
+		resp, err = http.DefaultClient.Do(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := DecodeResponse(resp); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("error loading %s: %s", uri, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// readSpecFile reads an API description from a URL or a local file path,
+// expanding any environment variables in the path.
+func readSpecFile(uri string) ([]byte, error) {
+	uriLower := strings.ToLower(uri)
+	if strings.Index(uriLower, "http") == 0 {
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			return []byte{}, err
 		}
+		return ReadURL(parsed)
 	}
+
+	return ioutil.ReadFile(os.ExpandEnv(uri))
+}
+
+// findSpecs locates the raw API description documents for an API, either
+// from the locally configured spec files or by checking the entrypoint for
+// link relations and well-known locations. A non-zero timeout limits each
+// request made to the server.
+func findSpecs(name string, config *APIConfig, uri *url.URL, timeout time.Duration) ([]apiSpec, error) {
+	specs := []apiSpec{}
+	uris := []string{}
+
 	if name != "" && len(config.SpecFiles) > 0 {
 		// Load the local files
 		for _, filename := range config.SpecFiles {
-			resp := &http.Response{
-				Proto:      "HTTP/1.1",
-				StatusCode: 200,
-			}
-
-			body, err := fromFileOrUrl(filename)
+			body, err := readSpecFile(filename)
 			if err != nil {
-				return API{}, err
+				return nil, err
 			}
 
-			for _, l := range loaders {
-				// Reset the body
-				resp.Body = ioutil.NopCloser(bytes.NewReader(body))
-
-				if l.Detect(resp) {
-					found = true
-					resp.Body = ioutil.NopCloser(bytes.NewReader(body))
-					tmp, err := load(root, *uri, *uri, resp, name, l)
-					if err != nil {
-						return API{}, err
-					}
-					desc.Merge(tmp)
-					break
-				}
+			if l := detectLoader(http.Header{}, body); l != nil {
+				specs = append(specs, apiSpec{
+					loader:   l,
+					location: *uri,
+					header:   http.Header{},
+					body:     body,
+				})
 			}
 		}
 
-		if found {
-			cacheAPI(name, &desc)
-			return desc, nil
+		if len(specs) > 0 {
+			return specs, nil
 		}
 	}
 
+	entrypoint := uri.String()
 	LogDebug("Checking API entrypoint %s", entrypoint)
 	req, err := http.NewRequest(http.MethodGet, entrypoint, nil)
 	if err != nil {
-		return API{}, err
+		return nil, err
 	}
 
 	// For fetching specs, we apply a 24-hour cache time if no cache headers
@@ -194,16 +340,17 @@ func Load(entrypoint string, root *cobra.Command) (API, error) {
 	if viper.GetBool("rsh-no-cache") || req.URL.Hostname() == "localhost" {
 		client = &http.Client{Transport: InvalidateCachedTransport()}
 	}
+	client.Timeout = timeout
 
 	httpResp, err := MakeRequest(req, WithClient(client))
 	if err != nil {
-		return API{}, err
+		return nil, err
 	}
 	defer httpResp.Body.Close()
 
 	resp, err := ParseResponse(httpResp)
 	if err != nil {
-		return API{}, err
+		return nil, err
 	}
 
 	// Start with known link relations for API descriptions.
@@ -220,49 +367,43 @@ func Load(entrypoint string, root *cobra.Command) (API, error) {
 		uris = append(uris, l.LocationHints()...)
 	}
 
-	uris = append(uris, uri.String())
+	uris = append(uris, entrypoint)
 
 	for _, checkURI := range uris {
 		parsed, err := url.Parse(checkURI)
 		if err != nil {
-			return API{}, err
+			return nil, err
 		}
 		resolved := uri.ResolveReference(parsed)
 		LogDebug("Checking %s", resolved)
 
 		req, err := http.NewRequest(http.MethodGet, resolved.String(), nil)
 		if err != nil {
-			return API{}, err
+			return nil, err
 		}
 
 		resp, err := MakeRequest(req, WithClient(client))
 		if err != nil {
-			return API{}, err
+			return nil, err
 		}
 		if err := DecodeResponse(resp); err != nil {
-			return API{}, err
+			return nil, err
 		}
 		defer resp.Body.Close()
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return API{}, err
+			return nil, err
 		}
 
-		for _, l := range loaders {
-			// Reset the body
-			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
-
-			if l.Detect(resp) {
-				resp.Body = ioutil.NopCloser(bytes.NewReader(body))
-
-				api, err := load(root, *uri, *resolved, resp, name, l)
-				if err == nil {
-					cacheAPI(name, &api)
-				}
-				return api, err
-			}
+		if l := detectLoader(resp.Header, body); l != nil {
+			return []apiSpec{{
+				loader:   l,
+				location: *resolved,
+				header:   resp.Header,
+				body:     body,
+			}}, nil
 		}
 	}
 
-	return API{}, fmt.Errorf("could not detect API type: %s", entrypoint)
+	return nil, fmt.Errorf("could not detect API type: %s", entrypoint)
 }
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestReadURLAuth(t *testing.T) {
+	defer gock.Off()
+	reset(false)
+
+	configs["internal-schemas"] = &APIConfig{
+		name: "internal-schemas",
+		Base: "https://schemas.internal.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {Headers: map[string]string{"X-Token": "abc123"}},
+		},
+	}
+
+	// Configured hosts get their profile's headers, even when another
+	// profile is selected for the API being loaded.
+	viper.Set("rsh-profile", "staging")
+	gock.New("https://schemas.internal.example.com").Get("/item.yaml").MatchHeader("X-Token", "abc123").Reply(http.StatusOK).BodyString("type: object")
+	u, _ := url.Parse("https://schemas.internal.example.com/item.yaml")
+	body, err := ReadURL(u)
+	assert.NoError(t, err)
+	assert.Equal(t, "type: object", string(body))
+
+	// Other hosts get no credentials or custom headers, and errors are
+	// reported.
+	viper.Set("rsh-profile", "default")
+	viper.Set("rsh-header", []string{"Authorization: Bearer secret"})
+	gock.New("https://other.example.com").Get("/item.yaml").
+		AddMatcher(func(req *http.Request, ereq *gock.Request) (bool, error) {
+			return req.Header.Get("Authorization") == "", nil
+		}).
+		Reply(http.StatusForbidden)
+	u, _ = url.Parse("https://other.example.com/item.yaml")
+	_, err = ReadURL(u)
+	assert.EqualError(t, err, "error loading https://other.example.com/item.yaml: 403 Forbidden")
+	assert.True(t, gock.IsDone())
+	viper.Set("rsh-header", []string{})
+}
+
+func TestSpecFingerprintVersion(t *testing.T) {
+	reset(false)
+
+	u, _ := url.Parse("https://api.example.com/openapi.json")
+	specs := []apiSpec{{location: *u, header: http.Header{}, body: []byte(`{}`)}}
+
+	before := specFingerprint(specs)
+	assert.Equal(t, before, specFingerprint(specs))
+
+	// Upgrading the CLI invalidates cached APIs even if the spec is the same.
+	version := Root.Version
+	defer func() { Root.Version = version }()
+	Root.Version = version + "-next"
+	assert.NotEqual(t, before, specFingerprint(specs))
+}
@@ -7,8 +7,11 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/alexeyco/simpletable"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v2"
@@ -31,27 +34,340 @@ type TLSConfig struct {
 	CACert             string `json:"ca_cert" mapstructure:"ca_cert"`
 }
 
-// APIProfile contains account-specific API information
+// APIProfile contains account-specific API information. A profile may extend
+// another profile to inherit its base, headers, query params, and auth.
 type APIProfile struct {
+	Extends string            `json:"extends,omitempty"`
+	Base    string            `json:"base,omitempty"`
 	Headers map[string]string `json:"headers,omitempty"`
 	Query   map[string]string `json:"query,omitempty"`
 	Auth    *APIAuth          `json:"auth"`
 }
 
+// OutputConfig sets the default presentation of responses from an API, used
+// when the corresponding option isn't passed as a flag or environment
+// variable.
+type OutputConfig struct {
+	Format string `json:"format,omitempty"`
+	Filter string `json:"filter,omitempty"`
+	Raw    bool   `json:"raw,omitempty"`
+}
+
 // APIConfig describes per-API configuration options like the base URI and
 // auth scheme, if any.
 type APIConfig struct {
-	name      string
-	Base      string                 `json:"base"`
-	SpecFiles []string               `json:"spec_files,omitempty" mapstructure:"spec_files,omitempty"`
-	Profiles  map[string]*APIProfile `json:"profiles,omitempty" mapstructure:",omitempty"`
-	TLS       *TLSConfig             `json:"tls,omitempty" mapstructure:",omitempty"`
+	name           string
+	Base           string                 `json:"base"`
+	SpecFiles      []string               `json:"spec_files,omitempty" mapstructure:"spec_files,omitempty"`
+	DefaultProfile string                 `json:"default_profile,omitempty" mapstructure:"default_profile,omitempty"`
+	Profiles       map[string]*APIProfile `json:"profiles,omitempty" mapstructure:",omitempty"`
+	TLS            *TLSConfig             `json:"tls,omitempty" mapstructure:",omitempty"`
+	Protobuf       *ProtobufConfig        `json:"protobuf,omitempty" mapstructure:",omitempty"`
+	Output         *OutputConfig          `json:"output,omitempty" mapstructure:",omitempty"`
+	AcceptTypes    []string               `json:"accept_types,omitempty" mapstructure:"accept_types,omitempty"`
+	UserAgent      string                 `json:"user_agent,omitempty" mapstructure:"user_agent,omitempty"`
+}
+
+// profileExplicit returns whether a profile was chosen via a flag, the
+// `RSH_PROFILE` environment variable, or the global config rather than being
+// the built-in default.
+func profileExplicit() bool {
+	if f := Root.PersistentFlags().Lookup("rsh-profile"); f != nil && f.Changed {
+		return true
+	}
+
+	if f := GlobalFlags.Lookup("rsh-profile"); f != nil && f.Changed {
+		return true
+	}
+
+	if _, ok := os.LookupEnv("RSH_PROFILE"); ok {
+		return true
+	}
+
+	return viper.InConfig("rsh-profile") || viper.GetString("rsh-profile") != "default"
+}
+
+// selectedProfile returns the name of the profile to use for an API, which
+// may be `nil` for unconfigured hosts. The precedence is the `-p` flag, the
+// `RSH_PROFILE` environment variable, the API's default profile, and finally
+// `default`.
+func selectedProfile(config *APIConfig) string {
+	if config != nil && config.DefaultProfile != "" && !profileExplicit() {
+		return config.DefaultProfile
+	}
+
+	return viper.GetString("rsh-profile")
+}
+
+// profileNames returns the sorted names of an API's profiles.
+func (a APIConfig) profileNames() []string {
+	names := []string{}
+	for name := range a.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// unknownProfileError describes a profile which isn't configured for an API
+// along with the profiles which are.
+func unknownProfileError(apiName string, config *APIConfig, profile string) error {
+	available := "none"
+	if config != nil && len(config.Profiles) > 0 {
+		available = strings.Join(config.profileNames(), ", ")
+	}
+
+	if apiName == "" {
+		return fmt.Errorf("Invalid profile %s, no API is configured for this URL (available profiles: %s)", profile, available)
+	}
+
+	return fmt.Errorf("Invalid profile %s for API %s (available profiles: %s)", profile, apiName, available)
+}
+
+// mergeValues returns a copy of the parent values with the child values
+// applied on top. Names are compared case-insensitively when `fold` is set,
+// e.g. for headers.
+func mergeValues(parent, child map[string]string, fold bool) map[string]string {
+	if len(parent) == 0 && len(child) == 0 {
+		return nil
+	}
+
+	merged := map[string]string{}
+	for k, v := range parent {
+		merged[k] = v
+	}
+
+	for k, v := range child {
+		if fold {
+			for existing := range merged {
+				if strings.EqualFold(existing, k) {
+					delete(merged, existing)
+				}
+			}
+		}
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// configDefaults merges headers or query params from the global config and a
+// profile, with profile values winning, and returns them along with where
+// each came from. An empty value removes an inherited one.
+func configDefaults(global, profile map[string]string, profileName string, fold bool) (map[string]string, map[string]string) {
+	values := mergeValues(global, profile, fold)
+	sources := map[string]string{}
+	for k, v := range values {
+		if v == "" {
+			delete(values, k)
+			continue
+		}
+
+		sources[k] = "global config"
+		if _, ok := profile[k]; ok {
+			sources[k] = "profile " + profileName
+		}
+	}
+
+	return values, sources
+}
+
+// mergeProfile returns a new profile with the child's values overriding the
+// parent's. Auth params are merged when both use the same auth type or the
+// child doesn't set one, otherwise the child's auth replaces the parent's.
+func mergeProfile(parent, child *APIProfile) *APIProfile {
+	merged := &APIProfile{
+		Extends: child.Extends,
+		Base:    child.Base,
+		Headers: mergeValues(parent.Headers, child.Headers, true),
+		Query:   mergeValues(parent.Query, child.Query, false),
+		Auth:    child.Auth,
+	}
+
+	if merged.Base == "" {
+		merged.Base = parent.Base
+	}
+
+	if parent.Auth != nil {
+		if child.Auth == nil {
+			merged.Auth = &APIAuth{
+				Name:   parent.Auth.Name,
+				Params: mergeValues(parent.Auth.Params, nil, false),
+			}
+		} else if child.Auth.Name == "" || child.Auth.Name == parent.Auth.Name {
+			merged.Auth = &APIAuth{
+				Name:   parent.Auth.Name,
+				Params: mergeValues(parent.Auth.Params, child.Auth.Params, false),
+			}
+		}
+	}
+
+	return merged
+}
+
+// resolveProfile returns a profile with the values of any profiles it
+// extends merged in. Returns `nil` if the profile doesn't exist, and an error
+// if a parent profile is missing or the inheritance chain has a cycle.
+func (a APIConfig) resolveProfile(name string) (*APIProfile, error) {
+	chain := []string{}
+	profiles := []*APIProfile{}
+
+	for current := name; ; {
+		for _, seen := range chain {
+			if seen == current {
+				return nil, fmt.Errorf("profile inheritance cycle: %s", strings.Join(append(chain, current), " -> "))
+			}
+		}
+
+		profile := a.Profiles[current]
+		if profile == nil {
+			if len(chain) == 0 {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("profile %s extends unknown profile %s", chain[len(chain)-1], current)
+		}
+
+		chain = append(chain, current)
+		profiles = append(profiles, profile)
+
+		if profile.Extends == "" {
+			break
+		}
+		current = profile.Extends
+	}
+
+	resolved := &APIProfile{}
+	for i := len(profiles) - 1; i >= 0; i-- {
+		resolved = mergeProfile(resolved, profiles[i])
+	}
+
+	return resolved, nil
 }
 
-// Save the API configuration to disk.
+// profileBase returns the base URI to use for a profile, which may override
+// the API's base.
+func (a APIConfig) profileBase(profile string) string {
+	if p, _ := a.resolveProfile(profile); p != nil && p.Base != "" {
+		return p.Base
+	}
+
+	return a.Base
+}
+
+// profileURI rewrites a URI under an API's base, or the base of any of its
+// profiles, to use the base of the given profile instead.
+func profileURI(uri, profile string) string {
+	_, config := findAPI(uri)
+	if config == nil {
+		return uri
+	}
+
+	bases := []string{config.Base}
+	for _, p := range config.Profiles {
+		if p != nil && p.Base != "" {
+			bases = append(bases, p.Base)
+		}
+	}
+
+	target := strings.TrimSuffix(config.profileBase(profile), "/")
+	for _, base := range bases {
+		base = strings.TrimSuffix(base, "/")
+		if strings.HasPrefix(uri, base) {
+			return target + strings.TrimPrefix(uri, base)
+		}
+	}
+
+	return uri
+}
+
+// Save the API configuration to disk. The file is re-read while holding a
+// lock first so that APIs saved by other processes in the meantime are kept.
 func (a APIConfig) Save() error {
-	apis.Set(a.name, a)
-	return apis.WriteConfig()
+	return withFileLock(apis.ConfigFileUsed(), func() error {
+		if err := apis.ReadInConfig(); err != nil {
+			return err
+		}
+
+		apis.Set(a.name, a)
+		return writeConfigAtomic(apis)
+	})
+}
+
+// removeAPI deletes an API configuration from disk along with any cached
+// data for it, like its API description and auth tokens.
+func removeAPI(name string) error {
+	if configs[name] == nil {
+		return fmt.Errorf("API %s not found", name)
+	}
+
+	err := withFileLock(apis.ConfigFileUsed(), func() error {
+		if err := apis.ReadInConfig(); err != nil {
+			return err
+		}
+
+		// Viper can't unset a key, so write the remaining settings to a new
+		// config.
+		updated := viper.New()
+		updated.SetConfigFile(apis.ConfigFileUsed())
+		for k, v := range apis.AllSettings() {
+			if k != strings.ToLower(name) {
+				updated.Set(k, v)
+			}
+		}
+
+		if err := writeConfigAtomic(updated); err != nil {
+			return err
+		}
+
+		apis = updated
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	delete(configs, name)
+
+	return clearAPICache(name)
+}
+
+// setDefaultProfile sets the profile an API uses when none is selected via a
+// flag or environment variable. An empty name resets it to `default`.
+func setDefaultProfile(apiName, profile string) error {
+	config := configs[apiName]
+	if config == nil {
+		return fmt.Errorf("API %s not found", apiName)
+	}
+
+	if profile == "default" {
+		profile = ""
+	}
+
+	if profile != "" && config.Profiles[profile] == nil {
+		return unknownProfileError(apiName, config, profile)
+	}
+
+	config.DefaultProfile = profile
+	return config.Save()
+}
+
+// askRemoveAPI removes an API after confirming with the user, unless `yes` is
+// set for non-interactive use.
+func askRemoveAPI(a asker, name string, yes bool) {
+	if configs[name] == nil {
+		panic(fmt.Errorf("API %s not found", name))
+	}
+
+	if !yes && !a.askConfirm(fmt.Sprintf("Remove API %s and its cached data?", name), false, "") {
+		return
+	}
+
+	if err := removeAPI(name); err != nil {
+		panic(err)
+	}
+
+	LogInfo("Removed API %s", name)
 }
 
 // Return colorized string of configuration in JSON or YAML
@@ -72,6 +388,10 @@ func (a APIConfig) GetPrettyDisplay(outFormat string) (string, error) {
 		return "", errors.New("unable to render configuration")
 	}
 
+	if !tty {
+		return string(marshalled), nil
+	}
+
 	// colorize
 	prettyConfig, err = Highlight(outFormat, marshalled)
 	if err != nil {
@@ -81,6 +401,208 @@ func (a APIConfig) GetPrettyDisplay(outFormat string) (string, error) {
 	return string(prettyConfig), nil
 }
 
+// secretNameParts match header, query, and auth param names which usually
+// hold secrets, like `Authorization`, `X-API-Key`, or `client_secret`.
+var secretNameParts = []string{"authorization", "cookie", "password", "secret", "token", "key", "credential"}
+
+// isSecretName returns whether a param or header name is likely to hold a
+// secret. Paths to files like `key_file` are not secret themselves.
+func isSecretName(name string) bool {
+	lower := strings.ToLower(name)
+	if strings.HasSuffix(lower, "file") || strings.HasSuffix(lower, "path") {
+		return false
+	}
+
+	for _, part := range secretNameParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replaceSecretValues returns a copy of the values with each non-empty secret
+// replaced by the result of calling `replace` with its name.
+func replaceSecretValues(values map[string]string, replace func(name string) string) map[string]string {
+	if values == nil {
+		return nil
+	}
+
+	replaced := map[string]string{}
+	for k, v := range values {
+		if isSecretName(k) && v != "" {
+			v = replace(k)
+		}
+		replaced[k] = v
+	}
+
+	return replaced
+}
+
+// redactValues returns a copy of the values with secrets redacted.
+func redactValues(values map[string]string) map[string]string {
+	return replaceSecretValues(values, func(name string) string {
+		return "REDACTED"
+	})
+}
+
+// replaceSecrets returns a copy of the config with secrets like passwords,
+// tokens, and API keys in profile headers, query params, and auth params
+// replaced by the result of calling `replace` with the profile and name.
+func (a APIConfig) replaceSecrets(replace func(profile, name string) string) APIConfig {
+	profiles := map[string]*APIProfile{}
+	for name, profile := range a.Profiles {
+		if profile == nil {
+			profiles[name] = nil
+			continue
+		}
+
+		profileName := name
+		replaceValue := func(key string) string {
+			return replace(profileName, key)
+		}
+
+		copied := &APIProfile{
+			Extends: profile.Extends,
+			Base:    profile.Base,
+			Headers: replaceSecretValues(profile.Headers, replaceValue),
+			Query:   replaceSecretValues(profile.Query, replaceValue),
+		}
+
+		if profile.Auth != nil {
+			copied.Auth = &APIAuth{
+				Name:   profile.Auth.Name,
+				Params: replaceSecretValues(profile.Auth.Params, replaceValue),
+			}
+		}
+
+		profiles[name] = copied
+	}
+	a.Profiles = profiles
+
+	return a
+}
+
+// redacted returns a copy of the config with secrets redacted so that it is
+// safe to display.
+func (a APIConfig) redacted() APIConfig {
+	return a.replaceSecrets(func(profile, name string) string {
+		return "REDACTED"
+	})
+}
+
+// apiSummary describes a configured API for listing.
+type apiSummary struct {
+	Name     string   `json:"name"`
+	Base     string   `json:"base"`
+	Profiles []string `json:"profiles"`
+	Auth     []string `json:"auth"`
+	Cache    string   `json:"cache"`
+}
+
+// summarizeAPIs returns a summary of each configured API sorted by name. The
+// cache is `fresh` if the cached API description has not expired, `stale` if
+// it has, and `none` if nothing is cached.
+func summarizeAPIs() []apiSummary {
+	names := []string{}
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	summaries := []apiSummary{}
+	for _, name := range names {
+		config := configs[name]
+
+		profiles := []string{}
+		authSeen := map[string]bool{}
+		auth := []string{}
+		for profileName, profile := range config.Profiles {
+			profiles = append(profiles, profileName)
+			if profile != nil && profile.Auth != nil && profile.Auth.Name != "" && !authSeen[profile.Auth.Name] {
+				authSeen[profile.Auth.Name] = true
+				auth = append(auth, profile.Auth.Name)
+			}
+		}
+		sort.Strings(profiles)
+		sort.Strings(auth)
+
+		cache := "none"
+		if expires := Cache.GetTime(apiCacheKey(name, "expires")); !expires.IsZero() {
+			cache = "stale"
+			if expires.After(time.Now()) {
+				cache = "fresh"
+			}
+		}
+
+		summaries = append(summaries, apiSummary{
+			Name:     name,
+			Base:     config.Base,
+			Profiles: profiles,
+			Auth:     auth,
+			Cache:    cache,
+		})
+	}
+
+	return summaries
+}
+
+func apisCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "apis",
+		Short: "List configured APIs",
+		Long:  "List each configured API with its base URI, profiles, auth types, and whether its cached API description is fresh, stale, or missing. Use `-o json` or `-o yaml` to get the list in another format, or filter it like a response, e.g. `-f 'body[].name'`.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			summaries := summarizeAPIs()
+
+			if viper.GetString("rsh-output-format") != "auto" || viper.GetString("rsh-filter") != "" || viper.GetString("rsh-jsonpath") != "" || viper.GetString("rsh-jq") != "" {
+				// Output formats and filters work like with a response.
+				b, err := json.Marshal(summaries)
+				if err != nil {
+					panic(err)
+				}
+				var body interface{}
+				if err := json.Unmarshal(b, &body); err != nil {
+					panic(err)
+				}
+				formatConverted(body)
+				return
+			}
+
+			if len(summaries) == 0 {
+				LogInfo("No APIs configured, add one with: %s api configure short-name", Root.CommandPath())
+				return
+			}
+
+			table := simpletable.New()
+			table.Header = &simpletable.Header{
+				Cells: []*simpletable.Cell{
+					{Align: simpletable.AlignCenter, Text: "Name"},
+					{Align: simpletable.AlignCenter, Text: "Base"},
+					{Align: simpletable.AlignCenter, Text: "Profiles"},
+					{Align: simpletable.AlignCenter, Text: "Auth"},
+					{Align: simpletable.AlignCenter, Text: "Cache"},
+				},
+			}
+
+			for _, summary := range summaries {
+				table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+					{Text: summary.Name},
+					{Text: summary.Base},
+					{Text: strings.Join(summary.Profiles, ", ")},
+					{Text: strings.Join(summary.Auth, ", ")},
+					{Text: summary.Cache},
+				})
+			}
+
+			table.SetStyle(simpletable.StyleCompactLite)
+			fmt.Fprintln(Stdout, table.String())
+		},
+	}
+}
+
 type apiConfigs map[string]*APIConfig
 
 var configs apiConfigs
@@ -113,21 +635,34 @@ func initAPIConfig() {
 		Short: "API management commands",
 	}
 	Root.AddCommand(apiCommand)
+	Root.AddCommand(apisCommand())
 
-	apiCommand.AddCommand(&cobra.Command{
+	var defaultProfile *string
+	configureCommand := &cobra.Command{
 		Use:     "configure short-name",
 		Aliases: []string{"config"},
 		Short:   "Initialize an API",
-		Long:    "Initializes an API with a short interactive prompt session to set up the base URI and auth if needed.",
+		Long:    "Initializes an API with a short interactive prompt session to set up the base URI and auth if needed. Pass `--rsh-default-profile` to set the default profile of an existing API without any prompts.",
 		Args:    cobra.MinimumNArgs(1),
-		Run:     askInitAPIDefault,
-	})
+		Run: func(cmd *cobra.Command, args []string) {
+			if cmd.Flags().Changed("rsh-default-profile") {
+				if err := setDefaultProfile(args[0], *defaultProfile); err != nil {
+					panic(err)
+				}
+				return
+			}
+
+			askInitAPIDefault(cmd, args)
+		},
+	}
+	defaultProfile = configureCommand.Flags().String("rsh-default-profile", "", "Set the profile used when none is selected, without prompting")
+	apiCommand.AddCommand(configureCommand)
 
 	apiCommand.AddCommand(&cobra.Command{
 		Use:     "show short-name",
 		Aliases: []string{"show"},
 		Short:   "Show an API",
-		Long:    "Show an API configuration.",
+		Long:    "Show an API configuration. Secrets like passwords, tokens, and API keys are redacted.",
 		Args:    cobra.MinimumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			config := configs[args[0]]
@@ -136,14 +671,40 @@ func initAPIConfig() {
 			}
 
 			outFormat := viper.Get("rsh-output-format").(string)
-			if prettyString, err := config.GetPrettyDisplay(outFormat); err == nil {
-				fmt.Println(prettyString)
+			if prettyString, err := config.redacted().GetPrettyDisplay(outFormat); err == nil {
+				fmt.Fprintln(Stdout, prettyString)
 			} else {
 				panic(err)
 			}
 		},
 	})
 
+	var yes *bool
+	removeCommand := &cobra.Command{
+		Use:     "remove short-name",
+		Aliases: []string{"rm"},
+		Short:   "Remove an API",
+		Long:    "Remove an API configuration along with its cached API description and auth tokens.",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			askRemoveAPI(defaultAsker{}, args[0], *yes)
+		},
+	}
+	yes = removeCommand.Flags().BoolP("rsh-yes", "y", false, "Disable prompt (answer yes automatically)")
+	apiCommand.AddCommand(removeCommand)
+
+	apiCommand.AddCommand(&cobra.Command{
+		Use:   "edit short-name",
+		Short: "Edit an API in your editor",
+		Long:  "Open an API configuration in your editor as YAML. Problems like unknown auth handlers, invalid headers, or duplicate profiles are shown as comments until fixed, and the configuration is only saved once valid.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := editAPIConfig(args[0]); err != nil {
+				panic(err)
+			}
+		},
+	})
+
 	apiCommand.AddCommand(&cobra.Command{
 		Use:   "sync short-name",
 		Short: "Sync an API",
@@ -158,6 +719,8 @@ func initAPIConfig() {
 		},
 	})
 
+	initAPIBundle()
+
 	// Register API sub-commands
 	configs = apiConfigs{}
 	if err := apis.Unmarshal(&configs); err != nil {
@@ -172,6 +735,12 @@ func initAPIConfig() {
 			}
 			seen[config.Base] = true
 			config.name = apiName
+
+			for profileName := range config.Profiles {
+				if _, err := config.resolveProfile(profileName); err != nil {
+					panic(fmt.Errorf("API %s: %w", apiName, err))
+				}
+			}
 			configs[apiName] = config
 
 			n := apiName
@@ -185,6 +754,10 @@ func initAPIConfig() {
 			Root.AddCommand(cmd)
 		}(config)
 	}
+	LogDebug("Loaded %d APIs from %s", len(configs), apis.ConfigFileUsed())
+
+	// Clean up anything left behind by APIs which have since been removed.
+	autoPruneCache()
 }
 
 func findAPI(uri string) (string, *APIConfig) {
@@ -195,5 +768,14 @@ func findAPI(uri string) (string, *APIConfig) {
 		}
 	}
 
+	// Requests may also go to a profile's base override.
+	for name, config := range configs {
+		for _, profile := range config.Profiles {
+			if profile != nil && profile.Base != "" && strings.HasPrefix(uri, profile.Base) {
+				return name, config
+			}
+		}
+	}
+
 	return "", nil
 }
@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func setupListAPIs() {
+	reset(false)
+
+	configs["list-a"] = &APIConfig{
+		name: "list-a",
+		Base: "https://a.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {
+				Headers: map[string]string{"X-API-Key": "abc123", "Accept-Language": "en"},
+				Query:   map[string]string{"access_token": "def456"},
+				Auth: &APIAuth{
+					Name: "oauth-client-credentials",
+					Params: map[string]string{
+						"client_id":     "my-client",
+						"client_secret": "shh",
+					},
+				},
+			},
+			"staging": {},
+		},
+	}
+	configs["list-b"] = &APIConfig{
+		name: "list-b",
+		Base: "https://b.example.com",
+	}
+
+	Cache.Set(apiCacheKey("list-a", "expires"), time.Now().Add(time.Hour))
+	Cache.Set(apiCacheKey("list-b", "expires"), time.Time{})
+}
+
+func TestAPIsCommand(t *testing.T) {
+	setupListAPIs()
+
+	summaries := []apiSummary{}
+	for _, s := range summarizeAPIs() {
+		if s.Name == "list-a" || s.Name == "list-b" {
+			summaries = append(summaries, s)
+		}
+	}
+
+	assert.Equal(t, []apiSummary{
+		{Name: "list-a", Base: "https://a.example.com", Profiles: []string{"default", "staging"}, Auth: []string{"oauth-client-credentials"}, Cache: "fresh"},
+		{Name: "list-b", Base: "https://b.example.com", Profiles: []string{}, Auth: []string{}, Cache: "none"},
+	}, summaries)
+
+	out := runNoReset("apis")
+	assert.Contains(t, out, "https://a.example.com")
+	assert.Contains(t, out, "default, staging")
+
+	setupListAPIs()
+	decoded := []apiSummary{}
+	assert.NoError(t, json.Unmarshal([]byte(runNoReset("apis -o json")), &decoded))
+	assert.Contains(t, decoded, summaries[0])
+
+	// Other formats and filters work like with a response.
+	setupListAPIs()
+	assert.Contains(t, runNoReset("apis -o yaml"), "- auth:\n")
+	setupListAPIs()
+	assert.Equal(t, "\""+summarizeAPIs()[0].Name+"\"\n", runNoReset("apis -f body[0].name"))
+}
+
+func TestAPIShowRedacted(t *testing.T) {
+	setupListAPIs()
+
+	out := runNoReset("api show list-a -o json")
+
+	shown := APIConfig{}
+	assert.NoError(t, json.Unmarshal([]byte(out), &shown))
+
+	profile := shown.Profiles["default"]
+	assert.Equal(t, "REDACTED", profile.Headers["X-API-Key"])
+	assert.Equal(t, "en", profile.Headers["Accept-Language"])
+	assert.Equal(t, "REDACTED", profile.Query["access_token"])
+	assert.Equal(t, "my-client", profile.Auth.Params["client_id"])
+	assert.Equal(t, "REDACTED", profile.Auth.Params["client_secret"])
+
+	// The config itself is not modified.
+	assert.Equal(t, "shh", configs["list-a"].Profiles["default"].Auth.Params["client_secret"])
+}
+
+func TestRemoveAPI(t *testing.T) {
+	reset(false)
+
+	config := &APIConfig{name: "remove-test", Base: "https://remove.example.com"}
+	configs["remove-test"] = config
+	assert.NoError(t, config.Save())
+
+	Cache.Set(authCacheKey("remove-test", "default")+".token", "abc")
+	assert.NoError(t, Cache.WriteConfig())
+
+	// Declining the prompt keeps everything in place.
+	askRemoveAPI(&mockAsker{t: t, responses: []string{"n"}}, "remove-test", false)
+	assert.NotNil(t, configs["remove-test"])
+	assert.NotNil(t, apis.Get("remove-test"))
+
+	askRemoveAPI(&mockAsker{t: t}, "remove-test", true)
+	assert.Nil(t, configs["remove-test"])
+	assert.Nil(t, apis.Get("remove-test"))
+	assert.Empty(t, Cache.GetString(authCacheKey("remove-test", "default")+".token"))
+
+	b, err := ioutil.ReadFile(apis.ConfigFileUsed())
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "remove-test")
+
+	assert.Contains(t, run("api remove remove-test -y"), "API remove-test not found")
+}
+
+func TestProfileInheritance(t *testing.T) {
+	defer gock.Off()
+	reset(false)
+
+	config := &APIConfig{
+		name: "inherit",
+		Base: "https://inherit.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {
+				Headers: map[string]string{"X-Tenant": "acme", "Authorization": "Bearer abc"},
+				Query:   map[string]string{"region": "us"},
+				Auth: &APIAuth{
+					Name:   "http-basic",
+					Params: map[string]string{"username": "admin", "password": "secret"},
+				},
+			},
+			"eu": {
+				Extends: "default",
+				Headers: map[string]string{"authorization": "Bearer def"},
+				Query:   map[string]string{"region": "eu"},
+				Auth:    &APIAuth{Params: map[string]string{"username": "eu-admin"}},
+			},
+			"eu-debug": {
+				Extends: "eu",
+				Headers: map[string]string{"X-Debug": "1"},
+			},
+		},
+	}
+	configs["inherit"] = config
+
+	resolved, err := config.resolveProfile("eu-debug")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"X-Tenant": "acme", "authorization": "Bearer def", "X-Debug": "1"}, resolved.Headers)
+	assert.Equal(t, map[string]string{"region": "eu"}, resolved.Query)
+	assert.Equal(t, "http-basic", resolved.Auth.Name)
+	assert.Equal(t, map[string]string{"username": "eu-admin", "password": "secret"}, resolved.Auth.Params)
+
+	// The stored profiles keep only their explicit values.
+	assert.Nil(t, config.Profiles["eu-debug"].Query)
+	assert.Equal(t, "", config.Profiles["eu"].Auth.Name)
+
+	// Requests use the merged profile.
+	gock.New("https://inherit.example.com").Get("/items").
+		MatchParam("region", "eu").
+		MatchHeader("X-Tenant", "acme").
+		MatchHeader("X-Debug", "1").
+		Reply(http.StatusOK)
+	runNoReset("https://inherit.example.com/items -p eu-debug")
+	assert.True(t, gock.IsDone())
+
+	// Missing parents and cycles are errors.
+	config.Profiles["broken"] = &APIProfile{Extends: "missing"}
+	_, err = config.resolveProfile("broken")
+	assert.EqualError(t, err, "profile broken extends unknown profile missing")
+
+	config.Profiles["a"] = &APIProfile{Extends: "b"}
+	config.Profiles["b"] = &APIProfile{Extends: "a"}
+	_, err = config.resolveProfile("a")
+	assert.EqualError(t, err, "profile inheritance cycle: a -> b -> a")
+	assert.Contains(t, validateAPIConfig("inherit", config).Error(), "profile inheritance cycle: a -> b -> a")
+}
+
+func TestDefaultProfile(t *testing.T) {
+	defer gock.Off()
+
+	setup := func() {
+		reset(false)
+		configs["default-profile"] = &APIConfig{
+			name:           "default-profile",
+			Base:           "https://default-profile.example.com",
+			DefaultProfile: "staging",
+			Profiles: map[string]*APIProfile{
+				"default": {Headers: map[string]string{"X-Env": "prod"}},
+				"staging": {Headers: map[string]string{"X-Env": "staging"}},
+			},
+		}
+	}
+
+	// The API's default profile is used when none is given.
+	setup()
+	gock.New("https://default-profile.example.com").Get("/").MatchHeader("X-Env", "staging").Reply(http.StatusOK)
+	runNoReset("https://default-profile.example.com/")
+	assert.True(t, gock.IsDone())
+
+	// An explicit flag wins, even when selecting `default`.
+	setup()
+	gock.New("https://default-profile.example.com").Get("/").MatchHeader("X-Env", "prod").Reply(http.StatusOK)
+	runNoReset("https://default-profile.example.com/ -p default")
+	assert.True(t, gock.IsDone())
+
+	// So does the environment.
+	setup()
+	t.Setenv("RSH_PROFILE", "default")
+	assert.Equal(t, "default", selectedProfile(configs["default-profile"]))
+	os.Unsetenv("RSH_PROFILE")
+	assert.Equal(t, "staging", selectedProfile(configs["default-profile"]))
+
+	// Unknown profiles list the available ones.
+	req, _ := http.NewRequest(http.MethodGet, "https://default-profile.example.com/", nil)
+	viper.Set("rsh-profile", "nope")
+	_, err := MakeRequest(req)
+	assert.EqualError(t, err, "Invalid profile nope for API default-profile (available profiles: default, staging)")
+	assert.Equal(t, ExitCodeUsage, exitCodeFor(err))
+
+	// The default can be set without prompting, but must exist.
+	setup()
+	configs["default-profile"].DefaultProfile = ""
+	assert.NoError(t, setDefaultProfile("default-profile", "staging"))
+	assert.Equal(t, "staging", configs["default-profile"].DefaultProfile)
+	assert.Error(t, setDefaultProfile("default-profile", "nope"))
+	assert.NoError(t, setDefaultProfile("default-profile", "default"))
+	assert.Equal(t, "", configs["default-profile"].DefaultProfile)
+}
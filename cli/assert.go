@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"fmt"
+
+	jmespath "github.com/danielgtaylor/go-jmespath-plus"
+	"github.com/spf13/viper"
+)
+
+// truthy returns whether a JMESPath result is true, where false, null, and
+// empty strings, lists, and objects are false.
+func truthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != ""
+	case []interface{}:
+		return len(t) > 0
+	case map[string]interface{}:
+		return len(t) > 0
+	}
+	return true
+}
+
+// checkAssert evaluates the `rsh-assert` expression against the response and
+// displays an error and sets a non-zero exit code if it is not true.
+func checkAssert(resp Response) error {
+	expr := viper.GetString("rsh-assert")
+	if expr == "" {
+		return nil
+	}
+
+	result, err := jmespath.Search(expr, makeJSONSafe(resp.Map(), true))
+	if err != nil {
+		return fmt.Errorf("assert expression %q: %w", expr, err)
+	}
+
+	if !truthy(result) {
+		LogError("Assertion failed: %s", expr)
+		exitCode = 1
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/spf13/viper"
+)
+
+// writeFileAtomic writes data to a temporary file next to `filename` and then
+// renames it into place, so an interrupted write can never leave a truncated
+// file behind. An existing file keeps its permissions, otherwise `perm` is
+// used.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	return replaceAtomic(filename, perm, func(tmp string) error {
+		return ioutil.WriteFile(tmp, data, perm)
+	})
+}
+
+// writeConfigAtomic saves a viper config to the file it was loaded from like
+// `WriteConfig`, but atomically.
+func writeConfigAtomic(v *viper.Viper) error {
+	filename := v.ConfigFileUsed()
+	if filename == "" {
+		// Let viper report the missing config file.
+		return v.WriteConfig()
+	}
+
+	return replaceAtomic(filename, 0600, v.WriteConfigAs)
+}
+
+// replaceAtomic calls `write` with the name of a temporary file in the same
+// directory as `filename`, then syncs and renames it over `filename`. The
+// temporary file keeps the extension so the format can be detected from it.
+func replaceAtomic(filename string, perm os.FileMode, write func(tmp string) error) error {
+	if info, err := os.Stat(filename); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+"*"+filepath.Ext(filename))
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp.Name()); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(tmp.Name(), os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// SaveCache atomically writes the cache values changed since it was loaded to
+// disk. Another process may have saved its own changes in the meantime, so the
+// file is re-read while holding a lock and only changed values are written
+// over it.
+func SaveCache() error {
+	filename := cacheFile()
+	return withFileLock(filename, func() error {
+		disk := map[string]interface{}{}
+		if b, err := ioutil.ReadFile(filename); err == nil {
+			if err := json.Unmarshal(b, &disk); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		merged := flatten(disk, ".").(map[string]interface{})
+		current := flatten(Cache.AllSettings(), ".").(map[string]interface{})
+		for k, v := range current {
+			if old, ok := cacheSnapshot[k]; !ok || !reflect.DeepEqual(old, v) {
+				merged[k] = v
+			}
+		}
+		for k := range cacheSnapshot {
+			if _, ok := current[k]; !ok {
+				delete(merged, k)
+			}
+		}
+
+		data, err := unflatten(merged, ".")
+		if err != nil {
+			return err
+		}
+
+		b, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		if err := writeFileAtomic(filename, b, 0600); err != nil {
+			return err
+		}
+
+		return loadCache()
+	})
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := path.Join(dir, "data.json")
+
+	assert.NoError(t, writeFileAtomic(filename, []byte(`{"a": 1}`), 0600))
+	info, err := os.Stat(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Existing permissions are kept.
+	assert.NoError(t, os.Chmod(filename, 0640))
+	assert.NoError(t, writeFileAtomic(filename, []byte(`{"a": 2}`), 0600))
+	b, _ := ioutil.ReadFile(filename)
+	assert.Equal(t, `{"a": 2}`, string(b))
+	info, _ = os.Stat(filename)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	// No temporary files are left behind.
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1)
+
+	// Missing directories are not created.
+	assert.Error(t, writeFileAtomic(path.Join(dir, "missing", "data.json"), []byte("{}"), 0600))
+}
+
+func TestWriteConfigAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := path.Join(dir, "apis.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`{"old": {"base": "https://old.example.com"}}`), 0600))
+
+	v := viper.New()
+	v.SetConfigFile(filename)
+	assert.NoError(t, v.ReadInConfig())
+	v.Set("new", map[string]interface{}{"base": "https://new.example.com"})
+	assert.NoError(t, writeConfigAtomic(v))
+
+	b, _ := ioutil.ReadFile(filename)
+	assert.JSONEq(t, `{
+		"old": {"base": "https://old.example.com"},
+		"new": {"base": "https://new.example.com"}
+	}`, string(b))
+
+	info, _ := os.Stat(filename)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1)
+}
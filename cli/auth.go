@@ -2,8 +2,13 @@ package cli
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"golang.org/x/term"
 )
@@ -23,6 +28,8 @@ type AuthHandler interface {
 	Parameters() []AuthParam
 
 	// OnRequest applies auth to an outgoing request before it hits the wire.
+	// The key is unique to the API and profile and can be used as a prefix to
+	// store values like tokens in the Cache.
 	OnRequest(req *http.Request, key string, params map[string]string) error
 }
 
@@ -61,3 +68,78 @@ func (a *BasicAuth) OnRequest(req *http.Request, key string, params map[string]s
 	req.SetBasicAuth(params["username"], params["password"])
 	return nil
 }
+
+// bearerFileToken is a token read from a file along with the file's
+// modification time when it was read.
+type bearerFileToken struct {
+	modified time.Time
+	token    string
+}
+
+// BearerFileAuth sends a bearer token read from a file, e.g. a Kubernetes
+// projected service account token. The file is read again whenever its
+// modification time changes, so rotated tokens are picked up.
+type BearerFileAuth struct {
+	lock   sync.Mutex
+	tokens map[string]bearerFileToken
+}
+
+// Parameters define the bearer file auth parameter names.
+func (a *BearerFileAuth) Parameters() []AuthParam {
+	return []AuthParam{
+		{Name: "path", Required: true, Help: "Path to a file containing the token"},
+		{Name: "prefix", Help: "Authorization header prefix, defaults to Bearer"},
+	}
+}
+
+// token returns the current token from the file at `path`.
+func (a *BearerFileAuth) token(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if cached, ok := a.tokens[path]; ok && cached.modified.Equal(info.ModTime()) {
+		return cached.token, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+
+	if a.tokens == nil {
+		a.tokens = map[string]bearerFileToken{}
+	}
+	a.tokens[path] = bearerFileToken{modified: info.ModTime(), token: token}
+
+	return token, nil
+}
+
+// OnRequest gets run before the request goes out on the wire.
+func (a *BearerFileAuth) OnRequest(req *http.Request, key string, params map[string]string) error {
+	if params["path"] == "" {
+		return fmt.Errorf("bearer-file auth requires a path")
+	}
+
+	token, err := a.token(os.ExpandEnv(params["path"]))
+	if err != nil {
+		return err
+	}
+
+	prefix := params["prefix"]
+	if prefix == "" {
+		prefix = "Bearer"
+	}
+
+	req.Header.Set("Authorization", prefix+" "+token)
+	return nil
+}
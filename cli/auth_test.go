@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBearerFileAuth(t *testing.T) {
+	filename := path.Join(t.TempDir(), "token")
+	modified := time.Now().Add(-time.Hour)
+	write := func(token string, mtime time.Time) {
+		assert.NoError(t, ioutil.WriteFile(filename, []byte(token), 0600))
+		assert.NoError(t, os.Chtimes(filename, mtime, mtime))
+	}
+
+	auth := &BearerFileAuth{}
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+
+	write("abc123\n", modified)
+	assert.NoError(t, auth.OnRequest(req, "bearer-file-test", map[string]string{"path": filename}))
+	assert.Equal(t, "Bearer abc123", req.Header.Get("Authorization"))
+
+	// The file is only read again when it has been modified.
+	write("def456", modified)
+	assert.NoError(t, auth.OnRequest(req, "bearer-file-test", map[string]string{"path": filename}))
+	assert.Equal(t, "Bearer abc123", req.Header.Get("Authorization"))
+
+	write("def456", modified.Add(time.Minute))
+	assert.NoError(t, auth.OnRequest(req, "bearer-file-test", map[string]string{"path": filename, "prefix": "Token"}))
+	assert.Equal(t, "Token def456", req.Header.Get("Authorization"))
+
+	write("", modified.Add(2*time.Minute))
+	assert.Error(t, auth.OnRequest(req, "bearer-file-test", map[string]string{"path": filename}))
+	assert.Error(t, auth.OnRequest(req, "bearer-file-test", map[string]string{"path": filename + "-missing"}))
+	assert.Error(t, auth.OnRequest(req, "bearer-file-test", map[string]string{}))
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// authToken describes a token cached by an auth handler like OAuth 2.0.
+type authToken struct {
+	Type         string    `json:"type"`
+	Token        string    `json:"token"`
+	Expires      time.Time `json:"expires"`
+	Expired      bool      `json:"expired"`
+	RefreshToken bool      `json:"refresh_token"`
+}
+
+// authSummary describes the auth in effect for an API profile.
+type authSummary struct {
+	API     string            `json:"api"`
+	Profile string            `json:"profile"`
+	Handler string            `json:"handler"`
+	Params  map[string]string `json:"params"`
+	Missing []string          `json:"missing,omitempty"`
+	Token   *authToken        `json:"token,omitempty"`
+}
+
+// summarizeAuth returns the resolved auth config of an API profile along with
+// any cached token, without making any requests. Secrets are redacted unless
+// `showSecrets` is set.
+func summarizeAuth(name, profileName string, showSecrets bool) (*authSummary, error) {
+	config := configs[name]
+	if config == nil {
+		return nil, fmt.Errorf("API %s not found", name)
+	}
+
+	profile, err := config.resolveProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile == nil {
+		return nil, unknownProfileError(name, config, profileName)
+	}
+
+	if profile.Auth == nil || profile.Auth.Name == "" {
+		return nil, fmt.Errorf("no auth set up for API %s profile %s", name, profileName)
+	}
+
+	summary := &authSummary{
+		API:     name,
+		Profile: profileName,
+		Handler: profile.Auth.Name,
+		Params:  map[string]string{},
+	}
+
+	for k, v := range profile.Auth.Params {
+		summary.Params[k] = v
+	}
+	if !showSecrets {
+		summary.Params = redactValues(summary.Params)
+	}
+
+	if handler, ok := authHandlers[profile.Auth.Name]; ok {
+		for _, p := range handler.Parameters() {
+			if _, ok := profile.Auth.Params[p.Name]; p.Required && !ok {
+				summary.Missing = append(summary.Missing, p.Name)
+			}
+		}
+	} else {
+		LogWarning("Unknown auth handler %s", profile.Auth.Name)
+	}
+
+	key := authCacheKey(name, profileName)
+	if expires := Cache.GetTime(key + ".expires"); !expires.IsZero() {
+		token := Cache.GetString(key + ".token")
+		if !showSecrets && token != "" {
+			token = "REDACTED"
+		}
+
+		summary.Token = &authToken{
+			Type:         Cache.GetString(key + ".type"),
+			Token:        token,
+			Expires:      expires,
+			Expired:      !expires.After(time.Now()),
+			RefreshToken: Cache.GetString(key+".refresh") != "",
+		}
+	}
+
+	return summary, nil
+}
+
+// String renders the auth summary as aligned lines for display.
+func (s authSummary) String() string {
+	lines := []string{
+		"API:      " + s.API,
+		"Profile:  " + s.Profile,
+		"Handler:  " + s.Handler,
+	}
+
+	if len(s.Params) > 0 {
+		names := []string{}
+		width := 0
+		for k := range s.Params {
+			names = append(names, k)
+			if len(k) > width {
+				width = len(k)
+			}
+		}
+		sort.Strings(names)
+
+		lines = append(lines, "Params:")
+		for _, k := range names {
+			lines = append(lines, fmt.Sprintf("  %-*s  %s", width+1, k+":", s.Params[k]))
+		}
+	}
+
+	if len(s.Missing) > 0 {
+		lines = append(lines, "Missing:  "+strings.Join(s.Missing, ", "))
+	}
+
+	if s.Token == nil {
+		lines = append(lines, "Token:    none cached")
+	} else {
+		status := "expires " + s.Token.Expires.Format(time.RFC3339)
+		if s.Token.Expired {
+			status = "expired " + s.Token.Expires.Format(time.RFC3339)
+		} else {
+			status += " (in " + time.Until(s.Token.Expires).Round(time.Second).String() + ")"
+		}
+		if s.Token.RefreshToken {
+			status += ", can be refreshed"
+		}
+
+		lines = append(lines, "Token:    "+strings.TrimSpace(s.Token.Type+" "+s.Token.Token), "Expires:  "+status)
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func authCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Auth commands",
+	}
+
+	var showSecrets *bool
+	show := &cobra.Command{
+		Use:   "show short-name",
+		Short: "Show the auth in effect for an API",
+		Long:  "Show the auth handler and params of an API for the selected profile (`-p`), including params inherited from parent profiles, along with any cached token and when it expires. No requests are made. Secrets like passwords, client secrets, and tokens are redacted unless `--rsh-show-secrets` is passed. Use `-o json` to get the details as JSON.",
+		Example: fmt.Sprintf(`  # Check which credentials the staging profile uses
+  $ %s auth show my-api -p staging`, Root.CommandPath()),
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeAPINames,
+		Run: func(cmd *cobra.Command, args []string) {
+			config := configs[args[0]]
+			if config == nil {
+				panic(fmt.Errorf("API %s not found", args[0]))
+			}
+
+			summary, err := summarizeAuth(args[0], selectedProfile(config), *showSecrets)
+			if err != nil {
+				panic(err)
+			}
+
+			if viper.GetString("rsh-output-format") == "json" {
+				b, err := json.MarshalIndent(summary, "", "  ")
+				if err != nil {
+					panic(err)
+				}
+				fmt.Fprintln(Stdout, string(b))
+				return
+			}
+
+			fmt.Fprint(Stdout, summary.String())
+		},
+	}
+	showSecrets = show.Flags().Bool("rsh-show-secrets", false, "Show secret values instead of redacting them")
+	cmd.AddCommand(show)
+
+	return cmd
+}
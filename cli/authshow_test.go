@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthShow(t *testing.T) {
+	defer reset(false)
+
+	setup := func() {
+		reset(false)
+		configs["auth-show-test"] = &APIConfig{
+			name: "auth-show-test",
+			Base: "https://auth.example.com",
+			Profiles: map[string]*APIProfile{
+				"default": {
+					Auth: &APIAuth{Name: "http-basic", Params: map[string]string{"username": "me", "password": "shh"}},
+				},
+				"partial": {
+					Auth: &APIAuth{Name: "http-basic", Params: map[string]string{"username": "other"}},
+				},
+				"noauth": {},
+			},
+		}
+
+		key := authCacheKey("auth-show-test", "default")
+		Cache.Set(key+".expires", time.Now().Add(time.Hour))
+		Cache.Set(key+".type", "Bearer")
+		Cache.Set(key+".token", "tok123")
+		Cache.Set(key+".refresh", "ref456")
+	}
+
+	setup()
+	out := runNoReset("auth show auth-show-test")
+	assert.Contains(t, out, "Handler:  http-basic")
+	assert.Contains(t, out, "username:  me")
+	assert.Contains(t, out, "password:  REDACTED")
+	assert.Contains(t, out, "Token:    Bearer REDACTED")
+	assert.Contains(t, out, "can be refreshed")
+	assert.NotContains(t, out, "shh")
+	assert.NotContains(t, out, "tok123")
+
+	setup()
+	out = runNoReset("auth show auth-show-test --rsh-show-secrets")
+	assert.Contains(t, out, "password:  shh")
+	assert.Contains(t, out, "Token:    Bearer tok123")
+
+	setup()
+	out = runNoReset("auth show auth-show-test -p partial -o json")
+	var summary authSummary
+	assert.NoError(t, json.Unmarshal([]byte(out), &summary), out)
+	assert.Equal(t, "partial", summary.Profile)
+	assert.Equal(t, "http-basic", summary.Handler)
+	assert.Equal(t, map[string]string{"username": "other"}, summary.Params)
+	assert.Equal(t, []string{"password"}, summary.Missing)
+	assert.Nil(t, summary.Token)
+
+	setup()
+	_, err := summarizeAuth("auth-show-test", "noauth", false)
+	assert.Error(t, err)
+
+	_, err = summarizeAuth("auth-show-test", "missing", false)
+	assert.Error(t, err)
+}
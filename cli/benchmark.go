@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/alexeyco/simpletable"
+	"github.com/spf13/cobra"
+)
+
+// significanceLevel is the p-value below which a difference in response
+// times is reported as statistically significant.
+const significanceLevel = 0.05
+
+// mannWhitneyU runs a two-sided Mann-Whitney U test on two samples and returns
+// the U statistic and p-value. The p-value uses the normal approximation with
+// corrections for ties and continuity, which is reasonable from around ten
+// values per sample. Unlike a t-test, it doesn't assume response times are
+// normally distributed, which they rarely are.
+func mannWhitneyU(a, b []time.Duration) (float64, float64) {
+	type value struct {
+		d     time.Duration
+		first bool
+	}
+
+	n1, n2 := float64(len(a)), float64(len(b))
+	if n1 == 0 || n2 == 0 {
+		return 0, 1
+	}
+
+	values := make([]value, 0, len(a)+len(b))
+	for _, d := range a {
+		values = append(values, value{d, true})
+	}
+	for _, d := range b {
+		values = append(values, value{d, false})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].d < values[j].d })
+
+	// Tied values share the average of their ranks.
+	rankSum := 0.0
+	ties := 0.0
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && values[j].d == values[i].d {
+			j++
+		}
+
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if values[k].first {
+				rankSum += rank
+			}
+		}
+
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	u1 := rankSum - n1*(n1+1)/2
+	u := math.Min(u1, n1*n2-u1)
+
+	n := n1 + n2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 {
+		// Every value is the same.
+		return u, 1
+	}
+
+	z := math.Max(math.Abs(u1-n1*n2/2)-0.5, 0) / sigma
+	return u, math.Erfc(z / math.Sqrt2)
+}
+
+// runCompare requests each URL `runs` times and measures the response times.
+// The URLs are called in turn, alternating which goes first, so that changes
+// in load or network conditions over time affect both equally. Requests
+// bypass the HTTP cache so each one hits the server.
+func runCompare(urls []string, runs int) ([]perfResult, error) {
+	results := make([]perfResult, len(urls))
+	for i, u := range urls {
+		results[i].Name = u
+	}
+
+	client := &http.Client{}
+	for run := 0; run < runs; run++ {
+		for i := range urls {
+			// Reverse the order on every other run.
+			if run%2 == 1 {
+				i = len(urls) - 1 - i
+			}
+
+			req, err := http.NewRequest(http.MethodGet, fixAddress(urls[i]), nil)
+			if err != nil {
+				return nil, err
+			}
+
+			start := time.Now()
+			resp, err := MakeRequest(req, WithClient(client), WithoutLog())
+			if err == nil {
+				_, err = io.Copy(ioutil.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			results[i].Durations = append(results[i].Durations, time.Since(start))
+
+			if err != nil || resp.StatusCode >= 400 {
+				results[i].Errors++
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// compareTable renders the response times of two URLs side by side with the
+// change from the first to the second, followed by the significance test.
+func compareTable(a, b perfResult) string {
+	sortedA := append([]time.Duration{}, a.Durations...)
+	sort.Slice(sortedA, func(i, j int) bool { return sortedA[i] < sortedA[j] })
+	sortedB := append([]time.Duration{}, b.Durations...)
+	sort.Slice(sortedB, func(i, j int) bool { return sortedB[i] < sortedB[j] })
+
+	table := simpletable.New()
+	table.Header = &simpletable.Header{}
+	for _, title := range []string{"", "A", "B", "Change"} {
+		table.Header.Cells = append(table.Header.Cells, &simpletable.Cell{Align: simpletable.AlignCenter, Text: title})
+	}
+
+	for _, row := range []struct {
+		name string
+		p    float64
+	}{{"p50", 50}, {"p95", 95}, {"p99", 99}, {"Min", 0}, {"Max", 100}} {
+		da, db := percentile(sortedA, row.p), percentile(sortedB, row.p)
+		change := "-"
+		if da > 0 {
+			change = fmt.Sprintf("%+.1f%%", 100*(float64(db)-float64(da))/float64(da))
+		}
+
+		table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+			{Text: row.name},
+			{Align: simpletable.AlignRight, Text: formatDuration(da)},
+			{Align: simpletable.AlignRight, Text: formatDuration(db)},
+			{Align: simpletable.AlignRight, Text: change},
+		})
+	}
+
+	errorRate := func(r perfResult) string {
+		return fmt.Sprintf("%.1f%%", 100*float64(r.Errors)/float64(len(r.Durations)))
+	}
+	table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+		{Text: "Errors"},
+		{Align: simpletable.AlignRight, Text: errorRate(a)},
+		{Align: simpletable.AlignRight, Text: errorRate(b)},
+		{Align: simpletable.AlignRight, Text: ""},
+	})
+	table.SetStyle(simpletable.StyleCompactLite)
+
+	u, p := mannWhitneyU(a.Durations, b.Durations)
+	out := fmt.Sprintf("A: %s\nB: %s\n\n%s\n\nMann-Whitney U = %.1f, p = %.4f\n", a.Name, b.Name, table.String(), u, p)
+
+	if p < significanceLevel {
+		faster := "A"
+		if percentile(sortedB, 50) < percentile(sortedA, 50) {
+			faster = "B"
+		}
+		out += fmt.Sprintf("The difference is statistically significant (p < %.2f), %s is faster\n", significanceLevel, faster)
+	} else {
+		out += fmt.Sprintf("The difference is not statistically significant (p >= %.2f), try more runs to detect smaller differences\n", significanceLevel)
+	}
+
+	return out
+}
+
+func benchmarkCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "benchmark",
+		Short: "Benchmark commands",
+	}
+
+	var runs *int
+	compare := &cobra.Command{
+		Use:   "compare url1 url2",
+		Short: "Compare response times of two endpoints",
+		Long:  "Request two URLs, e.g. `v1` and `v2` of an endpoint, the same number of times and compare their response time percentiles side by side. The URLs are requested in turn, alternating which goes first, so changes in conditions over time affect both equally. A Mann-Whitney U test shows whether the difference is statistically significant. Requests use the same auth, headers, and query params as normal requests but bypass the cache.",
+		Example: fmt.Sprintf(`  # Compare two versions of an endpoint with 50 requests each
+  $ %s benchmark compare my-api/v1/items my-api/v2/items --rsh-runs 50`, Root.CommandPath()),
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if *runs < 1 {
+				panic(fmt.Errorf("runs must be at least 1"))
+			}
+
+			results, err := runCompare(args, *runs)
+			if err != nil {
+				panic(err)
+			}
+
+			fmt.Fprint(Stdout, compareTable(results[0], results[1]))
+		},
+	}
+	runs = compare.Flags().Int("rsh-runs", 20, "Number of times to request each URL")
+	cmd.AddCommand(compare)
+
+	return cmd
+}
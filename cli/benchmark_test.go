@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func millis(values ...int) []time.Duration {
+	durations := []time.Duration{}
+	for _, v := range values {
+		durations = append(durations, time.Duration(v)*time.Millisecond)
+	}
+	return durations
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	// Completely separated samples.
+	u, p := mannWhitneyU(millis(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), millis(11, 12, 13, 14, 15, 16, 17, 18, 19, 20))
+	assert.Equal(t, 0.0, u)
+	assert.InDelta(t, 0.00018, p, 0.00001)
+
+	// Interleaved samples aren't significantly different.
+	u, p = mannWhitneyU(millis(1, 3, 5, 7, 9), millis(2, 4, 6, 8, 10))
+	assert.Equal(t, 10.0, u)
+	assert.InDelta(t, 0.676, p, 0.001)
+
+	// Ties share ranks.
+	u, p = mannWhitneyU(millis(1, 2, 2, 3), millis(2, 3, 3, 4))
+	assert.Equal(t, 3.0, u)
+	assert.Greater(t, p, significanceLevel)
+
+	_, p = mannWhitneyU(millis(5, 5, 5), millis(5, 5))
+	assert.Equal(t, 1.0, p)
+
+	_, p = mannWhitneyU(nil, millis(1))
+	assert.Equal(t, 1.0, p)
+}
+
+func TestBenchmarkCompare(t *testing.T) {
+	reset(false)
+	defer gock.Off()
+
+	order := []string{}
+	record := func(req *http.Request, ereq *gock.Request) (bool, error) {
+		order = append(order, req.URL.Host)
+		return true, nil
+	}
+
+	gock.New("http://a.example.com").Get("/items").Times(4).AddMatcher(record).Reply(200).BodyString("[]")
+	gock.New("http://b.example.com").Get("/items").Times(3).AddMatcher(record).Reply(200).BodyString("[]")
+	gock.New("http://b.example.com").Get("/items").AddMatcher(record).Reply(500)
+
+	results, err := runCompare([]string{"http://a.example.com/items", "http://b.example.com/items"}, 4)
+	assert.NoError(t, err)
+	assert.True(t, gock.IsDone())
+	assert.Equal(t, []string{"a.example.com", "b.example.com", "b.example.com", "a.example.com", "a.example.com", "b.example.com", "b.example.com", "a.example.com"}, order)
+	assert.Len(t, results[0].Durations, 4)
+	assert.Equal(t, 0, results[0].Errors)
+	assert.Equal(t, 1, results[1].Errors)
+
+	out := compareTable(results[0], results[1])
+	assert.Contains(t, out, "A: http://a.example.com/items")
+	assert.Contains(t, out, "25.0%")
+	assert.Contains(t, out, "Mann-Whitney U = ")
+
+	out = compareTable(perfResult{Name: "a", Durations: millis(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)}, perfResult{Name: "b", Durations: millis(11, 12, 13, 14, 15, 16, 17, 18, 19, 20)})
+	assert.Contains(t, out, "+100.0%")
+	assert.Contains(t, out, "p = 0.0002")
+	assert.Contains(t, out, "statistically significant (p < 0.05), A is faster")
+
+	out = compareTable(perfResult{Name: "a", Durations: millis(1, 3, 5, 7, 9)}, perfResult{Name: "b", Durations: millis(2, 4, 6, 8, 10)})
+	assert.Contains(t, out, "not statistically significant")
+
+	assert.Contains(t, run("benchmark compare http://a.example.com http://b.example.com --rsh-runs 0"), "runs must be at least 1")
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/spf13/cobra"
+)
+
+// findOperation finds an API operation by its command name or one of its
+// aliases.
+func findOperation(api API, name string) (Operation, bool) {
+	for _, op := range api.Operations {
+		if slug.Make(op.Name) == name {
+			return op, true
+		}
+
+		for _, alias := range op.Aliases {
+			if alias == name {
+				return op, true
+			}
+		}
+	}
+
+	return Operation{}, false
+}
+
+// previewBody builds the request body for an operation from the same
+// arguments as the operation command, i.e. path params followed by the body
+// input. JSON bodies are pretty-printed and multipart bodies are shown part
+// by part.
+func previewBody(op Operation, args []string) (string, error) {
+	if op.BodyMediaType == "" {
+		return "", fmt.Errorf("operation %s does not take a request body", slug.Make(op.Name))
+	}
+
+	if len(args) < len(op.PathParams) {
+		return "", fmt.Errorf("operation %s requires %d path params", slug.Make(op.Name), len(op.PathParams))
+	}
+
+	body, err := GetBody(op.BodyMediaType, args[len(op.PathParams):])
+	if err != nil {
+		return "", err
+	}
+
+	if isMultipart(op.BodyMediaType) {
+		return previewMultipart(body)
+	}
+
+	if strings.Contains(op.BodyMediaType, "json") && body != "" {
+		formatted := &bytes.Buffer{}
+		if err := json.Indent(formatted, []byte(body), "", "  "); err == nil {
+			body = formatted.String()
+		}
+	}
+
+	return body, nil
+}
+
+func bodyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "body short-name operation [args...]",
+		Short: "Preview an operation's request body",
+		Long:  "Build the request body for an API operation the same way the operation command would, including parsing CLI shorthand and encoding for the operation's content type, and print it without making a request. Path params must be passed before the body input just like when calling the operation.",
+		Example: fmt.Sprintf(`  # Preview the body for an operation
+  $ %s body my-api create-item name: Foo, tags: [a, b]
+
+  # Preview a body built from a file with modifications
+  $ %s body my-api update-item item1 <item.json tags[]: c`, Root.CommandPath(), Root.CommandPath()),
+		Args:               cobra.MinimumNArgs(2),
+		ValidArgsFunction:  completeAPINames,
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+		Run: func(cmd *cobra.Command, args []string) {
+			config := configs[args[0]]
+			if config == nil {
+				panic(fmt.Errorf("API %s not found", args[0]))
+			}
+
+			api, err := Load(config.Base, &cobra.Command{})
+			if err != nil {
+				panic(err)
+			}
+
+			op, ok := findOperation(api, args[1])
+			if !ok {
+				panic(fmt.Errorf("operation %s not found in API %s", args[1], args[0]))
+			}
+
+			body, err := previewBody(op, args[2:])
+			if err != nil {
+				panic(err)
+			}
+
+			out := []byte(body)
+			if tty && strings.Contains(op.BodyMediaType, "json") {
+				if out, err = Highlight("json", out); err != nil {
+					panic(err)
+				}
+			}
+
+			fmt.Fprintln(Stdout, string(out))
+		},
+	}
+
+	cmd.AddCommand(lastBodyCommand())
+
+	return cmd
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewBody(t *testing.T) {
+	api := API{
+		Operations: []Operation{
+			{Name: "List Items", Method: "GET"},
+			{
+				Name:          "update-item",
+				Aliases:       []string{"put-item"},
+				Method:        "PUT",
+				PathParams:    []*Param{{Type: "string", Name: "item-id"}},
+				BodyMediaType: "application/json",
+			},
+		},
+	}
+
+	op, ok := findOperation(api, "put-item")
+	assert.True(t, ok)
+	assert.Equal(t, "update-item", op.Name)
+
+	_, ok = findOperation(api, "missing")
+	assert.False(t, ok)
+
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		body, err := previewBody(op, []string{"item1", "foo: 1, bar: false"})
+		assert.NoError(t, err)
+		assert.Equal(t, "{\n  \"bar\": false,\n  \"foo\": 1\n}", body)
+
+		// Missing path params.
+		_, err = previewBody(op, []string{})
+		assert.Error(t, err)
+
+		// No body for this operation.
+		list, _ := findOperation(api, "list-items")
+		_, err = previewBody(list, []string{})
+		assert.Error(t, err)
+	})
+}
+
+func TestPreviewMultipartBody(t *testing.T) {
+	op := Operation{
+		Name:          "upload-image",
+		Method:        "POST",
+		BodyMediaType: "multipart/form-data",
+	}
+
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		body, err := previewBody(op, []string{"title: Sunset, rating: 5"})
+		assert.NoError(t, err)
+
+		boundary := strings.SplitN(body, "\n", 2)[0]
+		assert.Equal(t, strings.Join([]string{
+			boundary,
+			`Content-Disposition: form-data; name="rating"`,
+			"",
+			"5",
+			boundary,
+			`Content-Disposition: form-data; name="title"`,
+			"",
+			"Sunset",
+			boundary + "--",
+		}, "\n"), body)
+
+		// The request is sent with the same boundary.
+		encoded, err := GetBody(op.BodyMediaType, []string{"title: Sunset"})
+		assert.NoError(t, err)
+		assert.Equal(t, "multipart/form-data; boundary="+multipartBoundary(encoded), bodyContentType(op.BodyMediaType, encoded))
+	})
+}
+
+func TestMultipartFiles(t *testing.T) {
+	dir := t.TempDir()
+	image := path.Join(dir, "sunset.png")
+	assert.NoError(t, os.WriteFile(image, []byte{0x89, 'P', 'N', 'G', 0xff, 0x00}, 0600))
+	notes := path.Join(dir, "notes.txt")
+	assert.NoError(t, os.WriteFile(notes, []byte("Taken at 8pm"), 0600))
+
+	encoded, err := encodeMultipart(map[string]interface{}{
+		"image": "@" + image,
+		"notes": "@" + notes,
+		"tags":  []interface{}{"a"},
+	})
+	assert.NoError(t, err)
+
+	body, err := previewMultipart(encoded)
+	assert.NoError(t, err)
+
+	// Files have their name and a content type from their extension.
+	boundary := "--" + multipartBoundary(encoded)
+	assert.Equal(t, strings.Join([]string{
+		boundary,
+		`Content-Disposition: form-data; name="image"; filename="sunset.png"`,
+		"Content-Type: image/png",
+		"",
+		"<6 bytes of binary data>",
+		boundary,
+		`Content-Disposition: form-data; name="notes"; filename="notes.txt"`,
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		"Taken at 8pm",
+		boundary,
+		`Content-Disposition: form-data; name="tags"`,
+		"",
+		`["a"]`,
+		boundary + "--",
+	}, "\n"), body)
+
+	_, err = encodeMultipart(map[string]interface{}{"image": "@" + path.Join(dir, "missing.png")})
+	assert.Error(t, err)
+
+	_, err = encodeMultipart([]interface{}{"a"})
+	assert.Error(t, err)
+}
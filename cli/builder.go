@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// operationAsker prompts for operation inputs with `--rsh-interactive`.
+var operationAsker asker = defaultAsker{}
+
+// paramHelp returns the help text for a parameter prompt, including its
+// example value if it has one.
+func paramHelp(p *Param) string {
+	help := p.Description
+	if p.Example != nil {
+		if help != "" {
+			help += "\n"
+		}
+		help += fmt.Sprintf("Example: %v", p.Example)
+	}
+	return help
+}
+
+// askParamValue prompts for a parameter value, starting from the current
+// value or the parameter's default. Enums are chosen from a list and booleans
+// confirmed. Optional parameters can be left empty, which returns an empty
+// string so they aren't sent.
+func askParamValue(a asker, kind string, p *Param, current string) string {
+	message := fmt.Sprintf("%s %s (%s)", kind, p.OptionName(), p.Type)
+	if kind == "Path param" {
+		// Path params are positional so use their name from the API.
+		message = fmt.Sprintf("%s %s (%s)", kind, p.Name, p.Type)
+	}
+	if p.Required {
+		message += " required"
+	}
+
+	def := current
+	if def == "" && p.Default != nil {
+		def = fmt.Sprintf("%v", p.Default)
+	}
+
+	if len(p.Enum) > 0 {
+		options := []string{}
+		if !p.Required && kind != "Path param" {
+			options = append(options, "(none)")
+		}
+		for _, v := range p.Enum {
+			options = append(options, fmt.Sprintf("%v", v))
+		}
+
+		var selected interface{}
+		if def != "" {
+			selected = def
+		}
+
+		if choice := a.askSelect(message, options, selected, paramHelp(p)); choice != "(none)" {
+			return choice
+		}
+		return ""
+	}
+
+	if p.Type == "boolean" {
+		if def == "" {
+			def = "false"
+		}
+		value := a.askConfirm(message, def == "true", paramHelp(p))
+		if !p.Required && strconv.FormatBool(value) == def {
+			// Leave the default as-is rather than sending it.
+			return ""
+		}
+		return strconv.FormatBool(value)
+	}
+
+	if strings.HasPrefix(p.Type, "array[") {
+		message += ", comma-separated"
+	}
+
+	return a.askInput(message, def, p.Required || kind == "Path param", paramHelp(p))
+}
+
+// askRequest prompts for an operation's path, query, and header params and
+// its request body. The flags and body are set so the command runs as if they
+// had been passed, and the path params are returned along with the equivalent
+// command line.
+func (o Operation) askRequest(a asker, cmd *cobra.Command, args []string) ([]string, string) {
+	command := []string{cmd.CommandPath()}
+
+	pathArgs := make([]string, len(o.PathParams))
+	for i, p := range o.PathParams {
+		current := ""
+		if i < len(args) {
+			current = args[i]
+		}
+		pathArgs[i] = askParamValue(a, "Path param", p, current)
+		command = append(command, shellQuote(pathArgs[i]))
+	}
+
+	askFlags := func(kind string, params []*Param) {
+		for _, p := range params {
+			current := ""
+			if cmd.Flags().Changed(p.OptionName()) {
+				current = cmd.Flags().Lookup(p.OptionName()).Value.String()
+				current = strings.TrimSuffix(strings.TrimPrefix(current, "["), "]")
+			}
+
+			value := askParamValue(a, kind, p, current)
+			if value == "" {
+				continue
+			}
+
+			if err := cmd.Flags().Set(p.OptionName(), value); err != nil {
+				panic(fmt.Errorf("invalid value for %s: %w", p.OptionName(), err))
+			}
+			command = append(command, "--"+p.OptionName(), shellQuote(value))
+		}
+	}
+	askFlags("Query param", o.QueryParams)
+	askFlags("Header", o.HeaderParams)
+
+	if o.BodyMediaType != "" {
+		var body interface{}
+		if schema, ok := bodySchema(o); ok {
+			body = askSchemaValue(a, "", schema)
+		} else {
+			help := ""
+			if len(o.Examples) > 0 {
+				help = "Example: " + o.Examples[0]
+			}
+			input := a.askInput("Request body (JSON or YAML)", "", false, help)
+			if input != "" {
+				if err := (YAML{}).Unmarshal([]byte(input), &body); err != nil {
+					panic(fmt.Errorf("invalid request body: %w", err))
+				}
+			}
+		}
+
+		if body != nil {
+			b, err := json.Marshal(makeJSONSafe(body, false))
+			if err != nil {
+				panic(err)
+			}
+			viper.Set("rsh-body", string(b))
+			command = append(command, "--rsh-body", shellQuote(string(b)))
+		}
+	}
+
+	return pathArgs, strings.Join(command, " ")
+}
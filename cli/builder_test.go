@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestAskParamValue(t *testing.T) {
+	enum := &Param{Type: "string", Name: "mode", Enum: []interface{}{"fast", "safe"}}
+	assert.Equal(t, "safe", askParamValue(&mockAsker{t: t, responses: []string{"safe"}}, "Query param", enum, ""))
+	assert.Equal(t, "", askParamValue(&mockAsker{t: t, responses: []string{"(none)"}}, "Query param", enum, ""))
+
+	flag := &Param{Type: "boolean", Name: "verbose"}
+	assert.Equal(t, "", askParamValue(&mockAsker{t: t, responses: []string{"n"}}, "Query param", flag, ""))
+	assert.Equal(t, "true", askParamValue(&mockAsker{t: t, responses: []string{"y"}}, "Query param", flag, ""))
+
+	limit := &Param{Type: "integer", Name: "limit", Default: 10}
+	assert.Equal(t, "", askParamValue(&mockAsker{t: t, responses: []string{""}}, "Query param", limit, ""))
+
+	assert.Equal(t, "Items per page\nExample: 5", paramHelp(&Param{Description: "Items per page", Example: 5}))
+}
+
+func TestOperationInteractive(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").
+		Post("/items/item1").
+		MatchParam("mode", "safe").
+		MatchHeader("X-Request-Id", "abc").
+		BodyString(`"name":"Kari"`).
+		Reply(201).
+		JSON(map[string]interface{}{"id": "item1"})
+
+	op := Operation{
+		Name:        "create-item",
+		Method:      http.MethodPost,
+		URITemplate: "http://example.com/items/{id}",
+		PathParams: []*Param{
+			{Type: "string", Name: "id", Example: "item1"},
+		},
+		QueryParams: []*Param{
+			{Type: "string", Name: "mode", Enum: []interface{}{"fast", "safe"}},
+			{Type: "boolean", Name: "verbose"},
+		},
+		HeaderParams: []*Param{
+			{Type: "string", Name: "X-Request-ID", Required: true},
+		},
+		BodyMediaType: "application/json",
+		BodySchemas: map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"type":     "object",
+				"required": []interface{}{"name"},
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"type": "string"},
+					"tags": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				},
+			},
+		},
+	}
+
+	reset(false)
+	defer viper.Set("rsh-body", "")
+	capture := &strings.Builder{}
+	Stdout = capture
+	Stderr = capture
+
+	operationAsker = &mockAsker{t: t, responses: []string{
+		"item1",
+		"safe",
+		"n",
+		"abc",
+		"Kari",
+		"n",
+		"y",
+	}}
+	defer func() {
+		operationAsker = defaultAsker{}
+	}()
+
+	cmd := op.command()
+	cmd.SetArgs([]string{"-i"})
+	assert.NoError(t, cmd.Execute())
+
+	out := capture.String()
+	assert.Contains(t, out, "POST http://example.com/items/item1?mode=safe HTTP/1.1")
+	assert.Contains(t, out, `create-item item1 --mode safe --x-request-id abc --rsh-body '{"name":"Kari"}'`)
+	assert.Contains(t, out, "HTTP/1.1 201 Created")
+	assert.True(t, gock.IsDone())
+	assert.False(t, inspectRequests)
+
+	// Declining shows the request without sending it.
+	capture.Reset()
+	operationAsker = &mockAsker{t: t, responses: []string{"item2", "(none)", "n", "abc", "Kari", "n", "n"}}
+	cmd = op.command()
+	cmd.SetArgs([]string{"--rsh-interactive"})
+	assert.NoError(t, cmd.Execute())
+	assert.Contains(t, capture.String(), "POST http://example.com/items/item2 HTTP/1.1")
+	assert.NotContains(t, capture.String(), "201 Created")
+}
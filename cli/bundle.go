@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// apiBundleVersion is the version of the API bundle format written by this
+// version of Restish. Bundles with a newer version may contain settings which
+// would be silently dropped, so they are rejected instead.
+const apiBundleVersion = 1
+
+// apiBundle is a shareable API configuration with secrets replaced by
+// environment variable placeholders, optionally including the API description
+// so it works without fetching it first.
+type apiBundle struct {
+	Version int        `json:"version"`
+	Name    string     `json:"name"`
+	Config  *APIConfig `json:"config"`
+	API     *API       `json:"api,omitempty"`
+}
+
+// bundlePlaceholder matches a value which is entirely an environment variable
+// placeholder like `${SHOP_DEFAULT_AUTHORIZATION}`.
+var bundlePlaceholder = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// envNameInvalid matches characters which can't be used in an environment
+// variable name.
+var envNameInvalid = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// placeholderName returns an environment variable name built from the parts,
+// e.g. `SHOP_DEFAULT_X_API_KEY` for API `shop`, profile `default`, and header
+// `X-API-Key`.
+func placeholderName(parts ...string) string {
+	name := strings.ToUpper(strings.Join(parts, "_"))
+	return strings.Trim(envNameInvalid.ReplaceAllString(name, "_"), "_")
+}
+
+// exportBundle creates a bundle for an API, optionally loading its API
+// description to include it.
+func exportBundle(name string, withSpec bool) (*apiBundle, error) {
+	config := configs[name]
+	if config == nil {
+		return nil, fmt.Errorf("API %s not found", name)
+	}
+
+	stripped := config.replaceSecrets(func(profile, key string) string {
+		return "${" + placeholderName(name, profile, key) + "}"
+	})
+
+	bundle := &apiBundle{
+		Version: apiBundleVersion,
+		Name:    name,
+		Config:  &stripped,
+	}
+
+	if withSpec {
+		api, err := Load(config.Base, &cobra.Command{})
+		if err != nil {
+			return nil, err
+		}
+		bundle.API = &api
+	}
+
+	return bundle, nil
+}
+
+// fillPlaceholders replaces environment variable placeholders in the values
+// with the variable's value when it is set. The names of any unset variables
+// are added to `missing`.
+func fillPlaceholders(values map[string]string, missing map[string]bool) {
+	for k, v := range values {
+		if m := bundlePlaceholder.FindStringSubmatch(v); m != nil {
+			if value, ok := os.LookupEnv(m[1]); ok {
+				values[k] = value
+			} else {
+				missing[m[1]] = true
+			}
+		}
+	}
+}
+
+// parseBundle decodes a JSON or YAML API bundle and checks that its version
+// is supported.
+func parseBundle(data []byte) (*apiBundle, error) {
+	bundle := &apiBundle{}
+	if err := yaml.Unmarshal(data, bundle); err != nil {
+		return nil, fmt.Errorf("unable to parse API bundle: %w", err)
+	}
+
+	if bundle.Version == 0 || bundle.Name == "" || bundle.Config == nil {
+		return nil, errors.New("not an API bundle, create one with `api export`")
+	}
+
+	if bundle.Version > apiBundleVersion {
+		return nil, fmt.Errorf("API bundle version %d is not supported, please upgrade to import it", bundle.Version)
+	}
+
+	return bundle, nil
+}
+
+// importBundle installs the API from a bundle, filling in secrets from
+// environment variables. An existing API with the same name is replaced only
+// if `force` is set or the user confirms.
+func importBundle(a asker, bundle *apiBundle, force bool) error {
+	name := bundle.Name
+	if configs[name] != nil && !force {
+		if !a.askConfirm(fmt.Sprintf("API %s already exists, replace it?", name), false, "") {
+			return fmt.Errorf("API %s already exists, use --rsh-force to replace it", name)
+		}
+	}
+
+	config := bundle.Config
+	config.name = name
+
+	missing := map[string]bool{}
+	for _, profile := range config.Profiles {
+		if profile == nil {
+			continue
+		}
+		fillPlaceholders(profile.Headers, missing)
+		fillPlaceholders(profile.Query, missing)
+		if profile.Auth != nil {
+			fillPlaceholders(profile.Auth.Params, missing)
+		}
+	}
+
+	if err := validateAPIConfig(name, config); err != nil {
+		return err
+	}
+
+	if configs[name] != nil {
+		// The old cached API description may not match the new config.
+		if err := clearAPICache(name); err != nil {
+			return err
+		}
+	}
+
+	configs[name] = config
+	if err := config.Save(); err != nil {
+		return err
+	}
+
+	if bundle.API != nil {
+		cacheAPI(name, bundle.API, "")
+	}
+
+	LogInfo("Imported API %s with profiles %s", name, strings.Join(config.profileNames(), ", "))
+
+	if len(missing) > 0 {
+		names := []string{}
+		for k := range missing {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		LogWarning("Secrets were not set, set these environment variables and import again or use `api edit %s`: %s", name, strings.Join(names, ", "))
+	}
+
+	return nil
+}
+
+func initAPIBundle() {
+	var withSpec *bool
+	exportCmd := &cobra.Command{
+		Use:               "export short-name",
+		Short:             "Export an API as a shareable bundle",
+		Long:              "Export an API configuration as a single JSON (default) or YAML bundle which can be shared with teammates and installed with `api import-bundle`. Secrets like passwords, tokens, and API keys are replaced with environment variable placeholders. Use `--rsh-with-spec` to include the API description.",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeAPINames,
+		Run: func(cmd *cobra.Command, args []string) {
+			bundle, err := exportBundle(args[0], *withSpec)
+			if err != nil {
+				panic(err)
+			}
+
+			encoded, lexer, err := marshalDocument(viper.GetString("rsh-output-format"), bundle)
+			if err != nil {
+				panic(err)
+			}
+
+			if tty {
+				if encoded, err = Highlight(lexer, encoded); err != nil {
+					panic(err)
+				}
+			}
+
+			fmt.Fprint(Stdout, string(encoded))
+		},
+	}
+	withSpec = exportCmd.Flags().Bool("rsh-with-spec", false, "Include the API description in the bundle")
+	apiCommand.AddCommand(exportCmd)
+
+	var force *bool
+	importCmd := &cobra.Command{
+		Use:   "import-bundle file|-",
+		Short: "Install an API from a bundle",
+		Long:  "Install an API from a bundle created with `api export`. Secret placeholders like `${SHOP_DEFAULT_AUTHORIZATION}` are filled in from environment variables when set. If an API with the same name exists you are asked before replacing it, unless `--rsh-force` is passed.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := readInput(args[0])
+			if err != nil {
+				panic(err)
+			}
+
+			bundle, err := parseBundle(data)
+			if err != nil {
+				panic(err)
+			}
+
+			if err := importBundle(defaultAsker{}, bundle, *force); err != nil {
+				panic(err)
+			}
+		},
+	}
+	force = importCmd.Flags().Bool("rsh-force", false, "Replace an existing API with the same name without asking")
+	apiCommand.AddCommand(importCmd)
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceholderName(t *testing.T) {
+	assert.Equal(t, "SHOP_DEFAULT_X_API_KEY", placeholderName("shop", "default", "X-API-Key"))
+	assert.Equal(t, "MY_API_PROD_CLIENT_SECRET", placeholderName("my-api", "prod", "client_secret"))
+}
+
+func TestAPIBundle(t *testing.T) {
+	reset(false)
+	defer func() {
+		removeAPI("bundle-test")
+		reset(false)
+	}()
+
+	config := &APIConfig{
+		name: "bundle-test",
+		Base: "https://bundle.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {
+				Headers: map[string]string{"Authorization": "Bearer abc", "Accept-Language": "en"},
+				Auth: &APIAuth{
+					Name:   "http-basic",
+					Params: map[string]string{"username": "me", "password": "shh"},
+				},
+			},
+		},
+	}
+	configs["bundle-test"] = config
+	assert.NoError(t, config.Save())
+	cacheAPI("bundle-test", &API{Short: "Bundle API", Operations: []Operation{{Name: "list-items", Method: "GET", URITemplate: "https://bundle.example.com/items"}}}, "")
+
+	filename := path.Join(t.TempDir(), "bundle.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(runNoReset("api export bundle-test --rsh-with-spec")), 0600))
+
+	data, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "shh")
+	assert.NotContains(t, string(data), "Bearer abc")
+
+	bundle, err := parseBundle(data)
+	assert.NoError(t, err)
+	assert.Equal(t, apiBundleVersion, bundle.Version)
+	profile := bundle.Config.Profiles["default"]
+	assert.Equal(t, "${BUNDLE_TEST_DEFAULT_AUTHORIZATION}", profile.Headers["Authorization"])
+	assert.Equal(t, "en", profile.Headers["Accept-Language"])
+	assert.Equal(t, "me", profile.Auth.Params["username"])
+	assert.Equal(t, "${BUNDLE_TEST_DEFAULT_PASSWORD}", profile.Auth.Params["password"])
+	if assert.NotNil(t, bundle.API) {
+		assert.Equal(t, "list-items", bundle.API.Operations[0].Name)
+	}
+
+	// Importing over an existing API needs confirmation or `--rsh-force`.
+	assert.EqualError(t, importBundle(&mockAsker{t: t, responses: []string{"n"}}, bundle, false), "API bundle-test already exists, use --rsh-force to replace it")
+
+	assert.NoError(t, removeAPI("bundle-test"))
+	os.Setenv("BUNDLE_TEST_DEFAULT_PASSWORD", "from-env")
+	defer os.Unsetenv("BUNDLE_TEST_DEFAULT_PASSWORD")
+
+	out := runNoReset("api import-bundle " + filename)
+	assert.Contains(t, out, "BUNDLE_TEST_DEFAULT_AUTHORIZATION")
+
+	imported := configs["bundle-test"]
+	if assert.NotNil(t, imported) {
+		assert.Equal(t, "https://bundle.example.com", imported.Base)
+		assert.Equal(t, "from-env", imported.Profiles["default"].Auth.Params["password"])
+		assert.Equal(t, "${BUNDLE_TEST_DEFAULT_AUTHORIZATION}", imported.Profiles["default"].Headers["Authorization"])
+	}
+
+	cached, ok := loadCachedAPI("bundle-test")
+	assert.True(t, ok)
+	assert.Equal(t, "Bundle API", cached.Short)
+
+	assert.NotContains(t, runNoReset("api import-bundle "+filename+" --rsh-force"), "ERROR")
+}
+
+func TestParseBundleErrors(t *testing.T) {
+	_, err := parseBundle([]byte(`{"base": "https://example.com"}`))
+	assert.Error(t, err)
+
+	_, err = parseBundle([]byte(`{"version": 99, "name": "future", "config": {"base": "https://example.com"}}`))
+	assert.EqualError(t, err, "API bundle version 99 is not supported, please upgrade to import it")
+
+	_, err = parseBundle([]byte("version: 1\nname: yaml\nconfig:\n  base: https://example.com\n"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,247 @@
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// cacheVersion is the current layout of the cache file. Version 2 moved all
+// per-API entries into the `apis` namespace.
+const cacheVersion = 2
+
+// apiCacheKey returns the cache key for an API-specific value, e.g. the
+// `expires` key for the API `example` is `apis.example.expires`.
+func apiCacheKey(apiName string, parts ...string) string {
+	return strings.Join(append([]string{"apis", strings.ToLower(apiName)}, parts...), ".")
+}
+
+// authCacheKey returns the cache key prefix given to auth handlers for storing
+// values like tokens for an API profile.
+func authCacheKey(apiName, profile string) string {
+	return apiCacheKey(apiName, "profiles", strings.ToLower(profile))
+}
+
+// subMap returns the child map with the given key, creating it if needed.
+func subMap(m map[string]interface{}, key string) map[string]interface{} {
+	if child, ok := m[key].(map[string]interface{}); ok {
+		return child
+	}
+	child := map[string]interface{}{}
+	m[key] = child
+	return child
+}
+
+// migrateCacheData moves entries from the original flat cache layout into the
+// per-API namespace. Previously API entries like `example.expires` were stored
+// at the top level and auth tokens used `example:profile.token`. Anything
+// which is not recognizably API-specific is left untouched.
+func migrateCacheData(data map[string]interface{}) bool {
+	if v, ok := data["cache-version"].(float64); ok && int(v) >= cacheVersion {
+		return false
+	}
+
+	apis := map[string]interface{}{}
+	for k, v := range data {
+		entry, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if i := strings.Index(k, ":"); i > 0 {
+			profiles := subMap(subMap(apis, k[:i]), "profiles")
+			profiles[k[i+1:]] = entry
+			delete(data, k)
+			continue
+		}
+
+		if _, ok := entry["expires"]; ok {
+			api := subMap(apis, k)
+			for ek, ev := range entry {
+				api[ek] = ev
+			}
+			delete(data, k)
+		}
+	}
+
+	// An existing `apis` entry which was not moved above already uses the new
+	// layout, e.g. written by a newer version, so its values take precedence.
+	if existing, ok := data["apis"].(map[string]interface{}); ok {
+		mergeMissing(existing, apis)
+		apis = existing
+	}
+	data["apis"] = apis
+	data["cache-version"] = cacheVersion
+	return true
+}
+
+// mergeMissing recursively copies values from `src` into `dest` which are not
+// already present there.
+func mergeMissing(dest, src map[string]interface{}) {
+	for k, v := range src {
+		existing, ok := dest[k]
+		if !ok {
+			dest[k] = v
+			continue
+		}
+
+		em, ok1 := existing.(map[string]interface{})
+		vm, ok2 := v.(map[string]interface{})
+		if ok1 && ok2 {
+			mergeMissing(em, vm)
+		}
+	}
+}
+
+// pruneCacheData removes cached entries for APIs which are no longer
+// configured, returning the names of the removed APIs.
+func pruneCacheData(data map[string]interface{}, configured map[string]bool) []string {
+	removed := []string{}
+	apis, ok := data["apis"].(map[string]interface{})
+	if !ok {
+		return removed
+	}
+
+	for name := range apis {
+		if !configured[strings.ToLower(name)] {
+			delete(apis, name)
+			removed = append(removed, name)
+		}
+	}
+
+	return removed
+}
+
+// rewriteCache loads the raw cache file, lets `modify` change it, and if any
+// changes were made saves the file and reloads the cache.
+func rewriteCache(modify func(data map[string]interface{}) bool) error {
+	filename := cacheFile()
+	return withFileLock(filename, func() error {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+
+		data := map[string]interface{}{}
+		if err := json.Unmarshal(b, &data); err != nil {
+			return err
+		}
+
+		if !modify(data) {
+			return nil
+		}
+
+		if b, err = json.MarshalIndent(data, "", "  "); err != nil {
+			return err
+		}
+
+		if err := writeFileAtomic(filename, b, 0600); err != nil {
+			return err
+		}
+
+		return loadCache()
+	})
+}
+
+// removeAPICacheFiles removes any cached files for an API, like its loaded
+// API description. Files are matched case-insensitively as cache keys are
+// lowercased while files use the name the API was loaded with.
+func removeAPICacheFiles(apiName string) {
+	files, err := ioutil.ReadDir(cacheDir())
+	if err != nil {
+		return
+	}
+
+	for _, f := range files {
+		name := f.Name()
+		if path.Ext(name) == ".cbor" && strings.EqualFold(strings.TrimSuffix(name, ".cbor"), apiName) {
+			os.Remove(path.Join(cacheDir(), name))
+		}
+	}
+}
+
+// pruneCache removes cached data for APIs which no longer exist, returning
+// the names of the removed APIs. The configured APIs are read from disk so
+// that nothing is removed if the API config can't be read, and an empty
+// config is assumed to be a mistake rather than every API being removed.
+func pruneCache() ([]string, error) {
+	var removed []string
+
+	filename := apis.ConfigFileUsed()
+	err := withFileLock(filename, func() error {
+		onDisk := viper.New()
+		onDisk.SetConfigFile(filename)
+		if err := onDisk.ReadInConfig(); err != nil {
+			return err
+		}
+
+		configured := map[string]bool{}
+		for name := range onDisk.AllSettings() {
+			configured[strings.ToLower(name)] = true
+		}
+
+		if len(configured) == 0 {
+			LogDebug("No APIs configured in %s, skipping cache pruning", filename)
+			return nil
+		}
+
+		return rewriteCache(func(data map[string]interface{}) bool {
+			removed = pruneCacheData(data, configured)
+			for _, name := range removed {
+				removeAPICacheFiles(name)
+			}
+			return len(removed) > 0
+		})
+	})
+
+	return removed, err
+}
+
+// autoPruneInterval is how often cached data for removed APIs is cleaned up
+// automatically on startup.
+const autoPruneInterval = 24 * time.Hour
+
+// autoPruneCache prunes the cache like `cache gc` if that has not been done
+// within the last `autoPruneInterval`, so that removing an API by editing the
+// config does not leave its data behind forever. Failures are only logged.
+func autoPruneCache() {
+	if time.Since(Cache.GetTime("pruned")) < autoPruneInterval {
+		return
+	}
+
+	removed, err := pruneCache()
+	if err != nil {
+		LogDebug("Unable to prune cache: %v", err)
+		return
+	}
+
+	for _, name := range removed {
+		LogDebug("Removed cached data for API %s which is no longer configured", name)
+	}
+
+	Cache.Set("pruned", time.Now().UTC())
+	if err := SaveCache(); err != nil {
+		LogDebug("Unable to save cache: %v", err)
+	}
+}
+
+// clearAPICache removes all cached data for a single API.
+func clearAPICache(apiName string) error {
+	removeAPICacheFiles(apiName)
+	return rewriteCache(func(data map[string]interface{}) bool {
+		apis, ok := data["apis"].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, ok := apis[strings.ToLower(apiName)]; !ok {
+			return false
+		}
+		delete(apis, strings.ToLower(apiName))
+		return true
+	})
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateCache(t *testing.T) {
+	data := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"example": {"expires": "2022-01-01T00:00:00Z"},
+		"example:default": {"token": "abc", "refresh": "def"},
+		"other:testing": {"token": "ghi"},
+		"custom": {"value": 1},
+		"flag": true
+	}`), &data))
+
+	assert.True(t, migrateCacheData(data))
+
+	expected := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"cache-version": 2,
+		"apis": {
+			"example": {
+				"expires": "2022-01-01T00:00:00Z",
+				"profiles": {
+					"default": {"token": "abc", "refresh": "def"}
+				}
+			},
+			"other": {
+				"profiles": {
+					"testing": {"token": "ghi"}
+				}
+			}
+		},
+		"custom": {"value": 1},
+		"flag": true
+	}`), &expected))
+
+	b, _ := json.Marshal(data)
+	e, _ := json.Marshal(expected)
+	assert.JSONEq(t, string(e), string(b))
+
+	// Already migrated data is left alone.
+	roundTrip := map[string]interface{}{}
+	json.Unmarshal(b, &roundTrip)
+	assert.False(t, migrateCacheData(roundTrip))
+}
+
+func TestMigrateCacheExistingAPIs(t *testing.T) {
+	data := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"apis": {
+			"example": {"profiles": {"default": {"token": "new"}}},
+			"newer": {"expires": "2023-01-01T00:00:00Z"}
+		},
+		"example": {"expires": "2022-01-01T00:00:00Z"},
+		"example:default": {"token": "old"},
+		"other:default": {"token": "abc"}
+	}`), &data))
+
+	assert.True(t, migrateCacheData(data))
+
+	// Entries already in the new layout are kept and take precedence.
+	b, _ := json.Marshal(data)
+	assert.JSONEq(t, `{
+		"cache-version": 2,
+		"apis": {
+			"example": {
+				"expires": "2022-01-01T00:00:00Z",
+				"profiles": {"default": {"token": "new"}}
+			},
+			"newer": {"expires": "2023-01-01T00:00:00Z"},
+			"other": {"profiles": {"default": {"token": "abc"}}}
+		}
+	}`, string(b))
+}
+
+func TestPruneCache(t *testing.T) {
+	data := map[string]interface{}{
+		"apis": map[string]interface{}{
+			"keep":   map[string]interface{}{"expires": "2022-01-01T00:00:00Z"},
+			"remove": map[string]interface{}{"expires": "2022-01-01T00:00:00Z"},
+		},
+		"custom": "value",
+	}
+
+	removed := pruneCacheData(data, map[string]bool{"keep": true})
+
+	assert.Equal(t, []string{"remove"}, removed)
+	assert.Contains(t, data["apis"], "keep")
+	assert.NotContains(t, data["apis"], "remove")
+	assert.Equal(t, "value", data["custom"])
+}
+
+func TestPruneCacheRemovedAPIs(t *testing.T) {
+	reset(false)
+	defer func() {
+		removeAPI("prune-keep")
+		reset(false)
+	}()
+
+	config := &APIConfig{name: "prune-keep", Base: "https://prune-keep.example.com"}
+	configs["prune-keep"] = config
+	assert.NoError(t, config.Save())
+
+	Cache.Set(apiCacheKey("prune-keep", "expires"), "2022-01-01T00:00:00Z")
+	Cache.Set(apiCacheKey("Prune-Gone", "expires"), "2022-01-01T00:00:00Z")
+	assert.NoError(t, Cache.WriteConfig())
+
+	// Cached files keep the original case of the API name.
+	gone := path.Join(cacheDir(), "Prune-Gone.cbor")
+	assert.NoError(t, ioutil.WriteFile(gone, []byte{}, 0600))
+
+	// An empty API config is never used to prune.
+	b, err := ioutil.ReadFile(apis.ConfigFileUsed())
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(apis.ConfigFileUsed(), []byte("{}"), 0600))
+	removed, err := pruneCache()
+	assert.NoError(t, ioutil.WriteFile(apis.ConfigFileUsed(), b, 0600))
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+	assert.FileExists(t, gone)
+
+	removed, err = pruneCache()
+	assert.NoError(t, err)
+	assert.Contains(t, removed, "prune-gone")
+	assert.NotContains(t, removed, "prune-keep")
+	assert.NotEmpty(t, Cache.GetString(apiCacheKey("prune-keep", "expires")))
+	assert.Empty(t, Cache.GetString(apiCacheKey("Prune-Gone", "expires")))
+
+	_, err = os.Stat(gone)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestAutoPruneCache(t *testing.T) {
+	reset(false)
+	defer func() {
+		removeAPI("auto-prune-keep")
+		reset(false)
+	}()
+
+	config := &APIConfig{name: "auto-prune-keep", Base: "https://auto-prune-keep.example.com"}
+	configs["auto-prune-keep"] = config
+	assert.NoError(t, config.Save())
+
+	Cache.Set("pruned", time.Now().Add(-48*time.Hour))
+	Cache.Set(apiCacheKey("auto-prune-keep", "expires"), "2022-01-01T00:00:00Z")
+	Cache.Set(apiCacheKey("auto-prune-gone", "expires"), "2022-01-01T00:00:00Z")
+	assert.NoError(t, SaveCache())
+
+	// Loading the config prunes removed APIs once the interval has passed.
+	reset(false)
+	assert.NotEmpty(t, Cache.GetString(apiCacheKey("auto-prune-keep", "expires")))
+	assert.Empty(t, Cache.GetString(apiCacheKey("auto-prune-gone", "expires")))
+	assert.WithinDuration(t, time.Now(), Cache.GetTime("pruned"), time.Minute)
+
+	// Pruning is skipped until the interval passes again.
+	Cache.Set(apiCacheKey("auto-prune-gone", "expires"), "2022-01-01T00:00:00Z")
+	assert.NoError(t, SaveCache())
+	reset(false)
+	assert.NotEmpty(t, Cache.GetString(apiCacheKey("auto-prune-gone", "expires")))
+	clearAPICache("auto-prune-gone")
+}
+
+func TestClearAPICache(t *testing.T) {
+	reset(false)
+
+	Cache.Set(apiCacheKey("clear-test", "expires"), "2022-01-01T00:00:00Z")
+	Cache.Set(authCacheKey("clear-test", "default")+".token", "abc")
+	assert.NoError(t, Cache.WriteConfig())
+
+	assert.NoError(t, clearAPICache("clear-test"))
+	assert.Empty(t, Cache.GetString(apiCacheKey("clear-test", "expires")))
+	assert.Empty(t, Cache.GetString(authCacheKey("clear-test", "default")+".token"))
+}
@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"unicode/utf8"
+)
+
+// cassetteBody stores a body as text when possible so that cassettes are easy
+// to read and edit, falling back to base64 for binary data.
+type cassetteBody struct {
+	Text   string `json:"text,omitempty"`
+	Base64 string `json:"base64,omitempty"`
+}
+
+func newCassetteBody(b []byte) cassetteBody {
+	if utf8.Valid(b) {
+		return cassetteBody{Text: string(b)}
+	}
+	return cassetteBody{Base64: base64.StdEncoding.EncodeToString(b)}
+}
+
+func (b cassetteBody) bytes() []byte {
+	if b.Base64 != "" {
+		decoded, err := base64.StdEncoding.DecodeString(b.Base64)
+		if err != nil {
+			LogWarning("Invalid base64 body in cassette: %v", err)
+		}
+		return decoded
+	}
+	return []byte(b.Text)
+}
+
+// cassetteInteraction is a recorded request and its response.
+type cassetteInteraction struct {
+	Request struct {
+		Method  string       `json:"method"`
+		URI     string       `json:"uri"`
+		Headers http.Header  `json:"headers,omitempty"`
+		Body    cassetteBody `json:"body"`
+	} `json:"request"`
+	Response struct {
+		Status  int          `json:"status"`
+		Headers http.Header  `json:"headers,omitempty"`
+		Body    cassetteBody `json:"body"`
+	} `json:"response"`
+}
+
+// cassette is a file of recorded interactions which can be replayed by the
+// mock server. Requests are matched on their method and URI, and recording a
+// request again replaces the previous recording.
+type cassette struct {
+	filename     string
+	Interactions []*cassetteInteraction `json:"interactions"`
+	lock         sync.Mutex
+}
+
+// loadCassette loads a cassette file. A missing file is an empty cassette.
+func loadCassette(filename string) (*cassette, error) {
+	c := &cassette{filename: filename}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return c, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// find returns the recorded interaction for a request, if any.
+func (c *cassette) find(method, uri string) *cassetteInteraction {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, i := range c.Interactions {
+		if i.Request.Method == method && i.Request.URI == uri {
+			return i
+		}
+	}
+	return nil
+}
+
+// record adds or replaces an interaction and saves the cassette.
+func (c *cassette) record(interaction *cassetteInteraction) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	replaced := false
+	for i, existing := range c.Interactions {
+		if existing.Request.Method == interaction.Request.Method && existing.Request.URI == interaction.Request.URI {
+			c.Interactions[i] = interaction
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		c.Interactions = append(c.Interactions, interaction)
+	}
+
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(c.filename, b, 0600)
+}
+
+// hopHeaders are connection-specific headers which must not be forwarded by
+// a proxy, plus headers the HTTP client and server set themselves.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+	"Content-Length",
+}
+
+func copyHeaders(dst, src http.Header) {
+	for k, v := range src {
+		dst[k] = append([]string{}, v...)
+	}
+	for _, h := range hopHeaders {
+		dst.Del(h)
+	}
+}
+
+// writeInteraction writes a recorded response.
+func writeInteraction(w http.ResponseWriter, interaction *cassetteInteraction) {
+	copyHeaders(w.Header(), interaction.Response.Headers)
+	w.WriteHeader(interaction.Response.Status)
+	w.Write(interaction.Response.Body.bytes())
+}
+
+// replayHandler serves recorded responses from a cassette, passing any other
+// requests to the next handler.
+func replayHandler(c *cassette, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if interaction := c.find(r.Method, r.URL.RequestURI()); interaction != nil {
+			LogInfo("%s %s -> %d (replayed)", r.Method, r.URL.RequestURI(), interaction.Response.Status)
+			writeInteraction(w, interaction)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recordHandler forwards requests to the real API at `base`, using the
+// configured auth for the API, and records each request and response to the
+// cassette before returning the response.
+func recordHandler(c *cassette, base *url.URL) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		target := *base
+		target.Path = r.URL.Path
+		target.RawPath = r.URL.RawPath
+		target.RawQuery = r.URL.RawQuery
+
+		req, err := http.NewRequest(r.Method, target.String(), bytes.NewReader(body))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		copyHeaders(req.Header, r.Header)
+
+		// Recorded bodies should be readable, so don't compress them.
+		req.Header.Set("Accept-Encoding", "identity")
+
+		resp, err := MakeRequest(req, WithClient(&http.Client{}))
+		if err != nil {
+			LogError("%s %s -> %v", r.Method, r.URL.RequestURI(), err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		interaction := &cassetteInteraction{}
+		interaction.Request.Method = r.Method
+		interaction.Request.URI = r.URL.RequestURI()
+		interaction.Request.Headers = r.Header
+		interaction.Request.Body = newCassetteBody(body)
+		interaction.Response.Status = resp.StatusCode
+		interaction.Response.Headers = resp.Header
+		interaction.Response.Body = newCassetteBody(respBody)
+
+		if err := c.record(interaction); err != nil {
+			LogWarning("Unable to save cassette: %v", err)
+		}
+
+		LogInfo("%s %s -> %d (recorded)", r.Method, r.URL.RequestURI(), resp.StatusCode)
+		writeInteraction(w, interaction)
+	})
+}
@@ -0,0 +1,266 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// loadSpecFile loads an API description from a local file or URL using the
+// registered loaders, without registering any commands for it.
+func loadSpecFile(base *url.URL, filename string) (API, error) {
+	body, err := readSpecFile(filename)
+	if err != nil {
+		return API{}, err
+	}
+
+	spec := apiSpec{header: http.Header{}, body: body}
+	l := detectLoader(spec.header, body)
+	if l == nil {
+		return API{}, fmt.Errorf("could not detect API type: %s", filename)
+	}
+
+	return l.Load(*base, *base, spec.response())
+}
+
+// changelog holds a categorized list of human-readable API changes.
+type changelog struct {
+	Breaking []string
+	Added    []string
+	Changed  []string
+}
+
+// operationLabel describes an operation like `list-items` (GET /items).
+func operationLabel(op Operation) string {
+	uri := op.URITemplate
+	if u, err := url.Parse(uri); err == nil && u.Path != "" {
+		uri = u.Path
+	}
+	return fmt.Sprintf("`%s` (%s %s)", op.Name, op.Method, uri)
+}
+
+// operationParams returns all parameters for an operation keyed by location
+// and name, e.g. `query limit`.
+func operationParams(op Operation) map[string]*Param {
+	params := map[string]*Param{}
+	for _, p := range op.PathParams {
+		params["path "+p.Name] = p
+	}
+	for _, p := range op.QueryParams {
+		params["query "+p.Name] = p
+	}
+	for _, p := range op.HeaderParams {
+		params["header "+p.Name] = p
+	}
+	return params
+}
+
+// isRequired returns whether a param must be sent. Path params always are.
+func isRequired(key string, p *Param) bool {
+	return p.Required || strings.HasPrefix(key, "path ")
+}
+
+// isWidened returns true if changing a param from one type to another still
+// accepts all of the old values, e.g. integer to number.
+func isWidened(from, to string) bool {
+	return (from == "integer" && to == "number") ||
+		(from == "array[integer]" && to == "array[number]")
+}
+
+func sortedKeys(m map[string]*Param) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffOperation compares two versions of the same operation.
+func diffOperation(log *changelog, before, after Operation) {
+	label := operationLabel(after)
+
+	if before.Method != after.Method || before.URITemplate != after.URITemplate {
+		log.Breaking = append(log.Breaking, fmt.Sprintf("%s moved from %s", label, strings.TrimPrefix(operationLabel(before), "`"+before.Name+"` ")))
+	}
+
+	oldParams := operationParams(before)
+	newParams := operationParams(after)
+
+	for _, key := range sortedKeys(oldParams) {
+		p := oldParams[key]
+		np := newParams[key]
+		if np == nil {
+			if isRequired(key, p) {
+				log.Breaking = append(log.Breaking, fmt.Sprintf("%s removed required %s param", label, key))
+			} else {
+				log.Breaking = append(log.Breaking, fmt.Sprintf("%s removed optional %s param", label, key))
+			}
+			continue
+		}
+
+		if p.Type != np.Type {
+			if isWidened(p.Type, np.Type) {
+				log.Changed = append(log.Changed, fmt.Sprintf("%s widened %s param from `%s` to `%s`", label, key, p.Type, np.Type))
+			} else {
+				log.Breaking = append(log.Breaking, fmt.Sprintf("%s changed %s param from `%s` to `%s`", label, key, p.Type, np.Type))
+			}
+		}
+
+		if !isRequired(key, p) && isRequired(key, np) {
+			log.Breaking = append(log.Breaking, fmt.Sprintf("%s now requires %s param", label, key))
+		}
+
+		if p.Description != np.Description {
+			log.Changed = append(log.Changed, fmt.Sprintf("%s changed %s param description", label, key))
+		}
+	}
+
+	for _, key := range sortedKeys(newParams) {
+		if oldParams[key] != nil {
+			continue
+		}
+
+		if isRequired(key, newParams[key]) {
+			log.Breaking = append(log.Breaking, fmt.Sprintf("%s added required %s param", label, key))
+		} else {
+			log.Changed = append(log.Changed, fmt.Sprintf("%s added optional %s param", label, key))
+		}
+	}
+
+	switch {
+	case before.BodyMediaType != "" && after.BodyMediaType == "":
+		log.Breaking = append(log.Breaking, fmt.Sprintf("%s no longer accepts a request body", label))
+	case before.BodyMediaType != after.BodyMediaType && before.BodyMediaType != "":
+		log.Breaking = append(log.Breaking, fmt.Sprintf("%s changed request body from `%s` to `%s`", label, before.BodyMediaType, after.BodyMediaType))
+	case before.BodyMediaType == "" && after.BodyMediaType != "":
+		log.Changed = append(log.Changed, fmt.Sprintf("%s now accepts a `%s` request body", label, after.BodyMediaType))
+	}
+
+	if before.Short != after.Short || before.Long != after.Long {
+		log.Changed = append(log.Changed, fmt.Sprintf("%s changed description", label))
+	}
+}
+
+// diffAPIs computes a changelog between two versions of an API.
+func diffAPIs(before, after API) changelog {
+	log := changelog{}
+
+	oldOps := map[string]Operation{}
+	for _, op := range before.Operations {
+		oldOps[op.Name] = op
+	}
+
+	newOps := map[string]Operation{}
+	names := []string{}
+	for _, op := range after.Operations {
+		newOps[op.Name] = op
+		names = append(names, op.Name)
+	}
+	for _, op := range before.Operations {
+		if _, ok := newOps[op.Name]; !ok {
+			names = append(names, op.Name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		op, existed := oldOps[name]
+		newOp, exists := newOps[name]
+
+		switch {
+		case existed && !exists:
+			log.Breaking = append(log.Breaking, fmt.Sprintf("Removed %s", operationLabel(op)))
+		case !existed && exists:
+			added := "Added " + operationLabel(newOp)
+			if newOp.Short != "" {
+				added += ": " + newOp.Short
+			}
+			log.Added = append(log.Added, added)
+		default:
+			diffOperation(&log, op, newOp)
+		}
+	}
+
+	return log
+}
+
+// Markdown renders the changelog following Keep a Changelog conventions.
+func (c changelog) Markdown() string {
+	sb := &strings.Builder{}
+	sb.WriteString("# Changelog\n")
+
+	sections := []struct {
+		title string
+		items []string
+	}{
+		{"Breaking Changes", c.Breaking},
+		{"New Operations", c.Added},
+		{"Changed Operations", c.Changed},
+	}
+
+	empty := true
+	for _, section := range sections {
+		if len(section.items) == 0 {
+			continue
+		}
+		empty = false
+
+		sb.WriteString("\n## " + section.title + "\n\n")
+		for _, item := range section.items {
+			sb.WriteString("- " + item + "\n")
+		}
+	}
+
+	if empty {
+		sb.WriteString("\nNo changes.\n")
+	}
+
+	return sb.String()
+}
+
+func changelogCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "changelog short-name old-spec new-spec",
+		Short: "Generate an API changelog",
+		Long:  "Generate a Markdown changelog from the differences between two versions of an API description document, which may be local files or URLs. Changes are grouped into breaking changes, new operations, and changed operations.",
+		Example: fmt.Sprintf(`  # Compare a release against the previous one
+  $ %s changelog my-api v1/openapi.yaml v2/openapi.yaml >CHANGELOG.md`, Root.CommandPath()),
+		Args:              cobra.ExactArgs(3),
+		ValidArgsFunction: completeAPINames,
+		Run: func(cmd *cobra.Command, args []string) {
+			config := configs[args[0]]
+			if config == nil {
+				panic(fmt.Errorf("API %s not found", args[0]))
+			}
+
+			base, err := url.Parse(config.Base)
+			if err != nil {
+				panic(err)
+			}
+
+			before, err := loadSpecFile(base, args[1])
+			if err != nil {
+				panic(err)
+			}
+
+			after, err := loadSpecFile(base, args[2])
+			if err != nil {
+				panic(err)
+			}
+
+			out := []byte(diffAPIs(before, after).Markdown())
+			if tty {
+				if out, err = Highlight("markdown", out); err != nil {
+					panic(err)
+				}
+			}
+
+			fmt.Fprint(Stdout, string(out))
+		},
+	}
+}
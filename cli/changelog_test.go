@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangelog(t *testing.T) {
+	before := API{
+		Operations: []Operation{
+			{
+				Name:        "list-items",
+				Method:      "GET",
+				URITemplate: "https://api.example.com/items",
+				QueryParams: []*Param{
+					{Type: "integer", Name: "limit"},
+					{Type: "string", Name: "cursor"},
+				},
+			},
+			{
+				Name:        "get-item",
+				Short:       "Get an item",
+				Method:      "GET",
+				URITemplate: "https://api.example.com/items/{id}",
+				PathParams: []*Param{
+					{Type: "string", Name: "id"},
+				},
+			},
+			{
+				Name:        "delete-item",
+				Method:      "DELETE",
+				URITemplate: "https://api.example.com/items/{id}",
+			},
+		},
+	}
+
+	after := API{
+		Operations: []Operation{
+			{
+				Name:        "list-items",
+				Method:      "GET",
+				URITemplate: "https://api.example.com/items",
+				QueryParams: []*Param{
+					{Type: "number", Name: "limit"},
+					{Type: "string", Name: "sort"},
+					{Type: "string", Name: "tenant", Required: true},
+				},
+			},
+			{
+				Name:        "get-item",
+				Short:       "Get an item by ID",
+				Method:      "GET",
+				URITemplate: "https://api.example.com/items/{id}",
+				PathParams: []*Param{
+					{Type: "integer", Name: "id"},
+				},
+			},
+			{
+				Name:        "create-item",
+				Short:       "Create an item",
+				Method:      "POST",
+				URITemplate: "https://api.example.com/items",
+			},
+		},
+	}
+
+	assert.Equal(t, `# Changelog
+
+## Breaking Changes
+
+- Removed `+"`delete-item`"+` (DELETE /items/{id})
+- `+"`get-item`"+` (GET /items/{id}) changed path id param from `+"`string`"+` to `+"`integer`"+`
+- `+"`list-items`"+` (GET /items) removed optional query cursor param
+- `+"`list-items`"+` (GET /items) added required query tenant param
+
+## New Operations
+
+- Added `+"`create-item`"+` (POST /items): Create an item
+
+## Changed Operations
+
+- `+"`get-item`"+` (GET /items/{id}) changed description
+- `+"`list-items`"+` (GET /items) widened query limit param from `+"`integer`"+` to `+"`number`"+`
+- `+"`list-items`"+` (GET /items) added optional query sort param
+`, diffAPIs(before, after).Markdown())
+
+	assert.Equal(t, "# Changelog\n\nNo changes.\n", diffAPIs(before, before).Markdown())
+}
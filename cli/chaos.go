@@ -0,0 +1,316 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+// chaosTimeout is the failure which drops the connection without a response
+// instead of sending an error status.
+const chaosTimeout = "timeout"
+
+// chaosZ99 is the z-score of the 99th percentile of a normal distribution.
+const chaosZ99 = 2.3263
+
+// defaultChaosFailures are the failures injected unless configured otherwise.
+var defaultChaosFailures = []chaosFailure{"500", "503", chaosTimeout}
+
+// chaosDuration is a duration written like `100ms` or `5s` in chaos files.
+type chaosDuration time.Duration
+
+func (d *chaosDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like 100ms: %w", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = chaosDuration(parsed)
+	return nil
+}
+
+// chaosFailure is an HTTP status code to respond with, or `timeout` to hold
+// the request and then drop the connection.
+type chaosFailure string
+
+func (f *chaosFailure) UnmarshalJSON(data []byte) error {
+	var status int
+	if err := json.Unmarshal(data, &status); err == nil {
+		*f = chaosFailure(strconv.Itoa(status))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("failure must be a status code or %s", chaosTimeout)
+	}
+
+	*f = chaosFailure(s)
+	return nil
+}
+
+// chaosSettings configure how often mock responses fail, how they fail, and
+// how much latency is added before responding. Unset values are inherited.
+type chaosSettings struct {
+	Rate       *float64       `json:"rate,omitempty"`
+	LatencyP50 *chaosDuration `json:"latency_p50,omitempty"`
+	LatencyP99 *chaosDuration `json:"latency_p99,omitempty"`
+	Failures   []chaosFailure `json:"failures,omitempty"`
+}
+
+// merge returns the settings with any unset values taken from `parent`.
+func (s chaosSettings) merge(parent chaosSettings) chaosSettings {
+	if s.Rate == nil {
+		s.Rate = parent.Rate
+	}
+	if s.LatencyP50 == nil {
+		s.LatencyP50 = parent.LatencyP50
+	}
+	if s.LatencyP99 == nil {
+		s.LatencyP99 = parent.LatencyP99
+	}
+	if s.Failures == nil {
+		s.Failures = parent.Failures
+	}
+	return s
+}
+
+func (s chaosSettings) rate() float64 {
+	if s.Rate == nil {
+		return 0
+	}
+	return *s.Rate
+}
+
+func (s chaosSettings) latency() (time.Duration, time.Duration) {
+	var p50, p99 time.Duration
+	if s.LatencyP50 != nil {
+		p50 = time.Duration(*s.LatencyP50)
+	}
+	if s.LatencyP99 != nil {
+		p99 = time.Duration(*s.LatencyP99)
+	}
+	return p50, p99
+}
+
+func (s chaosSettings) validate() error {
+	if rate := s.rate(); rate < 0 || rate > 1 {
+		return fmt.Errorf("rate %v must be between 0 and 1", rate)
+	}
+
+	p50, p99 := s.latency()
+	if p50 < 0 || p99 < 0 {
+		return fmt.Errorf("latency must not be negative")
+	}
+	if p99 > 0 && p50 == 0 {
+		return fmt.Errorf("latency p99 requires a latency p50")
+	}
+	if p99 > 0 && p99 < p50 {
+		return fmt.Errorf("latency p99 %s must not be less than p50 %s", p99, p50)
+	}
+
+	for _, f := range s.Failures {
+		if f == chaosTimeout {
+			continue
+		}
+		if status, err := strconv.Atoi(string(f)); err != nil || status < 100 || status > 599 {
+			return fmt.Errorf("invalid failure %s, expected a status code or %s", f, chaosTimeout)
+		}
+	}
+
+	return nil
+}
+
+// chaosRoute overrides chaos settings for requests matching a method, if any,
+// and a path pattern like `/items/*`.
+type chaosRoute struct {
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path"`
+	chaosSettings
+}
+
+// chaosConfig is the chaos file, with default settings for every request
+// followed by per-route overrides, where the first matching route is used.
+type chaosConfig struct {
+	chaosSettings
+	Timeout *chaosDuration `json:"timeout,omitempty"`
+	Routes  []chaosRoute   `json:"routes,omitempty"`
+}
+
+// loadChaosConfig reads a YAML or JSON chaos file.
+func loadChaosConfig(filename string) (*chaosConfig, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &chaosConfig{}
+	if err := yaml.Unmarshal(b, config); err != nil {
+		return nil, fmt.Errorf("chaos file %s: %w", filename, err)
+	}
+
+	return config, nil
+}
+
+// enabled returns whether any failures or latency would be injected.
+func (c *chaosConfig) enabled() bool {
+	all := []chaosSettings{c.chaosSettings}
+	for _, route := range c.Routes {
+		all = append(all, route.chaosSettings)
+	}
+
+	for _, s := range all {
+		if p50, _ := s.latency(); s.rate() > 0 || p50 > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *chaosConfig) validate() error {
+	if err := c.chaosSettings.validate(); err != nil {
+		return err
+	}
+
+	if c.Timeout != nil && *c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+
+	for _, route := range c.Routes {
+		if route.Path == "" {
+			return fmt.Errorf("chaos route needs a path")
+		}
+		if _, err := path.Match(route.Path, ""); err != nil {
+			return fmt.Errorf("route %s: %w", route.Path, err)
+		}
+		if err := route.merge(c.chaosSettings).validate(); err != nil {
+			return fmt.Errorf("route %s: %w", route.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// settings returns the chaos settings for a request.
+func (c *chaosConfig) settings(r *http.Request) chaosSettings {
+	for _, route := range c.Routes {
+		if route.Method != "" && !strings.EqualFold(route.Method, r.Method) {
+			continue
+		}
+		if ok, _ := path.Match(route.Path, r.URL.Path); ok {
+			return route.merge(c.chaosSettings)
+		}
+	}
+
+	return c.chaosSettings
+}
+
+// chaos injects failures and latency into mock responses.
+type chaos struct {
+	config *chaosConfig
+	mu     sync.Mutex
+	rand   *rand.Rand
+}
+
+func newChaos(config *chaosConfig, source rand.Source) (*chaos, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	return &chaos{config: config, rand: rand.New(source)}, nil
+}
+
+// latency returns a random latency to add before responding. Latencies follow
+// a log-normal distribution, like real response times, fitted to the p50 and
+// p99. Without a p99 the p50 is always used.
+func (c *chaos) latency(s chaosSettings) time.Duration {
+	p50, p99 := s.latency()
+	if p50 <= 0 || p99 <= p50 {
+		return p50
+	}
+
+	c.mu.Lock()
+	z := c.rand.NormFloat64()
+	c.mu.Unlock()
+
+	sigma := math.Log(float64(p99)/float64(p50)) / chaosZ99
+	return time.Duration(float64(p50) * math.Exp(sigma*z))
+}
+
+// failure returns a random failure for a request, or an empty string if the
+// request should succeed.
+func (c *chaos) failure(s chaosSettings) chaosFailure {
+	failures := s.Failures
+	if len(failures) == 0 {
+		failures = defaultChaosFailures
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.rand.Float64() >= s.rate() {
+		return ""
+	}
+
+	return failures[c.rand.Intn(len(failures))]
+}
+
+// timeout returns how long a `timeout` failure holds the request before
+// dropping the connection, which defaults to 30 seconds.
+func (c *chaos) timeout() time.Duration {
+	if c.config.Timeout != nil {
+		return time.Duration(*c.config.Timeout)
+	}
+	return 30 * time.Second
+}
+
+// chaosHandler adds latency and randomly fails requests before passing them
+// on to the `next` handler, so clients can test retries, circuit breakers,
+// and timeouts against a mock.
+func chaosHandler(c *chaos, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := c.config.settings(r)
+
+		if d := c.latency(s); d > 0 {
+			select {
+			case <-time.After(d):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		switch failure := c.failure(s); failure {
+		case "":
+			next.ServeHTTP(w, r)
+		case chaosTimeout:
+			LogInfo("%s %s -> timeout (chaos)", r.Method, r.URL.Path)
+			select {
+			case <-time.After(c.timeout()):
+			case <-r.Context().Done():
+			}
+
+			// Closes the connection without sending a response.
+			panic(http.ErrAbortHandler)
+		default:
+			status, _ := strconv.Atoi(string(failure))
+			LogInfo("%s %s -> %d (chaos)", r.Method, r.URL.Path, status)
+			http.Error(w, http.StatusText(status), status)
+		}
+	})
+}
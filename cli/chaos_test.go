@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaosConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "chaos.yaml")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`
+rate: 0.1
+latency_p50: 100ms
+latency_p99: 5s
+failures: [503, timeout]
+timeout: 2s
+routes:
+  - path: /items/*
+    method: POST
+    rate: 1
+    failures: [500]
+  - path: /health
+    rate: 0
+    latency_p50: 0s
+    latency_p99: 0s
+`), 0600))
+
+	config, err := loadChaosConfig(filename)
+	assert.NoError(t, err)
+	assert.NoError(t, config.validate())
+	assert.True(t, config.enabled())
+
+	settings := config.settings(httptest.NewRequest(http.MethodGet, "/other", nil))
+	assert.Equal(t, 0.1, settings.rate())
+	assert.Equal(t, []chaosFailure{"503", chaosTimeout}, settings.Failures)
+	p50, p99 := settings.latency()
+	assert.Equal(t, 100*time.Millisecond, p50)
+	assert.Equal(t, 5*time.Second, p99)
+
+	// Routes inherit unset values from the defaults.
+	settings = config.settings(httptest.NewRequest(http.MethodPost, "/items/abc", nil))
+	assert.Equal(t, 1.0, settings.rate())
+	assert.Equal(t, []chaosFailure{"500"}, settings.Failures)
+	p50, _ = settings.latency()
+	assert.Equal(t, 100*time.Millisecond, p50)
+
+	// The method must match, and `*` doesn't match across segments.
+	assert.Equal(t, 0.1, config.settings(httptest.NewRequest(http.MethodGet, "/items/abc", nil)).rate())
+	assert.Equal(t, 0.1, config.settings(httptest.NewRequest(http.MethodPost, "/items/abc/parts", nil)).rate())
+	assert.Equal(t, 0.0, config.settings(httptest.NewRequest(http.MethodGet, "/health", nil)).rate())
+
+	for _, invalid := range []string{
+		"rate: 1.5",
+		"latency_p99: 1s",
+		"latency_p50: 2s\nlatency_p99: 1s",
+		"failures: [700]",
+		"failures: [oops]",
+		"routes:\n  - rate: 0.5",
+		"routes:\n  - path: /[\n    rate: 0.5",
+	} {
+		assert.NoError(t, ioutil.WriteFile(filename, []byte(invalid), 0600))
+		config, err := loadChaosConfig(filename)
+		assert.NoError(t, err)
+		assert.Error(t, config.validate(), invalid)
+	}
+
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("latency_p50: fast"), 0600))
+	_, err = loadChaosConfig(filename)
+	assert.Error(t, err)
+}
+
+func TestChaosHandler(t *testing.T) {
+	reset(false)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rate := func(r float64) *float64 { return &r }
+	duration := func(d time.Duration) *chaosDuration { c := chaosDuration(d); return &c }
+
+	serve := func(config *chaosConfig, count int) map[int]int {
+		injector, err := newChaos(config, rand.NewSource(1))
+		assert.NoError(t, err)
+
+		codes := map[int]int{}
+		for i := 0; i < count; i++ {
+			w := httptest.NewRecorder()
+			chaosHandler(injector, ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+			codes[w.Code]++
+		}
+		return codes
+	}
+
+	assert.Equal(t, map[int]int{200: 10}, serve(&chaosConfig{}, 10))
+
+	config := &chaosConfig{chaosSettings: chaosSettings{Rate: rate(1), Failures: []chaosFailure{"503"}}}
+	assert.Equal(t, map[int]int{503: 10}, serve(config, 10))
+
+	// Roughly the configured fraction of requests fail.
+	config = &chaosConfig{chaosSettings: chaosSettings{Rate: rate(0.2), Failures: []chaosFailure{"500", "503"}}}
+	codes := serve(config, 1000)
+	assert.InDelta(t, 800, codes[200], 50)
+	assert.InDelta(t, 100, codes[500], 40)
+	assert.InDelta(t, 100, codes[503], 40)
+
+	// Timeouts drop the connection without a response.
+	config = &chaosConfig{
+		chaosSettings: chaosSettings{Rate: rate(1), Failures: []chaosFailure{chaosTimeout}},
+		Timeout:       duration(time.Millisecond),
+	}
+	injector, err := newChaos(config, rand.NewSource(1))
+	assert.NoError(t, err)
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		chaosHandler(injector, ok).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items", nil))
+	})
+
+	// Latency is added before responding.
+	config = &chaosConfig{chaosSettings: chaosSettings{LatencyP50: duration(20 * time.Millisecond)}}
+	start := time.Now()
+	assert.Equal(t, map[int]int{200: 1}, serve(config, 1))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(20*time.Millisecond))
+}
+
+func TestChaosLatencyPercentiles(t *testing.T) {
+	p50, p99 := chaosDuration(100*time.Millisecond), chaosDuration(5*time.Second)
+	injector, err := newChaos(&chaosConfig{}, rand.NewSource(1))
+	assert.NoError(t, err)
+
+	settings := chaosSettings{LatencyP50: &p50, LatencyP99: &p99}
+	latencies := []time.Duration{}
+	for i := 0; i < 10000; i++ {
+		latencies = append(latencies, injector.latency(settings))
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	assert.InDelta(t, float64(100*time.Millisecond), float64(percentile(latencies, 50)), float64(10*time.Millisecond))
+	assert.InDelta(t, float64(5*time.Second), float64(percentile(latencies, 99)), float64(time.Second))
+}
@@ -12,8 +12,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
-	"runtime"
-	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
 
@@ -50,6 +49,15 @@ var Stdout io.Writer = os.Stdout
 // otherwise it defaults to `os.Stderr`.
 var Stderr io.Writer = os.Stderr
 
+// exitCode is the process exit code set while running a command.
+var exitCode int
+
+// GetExitCode returns the exit code to use after `Run` completes, which is
+// non-zero if e.g. a response signaled an error.
+func GetExitCode() int {
+	return exitCode
+}
+
 // Ugh, see https://github.com/spf13/cobra/issues/836
 var usageTemplate = `Usage:{{if .Runnable}}
   {{.UseLine}}{{end}}{{if .HasAvailableSubCommands}}
@@ -61,10 +69,10 @@ Aliases:
 Examples:
 {{.Example}}{{end}}{{if (not .Parent)}}{{if (gt (len .Commands) 9)}}
 
-Available API Commands:{{range .Commands}}{{if (not (or (eq .Name "help") (eq .Name "get") (eq .Name "put") (eq .Name "post") (eq .Name "patch") (eq .Name "delete") (eq .Name "head") (eq .Name "options") (eq .Name "cert") (eq .Name "api") (eq .Name "links") (eq .Name "edit") (eq .Name "completion") (eq .Name "auth-header")))}}
+Available API Commands:{{range .Commands}}{{if (not (or (eq .Name "help") (eq .Name "get") (eq .Name "put") (eq .Name "post") (eq .Name "patch") (eq .Name "delete") (eq .Name "head") (eq .Name "options") (eq .Name "cert") (eq .Name "api") (eq .Name "links") (eq .Name "edit") (eq .Name "completion") (eq .Name "auth-header") (eq .Name "export") (eq .Name "changelog") (eq .Name "discover") (eq .Name "curl-import") (eq .Name "perf") (eq .Name "cache") (eq .Name "body") (eq .Name "response") (eq .Name "pipeline") (eq .Name "mock") (eq .Name "save") (eq .Name "saved") (eq .Name "migrate") (eq .Name "schema") (eq .Name "diff-profile") (eq .Name "headers") (eq .Name "content-types") (eq .Name "status") (eq .Name "apis") (eq .Name "format") (eq .Name "config") (eq .Name "request") (eq .Name "import") (eq .Name "benchmark") (eq .Name "auth")))}}
   {{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{if .HasAvailableSubCommands}}
 
-Generic Commands:{{range .Commands}}{{if (or (eq .Name "help") (eq .Name "get") (eq .Name "put") (eq .Name "post") (eq .Name "patch") (eq .Name "delete") (eq .Name "head") (eq .Name "options") (eq .Name "cert") (eq .Name "api") (eq .Name "links") (eq .Name "edit") (eq .Name "completion") (eq .Name "auth-header"))}}
+Generic Commands:{{range .Commands}}{{if (or (eq .Name "help") (eq .Name "get") (eq .Name "put") (eq .Name "post") (eq .Name "patch") (eq .Name "delete") (eq .Name "head") (eq .Name "options") (eq .Name "cert") (eq .Name "api") (eq .Name "links") (eq .Name "edit") (eq .Name "completion") (eq .Name "auth-header") (eq .Name "export") (eq .Name "changelog") (eq .Name "discover") (eq .Name "curl-import") (eq .Name "perf") (eq .Name "cache") (eq .Name "body") (eq .Name "response") (eq .Name "pipeline") (eq .Name "mock") (eq .Name "save") (eq .Name "saved") (eq .Name "migrate") (eq .Name "schema") (eq .Name "diff-profile") (eq .Name "headers") (eq .Name "content-types") (eq .Name "status") (eq .Name "apis") (eq .Name "format") (eq .Name "config") (eq .Name "request") (eq .Name "import") (eq .Name "benchmark") (eq .Name "auth"))}}
   {{rpad .Name .NamePadding }} {{.Short}}{{end}}{{end}}{{end}}{{else}}{{if .HasAvailableSubCommands}}
 
 Available Commands:{{range .Commands}}{{if (or .IsAvailableCommand (eq .Name "help"))}}
@@ -83,24 +91,36 @@ Use "{{.CommandPath}} [command] --help" for more information about a command.{{e
 `
 
 var tty bool
-var au aurora.Aurora
+
+// au colors output, which starts disabled so that messages logged while
+// loading the config work before the TTY has been detected.
+var au aurora.Aurora = aurora.NewAurora(false)
 
 // Keeps track of currently selected API for shell completions
 var currentConfig *APIConfig
 
-func generic(method string, addr string, args []string) {
+func generic(method string, addr string, args []string) error {
 	var body io.Reader
 
 	d, err := GetBody("application/json", args)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if len(d) > 0 {
 		body = strings.NewReader(d)
 	}
 
-	req, _ := http.NewRequest(method, fixAddress(addr), body)
-	MakeRequestAndFormat(req)
+	req, err := http.NewRequest(method, fixAddress(addr), body)
+	if err != nil {
+		return exitError(ExitCodeUsage, err, "invalid URI %s", addr)
+	}
+
+	if err := MakeRequestAndFormat(req); err != nil {
+		return err
+	}
+	suggestAPI(req.URL.String())
+
+	return nil
 }
 
 // templateVarRegex used to find/replace variables `/{foo}/bar/{baz}` in a
@@ -208,6 +228,16 @@ func completeGenericCmd(method string, showAPIs bool) func(cmd *cobra.Command, a
 
 // Init will set up the CLI.
 func Init(name string, version string) {
+	initGlobalFlags()
+
+	// Check for verbose output before loading anything so that loading the
+	// config, cache, and APIs is logged too.
+	if verbose, _ := GlobalFlags.GetBool("rsh-verbose"); verbose {
+		enableVerbose = true
+	} else {
+		enableVerbose, _ = strconv.ParseBool(os.Getenv("RSH_VERBOSE"))
+	}
+
 	initConfig(name, "")
 	initCache(name)
 
@@ -217,6 +247,9 @@ func Init(name string, version string) {
 	encodings = map[string]ContentEncoding{}
 	linkParsers = []LinkParser{}
 	loaders = []Loader{}
+	prettyPrinters = []prettyPrinterEntry{}
+	recordedMetrics = nil
+	exitCode = 0
 
 	// Determine if we are using a TTY or colored output is forced-on.
 	tty = false
@@ -271,13 +304,18 @@ func Init(name string, version string) {
 		Args:              cobra.MinimumNArgs(1),
 		ValidArgsFunction: completeGenericCmd(http.MethodGet, false),
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			// The arguments are valid once the command runs, so errors from here
+			// on shouldn't print the usage.
+			cmd.SilenceUsage = true
+
 			settings := viper.AllSettings()
 			LogDebug("Configuration: %v", settings)
 		},
-		Run: func(cmd *cobra.Command, args []string) {
-			generic(http.MethodGet, args[0], args[1:])
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return generic(http.MethodGet, args[0], args[1:])
 		},
 	}
+	Root.SilenceErrors = true
 	Root.SetUsageTemplate(usageTemplate)
 	Root.SetHelpTemplate(`{{with (or .Long .Short)}}{{. | trimTrailingWhitespaces | highlight}}
 
@@ -289,8 +327,8 @@ func Init(name string, version string) {
 		Long:              "Perform an HTTP HEAD on the given URI",
 		Args:              cobra.MinimumNArgs(1),
 		ValidArgsFunction: completeGenericCmd(http.MethodHead, true),
-		Run: func(cmd *cobra.Command, args []string) {
-			generic(http.MethodHead, args[0], args[1:])
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return generic(http.MethodHead, args[0], args[1:])
 		},
 	}
 	Root.AddCommand(head)
@@ -301,8 +339,8 @@ func Init(name string, version string) {
 		Long:              "Perform an HTTP OPTIONS on the given URI",
 		Args:              cobra.MinimumNArgs(1),
 		ValidArgsFunction: completeGenericCmd(http.MethodOptions, true),
-		Run: func(cmd *cobra.Command, args []string) {
-			generic(http.MethodOptions, args[0], args[1:])
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return generic(http.MethodOptions, args[0], args[1:])
 		},
 	}
 	Root.AddCommand(options)
@@ -313,8 +351,8 @@ func Init(name string, version string) {
 		Long:              "Perform an HTTP GET on the given URI",
 		Args:              cobra.MinimumNArgs(1),
 		ValidArgsFunction: completeGenericCmd(http.MethodGet, true),
-		Run: func(cmd *cobra.Command, args []string) {
-			generic(http.MethodGet, args[0], args[1:])
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return generic(http.MethodGet, args[0], args[1:])
 		},
 	}
 	Root.AddCommand(get)
@@ -325,8 +363,8 @@ func Init(name string, version string) {
 		Long:              "Perform an HTTP POST on the given URI",
 		Args:              cobra.MinimumNArgs(1),
 		ValidArgsFunction: completeGenericCmd(http.MethodPost, true),
-		Run: func(cmd *cobra.Command, args []string) {
-			generic(http.MethodPost, args[0], args[1:])
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return generic(http.MethodPost, args[0], args[1:])
 		},
 	}
 	Root.AddCommand(post)
@@ -337,8 +375,8 @@ func Init(name string, version string) {
 		Long:              "Perform an HTTP PUT on the given URI",
 		Args:              cobra.MinimumNArgs(1),
 		ValidArgsFunction: completeGenericCmd(http.MethodPut, true),
-		Run: func(cmd *cobra.Command, args []string) {
-			generic(http.MethodPut, args[0], args[1:])
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return generic(http.MethodPut, args[0], args[1:])
 		},
 	}
 	Root.AddCommand(put)
@@ -349,8 +387,8 @@ func Init(name string, version string) {
 		Long:              "Perform an HTTP PATCH on the given URI",
 		Args:              cobra.MinimumNArgs(1),
 		ValidArgsFunction: completeGenericCmd(http.MethodPatch, true),
-		Run: func(cmd *cobra.Command, args []string) {
-			generic(http.MethodPatch, args[0], args[1:])
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return generic(http.MethodPatch, args[0], args[1:])
 		},
 	}
 	Root.AddCommand(patch)
@@ -361,8 +399,8 @@ func Init(name string, version string) {
 		Long:              "Perform an HTTP DELETE on the given URI",
 		Args:              cobra.MinimumNArgs(1),
 		ValidArgsFunction: completeGenericCmd(http.MethodDelete, true),
-		Run: func(cmd *cobra.Command, args []string) {
-			generic(http.MethodDelete, args[0], args[1:])
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return generic(http.MethodDelete, args[0], args[1:])
 		},
 	}
 	Root.AddCommand(delete)
@@ -414,9 +452,14 @@ func Init(name string, version string) {
 				return fmt.Errorf("No matched API for URL %s", args[0])
 			}
 
-			profile := config.Profiles[viper.GetString("rsh-profile")]
+			profileName := selectedProfile(config)
+			profile, err := config.resolveProfile(profileName)
+			if err != nil {
+				return err
+			}
+
 			if profile == nil {
-				return fmt.Errorf("Invalid profile %s", viper.GetString("rsh-profile"))
+				return unknownProfileError(name, config, profileName)
 			}
 
 			if profile.Auth == nil || profile.Auth.Name == "" {
@@ -425,9 +468,9 @@ func Init(name string, version string) {
 
 			if auth, ok := authHandlers[profile.Auth.Name]; ok {
 				req, _ := http.NewRequest(http.MethodGet, addr, nil)
-				err := auth.OnRequest(req, name+":"+viper.GetString("rsh-profile"), profile.Auth.Params)
+				err := auth.OnRequest(req, authCacheKey(name, profileName), profile.Auth.Params)
 				if err != nil {
-					panic(err)
+					return authError(profile.Auth.Name, err)
 				}
 				fmt.Fprintln(Stdout, req.Header.Get("Authorization"))
 			}
@@ -442,10 +485,10 @@ func Init(name string, version string) {
 		Long:              "Get TLS certificate information including expiration date",
 		Args:              cobra.ExactArgs(1),
 		ValidArgsFunction: completeGenericCmd(http.MethodGet, true),
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			u, err := url.Parse(fixAddress(args[0]))
 			if err != nil {
-				panic(err)
+				return exitError(ExitCodeUsage, err, "invalid URI %s", args[0])
 			}
 			addr := u.Host
 
@@ -455,8 +498,9 @@ func Init(name string, version string) {
 
 			conn, err := tls.Dial("tcp", addr, nil)
 			if err != nil {
-				panic(err)
+				return exitError(ExitCodeTransport, err, "unable to connect to %s", addr)
 			}
+			defer conn.Close()
 
 			chains := conn.ConnectionState().VerifiedChains
 			if chains != nil && len(chains) > 0 && len(chains[0]) > 0 {
@@ -484,6 +528,8 @@ Not after (expires): %s (%s)
 
 				fmt.Print(info)
 			}
+
+			return nil
 		},
 	}
 	Root.AddCommand(cert)
@@ -530,20 +576,46 @@ Not after (expires): %s (%s)
 	}
 	Root.AddCommand(linkCmd)
 
-	GlobalFlags = pflag.NewFlagSet("eager-flags", pflag.ContinueOnError)
-	GlobalFlags.ParseErrorsWhitelist.UnknownFlags = true
-	// GlobalFlags are 'hidden', don't print anything on error
-	GlobalFlags.Usage = func() {}
-	// Ensure parsing doesn't stop if the help flag is set
-	// (help seems to be special cased from ParseErrorsWhitelist.UnknownFlags)
-	GlobalFlags.BoolP("help", "h", false, "")
+	exportCommand = &cobra.Command{
+		Use:   "export",
+		Short: "Export API descriptions",
+		Long:  "Export an API's description document, converting it into another format.",
+	}
+	Root.AddCommand(exportCommand)
+
+	Root.AddCommand(changelogCommand())
+	Root.AddCommand(discoverCommand())
+	Root.AddCommand(curlImportCommand())
+	Root.AddCommand(perfCommand())
+	Root.AddCommand(benchmarkCommand())
+	Root.AddCommand(cacheCommand())
+	Root.AddCommand(bodyCommand())
+	Root.AddCommand(responseCommand())
+	Root.AddCommand(pipelineCommand())
+	Root.AddCommand(mockCommand())
+	Root.AddCommand(saveCommand())
+	Root.AddCommand(savedCommand())
+	Root.AddCommand(migrateCommand())
+	Root.AddCommand(schemaCommand())
+	Root.AddCommand(diffProfileCommand())
+	Root.AddCommand(headersCommand())
+	Root.AddCommand(contentTypesCommand())
+	Root.AddCommand(statusCommand())
+	Root.AddCommand(formatCommand())
+	Root.AddCommand(configCommand())
+	Root.AddCommand(requestCommand())
+	Root.AddCommand(importCommand())
+	Root.AddCommand(authCommand())
 
 	AddGlobalFlag("rsh-verbose", "v", "Enable verbose log output", false, false)
-	AddGlobalFlag("rsh-output-format", "o", "Output format [auto, json, yaml]", "auto", false)
+	AddGlobalFlag("rsh-debug", "", "Print stack traces for unexpected errors", false, false)
+	AddGlobalFlag("rsh-output-format", "o", "Output format [auto, json, yaml, csv]", "auto", false)
 	AddGlobalFlag("rsh-filter", "f", "Filter / project results using JMESPath Plus", "", false)
+	AddGlobalFlag("rsh-jsonpath", "", "Filter / project results using JSONPath", "", false)
+	AddGlobalFlag("rsh-jq", "", "Filter / project results using jq", "", false)
 	AddGlobalFlag("rsh-raw", "r", "Output result of query as raw rather than an escaped JSON string or list", false, false)
 	AddGlobalFlag("rsh-server", "s", "Override scheme://server:port for an API", "", false)
-	AddGlobalFlag("rsh-header", "H", "Add custom header", []string{}, true)
+	addGlobalArrayFlag("rsh-header", "H", "Add custom header")
 	AddGlobalFlag("rsh-query", "q", "Add custom query param", []string{}, true)
 	AddGlobalFlag("rsh-no-paginate", "", "Disable auto-pagination", false, false)
 	AddGlobalFlag("rsh-profile", "p", "API auth profile", "default", false)
@@ -552,10 +624,45 @@ Not after (expires): %s (%s)
 	AddGlobalFlag("rsh-client-cert", "", "Path to a PEM encoded client certificate", "", false)
 	AddGlobalFlag("rsh-client-key", "", "Path to a PEM encoded private key", "", false)
 	AddGlobalFlag("rsh-ca-cert", "", "Path to a PEM encoded CA cert", "", false)
+	AddGlobalFlag("rsh-http10", "", "Use HTTP/1.0 without keep-alive or chunked bodies, e.g. to test old servers", false, false)
+	AddGlobalFlag("rsh-connection", "", "Set the Connection header, e.g. close", "", false)
+	AddGlobalFlag("rsh-user-agent", "", "Set the User-Agent header, defaults to restish/<version>", "", false)
 	AddGlobalFlag("rsh-table", "t", "Enable table formatted output for array of objects", false, false)
+	AddGlobalFlag("rsh-swr", "", "Serve stale cached responses up to this duration past expiry while revalidating, e.g. 30s", "", false)
+	AddGlobalFlag("rsh-config-dir", "", "Directory for configuration and cache files", "", false)
+	AddGlobalFlag("rsh-cache-dir", "", "Directory for cache files, overriding the config directory", "", false)
+	AddGlobalFlag("rsh-include-timing", "", "Include the response time in output", false, false)
+	AddGlobalFlag("rsh-trace", "", "Send a trace header with each request and print its value", false, false)
+	AddGlobalFlag("rsh-trace-header", "", "Header used to send trace IDs", "traceparent", false)
+	AddGlobalFlag("rsh-metrics", "", "Write Prometheus textfile metrics for requests to this file", "", false)
+	AddGlobalFlag("rsh-cache-encrypt", "", "Encrypt cached responses at rest", false, false)
+	AddGlobalFlag("rsh-cache-max-size", "", "Maximum size of the response cache, e.g. 100MB", "", false)
+	AddGlobalFlag("rsh-cache-responses", "", "Cache successful GET responses regardless of their cache headers", false, false)
+	AddGlobalFlag("rsh-cache-ttl", "", "How long to cache responses with --rsh-cache-responses, e.g. 5m", "60s", false)
+	AddGlobalFlag("rsh-suggest-api", "", "Suggest configuring unknown hosts which serve an API description", false, false)
+	AddGlobalFlag("rsh-assert", "", "Exit with an error unless this JMESPath expression is true for the response", "", false)
+	AddGlobalFlag("rsh-quiet", "", "Don't print the response, e.g. when only using --rsh-assert", false, false)
+	AddGlobalFlag("rsh-headers-only", "", "Only print response headers as tab-separated name/value lines", false, false)
+	AddGlobalFlag("rsh-msgpack", "", "Request MessagePack responses via the Accept header", false, false)
+	AddGlobalFlag("rsh-sort-keys", "", "Sort object keys in output, disable to keep the order from the response", true, false)
+	AddGlobalFlag("rsh-ndjson-strict", "", "Fail on malformed lines in newline-delimited JSON responses instead of skipping them", false, false)
+	AddGlobalFlag("rsh-precise-numbers", "", "Decode JSON numbers exactly instead of as 64-bit floats, e.g. for large IDs", false, false)
+	AddGlobalFlag("rsh-accept-weight", "", "Override the Accept header q factor for a content type, e.g. application/cbor=0.5", []string{}, true)
+	AddGlobalFlag("rsh-response-type", "", "Force decoding the response body as the given content type", "", false)
+	AddGlobalFlag("rsh-request-template", "", "Merge the request body into this JSON template file, can be repeated", []string{}, true)
+	AddGlobalFlag("rsh-body", "", "Use this JSON, YAML, or raw text as the whole request body instead of body arguments", "", false)
+	AddGlobalFlag("rsh-validate", "", "Validate request bodies against the API description before sending", false, false)
 
 	Root.RegisterFlagCompletionFunc("rsh-output-format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		return []string{"auto", "json", "yaml"}, cobra.ShellCompDirectiveNoFileComp
+		return []string{"auto", "json", "yaml", "csv"}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	Root.RegisterFlagCompletionFunc("rsh-response-type", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		types := []string{}
+		for _, entry := range contentTypes {
+			types = append(types, entry.name)
+		}
+		return types, cobra.ShellCompDirectiveNoFileComp
 	})
 
 	Root.RegisterFlagCompletionFunc("rsh-profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -571,34 +678,209 @@ Not after (expires): %s (%s)
 	initAPIConfig()
 }
 
-func userHomeDir() string {
-	if runtime.GOOS == "windows" {
-		home := os.Getenv("HOMEDRIVE") + os.Getenv("HOMEPATH")
-		if home == "" {
-			home = os.Getenv("USERPROFILE")
+// initGlobalFlags sets up the eager global flags. Flags which are needed
+// before the configuration is loaded, like the config directory or verbose
+// output, are registered and parsed right away. The other global flags are
+// registered along with the root command's flags via `AddGlobalFlag` and
+// parsed once everything is set up.
+func initGlobalFlags() {
+	GlobalFlags = pflag.NewFlagSet("eager-flags", pflag.ContinueOnError)
+	GlobalFlags.ParseErrorsWhitelist.UnknownFlags = true
+	// GlobalFlags are 'hidden', don't print anything on error
+	GlobalFlags.Usage = func() {}
+	GlobalFlags.SetOutput(ioutil.Discard)
+	// Ensure parsing doesn't stop if the help flag is set
+	// (help seems to be special cased from ParseErrorsWhitelist.UnknownFlags)
+	GlobalFlags.BoolP("help", "h", false, "")
+
+	GlobalFlags.BoolP("rsh-verbose", "v", false, "")
+	GlobalFlags.String("rsh-config-dir", "", "")
+	GlobalFlags.String("rsh-cache-dir", "", "")
+	GlobalFlags.Parse(os.Args[1:])
+}
+
+// dirOverrides returns the directories set via `--rsh-config-dir` or
+// `RSH_CONFIG_DIR` and `--rsh-cache-dir` or `RSH_CACHE_DIR`, if any.
+func dirOverrides() (configDir string, cacheDir string) {
+	configDir, _ = GlobalFlags.GetString("rsh-config-dir")
+	cacheDir, _ = GlobalFlags.GetString("rsh-cache-dir")
+
+	if configDir == "" {
+		configDir = os.Getenv("RSH_CONFIG_DIR")
+	}
+
+	if cacheDir == "" {
+		cacheDir = os.Getenv("RSH_CACHE_DIR")
+	}
+
+	return
+}
+
+// appDirs returns the default configuration and cache directories for the
+// app. These follow the platform conventions, e.g. `$XDG_CONFIG_HOME/restish`
+// and `$XDG_CACHE_HOME/restish` on Linux or `%AppData%\restish` on Windows.
+func appDirs(appName string) (configPath string, cachePath string) {
+	// Without a home directory (e.g. in some containers) there is nowhere
+	// permanent to store things, so fall back to a temporary directory.
+	configPath = filepath.Join(os.TempDir(), appName)
+	cachePath = configPath
+
+	if dir, err := os.UserConfigDir(); err == nil {
+		configPath = filepath.Join(dir, appName)
+	}
+
+	if dir, err := os.UserCacheDir(); err == nil {
+		cachePath = filepath.Join(dir, appName)
+	}
+
+	return
+}
+
+// isConfigFile returns whether a file in the legacy directory belongs in the
+// config directory rather than the cache directory.
+func isConfigFile(name string) bool {
+	return name == "apis.json" || strings.HasPrefix(name, "config.")
+}
+
+// copyPath copies a file or directory tree from `src` to `dest`, keeping file
+// permissions.
+func copyPath(src, dest string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
 		}
-		return home
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+// migrateCopy copies legacy files into place and may be replaced in tests.
+var migrateCopy = copyPath
+
+// migrateLegacyDir moves files from the legacy `~/.appname` directory into
+// the new config and cache directories. Everything is copied before any
+// legacy files are removed, and copies are rolled back if one fails, so the
+// legacy directory continues to be used for everything with no data lost.
+// Files which already exist in the new directories are left in place.
+func migrateLegacyDir(appName, configPath, cachePath string) (string, string) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return configPath, cachePath
+	}
+
+	legacy := filepath.Join(home, "."+appName)
+	if legacy == configPath || legacy == cachePath {
+		return configPath, cachePath
+	}
+
+	files, err := ioutil.ReadDir(legacy)
+	if err != nil {
+		// No legacy directory, nothing to do!
+		return configPath, cachePath
 	}
-	return os.Getenv("HOME")
+
+	for _, dir := range []string{configPath, cachePath} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return legacy, legacy
+		}
+	}
+
+	copied := []string{}
+	moved := []string{}
+	skipped := []string{}
+	for _, file := range files {
+		src := filepath.Join(legacy, file.Name())
+		dest := filepath.Join(cachePath, file.Name())
+		if isConfigFile(file.Name()) {
+			dest = filepath.Join(configPath, file.Name())
+		}
+
+		if _, err := os.Stat(dest); err == nil {
+			// Never overwrite newer data.
+			skipped = append(skipped, file.Name())
+			continue
+		}
+
+		if err := migrateCopy(src, dest); err != nil {
+			LogWarning("Unable to move %s from legacy directory %s: %v", file.Name(), legacy, err)
+			for _, c := range append(copied, dest) {
+				os.RemoveAll(c)
+			}
+			return legacy, legacy
+		}
+		copied = append(copied, dest)
+		moved = append(moved, src)
+	}
+
+	// Only remove what was copied, so skipped files are never lost.
+	for _, src := range moved {
+		if err := os.RemoveAll(src); err != nil {
+			LogWarning("Unable to remove %s from legacy directory: %v", src, err)
+		}
+	}
+
+	if len(skipped) > 0 {
+		LogWarning("Kept %s in legacy directory %s as newer files exist, remove it once they are no longer needed", strings.Join(skipped, ", "), legacy)
+	} else if err := os.Remove(legacy); err != nil {
+		LogWarning("Unable to remove legacy directory %s: %v", legacy, err)
+	}
+	LogDebug("Moved files from legacy directory %s", legacy)
+
+	return configPath, cachePath
 }
 
 func cacheDir() string {
-	return path.Join(userHomeDir(), "."+viper.GetString("app-name"))
+	return viper.GetString("cache-directory")
 }
 
 func initConfig(appName, envPrefix string) {
-	// One-time setup to ensure the path exists so we can write files into it
-	// later as needed.
-	configDir := path.Join(userHomeDir(), "."+appName)
-	if err := os.MkdirAll(configDir, 0700); err != nil {
-		panic(err)
+	configPath, cachePath := appDirs(appName)
+	configOverride, cacheOverride := dirOverrides()
+	if configOverride == "" && cacheOverride == "" {
+		configPath, cachePath = migrateLegacyDir(appName, configPath, cachePath)
+	}
+
+	// A config directory holds everything unless the cache is moved elsewhere.
+	if configOverride != "" {
+		configPath, cachePath = configOverride, configOverride
+	}
+
+	if cacheOverride != "" {
+		cachePath = cacheOverride
+	}
+
+	// One-time setup to ensure the paths exist so we can write files into
+	// them later as needed.
+	for _, dir := range []string{configPath, cachePath} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			panic(err)
+		}
 	}
 
 	// Load configuration from file(s) if provided.
 	viper.SetConfigName("config")
 	viper.AddConfigPath("/etc/" + appName + "/")
-	viper.AddConfigPath("$HOME/." + appName + "/")
-	viper.ReadInConfig()
+	viper.AddConfigPath(configPath)
+	if err := viper.ReadInConfig(); err == nil {
+		LogDebug("Loaded config file %s", viper.ConfigFileUsed())
+	} else {
+		LogDebug("Not using a config file: %v", err)
+	}
+	loadGlobalDefaults()
 
 	// Load configuration from the environment if provided. Flags below get
 	// transformed automatically, e.g. `client-id` -> `PREFIX_CLIENT_ID`.
@@ -606,27 +888,85 @@ func initConfig(appName, envPrefix string) {
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	viper.AutomaticEnv()
 
+	if viper.GetBool("rsh-verbose") {
+		// Set in the config file.
+		enableVerbose = true
+	}
+	LogDebug("Using config directory %s and cache directory %s", configPath, cachePath)
+
 	// Save a few things that will be useful elsewhere.
 	viper.Set("app-name", appName)
-	viper.Set("config-directory", configDir)
+	viper.Set("config-directory", configPath)
+	viper.Set("cache-directory", cachePath)
 	viper.SetDefault("server-index", 0)
 }
 
-func initCache(appName string) {
+// globalDefaults holds the headers and query params from the global config
+// which are sent with every request, underneath any profile values.
+var globalDefaults APIProfile
+
+// loadGlobalDefaults loads the `headers` and `query` maps from the global
+// config. Viper lowercases keys, so the file is read directly when possible to
+// keep the case of query param names.
+func loadGlobalDefaults() {
+	globalDefaults = APIProfile{}
+
+	filename := viper.ConfigFileUsed()
+	if filename == "" {
+		return
+	}
+
+	if b, err := ioutil.ReadFile(filename); err == nil {
+		var defaults struct {
+			Headers map[string]string `yaml:"headers"`
+			Query   map[string]string `yaml:"query"`
+		}
+		if err := yaml.Unmarshal(b, &defaults); err == nil {
+			globalDefaults.Headers = defaults.Headers
+			globalDefaults.Query = defaults.Query
+			return
+		}
+	}
+
+	globalDefaults.Headers = viper.GetStringMapString("headers")
+	globalDefaults.Query = viper.GetStringMapString("query")
+}
+
+// cacheFile returns the path of the cache file.
+func cacheFile() string {
+	return path.Join(cacheDir(), "cache.json")
+}
+
+// cacheSnapshot holds the flattened cache values as last read from disk, so
+// that saving only writes the values which have since changed.
+var cacheSnapshot map[string]interface{}
+
+// loadCache (re)loads the cache from disk, discarding any unsaved values.
+func loadCache() error {
 	Cache = viper.New()
 	Cache.SetConfigName("cache")
-	Cache.AddConfigPath(viper.GetString("config-directory"))
+	Cache.AddConfigPath(cacheDir())
+	err := Cache.ReadInConfig()
+	cacheSnapshot = flatten(Cache.AllSettings(), ".").(map[string]interface{})
+	return err
+}
 
+func initCache(appName string) {
 	// Write a blank cache if no file is already there. Later you can use
-	// cli.Cache.SaveConfig() to write new values.
-	filename := path.Join(viper.GetString("config-directory"), "cache.json")
+	// cli.SaveCache() to write new values.
+	filename := cacheFile()
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
 		if err := ioutil.WriteFile(filename, []byte("{}"), 0600); err != nil {
 			panic(err)
 		}
 	}
 
-	Cache.ReadInConfig()
+	loadCache()
+	LogDebug("Loaded cache %s", filename)
+
+	if err := rewriteCache(migrateCacheData); err != nil {
+		panic(err)
+	}
 }
 
 // Defaults adds the default encodings, content types, and link parsers to
@@ -635,15 +975,29 @@ func Defaults() {
 	// Register content encodings
 	AddEncoding("gzip", &GzipEncoding{})
 	AddEncoding("br", &BrotliEncoding{})
+	AddEncoding("deflate", &DeflateEncoding{})
 
 	// Register content type marshallers
+	// gRPC-Web goes first so `+json` responses aren't decoded as plain JSON.
+	// It is only used for responses which claim to be gRPC-Web, so it isn't
+	// added to the `Accept` header.
+	AddContentType("application/grpc-web+json", 0, &GRPCWeb{})
 	AddContentType("application/cbor", 0.9, &CBOR{})
 	AddContentType("application/msgpack", 0.8, &MsgPack{})
 	AddContentType("application/ion", 0.6, &Ion{})
 	AddContentType("application/json", 0.5, &JSON{})
 	AddContentType("application/yaml", 0.5, &YAML{})
+	AddContentType("application/xml", 0.3, &XML{})
+	AddContentType("application/x-protobuf", 0, &Protobuf{})
+	AddContentType("application/x-ndjson", 0, &NDJSON{})
+	AddContentType("text/csv", 0, &CSV{})
 	AddContentType("text/*", 0.2, &Text{})
 
+	// Register pretty printers for the default output
+	AddPrettyPrinter("html", &HTMLPrinter{})
+	AddPrettyPrinter("xml", &XMLPrinter{})
+	AddPrettyPrinter("", &CSVPrinter{})
+
 	// Add link relation parsers
 	AddLinkParser(&LinkHeaderParser{})
 	AddLinkParser(&HALParser{})
@@ -652,6 +1006,8 @@ func Defaults() {
 
 	// Register auth schemes
 	AddAuth("http-basic", &BasicAuth{})
+	AddAuth("jwt", &JWTAuth{})
+	AddAuth("bearer-file", &BearerFileAuth{})
 }
 
 // Run the CLI! Parse arguments, make requests, print responses.
@@ -697,9 +1053,15 @@ func Run() {
 	if query, _ := GlobalFlags.GetStringSlice("rsh-query"); len(query) > 0 {
 		viper.Set("rsh-query", query)
 	}
-	if headers, _ := GlobalFlags.GetStringSlice("rsh-header"); len(headers) > 0 {
+	if headers, _ := GlobalFlags.GetStringArray("rsh-header"); len(headers) > 0 {
 		viper.Set("rsh-header", headers)
 	}
+	if weights, _ := GlobalFlags.GetStringSlice("rsh-accept-weight"); len(weights) > 0 {
+		viper.Set("rsh-accept-weight", weights)
+	}
+	if templates, _ := GlobalFlags.GetStringSlice("rsh-request-template"); len(templates) > 0 {
+		viper.Set("rsh-request-template", templates)
+	}
 
 	// Now that global flags are parsed we can enable verbose mode if requested.
 	if viper.GetBool("rsh-verbose") {
@@ -714,10 +1076,13 @@ func Run() {
 			// The explicit `help` command is followed by the actual commands
 			// you want help with. The first one is the API name.
 			apiName = args[2]
+		} else if apiName == "request" && len(args) > 3 && args[2] == "inspect" {
+			// Inspecting a request needs the API's operations.
+			apiName = args[3]
 		}
 
 		loaded := false
-		if apiName != "help" && apiName != "head" && apiName != "options" && apiName != "get" && apiName != "post" && apiName != "put" && apiName != "patch" && apiName != "delete" && apiName != "api" && apiName != "links" && apiName != "edit" && apiName != "auth-header" {
+		if apiName != "help" && apiName != "head" && apiName != "options" && apiName != "get" && apiName != "post" && apiName != "put" && apiName != "patch" && apiName != "delete" && apiName != "api" && apiName != "links" && apiName != "edit" && apiName != "auth-header" && apiName != "export" && apiName != "changelog" && apiName != "discover" && apiName != "curl-import" && apiName != "perf" && apiName != "benchmark" && apiName != "cache" && apiName != "body" && apiName != "response" && apiName != "pipeline" && apiName != "mock" && apiName != "save" && apiName != "saved" && apiName != "migrate" && apiName != "schema" && apiName != "diff-profile" && apiName != "headers" && apiName != "content-types" && apiName != "status" && apiName != "apis" && apiName != "format" && apiName != "config" && apiName != "request" && apiName != "import" && apiName != "auth" {
 			// Try to find the registered config for this API. If not found,
 			// there is no need to do anything since the normal flow will catch
 			// the command being missing and print help.
@@ -725,6 +1090,12 @@ func Run() {
 				currentConfig = cfg
 				for _, cmd := range Root.Commands() {
 					if cmd.Use == apiName {
+						if len(args) > 2 && args[1] == apiName && addSavedCommand(cmd, apiName, args[2]) {
+							// Imported requests are run without loading the API.
+							loaded = true
+							break
+						}
+
 						if _, err := Load(cfg.Base, cmd); err != nil {
 							panic(err)
 						}
@@ -738,7 +1109,7 @@ func Run() {
 		if !loaded {
 			// This could be a URL or short-name as part of a URL for generic
 			// commands. We should load the config for shell completion.
-			if apiName == "head" || apiName == "options" || apiName == "get" || apiName == "post" || apiName == "put" || apiName == "patch" || apiName == "delete" && len(args) > 2 {
+			if (apiName == "head" || apiName == "options" || apiName == "get" || apiName == "post" || apiName == "put" || apiName == "patch" || apiName == "delete") && len(args) > 2 {
 				apiName = args[2]
 			}
 			apiName = fixAddress(apiName)
@@ -749,14 +1120,31 @@ func Run() {
 	}
 
 	// Phew, we made it. Execute the command now that everything is loaded
-	// and all the relevant sub-commands are registered.
+	// and all the relevant sub-commands are registered. Metrics are written
+	// last, even if the command failed, after waiting for log hooks to finish.
 	defer func() {
-		if err := recover(); err != nil {
-			LogError("Caught error: %v", err)
-			LogDebug("%s", string(debug.Stack()))
+		waitLogHooks()
+		if err := WriteMetrics(); err != nil {
+			LogWarning("Unable to write metrics: %v", err)
 		}
 	}()
-	if err := Root.Execute(); err != nil {
-		LogError("Error: %v", err)
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+			reportError(err, true)
+		}
+	}()
+	if cmd, err := Root.ExecuteC(); err != nil {
+		if !cmd.SilenceUsage {
+			// The command never ran, so the arguments or flags are invalid.
+			err = &ExitError{Code: ExitCodeUsage, Err: err}
+		}
+		reportError(err, false)
 	}
+
+	// Now that output has been written, refresh any stale cached responses.
+	RevalidateStale()
 }
@@ -1,7 +1,9 @@
 package cli
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"strings"
@@ -91,6 +93,33 @@ func TestPutURI400(t *testing.T) {
 	}`)
 }
 
+func TestResponseTypeOverride(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/foo").Reply(200).SetHeader("Content-Type", "text/plain").BodyString(`{"hello": "world"}`)
+
+	expectJSON(t, "http://example.com/foo --rsh-response-type application/json", `{
+		"hello": "world"
+	}`)
+
+	// A forced decoder which fails is reported.
+	gock.New("http://example.com").Get("/foo").Reply(200).SetHeader("Content-Type", "text/plain").BodyString(`not json`)
+	out := run("http://example.com/foo --rsh-response-type application/json")
+	assert.Contains(t, out, "WARN: Unable to decode response as application/json")
+
+	// Internal requests, e.g. to discover an API, ignore the override.
+	reset(false)
+	viper.Set("rsh-response-type", "text/plain")
+	defer viper.Set("rsh-response-type", "")
+	gock.New("http://example.com").Get("/foo").Reply(200).JSON(map[string]interface{}{"hello": "world"})
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	resp, err := MakeRequest(req)
+	assert.NoError(t, err)
+	parsed, err := ParseResponse(resp)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"hello": "world"}, parsed.Body)
+}
+
 type TestAuth struct{}
 
 // Parameters returns a list of OAuth2 Authorization Code inputs.
@@ -119,8 +148,14 @@ func TestAuthHeader(t *testing.T) {
 				},
 			},
 			"no-auth": {},
+			"failing": {
+				Auth: &APIAuth{
+					Name: "hook-fail",
+				},
+			},
 		},
 	}
+	authHandlers["hook-fail"] = &authHookFailure{}
 
 	captured := runNoReset("auth-header bad-api")
 	assert.Contains(t, captured, "No matched API")
@@ -133,6 +168,12 @@ func TestAuthHeader(t *testing.T) {
 
 	captured = runNoReset("auth-header test-auth-header -p no-auth")
 	assert.Contains(t, captured, "No auth set up")
+
+	// Auth failures are reported rather than crashing.
+	captured = runNoReset("auth-header test-auth-header -p failing")
+	assert.Contains(t, captured, "auth hook-fail failed: some-error")
+	assert.NotContains(t, captured, "panic")
+	assert.Equal(t, ExitCodeClientError, GetExitCode())
 }
 
 func TestLinks(t *testing.T) {
@@ -182,7 +223,7 @@ func TestHelpHighlight(t *testing.T) {
 
 func TestLoadCache(t *testing.T) {
 	// Invalidate any existin cache.
-	Cache.Set("cache-test.expires", time.Now().Add(-24*time.Hour))
+	Cache.Set(apiCacheKey("cache-test", "expires"), time.Now().Add(-24*time.Hour))
 	Cache.WriteConfig()
 	defer gock.Off()
 
@@ -222,6 +263,50 @@ func TestLoadCache(t *testing.T) {
 	runNoReset("cache-test --help")
 }
 
+type countingLoader struct {
+	testLoader
+	count int
+}
+
+func (l *countingLoader) Load(entrypoint, spec url.URL, resp *http.Response) (API, error) {
+	l.count++
+	return l.testLoader.Load(entrypoint, spec, resp)
+}
+
+func TestLoadCacheUnchangedSpec(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://unchanged.example.com/openapi.json").Times(2).Reply(200).SetHeader("ETag", `"abc"`).JSON(map[string]interface{}{
+		"openapi": "3.0.0",
+	})
+	gock.New("https://unchanged.example.com/").Times(2).Reply(404)
+
+	reset(false)
+	configs["unchanged-test"] = &APIConfig{
+		name: "unchanged-test",
+		Base: "https://unchanged.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {},
+		},
+	}
+	Root.AddCommand(&cobra.Command{
+		Use: "unchanged-test",
+	})
+
+	loader := &countingLoader{testLoader: testLoader{API: API{Short: "Unchanged API"}}}
+	AddLoader(loader)
+
+	runNoReset("unchanged-test --help")
+	assert.Equal(t, 1, loader.count)
+
+	// Expire the cache. The spec hasn't changed, so it should not be parsed
+	// again.
+	Cache.Set(apiCacheKey("unchanged-test", "expires"), time.Now().Add(-time.Hour))
+	runNoReset("unchanged-test --help")
+	assert.Equal(t, 1, loader.count)
+	assert.True(t, Cache.GetTime(apiCacheKey("unchanged-test", "expires")).After(time.Now()))
+}
+
 func TestAPISync(t *testing.T) {
 	defer gock.Off()
 
@@ -243,7 +328,8 @@ func TestAPISync(t *testing.T) {
 
 func TestDuplicateAPIBase(t *testing.T) {
 	defer func() {
-		os.Remove(path.Join(userHomeDir(), ".test", "apis.json"))
+		configPath, _ := appDirs("test")
+		os.Remove(path.Join(configPath, "apis.json"))
 		reset(false)
 	}()
 	reset(false)
@@ -347,3 +433,164 @@ func TestCompletion(t *testing.T) {
 		"api.example.com/items/my-item/tags/{tag-id}\tGet tag details",
 	}, possible)
 }
+
+func TestMigrateLegacyDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	legacy := path.Join(home, ".test")
+	assert.NoError(t, os.MkdirAll(path.Join(legacy, "responses"), 0700))
+	assert.NoError(t, os.WriteFile(path.Join(legacy, "apis.json"), []byte("{}"), 0600))
+	assert.NoError(t, os.WriteFile(path.Join(legacy, "cache.json"), []byte("{}"), 0600))
+
+	configPath, cachePath := migrateLegacyDir("test", path.Join(home, "config", "test"), path.Join(home, "cache", "test"))
+
+	assert.Equal(t, path.Join(home, "config", "test"), configPath)
+	assert.Equal(t, path.Join(home, "cache", "test"), cachePath)
+	assert.FileExists(t, path.Join(configPath, "apis.json"))
+	assert.FileExists(t, path.Join(cachePath, "cache.json"))
+	assert.DirExists(t, path.Join(cachePath, "responses"))
+	assert.NoDirExists(t, legacy)
+}
+
+func TestMigrateLegacyDirSkipped(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	legacy := path.Join(home, ".test")
+	configDir := path.Join(home, "config", "test")
+	assert.NoError(t, os.MkdirAll(legacy, 0700))
+	assert.NoError(t, os.MkdirAll(configDir, 0700))
+	assert.NoError(t, os.WriteFile(path.Join(legacy, "apis.json"), []byte(`{"old-api": {}}`), 0600))
+	assert.NoError(t, os.WriteFile(path.Join(legacy, "cache.json"), []byte("{}"), 0600))
+	assert.NoError(t, os.WriteFile(path.Join(configDir, "apis.json"), []byte(`{"new-api": {}}`), 0600))
+
+	configPath, cachePath := migrateLegacyDir("test", configDir, path.Join(home, "cache", "test"))
+
+	// The newer config is kept, and the legacy one is not deleted.
+	assert.Equal(t, configDir, configPath)
+	b, _ := os.ReadFile(path.Join(configPath, "apis.json"))
+	assert.Equal(t, `{"new-api": {}}`, string(b))
+	assert.FileExists(t, path.Join(legacy, "apis.json"))
+	assert.FileExists(t, path.Join(cachePath, "cache.json"))
+	assert.NoFileExists(t, path.Join(legacy, "cache.json"))
+}
+
+func TestMigrateLegacyDirFailure(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	legacy := path.Join(home, ".test")
+	assert.NoError(t, os.MkdirAll(legacy, 0700))
+	assert.NoError(t, os.WriteFile(path.Join(legacy, "apis.json"), []byte(`{"my-api": {}}`), 0600))
+	assert.NoError(t, os.WriteFile(path.Join(legacy, "cache.json"), []byte("{}"), 0600))
+
+	// The second copy fails, e.g. because the cache is on a full disk.
+	calls := 0
+	migrateCopy = func(src, dest string) error {
+		calls++
+		if calls == 2 {
+			return errors.New("no space left on device")
+		}
+		return copyPath(src, dest)
+	}
+	defer func() {
+		migrateCopy = copyPath
+	}()
+
+	configPath, cachePath := migrateLegacyDir("test", path.Join(home, "config", "test"), path.Join(home, "cache", "test"))
+
+	// Everything stays in the legacy directory and nothing was copied.
+	assert.Equal(t, legacy, configPath)
+	assert.Equal(t, legacy, cachePath)
+	assert.FileExists(t, path.Join(legacy, "apis.json"))
+	assert.FileExists(t, path.Join(legacy, "cache.json"))
+	assert.NoFileExists(t, path.Join(home, "config", "test", "apis.json"))
+	assert.NoFileExists(t, path.Join(home, "cache", "test", "cache.json"))
+}
+
+func TestConfigDirOverride(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("RSH_CONFIG_DIR", dir)
+
+	reset(false)
+	defer func() {
+		os.Unsetenv("RSH_CONFIG_DIR")
+		reset(false)
+	}()
+
+	assert.Equal(t, dir, viper.GetString("config-directory"))
+	assert.Equal(t, dir, cacheDir())
+	assert.FileExists(t, path.Join(dir, "cache.json"))
+}
+
+func TestCacheDirOverride(t *testing.T) {
+	configDir := t.TempDir()
+	cacheDir := path.Join(t.TempDir(), "nested", "cache")
+	t.Setenv("RSH_CONFIG_DIR", configDir)
+	t.Setenv("RSH_CACHE_DIR", cacheDir)
+
+	reset(false)
+	defer func() {
+		os.Unsetenv("RSH_CONFIG_DIR")
+		os.Unsetenv("RSH_CACHE_DIR")
+		reset(false)
+	}()
+
+	assert.Equal(t, configDir, viper.GetString("config-directory"))
+	assert.Equal(t, cacheDir, viper.GetString("cache-directory"))
+	assert.FileExists(t, path.Join(cacheDir, "cache.json"))
+	assert.NoFileExists(t, path.Join(configDir, "cache.json"))
+
+	info, err := os.Stat(cacheDir)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+}
+
+func TestVerboseEarlyLogging(t *testing.T) {
+	defer gock.Off()
+	defer func() {
+		os.Args = []string{"restish"}
+		reset(false)
+		enableVerbose = false
+	}()
+
+	initWith := func(args ...string) string {
+		capture := &strings.Builder{}
+		Stderr = capture
+		os.Args = append([]string{"restish"}, args...)
+		reset(false)
+		return capture.String()
+	}
+
+	// Loading the config is logged before the flags are parsed.
+	out := initWith("-v", "get", "http://example.com/")
+	assert.Contains(t, out, "DEBUG: Using config directory")
+	assert.Contains(t, out, "DEBUG: Loaded cache")
+	assert.Regexp(t, `DEBUG: Loaded \d+ APIs from`, out)
+
+	out = initWith("get", "http://example.com/", "--rsh-verbose")
+	assert.Contains(t, out, "DEBUG: Using config directory")
+
+	out = initWith("get", "http://example.com/")
+	assert.NotContains(t, out, "DEBUG:")
+	assert.False(t, enableVerbose)
+
+	os.Setenv("RSH_VERBOSE", "1")
+	out = initWith("get", "http://example.com/")
+	os.Unsetenv("RSH_VERBOSE")
+	assert.Contains(t, out, "DEBUG: Using config directory")
+
+	// Auth applied to a request is logged as well.
+	initWith("-v")
+	configs["verbose-test"] = &APIConfig{
+		name: "verbose-test",
+		Base: "http://verbose.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {Auth: &APIAuth{Name: "http-basic", Params: map[string]string{"username": "u", "password": "p"}}},
+		},
+	}
+	gock.New("http://verbose.example.com").Get("/items").Reply(http.StatusOK)
+	out = runNoReset("-v http://verbose.example.com/items")
+	assert.Contains(t, out, "DEBUG: Auth http-basic from profile default")
+}
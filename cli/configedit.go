@@ -0,0 +1,408 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/net/http/httpguts"
+	yamlv2 "gopkg.in/yaml.v2"
+)
+
+// editErrorPrefix marks validation errors which are added as comments to the
+// top of a file being edited.
+const editErrorPrefix = "# ERROR: "
+
+// validationError holds a list of problems found in an edited config.
+type validationError []string
+
+func (e validationError) Error() string {
+	return strings.Join(e, "\n")
+}
+
+// stripEditErrors removes validation error comments from edited data.
+func stripEditErrors(data []byte) []byte {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for len(lines) > 0 && bytes.HasPrefix(lines[0], []byte(editErrorPrefix)) {
+		lines = lines[1:]
+	}
+	return bytes.Join(lines, nil)
+}
+
+// editValidated opens the data in the user's editor until the result passes
+// validation, then returns it. When validation fails, the file is re-opened
+// with the errors as comments at the top. Saving without any changes cancels
+// the edit and returns `nil`.
+func editValidated(data []byte, ext string, validate func([]byte) error) ([]byte, error) {
+	editor := getEditor()
+	if editor == "" {
+		return nil, errors.New("please set the VISUAL or EDITOR environment variable with your preferred editor")
+	}
+
+	tmp, err := os.CreateTemp("", "rsh-edit*"+ext)
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	content := data
+	for {
+		if err := os.WriteFile(tmp.Name(), content, 0600); err != nil {
+			return nil, err
+		}
+
+		if err := runEditor(editor, tmp.Name()); err != nil {
+			return nil, err
+		}
+
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		edited = stripEditErrors(edited)
+		if bytes.Equal(bytes.TrimSpace(edited), bytes.TrimSpace(stripEditErrors(content))) {
+			return nil, nil
+		}
+
+		err = validate(edited)
+		if err == nil {
+			return edited, nil
+		}
+
+		comments := ""
+		for _, line := range strings.Split(err.Error(), "\n") {
+			comments += editErrorPrefix + line + "\n"
+		}
+		content = append([]byte(comments), edited...)
+	}
+}
+
+// parseEdited decodes edited YAML into `value`. Duplicate keys, like two
+// profiles with the same name, are an error rather than silently replacing
+// each other.
+func parseEdited(data []byte, value interface{}) error {
+	var strict interface{}
+	if err := yamlv2.UnmarshalStrict(data, &strict); err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(data, value)
+}
+
+// validateAPIConfig checks an edited API config for problems which would
+// otherwise only show up when making requests.
+func validateAPIConfig(name string, config *APIConfig) error {
+	problems := validationError{}
+
+	if config.Base == "" {
+		problems = append(problems, "base is required")
+	}
+
+	for other, c := range configs {
+		if other != name && c.Base == config.Base {
+			problems = append(problems, fmt.Sprintf("base %s is already used by API %s", config.Base, other))
+		}
+	}
+
+	if config.DefaultProfile != "" && config.Profiles[config.DefaultProfile] == nil {
+		problems = append(problems, fmt.Sprintf("default_profile %s is not a configured profile", config.DefaultProfile))
+	}
+
+	profileNames := config.profileNames()
+
+	seen := map[string]string{}
+	for _, profileName := range profileNames {
+		// Config keys are case-insensitive, so these would overwrite each other.
+		if first, ok := seen[strings.ToLower(profileName)]; ok {
+			problems = append(problems, fmt.Sprintf("duplicate profiles %s and %s", first, profileName))
+		}
+		seen[strings.ToLower(profileName)] = profileName
+
+		profile := config.Profiles[profileName]
+		if profile == nil {
+			continue
+		}
+
+		for header, value := range profile.Headers {
+			if !httpguts.ValidHeaderFieldName(header) {
+				problems = append(problems, fmt.Sprintf("profile %s: invalid header name %q", profileName, header))
+			} else if !httpguts.ValidHeaderFieldValue(value) {
+				problems = append(problems, fmt.Sprintf("profile %s: invalid value for header %s", profileName, header))
+			}
+		}
+
+		// Auth may be inherited from a parent profile, so check the result.
+		resolved, err := config.resolveProfile(profileName)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+
+		if profile.Auth == nil {
+			continue
+		}
+
+		profile = resolved
+		if profile.Auth == nil || profile.Auth.Name == "" {
+			continue
+		}
+
+		handler := authHandlers[profile.Auth.Name]
+		if handler == nil {
+			problems = append(problems, fmt.Sprintf("profile %s: unknown auth handler %s", profileName, profile.Auth.Name))
+			continue
+		}
+
+		for _, param := range handler.Parameters() {
+			if param.Required && profile.Auth.Params[param.Name] == "" {
+				problems = append(problems, fmt.Sprintf("profile %s: missing required auth param %s", profileName, param.Name))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return problems
+	}
+
+	return nil
+}
+
+// editAPIConfig edits an API config as YAML and saves it when valid.
+func editAPIConfig(name string) error {
+	config := configs[name]
+	if config == nil {
+		return fmt.Errorf("API %s not found", name)
+	}
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	var edited *APIConfig
+	result, err := editValidated(data, ".yaml", func(b []byte) error {
+		edited = &APIConfig{}
+		if err := parseEdited(b, edited); err != nil {
+			return err
+		}
+		return validateAPIConfig(name, edited)
+	})
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		LogInfo("No changes made")
+		return nil
+	}
+
+	edited.name = name
+	configs[name] = edited
+	return edited.Save()
+}
+
+// validateGlobalConfig checks that global config keys are known options with
+// values of the right type.
+func validateGlobalConfig(settings map[string]interface{}) error {
+	problems := validationError{}
+
+	for key, value := range settings {
+		if key == "color" || key == "nocolor" {
+			continue
+		}
+
+		if key == "headers" || key == "query" {
+			values, ok := value.(map[string]interface{})
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s must be a map of names to values", key))
+				continue
+			}
+
+			for name, v := range values {
+				if _, ok := v.(string); !ok {
+					problems = append(problems, fmt.Sprintf("invalid value for %s %s: %v", key, name, v))
+				} else if key == "headers" && !httpguts.ValidHeaderFieldName(name) {
+					problems = append(problems, fmt.Sprintf("invalid header name %q", name))
+				}
+			}
+			continue
+		}
+
+		if key == "saved" {
+			values, ok := value.(map[string]interface{})
+			if !ok {
+				problems = append(problems, "saved must be a map of names to requests")
+				continue
+			}
+
+			for name, v := range values {
+				request, ok := v.(map[string]interface{})
+				if !ok {
+					problems = append(problems, fmt.Sprintf("invalid saved request %s: %v", name, v))
+				} else if u, ok := request["url"].(string); !ok || u == "" {
+					problems = append(problems, fmt.Sprintf("saved request %s must have a url", name))
+				}
+			}
+			continue
+		}
+
+		flag := GlobalFlags.Lookup(key)
+		if flag == nil {
+			problems = append(problems, fmt.Sprintf("unknown option %s", key))
+			continue
+		}
+
+		valid := true
+		switch flag.Value.Type() {
+		case "bool":
+			_, valid = value.(bool)
+		case "int":
+			f, ok := value.(float64)
+			valid = ok && f == float64(int64(f))
+		case "float64":
+			_, valid = value.(float64)
+		case "stringSlice", "boolSlice", "intSlice":
+			switch value.(type) {
+			case []interface{}, string:
+			default:
+				valid = false
+			}
+		default:
+			switch value.(type) {
+			case map[string]interface{}, []interface{}, nil:
+				valid = false
+			}
+		}
+
+		if !valid {
+			problems = append(problems, fmt.Sprintf("invalid value for %s: %v", key, value))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return problems
+	}
+
+	return nil
+}
+
+// globalConfigFile returns the global config file, which may not exist yet.
+func globalConfigFile() string {
+	if filename := viper.ConfigFileUsed(); filename != "" {
+		return filename
+	}
+
+	return path.Join(viper.GetString("config-directory"), "config.json")
+}
+
+// editGlobalConfig edits the global config file as YAML and saves it in its
+// original format when valid.
+func editGlobalConfig() error {
+	filename := globalConfigFile()
+
+	// Read just the file, ignoring any flags and environment variables.
+	current := viper.New()
+	current.SetConfigFile(filename)
+	if _, err := os.Stat(filename); err == nil {
+		if err := current.ReadInConfig(); err != nil {
+			return err
+		}
+	}
+
+	data := []byte{}
+	if settings := current.AllSettings(); len(settings) > 0 {
+		var err error
+		if data, err = yaml.Marshal(settings); err != nil {
+			return err
+		}
+	}
+
+	var settings map[string]interface{}
+	result, err := editValidated(data, ".yaml", func(b []byte) error {
+		settings = map[string]interface{}{}
+		if err := parseEdited(b, &settings); err != nil {
+			return err
+		}
+		return validateGlobalConfig(settings)
+	})
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		LogInfo("No changes made")
+		return nil
+	}
+
+	updated := viper.New()
+	updated.SetConfigFile(filename)
+	for k, v := range settings {
+		updated.Set(k, v)
+	}
+	return withFileLock(filename, func() error {
+		return writeConfigAtomic(updated)
+	})
+}
+
+func configCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Global configuration commands",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "edit",
+		Short: "Edit the global configuration",
+		Long:  "Open the global configuration in your editor as YAML. Unknown options and invalid values are shown as comments until fixed, and the file is only saved once valid.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := editGlobalConfig(); err != nil {
+				panic(err)
+			}
+		},
+	})
+
+	var showSecrets *bool
+	view := &cobra.Command{
+		Use:   "view [short-name]",
+		Short: "Show the effective configuration",
+		Long:  "Show the effective configuration after merging the config file, environment variables, and flags, along with where each value came from and the locations of config and cache files. Given an API, its base URI, headers, query params, and auth type for the selected profile (`-p`) are shown too. Secrets like passwords, tokens, and API keys are redacted unless `--rsh-show-secrets` is passed.",
+		Example: fmt.Sprintf(`  # Why is that header being sent to my-api?
+  $ %s config view my-api -p staging
+
+  # Attach the settings to a support ticket
+  $ %s config view my-api -o json`, Root.CommandPath(), Root.CommandPath()),
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeAPINames,
+		Run: func(cmd *cobra.Command, args []string) {
+			api := ""
+			if len(args) > 0 {
+				api = args[0]
+			}
+
+			data, err := viewConfig(api, *showSecrets)
+			if err != nil {
+				panic(err)
+			}
+
+			if err := Formatter.Format(Response{Body: data}); err != nil {
+				panic(err)
+			}
+		},
+	}
+	showSecrets = view.Flags().Bool("rsh-show-secrets", false, "Show secret values instead of redacting them")
+	cmd.AddCommand(view)
+
+	return cmd
+}
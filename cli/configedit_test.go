@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEditor sets the editor to a script which replaces the edited file with
+// each of the given documents in turn, failing if the file being edited
+// doesn't contain the matching expected text (if any).
+func fakeEditor(t *testing.T, steps ...[2]string) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\nstep=$(cat " + path.Join(dir, "step") + " 2>/dev/null || echo 0)\n"
+	for i, step := range steps {
+		doc := path.Join(dir, fmt.Sprintf("doc%d", i))
+		assert.NoError(t, os.WriteFile(doc, []byte(step[1]), 0600))
+		check := ""
+		if step[0] != "" {
+			check = fmt.Sprintf("grep -qF %q \"$1\" || exit 1; ", step[0])
+		}
+		script += fmt.Sprintf("if [ \"$step\" = %d ]; then %scp %s \"$1\"; fi\n", i, check, doc)
+	}
+	script += "echo $((step + 1)) > " + path.Join(dir, "step") + "\n"
+
+	filename := path.Join(dir, "editor.sh")
+	assert.NoError(t, os.WriteFile(filename, []byte(script), 0700))
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "sh "+filename)
+}
+
+func TestValidateAPIConfig(t *testing.T) {
+	reset(false)
+
+	config := &APIConfig{}
+	assert.NoError(t, parseEdited([]byte(`
+base: https://validate.example.com
+profiles:
+  Dev:
+    headers:
+      Bad Header: value
+  dev:
+    auth:
+      name: nope
+  prod:
+    headers:
+      X-Test: "line\nbreak"
+    auth:
+      name: http-basic
+      params:
+        username: user
+`), config))
+
+	err := validateAPIConfig("validate-test", config)
+	assert.Equal(t, validationError{
+		"duplicate profiles Dev and dev",
+		"profile Dev: invalid header name \"Bad Header\"",
+		"profile dev: unknown auth handler nope",
+		"profile prod: invalid value for header X-Test",
+		"profile prod: missing required auth param password",
+	}, err)
+
+	assert.Error(t, parseEdited([]byte("base: a\nprofiles:\n  dev: {}\n  dev: {}\n"), config))
+}
+
+func TestEditAPIConfig(t *testing.T) {
+	reset(false)
+
+	config := &APIConfig{name: "edit-test", Base: "https://edit.example.com"}
+	configs["edit-test"] = config
+	assert.NoError(t, config.Save())
+	defer removeAPI("edit-test")
+
+	// Saving without changes does nothing.
+	fakeEditor(t, [2]string{"base: https://edit.example.com", "base: https://edit.example.com\n"})
+	assert.NoError(t, editAPIConfig("edit-test"))
+	assert.Equal(t, config, configs["edit-test"])
+
+	// Invalid changes are re-opened with the errors until fixed.
+	fakeEditor(t,
+		[2]string{"base: https://edit.example.com", "base: https://edit.example.com\nprofiles:\n  default:\n    auth:\n      name: nope\n"},
+		[2]string{"# ERROR: profile default: unknown auth handler nope", "base: https://edit.example.com\nprofiles:\n  default:\n    headers:\n      X-Test: abc\n"},
+	)
+	assert.NoError(t, editAPIConfig("edit-test"))
+	assert.Equal(t, "abc", configs["edit-test"].Profiles["default"].Headers["X-Test"])
+	assert.Equal(t, "edit-test", configs["edit-test"].name)
+
+	b, err := os.ReadFile(apis.ConfigFileUsed())
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "abc")
+
+	assert.Error(t, editAPIConfig("missing"))
+}
+
+func TestEditGlobalConfig(t *testing.T) {
+	reset(false)
+
+	filename := globalConfigFile()
+	defer os.Remove(filename)
+
+	fakeEditor(t,
+		[2]string{"", "rsh-unknown: true\nrsh-verbose: yes please\n"},
+		[2]string{"# ERROR: unknown option rsh-unknown", "rsh-profile: staging\n"},
+	)
+	assert.NoError(t, editGlobalConfig())
+
+	b, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"rsh-profile": "staging"}`, string(b))
+}
+
+func TestValidateGlobalDefaults(t *testing.T) {
+	reset(false)
+
+	assert.NoError(t, validateGlobalConfig(map[string]interface{}{
+		"headers": map[string]interface{}{"X-Request-Source": "cli"},
+		"query":   map[string]interface{}{"team": "core"},
+	}))
+
+	err := validateGlobalConfig(map[string]interface{}{
+		"headers": map[string]interface{}{"Bad Header": "cli"},
+		"query":   "team=core",
+	})
+	assert.EqualError(t, err, "invalid header name \"Bad Header\"\nquery must be a map of names to values")
+
+	assert.NoError(t, validateGlobalConfig(map[string]interface{}{
+		"saved": map[string]interface{}{
+			"weather": map[string]interface{}{"method": "GET", "url": "https://example.com/weather"},
+		},
+	}))
+
+	err = validateGlobalConfig(map[string]interface{}{
+		"saved": map[string]interface{}{
+			"weather": map[string]interface{}{"method": "GET"},
+		},
+	})
+	assert.EqualError(t, err, "saved request weather must have a url")
+}
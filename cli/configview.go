@@ -0,0 +1,315 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// settingSource describes where the effective value of a global option came
+// from, in order of precedence: a command-line flag, an environment variable,
+// the global config file, or the built-in default.
+func settingSource(name string) string {
+	if f := GlobalFlags.Lookup(name); f != nil && f.Changed {
+		return "flag --" + name
+	}
+
+	if f := Root.PersistentFlags().Lookup(name); f != nil && f.Changed {
+		return "flag --" + name
+	}
+
+	env := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	if _, ok := os.LookupEnv(env); ok {
+		return "env " + env
+	}
+
+	if viper.InConfig(name) {
+		return "file " + viper.ConfigFileUsed()
+	}
+
+	return "default"
+}
+
+// redactParam redacts the value of a `name<sep>value` param like a header or
+// query flag if its name is likely to hold a secret.
+func redactParam(param, sep string) string {
+	parts := strings.SplitN(param, sep, 2)
+	if len(parts) == 2 && strings.TrimSpace(parts[1]) != "" && isSecretName(strings.TrimSpace(parts[0])) {
+		return parts[0] + sep + " REDACTED"
+	}
+	return param
+}
+
+// viewSettings returns the effective value and source of each global option.
+// Secrets in headers and query params set via flags are redacted unless
+// `showSecrets` is set.
+func viewSettings(showSecrets bool) map[string]interface{} {
+	settings := map[string]interface{}{}
+	GlobalFlags.VisitAll(func(f *pflag.Flag) {
+		if f.Name == "help" {
+			return
+		}
+
+		value := viper.Get(f.Name)
+		if !showSecrets && (f.Name == "rsh-header" || f.Name == "rsh-query") {
+			sep := ":"
+			if f.Name == "rsh-query" {
+				sep = "="
+			}
+			redacted := []interface{}{}
+			for _, param := range viper.GetStringSlice(f.Name) {
+				redacted = append(redacted, strings.TrimSpace(redactParam(param, sep)))
+			}
+			value = redacted
+		}
+
+		settings[f.Name] = map[string]interface{}{
+			"value":  value,
+			"source": settingSource(f.Name),
+		}
+	})
+
+	return settings
+}
+
+// definingProfile returns the name of the profile in the inheritance chain
+// starting at `name` which sets the header or query param `key`.
+func definingProfile(config *APIConfig, name, key string, header bool) string {
+	for current, seen := name, map[string]bool{}; !seen[current]; {
+		seen[current] = true
+		profile := config.Profiles[current]
+		if profile == nil {
+			break
+		}
+
+		values := profile.Query
+		if header {
+			values = profile.Headers
+		}
+		for k := range values {
+			if k == key || (header && strings.EqualFold(k, key)) {
+				return current
+			}
+		}
+
+		if profile.Extends == "" {
+			break
+		}
+		current = profile.Extends
+	}
+
+	return name
+}
+
+// viewValues describes the merged global and profile headers or query params
+// along with the flags which replace them, as sent with a request.
+func viewValues(config *APIConfig, profile *APIProfile, profileName string, header bool, showSecrets bool) map[string]interface{} {
+	global, values := globalDefaults.Query, profile.Query
+	flag, sep := "rsh-query", "="
+	if header {
+		global, values = globalDefaults.Headers, profile.Headers
+		flag, sep = "rsh-header", ":"
+	}
+
+	merged, sources := configDefaults(global, values, profileName, header)
+	if !showSecrets {
+		merged = redactValues(merged)
+	}
+
+	// Flags replace any config values with the same name.
+	flagValues := map[string][]string{}
+	for _, param := range viper.GetStringSlice(flag) {
+		parts := strings.SplitN(param, sep, 2)
+		name := strings.TrimSpace(parts[0])
+		if header {
+			name = http.CanonicalHeaderKey(name)
+		}
+		value := ""
+		if len(parts) > 1 {
+			value = strings.TrimSpace(parts[1])
+		}
+		if !showSecrets && value != "" && isSecretName(name) {
+			value = "REDACTED"
+		}
+		flagValues[name] = append(flagValues[name], value)
+	}
+
+	result := map[string]interface{}{}
+	for name, value := range merged {
+		key := name
+		if header {
+			key = http.CanonicalHeaderKey(name)
+		}
+		if _, ok := flagValues[key]; ok {
+			continue
+		}
+		source := sources[name]
+		if source != "global config" {
+			source = "profile " + definingProfile(config, profileName, name, header)
+		}
+		result[key] = map[string]interface{}{
+			"value":  value,
+			"source": source,
+		}
+	}
+
+	for name, values := range flagValues {
+		if strings.Join(values, "") == "" {
+			// An empty flag value removes the header or param.
+			continue
+		}
+		result[name] = map[string]interface{}{
+			"value":  strings.Join(values, ", "),
+			"source": settingSource(flag),
+		}
+	}
+
+	return result
+}
+
+// viewAPI returns the effective settings of an API for the selected profile,
+// like its base URI, headers, query params, and auth type.
+func viewAPI(name string, showSecrets bool) (map[string]interface{}, error) {
+	config := configs[name]
+	if config == nil {
+		return nil, fmt.Errorf("API %s not found", name)
+	}
+
+	profileName := selectedProfile(config)
+	profileSource := settingSource("rsh-profile")
+	if config.DefaultProfile != "" && !profileExplicit() {
+		profileSource = "API default profile"
+	}
+
+	profile, err := config.resolveProfile(profileName)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		if profileName != "default" {
+			return nil, unknownProfileError(name, config, profileName)
+		}
+		profile = &APIProfile{}
+	}
+
+	base := config.profileBase(profileName)
+	baseSource := "API config"
+	if profile.Base != "" {
+		baseSource = "profile " + profileName
+	}
+	if server := viper.GetString("rsh-server"); server != "" {
+		base = server
+		baseSource = settingSource("rsh-server")
+	}
+
+	view := map[string]interface{}{
+		"name": name,
+		"base": map[string]interface{}{
+			"value":  base,
+			"source": baseSource,
+		},
+		"profile": map[string]interface{}{
+			"value":  profileName,
+			"source": profileSource,
+		},
+		"headers": viewValues(config, profile, profileName, true, showSecrets),
+		"query":   viewValues(config, profile, profileName, false, showSecrets),
+	}
+
+	if len(config.SpecFiles) > 0 {
+		view["spec_files"] = config.SpecFiles
+	}
+
+	agentSource := "default"
+	if viper.GetString("rsh-user-agent") != "" {
+		agentSource = settingSource("rsh-user-agent")
+	} else if config.UserAgent != "" {
+		agentSource = "API config"
+	}
+	view["user_agent"] = map[string]interface{}{
+		"value":  userAgent(config),
+		"source": agentSource,
+	}
+
+	if profile.Auth != nil && profile.Auth.Name != "" {
+		params := profile.Auth.Params
+		if !showSecrets {
+			params = redactValues(params)
+		}
+		view["auth"] = map[string]interface{}{
+			"type":   profile.Auth.Name,
+			"params": params,
+		}
+	}
+
+	if config.TLS != nil && *config.TLS != (TLSConfig{}) {
+		view["tls"] = map[string]interface{}{
+			"insecure": config.TLS.InsecureSkipVerify,
+			"cert":     config.TLS.Cert,
+			"key":      config.TLS.Key,
+			"ca_cert":  config.TLS.CACert,
+		}
+	}
+
+	return view, nil
+}
+
+// viewConfig returns the effective configuration, optionally including an
+// API's settings for the selected profile.
+func viewConfig(api string, showSecrets bool) (map[string]interface{}, error) {
+	configFile := viper.ConfigFileUsed()
+	if configFile == "" {
+		configFile = "none"
+	}
+
+	view := map[string]interface{}{
+		"files": map[string]interface{}{
+			"config":           configFile,
+			"apis":             apis.ConfigFileUsed(),
+			"config_directory": viper.GetString("config-directory"),
+			"cache":            cacheFile(),
+			"cache_directory":  cacheDir(),
+			"response_cache":   responseCacheDir(),
+		},
+		"settings": viewSettings(showSecrets),
+	}
+
+	global := map[string]interface{}{}
+	if len(globalDefaults.Headers) > 0 {
+		global["headers"] = globalDefaults.Headers
+		if !showSecrets {
+			global["headers"] = redactValues(globalDefaults.Headers)
+		}
+	}
+	if len(globalDefaults.Query) > 0 {
+		global["query"] = globalDefaults.Query
+		if !showSecrets {
+			global["query"] = redactValues(globalDefaults.Query)
+		}
+	}
+	if len(global) > 0 {
+		view["global"] = global
+	}
+
+	if api != "" {
+		apiView, err := viewAPI(api, showSecrets)
+		if err != nil {
+			return nil, err
+		}
+		view["api"] = apiView
+	} else {
+		names := []string{}
+		for name := range configs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		view["apis"] = names
+	}
+
+	return view, nil
+}
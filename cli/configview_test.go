@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigView(t *testing.T) {
+	defer func() {
+		reset(false)
+		globalDefaults = APIProfile{}
+	}()
+
+	filename := path.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("rsh-sort-keys: false\nheaders:\n  X-Source: cli\n"), 0600))
+
+	config := &APIConfig{
+		name: "view-test",
+		Base: "https://view.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {
+				Headers: map[string]string{"Authorization": "Bearer abc", "X-Tenant": "t1"},
+				Query:   map[string]string{"api_key": "k123"},
+				Auth:    &APIAuth{Name: "http-basic", Params: map[string]string{"username": "me", "password": "shh"}},
+			},
+			"staging": {
+				Extends: "default",
+				Base:    "https://staging.view.example.com",
+				Headers: map[string]string{"X-Tenant": "t2"},
+			},
+		},
+	}
+
+	os.Setenv("RSH_TRACE_HEADER", "x-trace")
+	defer os.Unsetenv("RSH_TRACE_HEADER")
+
+	view := func(args string) map[string]interface{} {
+		reset(false)
+		viper.SetConfigFile(filename)
+		assert.NoError(t, viper.ReadInConfig())
+		loadGlobalDefaults()
+		configs["view-test"] = config
+
+		out := runNoReset("config view " + args + " -o json")
+		var parsed struct {
+			Body map[string]interface{} `json:"body"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(out), &parsed), out)
+		return parsed.Body
+	}
+
+	body := view("view-test -p staging -H X-Debug:1 -H X-Api-Key:flag-secret")
+	setting := func(name string) interface{} {
+		return body["settings"].(map[string]interface{})[name]
+	}
+	assert.Equal(t, map[string]interface{}{"value": "x-trace", "source": "env RSH_TRACE_HEADER"}, setting("rsh-trace-header"))
+	assert.Equal(t, map[string]interface{}{"value": false, "source": "file " + filename}, setting("rsh-sort-keys"))
+	assert.Equal(t, map[string]interface{}{"value": "staging", "source": "flag --rsh-profile"}, setting("rsh-profile"))
+	assert.Equal(t, map[string]interface{}{"value": false, "source": "default"}, setting("rsh-insecure"))
+	assert.Equal(t, []interface{}{"X-Debug:1", "X-Api-Key: REDACTED"}, setting("rsh-header").(map[string]interface{})["value"])
+	assert.Equal(t, filename, body["files"].(map[string]interface{})["config"])
+
+	api := body["api"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"value": "https://staging.view.example.com", "source": "profile staging"}, api["base"])
+	assert.Equal(t, map[string]interface{}{
+		"Authorization": map[string]interface{}{"value": "REDACTED", "source": "profile default"},
+		"X-Tenant":      map[string]interface{}{"value": "t2", "source": "profile staging"},
+		"X-Source":      map[string]interface{}{"value": "cli", "source": "global config"},
+		"X-Debug":       map[string]interface{}{"value": "1", "source": "flag --rsh-header"},
+		"X-Api-Key":     map[string]interface{}{"value": "REDACTED", "source": "flag --rsh-header"},
+	}, api["headers"])
+	assert.Equal(t, map[string]interface{}{
+		"api_key": map[string]interface{}{"value": "REDACTED", "source": "profile default"},
+	}, api["query"])
+	assert.Equal(t, map[string]interface{}{
+		"type":   "http-basic",
+		"params": map[string]interface{}{"username": "me", "password": "REDACTED"},
+	}, api["auth"])
+
+	// Secrets can be shown, e.g. to check a token is the expected one.
+	body = view("view-test --rsh-show-secrets")
+	api = body["api"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"value": "default", "source": "default"}, api["profile"])
+	assert.Equal(t, "Bearer abc", api["headers"].(map[string]interface{})["Authorization"].(map[string]interface{})["value"])
+	assert.Equal(t, "shh", api["auth"].(map[string]interface{})["params"].(map[string]interface{})["password"])
+
+	// Without an API the configured API names are listed.
+	body = view("")
+	assert.Contains(t, body["apis"], "view-test")
+	assert.Equal(t, map[string]interface{}{"headers": map[string]interface{}{"X-Source": "cli"}}, body["global"])
+
+	config.DefaultProfile = "staging"
+	body = view("view-test")
+	assert.Equal(t, map[string]interface{}{"value": "staging", "source": "API default profile"}, body["api"].(map[string]interface{})["profile"])
+
+	assert.Contains(t, run("config view missing-api"), "API missing-api not found")
+}
@@ -1,21 +1,40 @@
 package cli
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"reflect"
+	"strconv"
 	"strings"
 
+	"github.com/alexeyco/simpletable"
 	"github.com/amzn/ion-go/ion"
 	"github.com/fxamacker/cbor/v2"
 	"github.com/shamaton/msgpack/v2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"gopkg.in/yaml.v2"
 )
 
-// ContentType is used to marshal/unmarshal data to various formats.
+// ContentType is used to marshal/unmarshal data to various formats. Register
+// custom formats with `AddContentType` to use them for request bodies,
+// response decoding, and the `Accept` header.
 type ContentType interface {
+	// Detect returns whether the handler supports a content type, which may
+	// include parameters like `; charset=utf-8`.
 	Detect(contentType string) bool
+
+	// Marshal encodes a request body. Handlers which can only decode return an
+	// error.
 	Marshal(value interface{}) ([]byte, error)
+
+	// Unmarshal decodes a response body into a pointer to an `interface{}`,
+	// which should be set to maps, lists, and scalars so it can be filtered
+	// and formatted like JSON.
 	Unmarshal(data []byte, value interface{}) error
 }
 
@@ -29,7 +48,9 @@ type contentTypeEntry struct {
 var contentTypes []contentTypeEntry = []contentTypeEntry{}
 
 // AddContentType adds a new content type marshaller with the given default
-// content type name and q factor (0-1.0, higher has priority).
+// content type name and q factor (0-1.0, higher has priority). Handlers are
+// checked in the order they are added, and a q factor of zero decodes the type
+// without requesting it.
 func AddContentType(name string, q float32, ct ContentType) {
 	contentTypes = append(contentTypes, contentTypeEntry{
 		name: name,
@@ -38,11 +59,109 @@ func AddContentType(name string, q float32, ct ContentType) {
 	})
 }
 
-func buildAcceptHeader() string {
-	accept := []string{}
+// acceptWeights returns q factor overrides from the `rsh-accept-weight`
+// config, which contains entries like `application/cbor=0.5`.
+func acceptWeights() map[string]float64 {
+	weights := map[string]float64{}
+	for _, entry := range viper.GetStringSlice("rsh-accept-weight") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			LogWarning("Invalid accept weight %s, expected e.g. application/cbor=0.5", entry)
+			continue
+		}
+
+		q, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 32)
+		if err != nil || q < 0 || q > 1 {
+			LogWarning("Invalid accept weight %s, must be between 0 and 1", entry)
+			continue
+		}
 
+		weights[strings.TrimSpace(parts[0])] = q
+	}
+	return weights
+}
+
+// acceptType is a content type and its q factor in the `Accept` header.
+type acceptType struct {
+	name string
+	q    float64
+}
+
+// registeredWeight returns the q factor of the content type which handles the
+// given type, e.g. `application/yaml` for `text/yaml`, or 1.0 for unknown
+// types.
+func registeredWeight(name string) float64 {
 	for _, entry := range contentTypes {
-		accept = append(accept, fmt.Sprintf("%s;q=%.3g", entry.name, entry.q))
+		if entry.name == name || entry.ct.Detect(name) {
+			return float64(entry.q)
+		}
+	}
+	return 1.0
+}
+
+// acceptTypes returns the content types to request in order. By default these
+// are the registered content types, but an API may configure its own list
+// with entries like `application/json;q=1.0`. Weight overrides from
+// `rsh-accept-weight` apply to both, and types with a q factor of zero are
+// left out.
+func acceptTypes(configured []string) []acceptType {
+	types := []acceptType{}
+	weights := acceptWeights()
+
+	if len(configured) == 0 {
+		if _, ok := weights["application/msgpack"]; !ok && !viper.GetBool("rsh-msgpack") {
+			// MessagePack responses are always decoded, but only requested when
+			// enabled as servers may prefer it over JSON.
+			weights["application/msgpack"] = 0
+		}
+
+		for _, entry := range contentTypes {
+			types = append(types, acceptType{name: entry.name, q: float64(entry.q)})
+		}
+	} else {
+		for _, item := range configured {
+			name, params, err := mime.ParseMediaType(item)
+			if err != nil {
+				LogWarning("Invalid accept type %s: %v", item, err)
+				continue
+			}
+
+			q := registeredWeight(name)
+			if v, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(v, 32); err != nil || q < 0 || q > 1 {
+					LogWarning("Invalid accept type %s, q must be between 0 and 1", item)
+					continue
+				}
+			}
+
+			types = append(types, acceptType{name: name, q: q})
+		}
+	}
+
+	filtered := []acceptType{}
+	for _, t := range types {
+		if w, ok := weights[t.name]; ok {
+			t.q = w
+		}
+
+		if t.q == 0 {
+			// A weight of zero means the type should not be requested at all.
+			continue
+		}
+
+		filtered = append(filtered, t)
+	}
+
+	return filtered
+}
+
+// buildAcceptHeader returns the `Accept` header value for the registered
+// content types, or for the given types if an API configures them.
+func buildAcceptHeader(configured ...string) string {
+	accept := []string{}
+
+	for _, t := range acceptTypes(configured) {
+		accept = append(accept, fmt.Sprintf("%s;q=%.3g", t.name, t.q))
 	}
 
 	accept = append(accept, "*/*")
@@ -50,24 +169,97 @@ func buildAcceptHeader() string {
 	return strings.Join(accept, ",")
 }
 
-// Marshal a value to the given content type if possible.
-func Marshal(contentType string, value interface{}) ([]byte, error) {
+// canEncode returns whether a content type can be used to encode request
+// bodies, which is checked by encoding a sample object.
+func canEncode(ct ContentType) bool {
+	_, err := ct.Marshal(map[string]interface{}{"id": 1})
+	return err == nil
+}
+
+// contentTypesTable renders the registered content types in order along with
+// their q factor and whether they can encode request bodies.
+func contentTypesTable() string {
+	weights := map[string]float64{}
+	for _, t := range acceptTypes(nil) {
+		weights[t.name] = t.q
+	}
+
+	table := simpletable.New()
+	table.Header = &simpletable.Header{
+		Cells: []*simpletable.Cell{
+			{Align: simpletable.AlignCenter, Text: "Content Type"},
+			{Align: simpletable.AlignCenter, Text: "Q"},
+			{Align: simpletable.AlignCenter, Text: "Encode"},
+		},
+	}
+
+	for _, entry := range contentTypes {
+		encode := "no"
+		if canEncode(entry.ct) {
+			encode = "yes"
+		}
+
+		table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+			{Text: entry.name},
+			{Align: simpletable.AlignRight, Text: fmt.Sprintf("%.3g", weights[entry.name])},
+			{Text: encode},
+		})
+	}
+
+	table.SetStyle(simpletable.StyleCompactLite)
+	return table.String() + "\n"
+}
+
+func contentTypesCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "content-types",
+		Short: "List registered content types",
+		Long:  "List the content types which can be decoded in the order they are checked, along with their q factor in the `Accept` header and whether they can encode request bodies. A q factor of zero means the type is not requested, e.g. MessagePack unless `--rsh-msgpack` is set. Use `--rsh-accept-weight` or an API's `accept_types` config to change what is requested.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Fprint(Stdout, contentTypesTable())
+		},
+	}
+}
+
+// findContentType returns the first registered handler which detects the
+// content type. Types with a structured syntax suffix like
+// `application/vnd.example+cbor` fall back to the handler for the suffix,
+// e.g. `application/cbor`, if no handler detects them directly.
+func findContentType(contentType string) (contentTypeEntry, bool) {
 	for _, entry := range contentTypes {
 		if entry.ct.Detect(contentType) {
-			return entry.ct.Marshal(value)
+			return entry, true
+		}
+	}
+
+	mt := mediaType(contentType)
+	if i := strings.LastIndex(mt, "+"); i != -1 && i < len(mt)-1 {
+		suffix := "application/" + mt[i+1:]
+		for _, entry := range contentTypes {
+			if entry.ct.Detect(suffix) {
+				return entry, true
+			}
 		}
 	}
 
+	return contentTypeEntry{}, false
+}
+
+// Marshal a value to the given content type if possible.
+func Marshal(contentType string, value interface{}) ([]byte, error) {
+	if entry, ok := findContentType(contentType); ok {
+		return entry.ct.Marshal(value)
+	}
+
 	return nil, fmt.Errorf("cannot marshal %s", contentType)
 }
 
 // Unmarshal raw data from the given content type into a value.
 func Unmarshal(contentType string, data []byte, value interface{}) error {
-	for _, entry := range contentTypes {
-		if entry.ct.Detect(contentType) {
-			LogDebug("Unmarshalling from %s", entry.name)
-			return entry.ct.Unmarshal(data, value)
-		}
+	if entry, ok := findContentType(contentType); ok {
+		LogDebug("Unmarshalling from %s", entry.name)
+		return entry.ct.Unmarshal(data, value)
 	}
 
 	return fmt.Errorf("cannot unmarshal %s", contentType)
@@ -147,9 +339,25 @@ func (j JSON) Marshal(value interface{}) ([]byte, error) {
 	return json.Marshal(value)
 }
 
-// Unmarshal the value from encoded JSON.
+// Unmarshal the value from encoded JSON. With `rsh-precise-numbers` set,
+// numbers are decoded as `json.Number` so that large integers like 64-bit IDs
+// aren't rounded.
 func (j JSON) Unmarshal(data []byte, value interface{}) error {
-	return json.Unmarshal(data, value)
+	if !viper.GetBool("rsh-precise-numbers") {
+		return json.Unmarshal(data, value)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(value); err != nil {
+		return err
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("invalid JSON: unexpected data after top-level value")
+	}
+
+	return nil
 }
 
 // YAML describes content types like `application/yaml` or
@@ -180,7 +388,7 @@ func (y YAML) Unmarshal(data []byte, value interface{}) error {
 // `application/foo+cbor`. http://cbor.io/
 type CBOR struct{}
 
-// Detect if the content type is YAML.
+// Detect if the content type is CBOR.
 func (c CBOR) Detect(contentType string) bool {
 	first := strings.Split(contentType, ";")[0]
 	if first == "application/cbor" || strings.HasSuffix(first, "+cbor") {
@@ -190,12 +398,15 @@ func (c CBOR) Detect(contentType string) bool {
 	return false
 }
 
-// Marshal the value to encoded YAML.
+// cborEncMode sorts map keys so that encoding is deterministic.
+var cborEncMode, _ = cbor.EncOptions{Sort: cbor.SortCanonical}.EncMode()
+
+// Marshal the value to encoded CBOR.
 func (c CBOR) Marshal(value interface{}) ([]byte, error) {
-	return cbor.Marshal(value)
+	return cborEncMode.Marshal(value)
 }
 
-// Unmarshal the value from encoded YAML.
+// Unmarshal the value from encoded CBOR.
 func (c CBOR) Unmarshal(data []byte, value interface{}) error {
 	return cbor.Unmarshal(data, value)
 }
@@ -204,7 +415,7 @@ func (c CBOR) Unmarshal(data []byte, value interface{}) error {
 // `application/foo+msgpack`. https://msgpack.org/
 type MsgPack struct{}
 
-// Detect if the content type is YAML.
+// Detect if the content type is MessagePack.
 func (m MsgPack) Detect(contentType string) bool {
 	first := strings.Split(contentType, ";")[0]
 	if first == "application/msgpack" || first == "application/x-msgpack" || first == "application/vnd.msgpack" || strings.HasSuffix(first, "+msgpack") {
@@ -214,12 +425,13 @@ func (m MsgPack) Detect(contentType string) bool {
 	return false
 }
 
-// Marshal the value to encoded YAML.
+// Marshal the value to encoded MessagePack.
 func (m MsgPack) Marshal(value interface{}) ([]byte, error) {
 	return msgpack.Marshal(value)
 }
 
-// Unmarshal the value from encoded YAML.
+// Unmarshal the value from encoded MessagePack. Binary data is decoded as
+// `[]byte`.
 func (m MsgPack) Unmarshal(data []byte, value interface{}) error {
 	return msgpack.Unmarshal(data, value)
 }
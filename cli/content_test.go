@@ -1,9 +1,17 @@
 package cli
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/fxamacker/cbor/v2"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
 )
 
 var contentTests = []struct {
@@ -40,3 +48,316 @@ func TestContentTypes(parent *testing.T) {
 		})
 	}
 }
+
+func TestCBORRoundTrip(t *testing.T) {
+	ct := &CBOR{}
+
+	original := map[interface{}]interface{}{
+		"id":      uint64(123),
+		"binary":  []byte{0xde, 0xad, 0xbe, 0xef},
+		"tagged":  cbor.Tag{Number: 1000, Content: "custom"},
+		uint64(1): "non-string key",
+	}
+
+	encoded, err := ct.Marshal(original)
+	assert.NoError(t, err)
+
+	var decoded interface{}
+	assert.NoError(t, ct.Unmarshal(encoded, &decoded))
+	assert.Equal(t, original, decoded)
+
+	// Encoding is deterministic, so the bytes round-trip as well.
+	again, err := ct.Marshal(decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, encoded, again)
+
+	// Readable output uses hex for bytes and CBOR diagnostic notation for tags.
+	readable, err := MarshalReadable(decoded)
+	assert.NoError(t, err)
+	assert.Contains(t, string(readable), "binary: 0xdeadbeef")
+	assert.Contains(t, string(readable), `tagged: 1000("custom")`)
+	assert.Contains(t, string(readable), `1: "non-string key"`)
+
+	// Filtering and JSON output need string keys.
+	safe := makeJSONSafe(decoded, false).(map[string]interface{})
+	assert.Equal(t, "non-string key", safe["1"])
+	assert.Equal(t, map[string]interface{}{"tag": uint64(1000), "value": "custom"}, safe["tagged"])
+}
+
+func TestCBORRequestBody(t *testing.T) {
+	reset(false)
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		body, err := GetBody("application/cbor", []string{"foo: bar"})
+		assert.NoError(t, err)
+
+		var decoded interface{}
+		assert.NoError(t, cbor.Unmarshal([]byte(body), &decoded))
+		assert.Equal(t, map[interface{}]interface{}{"foo": "bar"}, decoded)
+	})
+}
+
+func TestAcceptWeight(t *testing.T) {
+	reset(false)
+	assert.Contains(t, buildAcceptHeader(), "application/cbor;q=0.9")
+
+	viper.Set("rsh-accept-weight", []string{"application/cbor=0.2", "application/ion=0", "bad"})
+	defer viper.Set("rsh-accept-weight", []string{})
+
+	accept := buildAcceptHeader()
+	assert.Contains(t, accept, "application/cbor;q=0.2")
+	assert.NotContains(t, accept, "application/ion")
+	assert.Contains(t, accept, "application/json;q=0.5")
+}
+
+func TestAcceptTypesConfig(t *testing.T) {
+	reset(false)
+
+	assert.Equal(t, "application/json;q=1,application/cbor;q=0.9,text/yaml;q=0.5,*/*", buildAcceptHeader("application/json;q=1.0", "application/cbor;q=0.9", "text/yaml", "bad;;"))
+
+	viper.Set("rsh-accept-weight", []string{"application/cbor=0"})
+	defer viper.Set("rsh-accept-weight", []string{})
+	assert.Equal(t, "application/json;q=1,*/*", buildAcceptHeader("application/json;q=1.0", "application/cbor;q=0.9"))
+}
+
+func TestAcceptTypesRequest(t *testing.T) {
+	defer gock.Off()
+
+	reset(false)
+	configs["accept-test"] = &APIConfig{
+		name:        "accept-test",
+		Base:        "http://accept.example.com",
+		AcceptTypes: []string{"application/cbor;q=1.0", "application/json;q=0.5"},
+	}
+
+	gock.New("http://accept.example.com").
+		Get("/items").
+		MatchHeader("Accept", `^application/cbor;q=1,application/json;q=0\.5,\*/\*$`).
+		Reply(204)
+
+	runNoReset("http://accept.example.com/items")
+	assert.True(t, gock.IsDone())
+}
+
+func TestContentTypesCommand(t *testing.T) {
+	out := run("content-types")
+	assert.Regexp(t, `application/json\s+0.5\s+yes`, out)
+	assert.Regexp(t, `application/msgpack\s+0\s+yes`, out)
+	assert.Regexp(t, `text/csv\s+0\s+no`, out)
+}
+
+func TestMsgPack(t *testing.T) {
+	reset(false)
+
+	ct := &MsgPack{}
+	b, err := ct.Marshal(map[string]interface{}{"id": 1, "binary": []byte{0xde, 0xad}})
+	assert.NoError(t, err)
+
+	var decoded interface{}
+	assert.NoError(t, ct.Unmarshal(b, &decoded))
+	assert.Equal(t, []byte{0xde, 0xad}, makeJSONSafe(decoded, true).(map[string]interface{})["binary"])
+
+	readable, err := MarshalReadable(decoded)
+	assert.NoError(t, err)
+	assert.Contains(t, string(readable), "binary: 0xdead")
+
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		body, err := GetBody("application/msgpack", []string{"foo: bar"})
+		assert.NoError(t, err)
+		assert.NoError(t, ct.Unmarshal([]byte(body), &decoded))
+		assert.Equal(t, "bar", makeJSONSafe(decoded, true).(map[string]interface{})["foo"])
+	})
+
+	// Only requested when enabled.
+	assert.NotContains(t, buildAcceptHeader(), "msgpack")
+	viper.Set("rsh-msgpack", true)
+	defer viper.Set("rsh-msgpack", false)
+	assert.Contains(t, buildAcceptHeader(), "application/msgpack;q=0.8")
+}
+
+func TestXML(t *testing.T) {
+	ct := &XML{}
+	assert.True(t, ct.Detect("text/xml; charset=utf-8"))
+	assert.True(t, ct.Detect("application/soap+xml"))
+
+	var decoded interface{}
+	err := ct.Unmarshal([]byte(`<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope">
+  <soap:Body>
+    <items count="2">
+      <item id="1">First &amp; best</item>
+      <item id="2"/>
+      <name>Items</name>
+    </items>
+  </soap:Body>
+</soap:Envelope>`), &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Envelope": map[string]interface{}{
+			"@xmlns:soap": "http://www.w3.org/2003/05/soap-envelope",
+			"Body": map[string]interface{}{
+				"items": map[string]interface{}{
+					"@count": "2",
+					"item": []interface{}{
+						map[string]interface{}{"@id": "1", "#text": "First & best"},
+						map[string]interface{}{"@id": "2"},
+					},
+					"name": "Items",
+				},
+			},
+		},
+	}, decoded)
+
+	// Entity expansion is not allowed.
+	err = ct.Unmarshal([]byte(`<!DOCTYPE lolz [<!ENTITY lol "lol"><!ENTITY lol2 "&lol;&lol;">]><lolz>&lol2;</lolz>`), &decoded)
+	assert.Error(t, err)
+
+	err = ct.Unmarshal([]byte(strings.Repeat("<a>", xmlMaxDepth+1)+strings.Repeat("</a>", xmlMaxDepth+1)), &decoded)
+	assert.Error(t, err)
+
+	_, err = ct.Marshal(decoded)
+	assert.Error(t, err)
+}
+
+func TestCSV(t *testing.T) {
+	ct := &CSV{}
+	assert.True(t, ct.Detect("text/csv; charset=utf-8"))
+	assert.True(t, ct.Detect("text/tab-separated-values"))
+	assert.False(t, ct.Detect("text/plain"))
+
+	var decoded interface{}
+	err := ct.Unmarshal([]byte("\uFEFFid,name,price,active,note\n1,\"Foo, Inc\",1.5,true,\n2,Bar,2,FALSE,nan\n3,Baz,,false,x,extra\n"), &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": int64(1), "name": "Foo, Inc", "price": 1.5, "active": true, "note": "", "6": ""},
+		map[string]interface{}{"id": int64(2), "name": "Bar", "price": 2.0, "active": false, "note": "nan", "6": ""},
+		map[string]interface{}{"id": int64(3), "name": "Baz", "price": nil, "active": false, "note": "x", "6": "extra"},
+	}, decoded)
+
+	// Semicolon and tab delimiters are detected from the header.
+	err = ct.Unmarshal([]byte("a;b\n\"x;y\";2\n"), &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"a": "x;y", "b": int64(2)}}, decoded)
+
+	err = ct.Unmarshal([]byte("a\tb\nx\t2\n"), &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"a": "x", "b": int64(2)}}, decoded)
+
+	// Columns with leading zeros stay strings so the zeros aren't lost.
+	err = ct.Unmarshal([]byte("zip,id,count,ratio\n02134,0,0,0.5\n94103,-01,10,-0.25\n"), &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"zip": "02134", "id": "0", "count": int64(0), "ratio": 0.5},
+		map[string]interface{}{"zip": "94103", "id": "-01", "count": int64(10), "ratio": -0.25},
+	}, decoded)
+
+	err = ct.Unmarshal([]byte(""), &decoded)
+	assert.Error(t, err)
+
+	_, err = ct.Marshal(decoded)
+	assert.Error(t, err)
+}
+
+func TestNDJSON(t *testing.T) {
+	defer viper.Set("rsh-ndjson-strict", false)
+
+	ct := &NDJSON{}
+	assert.True(t, ct.Detect("application/x-ndjson"))
+	assert.True(t, ct.Detect("application/jsonl; charset=utf-8"))
+	assert.False(t, ct.Detect("application/json"))
+
+	var decoded interface{}
+	err := ct.Unmarshal([]byte("{\"id\": 1}\n\n{\"id\": 2}\r\nnot json\n[3]"), &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": 1.0},
+		map[string]interface{}{"id": 2.0},
+		[]interface{}{3.0},
+	}, decoded)
+
+	viper.Set("rsh-ndjson-strict", true)
+	err = ct.Unmarshal([]byte("{\"id\": 1}\nnot json\n"), &decoded)
+	assert.ErrorContains(t, err, "line 2")
+
+	b, err := ct.Marshal([]interface{}{map[string]interface{}{"a": "<b>"}, 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"a\":\"<b>\"}\n1\n", string(b))
+}
+
+// kvContentType is an example of a custom handler for `key=value` lines, as
+// an embedding application might register.
+type kvContentType struct{}
+
+func (kv kvContentType) Detect(contentType string) bool {
+	return mediaType(contentType) == "application/x-kv"
+}
+
+func (kv kvContentType) Marshal(value interface{}) ([]byte, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("only objects can be encoded")
+	}
+
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	lines := []string{}
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s=%v", k, m[k]))
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+func (kv kvContentType) Unmarshal(data []byte, value interface{}) error {
+	m := map[string]interface{}{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if parts := strings.SplitN(line, "=", 2); len(parts) == 2 {
+			m[parts[0]] = parts[1]
+		}
+	}
+	*value.(*interface{}) = m
+	return nil
+}
+
+func TestCustomContentType(t *testing.T) {
+	defer gock.Off()
+
+	reset(false)
+	AddContentType("application/x-kv", 0.95, &kvContentType{})
+
+	// Accept negotiation
+	assert.Contains(t, buildAcceptHeader(), "application/x-kv;q=0.95")
+
+	// Request encoding
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		body, err := GetBody("application/x-kv", []string{"foo: bar, id: 1"})
+		assert.NoError(t, err)
+		assert.Equal(t, "foo=bar\nid=1", body)
+	})
+
+	// Response decoding, including via a structured syntax suffix.
+	gock.New("http://example.com").
+		Get("/kv").
+		MatchHeader("Accept", "application/x-kv").
+		Reply(200).
+		SetHeader("Content-Type", "application/vnd.example+x-kv").
+		BodyString("a=1\nb=2")
+
+	out := runNoReset("-o json -f body http://example.com/kv")
+	assert.JSONEq(t, `{"a": "1", "b": "2"}`, out)
+}
+
+func TestContentTypeSuffix(t *testing.T) {
+	reset(false)
+
+	encoded, err := Marshal("application/vnd.example+cbor", map[string]interface{}{"id": 1})
+	assert.NoError(t, err)
+
+	var decoded interface{}
+	assert.NoError(t, Unmarshal("application/vnd.example+cbor; v=2", encoded, &decoded))
+	assert.EqualValues(t, 1, makeJSONSafe(decoded, false).(map[string]interface{})["id"])
+
+	assert.Error(t, Unmarshal("application/vnd.example+unknown", encoded, &decoded))
+}
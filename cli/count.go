@@ -0,0 +1,22 @@
+package cli
+
+import "fmt"
+
+// countValue returns the number of items in an array, the number of keys in
+// an object, or the length of a string in bytes. Other values can't be
+// counted.
+func countValue(data interface{}) (int, error) {
+	switch v := data.(type) {
+	case []interface{}:
+		return len(v), nil
+	case map[string]interface{}:
+		return len(v), nil
+	case string:
+		return len(v), nil
+	}
+
+	if data == nil {
+		return 0, fmt.Errorf("cannot count null")
+	}
+	return 0, fmt.Errorf("cannot count %v", data)
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountValue(t *testing.T) {
+	for _, tc := range []struct {
+		data     interface{}
+		expected int
+	}{
+		{[]interface{}{1.0, 2.0, 3.0}, 3},
+		{[]interface{}{}, 0},
+		{map[string]interface{}{"a": 1.0, "b": nil}, 2},
+		{"héllo", 6},
+		{"", 0},
+	} {
+		n, err := countValue(tc.data)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, n)
+	}
+
+	for _, data := range []interface{}{nil, 1.0, true} {
+		_, err := countValue(data)
+		assert.Error(t, err)
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	filename := path.Join(t.TempDir(), "page.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`{"items": [{"id": 1}, {"id": 2}], "next": "abc", "total": 10}`), 0600))
+
+	assert.Equal(t, "3\n", run("format count "+filename))
+	assert.Equal(t, "2\n", run("format count -f body.items "+filename))
+	assert.Equal(t, "3\n", run("format count -f body.next "+filename))
+
+	WithFakeStdin([]byte("- a\n- b\n"), 0, func() {
+		assert.Equal(t, "2\n", run("format count"))
+	})
+
+	assert.Contains(t, run("format count -f body.total "+filename), "cannot count 10")
+	assert.Equal(t, 1, GetExitCode())
+}
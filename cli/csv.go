@@ -0,0 +1,251 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CSV describes content types like `text/csv` or `text/tab-separated-values`.
+// Documents are decoded into a list of objects using the header row as keys.
+// Columns where every value is a number or boolean are converted to that
+// type, with empty values becoming `null`.
+type CSV struct{}
+
+// Detect if the content type is CSV.
+func (c CSV) Detect(contentType string) bool {
+	switch mediaType(contentType) {
+	case "text/csv", "application/csv", "text/tab-separated-values":
+		return true
+	}
+
+	return false
+}
+
+// Marshal is not supported for CSV.
+func (c CSV) Marshal(value interface{}) ([]byte, error) {
+	return nil, errors.New("encoding CSV is not supported")
+}
+
+// csvDelimiter guesses the field delimiter from the header line, which is
+// whichever of comma, tab, or semicolon appears most often outside of quotes.
+func csvDelimiter(data []byte) rune {
+	header := data
+	if i := bytes.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+
+	counts := map[rune]int{}
+	quoted := false
+	for _, r := range string(header) {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == ',' || r == '\t' || r == ';'):
+			counts[r]++
+		}
+	}
+
+	delimiter := ','
+	for _, r := range []rune{'\t', ';'} {
+		if counts[r] > counts[delimiter] {
+			delimiter = r
+		}
+	}
+
+	return delimiter
+}
+
+// hasLeadingZero returns whether a value starts with a zero which would be
+// lost when converting it to a number, like `007` or `-01.5`.
+func hasLeadingZero(v string) bool {
+	v = strings.TrimLeft(v, "+-")
+	return len(v) > 1 && v[0] == '0' && v[1] != '.'
+}
+
+// csvColumn converts the values of a column to numbers or booleans if every
+// non-empty value is one. Otherwise the values are left as strings, as are
+// columns with leading zeros like zip codes or IDs which would otherwise
+// lose them.
+func csvColumn(values []string) []interface{} {
+	converted := make([]interface{}, len(values))
+
+	numeric := true
+	for _, v := range values {
+		if hasLeadingZero(v) {
+			numeric = false
+			break
+		}
+	}
+
+	convert := func(parse func(string) (interface{}, bool)) bool {
+		found := false
+		for i, v := range values {
+			if v == "" {
+				converted[i] = nil
+				continue
+			}
+			parsed, ok := parse(v)
+			if !ok {
+				return false
+			}
+			converted[i] = parsed
+			found = true
+		}
+		return found
+	}
+
+	if numeric && convert(func(v string) (interface{}, bool) {
+		i, err := strconv.ParseInt(v, 10, 64)
+		return i, err == nil
+	}) {
+		return converted
+	}
+
+	if numeric && convert(func(v string) (interface{}, bool) {
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil && !math.IsInf(f, 0) && !math.IsNaN(f)
+	}) {
+		return converted
+	}
+
+	if convert(func(v string) (interface{}, bool) {
+		lower := strings.ToLower(v)
+		return lower == "true", lower == "true" || lower == "false"
+	}) {
+		return converted
+	}
+
+	for i, v := range values {
+		converted[i] = v
+	}
+	return converted
+}
+
+// Unmarshal the value from encoded CSV. The delimiter is detected from the
+// header row, so tab and semicolon separated values also work. Fields past
+// the end of the header use their column number as the key.
+func (c CSV) Unmarshal(data []byte, value interface{}) error {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr {
+		return fmt.Errorf("value must be pointer but found %s", v.Kind())
+	}
+
+	// Skip the unicode BOM some spreadsheet exports include.
+	data = bytes.TrimPrefix(data, []byte("\uFEFF"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = csvDelimiter(data)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 {
+		return errors.New("no CSV header row found")
+	}
+
+	header := rows[0]
+	rows = rows[1:]
+
+	columns := len(header)
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	items := make([]interface{}, len(rows))
+	for i := range items {
+		items[i] = map[string]interface{}{}
+	}
+
+	for col := 0; col < columns; col++ {
+		key := strconv.Itoa(col + 1)
+		if col < len(header) && header[col] != "" {
+			key = header[col]
+		}
+
+		values := make([]string, len(rows))
+		for i, row := range rows {
+			if col < len(row) {
+				values[i] = row[col]
+			}
+		}
+
+		for i, converted := range csvColumn(values) {
+			items[i].(map[string]interface{})[key] = converted
+		}
+	}
+
+	v.Elem().Set(reflect.ValueOf(items))
+	return nil
+}
+
+// marshalCSV encodes an object or a list of objects as CSV for `-o csv`. The
+// header row holds the sorted keys of all objects, and nested values are
+// written as JSON.
+func marshalCSV(data interface{}) ([]byte, error) {
+	rows, ok := data.([]interface{})
+	if !ok {
+		rows = []interface{}{data}
+	}
+
+	seen := map[string]bool{}
+	header := []string{}
+	for _, row := range rows {
+		m, ok := row.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("CSV output requires an object or a list of objects")
+		}
+
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				header = append(header, k)
+			}
+		}
+	}
+	sort.Strings(header)
+
+	buf := &bytes.Buffer{}
+	writer := csv.NewWriter(buf)
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		m := row.(map[string]interface{})
+		record := make([]string, len(header))
+		for i, k := range header {
+			switch v := m[k].(type) {
+			case nil:
+			case string:
+				record[i] = v
+			default:
+				b, err := json.Marshal(v)
+				if err != nil {
+					return nil, err
+				}
+				record[i] = string(b)
+			}
+		}
+
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
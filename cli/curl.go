@@ -0,0 +1,436 @@
+package cli
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// curlRequest describes a request parsed from a curl command.
+type curlRequest struct {
+	Method   string
+	URL      string
+	Headers  [][2]string
+	Body     string
+	Insecure bool
+}
+
+// Header returns the first header value with the given name, if any.
+func (c *curlRequest) Header(name string) string {
+	for _, h := range c.Headers {
+		if strings.EqualFold(h[0], name) {
+			return h[1]
+		}
+	}
+	return ""
+}
+
+// splitShellWords splits a command line into words like a POSIX shell,
+// handling single, double, and `$'...'` quotes as well as escaped newlines
+// used to split long commands.
+func splitShellWords(input string) ([]string, error) {
+	words := []string{}
+	word := strings.Builder{}
+	inWord := false
+
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+
+		switch {
+		case c == '\\':
+			if i+1 < len(input) {
+				i++
+				if input[i] != '\n' && input[i] != '\r' {
+					word.WriteByte(input[i])
+					inWord = true
+				}
+			}
+		case c == '\'':
+			end := strings.IndexByte(input[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(input[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(input) && input[i+1] == '\'':
+			// ANSI-C quoting as used by e.g. browser "copy as cURL" features.
+			i += 2
+			for ; i < len(input) && input[i] != '\''; i++ {
+				if input[i] == '\\' && i+1 < len(input) {
+					i++
+					switch input[i] {
+					case 'n':
+						word.WriteByte('\n')
+					case 't':
+						word.WriteByte('\t')
+					case 'r':
+						word.WriteByte('\r')
+					default:
+						word.WriteByte(input[i])
+					}
+					continue
+				}
+				word.WriteByte(input[i])
+			}
+			if i >= len(input) {
+				return nil, errors.New("unterminated single quote")
+			}
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(input) && input[i] != '"'; i++ {
+				if input[i] == '\\' && i+1 < len(input) && strings.IndexByte("\"\\$`\n", input[i+1]) >= 0 {
+					i++
+					if input[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(input[i])
+			}
+			if i >= len(input) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// curlValueOptions are the curl options which take a value, mapped from the
+// short to the long name.
+var curlValueOptions = map[string]string{
+	"X": "request",
+	"H": "header",
+	"d": "data",
+	"u": "user",
+	"A": "user-agent",
+	"e": "referer",
+	"b": "cookie",
+	"o": "output",
+	"m": "max-time",
+	"x": "proxy",
+	"w": "write-out",
+	"F": "form",
+	"T": "upload-file",
+	"E": "cert",
+	"r": "range",
+	"c": "cookie-jar",
+	"K": "config",
+	"U": "proxy-user",
+	"Y": "speed-limit",
+	"y": "speed-time",
+	"z": "time-cond",
+	"C": "continue-at",
+	"D": "dump-header",
+	"P": "ftp-port",
+	"Q": "quote",
+	"t": "telnet-option",
+}
+
+// curlLongValueOptions are long-only curl options which take a value.
+var curlLongValueOptions = map[string]bool{
+	"data-raw": true, "data-binary": true, "data-ascii": true, "data-urlencode": true,
+	"json": true, "url": true, "key": true, "cacert": true, "connect-timeout": true,
+	"retry": true, "retry-delay": true, "retry-max-time": true, "max-redirs": true,
+	"resolve": true, "connect-to": true, "interface": true, "limit-rate": true,
+	"oauth2-bearer": true, "aws-sigv4": true,
+}
+
+// curlBoolOptions maps the short curl flags which do not take a value to
+// their long name.
+var curlBoolOptions = map[string]string{
+	"G": "get",
+	"I": "head",
+	"k": "insecure",
+}
+
+// parseCurl parses curl arguments into a request.
+func parseCurl(args []string) (*curlRequest, error) {
+	if len(args) > 0 && args[0] == "curl" {
+		args = args[1:]
+	}
+
+	c := &curlRequest{}
+	data := []string{}
+	isJSON := false
+	get := false
+	head := false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		options := [][2]string{}
+		if strings.HasPrefix(arg, "--") {
+			name := arg[2:]
+			value := ""
+			hasValue := false
+			if idx := strings.IndexByte(name, '='); idx >= 0 {
+				name, value, hasValue = name[:idx], name[idx+1:], true
+			}
+
+			takesValue := curlLongValueOptions[name]
+			for _, long := range curlValueOptions {
+				if long == name {
+					takesValue = true
+				}
+			}
+
+			if takesValue && !hasValue {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("missing value for --%s", name)
+				}
+				i++
+				value = args[i]
+			}
+			options = append(options, [2]string{name, value})
+		} else if strings.HasPrefix(arg, "-") && len(arg) > 1 {
+			// Short options can be combined, e.g. `-sSL` or `-XPOST`.
+			for j := 1; j < len(arg); j++ {
+				short := arg[j : j+1]
+				if long, ok := curlValueOptions[short]; ok {
+					value := arg[j+1:]
+					if value == "" {
+						if i+1 >= len(args) {
+							return nil, fmt.Errorf("missing value for -%s", short)
+						}
+						i++
+						value = args[i]
+					}
+					options = append(options, [2]string{long, value})
+					break
+				}
+
+				if long, ok := curlBoolOptions[short]; ok {
+					options = append(options, [2]string{long, ""})
+				}
+			}
+		} else {
+			options = append(options, [2]string{"url", arg})
+		}
+
+		for _, option := range options {
+			name, value := option[0], option[1]
+			switch name {
+			case "url":
+				c.URL = value
+			case "request":
+				c.Method = strings.ToUpper(value)
+			case "header":
+				parts := strings.SplitN(value, ":", 2)
+				if len(parts) == 2 {
+					c.Headers = append(c.Headers, [2]string{strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])})
+				}
+			case "data", "data-raw", "data-binary", "data-ascii":
+				if strings.HasPrefix(value, "@") && name != "data-raw" {
+					return nil, fmt.Errorf("reading request data from files is not supported: %s", value)
+				}
+				data = append(data, value)
+			case "data-urlencode":
+				if parts := strings.SplitN(value, "=", 2); len(parts) == 2 {
+					data = append(data, parts[0]+"="+url.QueryEscape(parts[1]))
+				} else {
+					data = append(data, url.QueryEscape(value))
+				}
+			case "json":
+				data = append(data, value)
+				isJSON = true
+			case "user":
+				c.Headers = append(c.Headers, [2]string{"Authorization", "Basic " + base64.StdEncoding.EncodeToString([]byte(value))})
+			case "oauth2-bearer":
+				c.Headers = append(c.Headers, [2]string{"Authorization", "Bearer " + value})
+			case "user-agent":
+				c.Headers = append(c.Headers, [2]string{"User-Agent", value})
+			case "referer":
+				c.Headers = append(c.Headers, [2]string{"Referer", value})
+			case "cookie":
+				if strings.Contains(value, "=") {
+					c.Headers = append(c.Headers, [2]string{"Cookie", value})
+				}
+			case "get":
+				get = true
+			case "head":
+				head = true
+			case "insecure":
+				c.Insecure = true
+			case "form", "upload-file":
+				return nil, fmt.Errorf("curl option --%s is not supported", name)
+			default:
+				LogDebug("Ignoring curl option --%s", name)
+			}
+		}
+	}
+
+	if c.URL == "" {
+		return nil, errors.New("no URL found in curl command")
+	}
+
+	if len(data) > 0 {
+		joined := strings.Join(data, "&")
+		if get {
+			sep := "?"
+			if strings.Contains(c.URL, "?") {
+				sep = "&"
+			}
+			c.URL += sep + joined
+		} else {
+			c.Body = joined
+			if c.Method == "" {
+				c.Method = http.MethodPost
+			}
+			if c.Header("Content-Type") == "" {
+				// Match the defaults curl uses.
+				if isJSON {
+					c.Headers = append(c.Headers, [2]string{"Content-Type", "application/json"})
+				} else {
+					c.Headers = append(c.Headers, [2]string{"Content-Type", "application/x-www-form-urlencoded"})
+				}
+			}
+		}
+	}
+
+	if isJSON && c.Header("Accept") == "" {
+		c.Headers = append(c.Headers, [2]string{"Accept", "application/json"})
+	}
+
+	if c.Method == "" {
+		c.Method = http.MethodGet
+		if head {
+			c.Method = http.MethodHead
+		}
+	}
+
+	return c, nil
+}
+
+// shellQuote quotes a value for use in a POSIX shell if needed.
+func shellQuote(value string) string {
+	if value != "" && strings.Trim(value, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@%+,") == "" {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// Command renders the equivalent restish command. Bodies, like JSON, are
+// passed verbatim via stdin so they are sent exactly as curl would send them.
+func (c *curlRequest) Command(name string) string {
+	parts := []string{name}
+	if c.Method != http.MethodGet {
+		parts = append(parts, strings.ToLower(c.Method))
+	}
+	parts = append(parts, shellQuote(c.URL))
+
+	for _, h := range c.Headers {
+		parts = append(parts, "-H", shellQuote(h[0]+":"+h[1]))
+	}
+
+	if c.Insecure {
+		parts = append(parts, "--rsh-insecure")
+	}
+
+	command := strings.Join(parts, " ")
+	if c.Body != "" {
+		command = "echo " + shellQuote(c.Body) + " | " + command
+	}
+
+	return command
+}
+
+// Request creates an HTTP request from the parsed curl command.
+func (c *curlRequest) Request() (*http.Request, error) {
+	var body io.Reader
+	if c.Body != "" {
+		body = strings.NewReader(c.Body)
+	}
+
+	req, err := http.NewRequest(c.Method, fixAddress(c.URL), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range c.Headers {
+		req.Header.Add(h[0], h[1])
+	}
+
+	return req, nil
+}
+
+func curlImportCommand() *cobra.Command {
+	var exec *bool
+
+	cmd := &cobra.Command{
+		Use:   "curl-import [curl-command]",
+		Short: "Convert a curl command to restish",
+		Long:  "Parse a curl command and print the equivalent restish command, or run it directly with `--rsh-exec`. The curl command is read from stdin if not passed as an argument.",
+		Example: fmt.Sprintf(`  # Print the equivalent command
+  $ %s curl-import 'curl -X POST https://api.rest.sh/ -H "Content-Type: application/json" -d "{\"name\": \"foo\"}"'
+
+  # Run a command from the clipboard
+  $ pbpaste | %s curl-import --rsh-exec`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			input := ""
+			if len(args) > 0 {
+				input = args[0]
+			} else {
+				b, err := ioutil.ReadAll(Stdin)
+				if err != nil {
+					panic(err)
+				}
+				input = string(b)
+			}
+
+			words, err := splitShellWords(input)
+			if err != nil {
+				panic(err)
+			}
+
+			c, err := parseCurl(words)
+			if err != nil {
+				panic(err)
+			}
+
+			if !*exec {
+				fmt.Fprintln(Stdout, c.Command(Root.CommandPath()))
+				return
+			}
+
+			if c.Insecure {
+				viper.Set("rsh-insecure", true)
+			}
+
+			req, err := c.Request()
+			if err != nil {
+				panic(err)
+			}
+			if err := MakeRequestAndFormat(req); err != nil {
+				panic(err)
+			}
+		},
+	}
+	exec = cmd.Flags().Bool("rsh-exec", false, "Run the request instead of printing the command")
+
+	return cmd
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestSplitShellWords(t *testing.T) {
+	words, err := splitShellWords(`curl -H 'Accept: a' \
+  "https://example.com/?q=\"x\"" --data-raw $'{"a":\n1}' plain\ word`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"curl", "-H", "Accept: a", `https://example.com/?q="x"`, "--data-raw", "{\"a\":\n1}", "plain word"}, words)
+
+	_, err = splitShellWords(`curl 'unterminated`)
+	assert.Error(t, err)
+}
+
+func TestCurlImport(t *testing.T) {
+	c, err := parseCurl([]string{"curl", "-sSL", "-XPOST", "https://example.com/items", "-H", "Content-Type: application/json", "-d", `{"count": 5}`, "-k"})
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", c.Method)
+	assert.Equal(t, "https://example.com/items", c.URL)
+	assert.True(t, c.Insecure)
+	assert.Equal(t, `echo '{"count": 5}' | restish post https://example.com/items -H Content-Type:application/json --rsh-insecure`, c.Command("restish"))
+
+	// Form data defaults to POST and is sent via stdin.
+	c, err = parseCurl([]string{"curl", "https://example.com/login", "--data", "user=a", "--data-urlencode", "pass=b c", "-u", "u:p"})
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", c.Method)
+	assert.Equal(t, "user=a&pass=b+c", c.Body)
+	assert.Equal(t, "echo 'user=a&pass=b+c' | restish post https://example.com/login -H 'Authorization:Basic dTpw' -H Content-Type:application/x-www-form-urlencoded", c.Command("restish"))
+
+	// GET with data moves it into the query.
+	c, err = parseCurl([]string{"curl", "-G", "--url=https://example.com/search", "-d", "q=1", "-H", "Accept: a, b"})
+	assert.NoError(t, err)
+	assert.Equal(t, `restish 'https://example.com/search?q=1' -H 'Accept:a, b'`, c.Command("restish"))
+
+	_, err = parseCurl([]string{"curl", "-F", "file=@a.txt", "https://example.com"})
+	assert.Error(t, err)
+
+	_, err = parseCurl([]string{"curl", "-X", "GET"})
+	assert.Error(t, err)
+}
+
+func TestCurlImportExec(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Put("/items/1").MatchHeader("X-Test", "abc").BodyString(`{"a":1}`).Reply(200).JSON(map[string]interface{}{
+		"ok": true,
+	})
+
+	WithFakeStdin([]byte(`curl -X PUT http://example.com/items/1 -H 'X-Test: abc' --json '{"a":1}'`), 0, func() {
+		captured := run("-o json -f body curl-import --rsh-exec")
+		assert.JSONEq(t, `{"ok": true}`, captured)
+	})
+}
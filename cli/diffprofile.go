@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// volatileHeaders are response headers which usually differ between otherwise
+// identical responses.
+var volatileHeaders = map[string]bool{
+	"Age":        true,
+	"Date":       true,
+	"Expires":    true,
+	"Set-Cookie": true,
+}
+
+// volatileHeaderParts match headers for per-request values like request,
+// session, and correlation IDs, e.g. `X-Request-Id` or `Traceparent`.
+var volatileHeaderParts = []string{"request-id", "requestid", "correlation", "session", "trace", "span-id"}
+
+// isVolatileHeader returns whether a header should be ignored when comparing
+// responses.
+func isVolatileHeader(name string) bool {
+	if volatileHeaders[http.CanonicalHeaderKey(name)] {
+		return true
+	}
+
+	lower := strings.ToLower(name)
+	for _, part := range volatileHeaderParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// diffDocument returns the status, non-volatile headers, and body of a
+// response as indented JSON for comparison.
+func diffDocument(resp Response) ([]byte, error) {
+	headers := map[string]string{}
+	for k, v := range resp.Headers {
+		if !isVolatileHeader(k) {
+			headers[k] = v
+		}
+	}
+
+	doc := makeJSONSafe(map[string]interface{}{
+		"status":  resp.Status,
+		"headers": headers,
+		"body":    resp.Body,
+	}, false)
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// diffResponses returns a unified diff of two responses, or an empty string
+// if they are the same.
+func diffResponses(name1 string, resp1 Response, name2 string, resp2 Response) (string, error) {
+	doc1, err := diffDocument(resp1)
+	if err != nil {
+		return "", err
+	}
+
+	doc2, err := diffDocument(resp2)
+	if err != nil {
+		return "", err
+	}
+
+	edits := myers.ComputeEdits(span.URIFromPath(name1), string(doc1), string(doc2))
+	if len(edits) == 0 {
+		return "", nil
+	}
+
+	return fmt.Sprint(gotextdiff.ToUnified(name1, name2, string(doc1), edits)), nil
+}
+
+// fetchProfiles sends a copy of the request with each profile in parallel,
+// returning the parsed responses in the same order.
+func fetchProfiles(req *http.Request, profiles []string) ([]Response, error) {
+	responses := make([]Response, len(profiles))
+	errs := make([]error, len(profiles))
+
+	wg := sync.WaitGroup{}
+	for i, profile := range profiles {
+		clone := req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			clone.Body = body
+		}
+
+		if viper.GetString("rsh-server") == "" {
+			// Each profile may use a different server.
+			if u, err := url.Parse(profileURI(req.URL.String(), profile)); err == nil {
+				clone.URL = u
+				clone.Host = u.Host
+			}
+		}
+
+		wg.Add(1)
+		go func(i int, profile string, clone *http.Request) {
+			defer wg.Done()
+			defer func() {
+				// Requests panic on errors like failed auth.
+				if r := recover(); r != nil {
+					errs[i] = fmt.Errorf("%v", r)
+				}
+			}()
+
+			responses[i], errs[i] = GetParsedResponse(clone, WithProfile(profile))
+		}(i, profile, clone)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("request with profile %s failed: %w", profiles[i], err)
+		}
+	}
+
+	return responses, nil
+}
+
+func diffProfileCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff-profile short-name operation [args...] --profile1 name --profile2 name",
+		Short: "Compare responses from two profiles",
+		Long:  "Call an API operation with two auth profiles at the same time and show a unified diff of the responses. The operation takes the same arguments and flags as usual. Headers which typically change on every request, like `Date` or request, session, and correlation IDs, are ignored. The exit code is non-zero if the responses differ.",
+		Example: fmt.Sprintf(`  # Compare an item between staging and production profiles
+  $ %s diff-profile my-api get-item item1 --profile1 staging --profile2 prod`, Root.CommandPath()),
+		Args:               cobra.MinimumNArgs(2),
+		ValidArgsFunction:  completeAPINames,
+		DisableFlagParsing: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			config := configs[args[0]]
+			if config == nil {
+				panic(fmt.Errorf("API %s not found", args[0]))
+			}
+
+			api, err := Load(config.Base, &cobra.Command{})
+			if err != nil {
+				panic(err)
+			}
+
+			op, ok := findOperation(api, args[1])
+			if !ok {
+				panic(fmt.Errorf("operation %s not found in API %s", args[1], args[0]))
+			}
+
+			// Parse the operation's arguments & flags like calling it directly, but
+			// capture the request instead of sending it.
+			var req *http.Request
+			sub := op.commandWithHandler(func(r *http.Request) error {
+				req = r
+				return nil
+			})
+			profile1 := sub.Flags().String("profile1", "", "First auth profile")
+			profile2 := sub.Flags().String("profile2", "", "Second auth profile")
+			sub.Flags().AddFlagSet(Root.PersistentFlags())
+			sub.SetArgs(args[2:])
+			sub.SetOut(Stdout)
+			if err := sub.Execute(); err != nil {
+				panic(err)
+			}
+
+			if req == nil {
+				// The request was not built, e.g. because validation failed.
+				return
+			}
+
+			profiles := []string{*profile1, *profile2}
+			for _, p := range profiles {
+				if p == "" {
+					panic(fmt.Errorf("both --profile1 and --profile2 are required"))
+				}
+				if config.Profiles[p] == nil && p != "default" {
+					panic(fmt.Errorf("profile %s not found in API %s", p, args[0]))
+				}
+			}
+
+			responses, err := fetchProfiles(req, profiles)
+			if err != nil {
+				panic(err)
+			}
+
+			diff, err := diffResponses(profiles[0], responses[0], profiles[1], responses[1])
+			if err != nil {
+				panic(err)
+			}
+
+			if diff == "" {
+				fmt.Fprintln(Stdout, "No differences found")
+				return
+			}
+
+			if tty {
+				if d, err := Highlight("diff", []byte(diff)); err == nil {
+					diff = string(d)
+				}
+			}
+			fmt.Fprint(Stdout, diff)
+			exitCode = 1
+		},
+	}
+}
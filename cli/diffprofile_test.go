@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestVolatileHeaders(t *testing.T) {
+	for _, name := range []string{"Date", "set-cookie", "X-Request-Id", "X-Correlation-ID", "Traceparent", "X-Session-Token"} {
+		assert.True(t, isVolatileHeader(name), name)
+	}
+
+	for _, name := range []string{"Content-Type", "Etag", "X-Env"} {
+		assert.False(t, isVolatileHeader(name), name)
+	}
+}
+
+func TestDiffProfiles(t *testing.T) {
+	defer gock.Off()
+	reset(false)
+
+	configs["diff-test"] = &APIConfig{
+		name: "diff-test",
+		Base: "http://example.com",
+		Profiles: map[string]*APIProfile{
+			"staging": {Headers: map[string]string{"X-Env": "staging"}},
+			"prod":    {Headers: map[string]string{"X-Env": "prod"}},
+		},
+	}
+	defer delete(configs, "diff-test")
+
+	gock.New("http://example.com").
+		Put("/items/item1").
+		MatchHeader("X-Env", "staging").
+		BodyString("name").
+		Reply(200).
+		SetHeader("X-Request-Id", "abc").
+		JSON(map[string]interface{}{"name": "Foo", "env": "staging"})
+
+	gock.New("http://example.com").
+		Put("/items/item1").
+		MatchHeader("X-Env", "prod").
+		BodyString("name").
+		Reply(200).
+		SetHeader("X-Request-Id", "def").
+		JSON(map[string]interface{}{"name": "Foo", "env": "prod"})
+
+	// Capture the request built from the operation's arguments.
+	op := Operation{
+		Name:        "get-item",
+		Method:      http.MethodGet,
+		URITemplate: "http://example.com/items/{id}",
+		PathParams:  []*Param{{Type: "string", Name: "id"}},
+	}
+
+	var req *http.Request
+	sub := op.commandWithHandler(func(r *http.Request) error {
+		req = r
+		return nil
+	})
+	assert.NoError(t, sub.RunE(sub, []string{"item1"}))
+	assert.Equal(t, "http://example.com/items/item1", req.URL.String())
+
+	req, _ = http.NewRequest(http.MethodPut, "http://example.com/items/item1", strings.NewReader("name"))
+	responses, err := fetchProfiles(req, []string{"staging", "prod"})
+	assert.NoError(t, err)
+	assert.True(t, gock.IsDone())
+
+	diff, err := diffResponses("staging", responses[0], "prod", responses[1])
+	assert.NoError(t, err)
+	assert.Contains(t, diff, "--- staging\n+++ prod\n")
+	assert.Contains(t, diff, "-    \"env\": \"staging\",\n+    \"env\": \"prod\",\n")
+	assert.NotContains(t, diff, "Request-Id")
+
+	same, err := diffResponses("staging", responses[0], "staging", responses[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "", same)
+
+	// Invalid profiles are returned as errors rather than panicking.
+	_, err = fetchProfiles(req, []string{"missing"})
+	assert.Error(t, err)
+}
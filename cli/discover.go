@@ -0,0 +1,318 @@
+package cli
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// discoverRegistry lists the well-known APIs to look for credentials for. AWS
+// is deliberately left out: its requests must be signed with SigV4, which no
+// auth handler supports, and it has no single base URI to register since each
+// service and region has its own.
+//
+//go:embed discover.json
+var discoverRegistry []byte
+
+// knownAPI describes a well-known API and where to find credentials for it.
+type knownAPI struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+	Base  string `json:"base"`
+
+	// Env maps template variable names to the environment variables which may
+	// contain their values, in order of preference.
+	Env map[string][]string `json:"env"`
+
+	// Headers and Auth can contain `{variable}` placeholders.
+	Headers map[string]string `json:"headers,omitempty"`
+	Auth    *APIAuth          `json:"auth,omitempty"`
+}
+
+// netrcEntry is a single `machine` in a netrc file.
+type netrcEntry struct {
+	Machine  string
+	Login    string
+	Password string
+}
+
+// discovery is a suggested API configuration found from local credentials.
+type discovery struct {
+	Source string
+	Title  string
+	Config *APIConfig
+}
+
+func loadKnownAPIs() ([]knownAPI, error) {
+	known := []knownAPI{}
+	if err := json.Unmarshal(discoverRegistry, &known); err != nil {
+		return nil, err
+	}
+	return known, nil
+}
+
+// parseNetrc parses netrc-formatted credentials. Macro definitions and the
+// `default` entry are ignored.
+func parseNetrc(data string) []netrcEntry {
+	entries := []netrcEntry{}
+	var current *netrcEntry
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	inMacro := false
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if inMacro {
+			// Macros end with a blank line.
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			if strings.HasPrefix(fields[i], "#") {
+				break
+			}
+
+			value := ""
+			if i+1 < len(fields) {
+				value = fields[i+1]
+			}
+
+			switch fields[i] {
+			case "machine":
+				entries = append(entries, netrcEntry{Machine: value})
+				current = &entries[len(entries)-1]
+				i++
+			case "default":
+				current = nil
+			case "login":
+				if current != nil {
+					current.Login = value
+				}
+				i++
+			case "password":
+				if current != nil {
+					current.Password = value
+				}
+				i++
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+
+	return entries
+}
+
+// readNetrc loads entries from `$NETRC` or `~/.netrc` if present.
+func readNetrc() []netrcEntry {
+	filename := os.Getenv("NETRC")
+	if filename == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		filename = filepath.Join(home, ".netrc")
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+
+	return parseNetrc(string(data))
+}
+
+// renderTemplate replaces `{name}` placeholders with the given values.
+func renderTemplate(template string, values map[string]string) string {
+	for k, v := range values {
+		template = strings.ReplaceAll(template, "{"+k+"}", v)
+	}
+	return template
+}
+
+// newDiscoveredConfig creates an API config with a default profile.
+func newDiscoveredConfig(name, base string, headers map[string]string, auth *APIAuth) *APIConfig {
+	profile := &APIProfile{Headers: headers, Auth: auth}
+	return &APIConfig{
+		name:     name,
+		Base:     base,
+		Profiles: map[string]*APIProfile{"default": profile},
+	}
+}
+
+// fromKnownAPI renders a known API config with the given template values.
+func fromKnownAPI(api knownAPI, values map[string]string) *APIConfig {
+	var headers map[string]string
+	if len(api.Headers) > 0 {
+		headers = map[string]string{}
+		for k, v := range api.Headers {
+			headers[k] = renderTemplate(v, values)
+		}
+	}
+
+	var auth *APIAuth
+	if api.Auth != nil {
+		auth = &APIAuth{Name: api.Auth.Name, Params: map[string]string{}}
+		for k, v := range api.Auth.Params {
+			auth.Params[k] = renderTemplate(v, values)
+		}
+	}
+
+	return newDiscoveredConfig(api.Name, api.Base, headers, auth)
+}
+
+// nameFromHost derives an API short name from a hostname, e.g.
+// `api.example.com` becomes `example`.
+func nameFromHost(host string) string {
+	parts := strings.Split(host, ".")
+	for _, part := range parts[:len(parts)-1] {
+		if part != "api" && part != "www" {
+			return part
+		}
+	}
+	return parts[0]
+}
+
+// discoverAPIs finds credentials for APIs which are not yet configured.
+// Environment variables are checked for known APIs first, followed by netrc
+// entries.
+func discoverAPIs(known []knownAPI, getenv func(string) string, netrc []netrcEntry) []discovery {
+	found := []discovery{}
+	seen := map[string]bool{}
+
+	isNew := func(name, base string) bool {
+		if seen[name] || configs[name] != nil {
+			return false
+		}
+		if n, _ := findAPI(base); n != "" {
+			return false
+		}
+		return true
+	}
+
+	machines := map[string]netrcEntry{}
+	for _, entry := range netrc {
+		machines[entry.Machine] = entry
+	}
+
+	for _, api := range known {
+		if !isNew(api.Name, api.Base) {
+			continue
+		}
+
+		values := map[string]string{}
+		sources := []string{}
+		for variable, candidates := range api.Env {
+			for _, env := range candidates {
+				if v := getenv(env); v != "" {
+					values[variable] = v
+					sources = append(sources, "$"+env)
+					break
+				}
+			}
+		}
+
+		if len(api.Env) > 0 && len(values) == len(api.Env) {
+			sort.Strings(sources)
+			found = append(found, discovery{
+				Source: strings.Join(sources, ", "),
+				Title:  api.Title,
+				Config: fromKnownAPI(api, values),
+			})
+			seen[api.Name] = true
+			continue
+		}
+
+		if u, err := url.Parse(api.Base); err == nil {
+			if entry, ok := machines[u.Hostname()]; ok && entry.Login != "" {
+				found = append(found, discovery{
+					Source: "~/.netrc",
+					Title:  api.Title,
+					Config: newDiscoveredConfig(api.Name, api.Base, nil, &APIAuth{
+						Name:   "http-basic",
+						Params: map[string]string{"username": entry.Login, "password": entry.Password},
+					}),
+				})
+				seen[api.Name] = true
+				delete(machines, u.Hostname())
+			}
+		}
+	}
+
+	for _, entry := range netrc {
+		if _, ok := machines[entry.Machine]; !ok || entry.Machine == "" || entry.Login == "" {
+			continue
+		}
+
+		name := nameFromHost(entry.Machine)
+		base := "https://" + entry.Machine
+		if !isNew(name, base) {
+			continue
+		}
+
+		found = append(found, discovery{
+			Source: "~/.netrc",
+			Title:  entry.Machine,
+			Config: newDiscoveredConfig(name, base, nil, &APIAuth{
+				Name:   "http-basic",
+				Params: map[string]string{"username": entry.Login, "password": entry.Password},
+			}),
+		})
+		seen[name] = true
+	}
+
+	return found
+}
+
+// askDiscover suggests each discovered API to the user and saves the ones
+// which are confirmed.
+func askDiscover(a asker, found []discovery) {
+	if len(found) == 0 {
+		fmt.Fprintln(Stdout, "No new APIs found.")
+		return
+	}
+
+	for _, d := range found {
+		c := d.Config
+		fmt.Fprintf(Stdout, "Found credentials for %s in %s\n", d.Title, d.Source)
+
+		if !a.askConfirm(fmt.Sprintf("Register API `%s` with base %s?", c.name, c.Base), true, fmt.Sprintf("This is equivalent to running `%s api configure %s %s` and will save the credentials to the default profile.", Root.CommandPath(), c.name, c.Base)) {
+			continue
+		}
+
+		if err := c.Save(); err != nil {
+			panic(err)
+		}
+		configs[c.name] = c
+	}
+}
+
+func discoverCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "discover",
+		Short: "Discover APIs from local credentials",
+		Long:  "Scan environment variables like `GITHUB_TOKEN` and `~/.netrc` for credentials to well-known APIs which are not yet configured, and suggest configurations to register after confirmation.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			known, err := loadKnownAPIs()
+			if err != nil {
+				panic(err)
+			}
+
+			askDiscover(defaultAsker{}, discoverAPIs(known, os.Getenv, readNetrc()))
+		},
+	}
+}
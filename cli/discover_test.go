@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNetrc(t *testing.T) {
+	entries := parseNetrc(`# Credentials
+machine api.github.com login octocat password secret
+machine example.com
+  login user
+  password pass
+
+macdef init
+machine not-a-machine login foo
+
+default login anonymous password guest
+`)
+
+	assert.Equal(t, []netrcEntry{
+		{Machine: "api.github.com", Login: "octocat", Password: "secret"},
+		{Machine: "example.com", Login: "user", Password: "pass"},
+	}, entries)
+}
+
+func TestDiscoverAPIs(t *testing.T) {
+	reset(false)
+
+	known, err := loadKnownAPIs()
+	assert.NoError(t, err)
+
+	env := map[string]string{
+		"GH_TOKEN":           "gh-abc",
+		"TWILIO_ACCOUNT_SID": "AC123",
+	}
+
+	found := discoverAPIs(known, func(k string) string { return env[k] }, []netrcEntry{
+		{Machine: "api.twilio.com", Login: "AC456", Password: "tw-secret"},
+		{Machine: "api.example.com", Login: "user", Password: "pass"},
+	})
+
+	assert.Len(t, found, 3)
+
+	assert.Equal(t, "$GH_TOKEN", found[0].Source)
+	assert.Equal(t, "github", found[0].Config.name)
+	assert.Equal(t, "https://api.github.com", found[0].Config.Base)
+	assert.Equal(t, "Bearer gh-abc", found[0].Config.Profiles["default"].Headers["Authorization"])
+
+	// Twilio needs two env vars, so falls back to netrc.
+	assert.Equal(t, "~/.netrc", found[1].Source)
+	assert.Equal(t, "twilio", found[1].Config.name)
+	assert.Equal(t, "AC456", found[1].Config.Profiles["default"].Auth.Params["username"])
+
+	assert.Equal(t, "example", found[2].Config.name)
+	assert.Equal(t, "https://api.example.com", found[2].Config.Base)
+	assert.Equal(t, "http-basic", found[2].Config.Profiles["default"].Auth.Name)
+}
+
+func TestDiscoverSkipsConfigured(t *testing.T) {
+	reset(false)
+
+	configs["gh"] = &APIConfig{name: "gh", Base: "https://api.github.com"}
+	defer delete(configs, "gh")
+
+	known, err := loadKnownAPIs()
+	assert.NoError(t, err)
+
+	found := discoverAPIs(known, func(k string) string {
+		if k == "GITHUB_TOKEN" {
+			return "abc"
+		}
+		return ""
+	}, nil)
+
+	assert.Empty(t, found)
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -35,6 +36,24 @@ func getEditor() string {
 	return editor
 }
 
+// runEditor opens a file in the given editor command and waits for it to exit.
+func runEditor(editor, filename string) error {
+	parts, err := shlex.Split(editor)
+	if err != nil {
+		return err
+	}
+
+	if len(parts) == 0 {
+		return errors.New("no editor command set")
+	}
+
+	cmd := exec.Command(parts[0], append(parts[1:], filename)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
 func edit(addr string, args []string, interactive, noPrompt bool, exitFunc func(int), editMarshal func(interface{}) ([]byte, error), editUnmarshal func([]byte, interface{}) error, ext string) {
 	if !interactive && len(args) == 0 {
 		fmt.Fprintln(os.Stderr, "No arguments passed to modify the resource. Use `-i` to enable interactive mode.")
@@ -120,16 +139,7 @@ export EDITOR="vim"`)
 		tmp.Close()
 
 		// Open editor and wait for exit
-		parts, err := shlex.Split(editor)
-		panicOnErr(err)
-		name := parts[0]
-		args := append(parts[1:], tmp.Name())
-
-		cmd := exec.Command(name, args...)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		panicOnErr(cmd.Run())
+		panicOnErr(runEditor(editor, tmp.Name()))
 
 		// Read file contents
 		b, err := os.ReadFile(tmp.Name())
@@ -180,5 +190,7 @@ export EDITOR="vim"`)
 		req.Header.Set("If-Unmodified-Since", lastModified)
 	}
 
-	MakeRequestAndFormat(req)
+	if err := MakeRequestAndFormat(req); err != nil {
+		panic(err)
+	}
 }
@@ -1,11 +1,16 @@
 package cli
 
 import (
+	"bufio"
+	"bytes"
+	"compress/flate"
 	"compress/gzip"
+	"compress/zlib"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/andybalholm/brotli"
@@ -31,31 +36,44 @@ func buildAcceptEncodingHeader() string {
 	for name := range encodings {
 		accept = append(accept, name)
 	}
+	sort.Strings(accept)
 
 	return strings.Join(accept, ", ")
 }
 
 // DecodeResponse will replace the response body with a decoding reader if needed.
+// The `Content-Encoding` header may list several encodings in the order they
+// were applied, e.g. `deflate, gzip`, so they are removed in reverse order.
 // Assumes the original body will be closed outside of this function.
 func DecodeResponse(resp *http.Response) error {
-	contentEncoding := resp.Header.Get("content-encoding")
+	contentEncoding := strings.Join(resp.Header.Values("content-encoding"), ",")
+
+	names := []string{}
+	for _, name := range strings.Split(contentEncoding, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || name == "identity" {
+			continue
+		}
+
+		if encodings[name] == nil {
+			return fmt.Errorf("unsupported content-encoding %s", name)
+		}
+		names = append(names, name)
+	}
 
-	if contentEncoding == "" {
+	if len(names) == 0 {
 		// Nothing to do!
 		return nil
 	}
 
-	encoding := encodings[contentEncoding]
-
-	if encoding == nil {
-		return fmt.Errorf("unsupported content-encoding %s", contentEncoding)
-	}
-
-	LogDebug("Decoding response from %s", contentEncoding)
+	LogDebug("Decoding response from %s", strings.Join(names, ", "))
 
-	reader, err := encoding.Reader(resp.Body)
-	if err != nil {
-		return err
+	var reader io.Reader = resp.Body
+	for i := len(names) - 1; i >= 0; i-- {
+		var err error
+		if reader, err = encodings[names[i]].Reader(reader); err != nil {
+			return fmt.Errorf("unable to decode %s: %w", names[i], err)
+		}
 	}
 
 	resp.Body = ioutil.NopCloser(reader)
@@ -78,3 +96,31 @@ type BrotliEncoding struct{}
 func (b BrotliEncoding) Reader(stream io.Reader) (io.Reader, error) {
 	return io.Reader(brotli.NewReader(stream)), nil
 }
+
+// DeflateEncoding supports deflate content encoding, which should be zlib
+// wrapped (RFC 1950) but some servers send raw deflate data (RFC 1951), so
+// zlib is tried first and raw deflate is used if the zlib header is invalid.
+type DeflateEncoding struct{}
+
+// Reader returns a new reader for the stream that removes the deflate encoding.
+func (d DeflateEncoding) Reader(stream io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(stream)
+
+	// Peeking leaves the header in the stream for whichever reader is used.
+	header, err := buffered.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if len(header) == 0 {
+		// Empty bodies, e.g. for `HEAD` requests, have nothing to decode.
+		return buffered, nil
+	}
+
+	if _, err := zlib.NewReader(bytes.NewReader(header)); err == nil {
+		return zlib.NewReader(buffered)
+	}
+
+	LogDebug("Invalid zlib header, decoding raw deflate")
+	return flate.NewReader(buffered), nil
+}
@@ -2,7 +2,9 @@ package cli
 
 import (
 	"bytes"
+	"compress/flate"
 	"compress/gzip"
+	"compress/zlib"
 	"io/ioutil"
 	"net/http"
 	"testing"
@@ -27,6 +29,22 @@ func brEnc(data string) []byte {
 	return b.Bytes()
 }
 
+func deflateEnc(data string) []byte {
+	b := bytes.NewBuffer(nil)
+	w := zlib.NewWriter(b)
+	w.Write([]byte(data))
+	w.Close()
+	return b.Bytes()
+}
+
+func rawDeflateEnc(data string) []byte {
+	b := bytes.NewBuffer(nil)
+	w, _ := flate.NewWriter(b, flate.DefaultCompression)
+	w.Write([]byte(data))
+	w.Close()
+	return b.Bytes()
+}
+
 var encodingTests = []struct {
 	name   string
 	header string
@@ -35,9 +53,17 @@ var encodingTests = []struct {
 	{"none", "", []byte("hello world")},
 	{"gzip", "gzip", gzipEnc("hello world")},
 	{"brotli", "br", brEnc("hello world")},
+	{"deflate", "deflate", deflateEnc("hello world")},
+	{"raw-deflate", "deflate", rawDeflateEnc("hello world")},
+	{"identity", "identity", []byte("hello world")},
+	{"deflate-gzip", "deflate, gzip", gzipEnc(string(deflateEnc("hello world")))},
+	{"gzip-br", "GZIP,br", brEnc(string(gzipEnc("hello world")))},
+	{"br-identity-gzip", "br, identity, gzip", gzipEnc(string(brEnc("hello world")))},
 }
 
 func TestEncodings(parent *testing.T) {
+	reset(false)
+
 	for _, tt := range encodingTests {
 		parent.Run(tt.name, func(t *testing.T) {
 			resp := &http.Response{
@@ -56,3 +82,54 @@ func TestEncodings(parent *testing.T) {
 		})
 	}
 }
+
+func TestEncodingChainHeaders(t *testing.T) {
+	reset(false)
+
+	// Encodings may also be listed in separate headers.
+	resp := &http.Response{
+		Header: http.Header{
+			"Content-Encoding": []string{"deflate", "gzip"},
+		},
+		Body: ioutil.NopCloser(bytes.NewReader(gzipEnc(string(deflateEnc("hello world"))))),
+	}
+
+	assert.NoError(t, DecodeResponse(resp))
+	data, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+}
+
+func TestEncodingUnsupported(t *testing.T) {
+	reset(false)
+
+	resp := &http.Response{
+		Header: http.Header{
+			"Content-Encoding": []string{"gzip, compress"},
+		},
+		Body: ioutil.NopCloser(bytes.NewReader(gzipEnc("hello world"))),
+	}
+
+	assert.EqualError(t, DecodeResponse(resp), "unsupported content-encoding compress")
+}
+
+func TestDeflateEncoding(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"zlib": deflateEnc("hello world"),
+		"raw":  rawDeflateEnc("hello world"),
+	} {
+		reader, err := DeflateEncoding{}.Reader(bytes.NewReader(data))
+		assert.NoError(t, err, name)
+
+		decoded, err := ioutil.ReadAll(reader)
+		assert.NoError(t, err, name)
+		assert.Equal(t, "hello world", string(decoded), name)
+	}
+
+	// An empty body has nothing to decode.
+	reader, err := DeflateEncoding{}.Reader(bytes.NewReader(nil))
+	assert.NoError(t, err)
+	decoded, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Empty(t, decoded)
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"runtime/debug"
+
+	"github.com/spf13/viper"
+)
+
+// Exit codes for categories of errors, so that scripts can tell e.g. a typo
+// in the arguments apart from a server being down.
+const (
+	ExitCodeError       = 1
+	ExitCodeUsage       = 2
+	ExitCodeClientError = 3
+	ExitCodeServerError = 4
+	ExitCodeTransport   = 5
+)
+
+// ExitError is an error with a message for the user and the exit code to use
+// when it stops a command.
+type ExitError struct {
+	Code    int
+	Message string
+	Err     error
+}
+
+func (e *ExitError) Error() string {
+	switch {
+	case e.Err == nil:
+		return e.Message
+	case e.Message == "":
+		return e.Err.Error()
+	}
+
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// exitError returns an error which exits with `code`, describing the cause
+// `err` (which may be `nil`) with a formatted message.
+func exitError(code int, err error, format string, values ...interface{}) error {
+	return &ExitError{Code: code, Message: fmt.Sprintf(format, values...), Err: err}
+}
+
+// exitCodeFor returns the exit code for an error. Errors without an explicit
+// code which come from connecting to a server use the transport code, while
+// anything else is a generic error.
+func exitCodeFor(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Op != "parse" {
+		return ExitCodeTransport
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ExitCodeTransport
+	}
+
+	return ExitCodeError
+}
+
+// statusExitCode returns the exit code for an HTTP response status, which is
+// zero unless the status is a client or server error.
+func statusExitCode(status int) int {
+	switch {
+	case status >= 400 && status < 500:
+		return ExitCodeClientError
+	case status >= 500 && status < 600:
+		return ExitCodeServerError
+	}
+
+	return 0
+}
+
+// checkStatus sets a non-zero exit code for error responses, which is `3` for
+// client errors and `4` for server errors. Problem responses with any other
+// status exit with `1`.
+func checkStatus(resp Response) {
+	if code := statusExitCode(resp.Status); code != 0 {
+		exitCode = code
+		return
+	}
+
+	if isProblem(resp.Headers["Content-Type"]) {
+		exitCode = ExitCodeError
+	}
+}
+
+// reportError logs an error which stopped a command and sets the exit code.
+// Stack traces for unexpected errors are only shown with `--rsh-debug` as
+// they rarely help outside of debugging Restish itself.
+func reportError(err error, stack bool) {
+	exitCode = exitCodeFor(err)
+	LogError("%v", err)
+
+	if stack && viper.GetBool("rsh-debug") {
+		fmt.Fprintf(Stderr, "%s\n", debug.Stack())
+	}
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestExitCodes(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/ok").Reply(200).JSON(map[string]interface{}{})
+	gock.New("http://example.com").Get("/missing").Reply(404).JSON(map[string]interface{}{})
+	gock.New("http://example.com").Get("/fail").Reply(502).BodyString("bad gateway")
+
+	run("http://example.com/ok")
+	assert.Equal(t, 0, GetExitCode())
+
+	run("http://example.com/missing")
+	assert.Equal(t, ExitCodeClientError, GetExitCode())
+
+	run("http://example.com/fail")
+	assert.Equal(t, ExitCodeServerError, GetExitCode())
+
+	// Missing arguments print the usage.
+	out := run("get")
+	assert.Equal(t, ExitCodeUsage, GetExitCode())
+	assert.Contains(t, out, "Usage:")
+
+	out = run("post http://example.com/items --rsh-request-template /does-not-exist.json")
+	assert.Equal(t, ExitCodeUsage, GetExitCode())
+	assert.Contains(t, out, "invalid request body")
+	assert.NotContains(t, out, "Usage:")
+	assert.NotContains(t, out, "goroutine")
+
+	out = run("http://example.com/%zz")
+	assert.Equal(t, ExitCodeUsage, GetExitCode())
+	assert.Contains(t, out, "invalid URI")
+
+	gock.Off()
+	run("http://127.0.0.1:1/")
+	assert.Equal(t, ExitCodeTransport, GetExitCode())
+}
+
+func TestReportErrorStack(t *testing.T) {
+	defer reset(false)
+
+	reset(false)
+	capture := &strings.Builder{}
+	Stderr = capture
+
+	reportError(&ExitError{Code: ExitCodeUsage, Message: "bad input", Err: errors.New("oops")}, true)
+	assert.Equal(t, ExitCodeUsage, GetExitCode())
+	assert.Contains(t, capture.String(), "bad input: oops")
+	assert.NotContains(t, capture.String(), "goroutine")
+
+	viper.Set("rsh-debug", true)
+	reportError(errors.New("boom"), true)
+	assert.Equal(t, ExitCodeError, GetExitCode())
+	assert.Contains(t, capture.String(), "goroutine")
+}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Exporter converts a raw API description document into another format, for
+// example to downconvert OpenAPI 3 to Swagger 2.0 for older tooling.
+type Exporter interface {
+	// Detect if the exporter is able to convert the given API description.
+	Detect(resp *http.Response) bool
+
+	// Export the API description into a document which can be marshalled to
+	// JSON or YAML for output.
+	Export(spec url.URL, resp *http.Response) (interface{}, error)
+}
+
+var exportCommand *cobra.Command
+
+// AddExporter registers a new named exporter as an `export` sub-command.
+func AddExporter(name, description string, e Exporter) {
+	exportCommand.AddCommand(&cobra.Command{
+		Use:               name + " short-name",
+		Short:             description,
+		Long:              description + ". Loads the API description and prints the converted document as JSON (default) or YAML.",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeAPINames,
+		Run: func(cmd *cobra.Command, args []string) {
+			doc, err := exportAPI(args[0], e)
+			if err != nil {
+				panic(err)
+			}
+
+			outFormat := viper.GetString("rsh-output-format")
+			encoded, lexer, err := marshalDocument(outFormat, doc)
+			if err != nil {
+				panic(err)
+			}
+
+			if tty {
+				if encoded, err = Highlight(lexer, encoded); err != nil {
+					panic(err)
+				}
+			}
+
+			fmt.Fprint(Stdout, string(encoded))
+		},
+	})
+}
+
+// completeAPINames completes the first argument with configured API names.
+// Any further arguments fall back to file completion.
+func completeAPINames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+
+	possible := []string{}
+	for name := range configs {
+		if strings.HasPrefix(name, toComplete) {
+			possible = append(possible, name)
+		}
+	}
+	return possible, cobra.ShellCompDirectiveNoFileComp
+}
+
+// exportAPI finds the API description for the named API and converts it with
+// the given exporter.
+func exportAPI(name string, e Exporter) (interface{}, error) {
+	config := configs[name]
+	if config == nil {
+		return nil, fmt.Errorf("API %s not found", name)
+	}
+
+	base := config.Base
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+
+	uri, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+
+	specs, err := findSpecs(name, config, uri, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, spec := range specs {
+		if e.Detect(spec.response()) {
+			return e.Export(spec.location, spec.response())
+		}
+	}
+
+	return nil, fmt.Errorf("no exportable API description found for %s", name)
+}
+
+// marshalDocument encodes a document as YAML if requested, otherwise as
+// indented JSON. Returns the encoded bytes and the lexer name to highlight
+// them with.
+func marshalDocument(outFormat string, doc interface{}) ([]byte, string, error) {
+	if outFormat == "yaml" {
+		encoded, err := yaml.Marshal(doc)
+		return encoded, "yaml", err
+	}
+
+	encoded, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+	return append(encoded, '\n'), "json", nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -11,46 +12,72 @@ import (
 func AddGlobalFlag(name, short, description string, defaultValue interface{}, multi bool) {
 	viper.SetDefault(name, defaultValue)
 
+	if multi {
+		if s, ok := viper.Get(name).(string); ok {
+			// Probably loaded from the environment.
+			viper.Set(name, strings.Split(s, ","))
+		}
+	}
+
 	flags := Root.PersistentFlags()
+	addFlag(flags, name, short, description, defaultValue, multi)
+
+	// Flags needed before the config is loaded are already registered.
+	if GlobalFlags.Lookup(name) == nil {
+		addFlag(GlobalFlags, name, short, description, defaultValue, multi)
+	}
+
+	viper.BindPFlag(name, flags.Lookup(name))
+}
+
+// addFlag adds a flag to a flag set using the current config value as its
+// default.
+func addFlag(flags *pflag.FlagSet, name, short, description string, defaultValue interface{}, multi bool) {
 	switch defaultValue.(type) {
 	case bool:
 		if multi {
 			flags.BoolSliceP(name, short, viper.Get(name).([]bool), description)
-			GlobalFlags.BoolSliceP(name, short, viper.Get(name).([]bool), description)
 		} else {
 			flags.BoolP(name, short, viper.GetBool(name), description)
-			GlobalFlags.BoolP(name, short, viper.GetBool(name), description)
 		}
 	case int, int16, int32, int64, uint16, uint32, uint64:
 		if multi {
 			flags.IntSliceP(name, short, viper.Get(name).([]int), description)
-			GlobalFlags.IntSliceP(name, short, viper.Get(name).([]int), description)
 		} else {
 			flags.IntP(name, short, viper.GetInt(name), description)
-			GlobalFlags.IntP(name, short, viper.GetInt(name), description)
 		}
 	case float32, float64:
 		if multi {
 			panic(fmt.Errorf("unsupported float slice param"))
 		} else {
 			flags.Float64P(name, short, viper.GetFloat64(name), description)
-			GlobalFlags.Float64P(name, short, viper.GetFloat64(name), description)
 		}
 	default:
 		if multi {
-			v := viper.Get(name)
-			if s, ok := v.(string); ok {
-				// Probably loaded from the environment.
-				v = strings.Split(s, ",")
-				viper.Set(name, v)
-			}
-			flags.StringSliceP(name, short, v.([]string), description)
-			GlobalFlags.StringSliceP(name, short, v.([]string), description)
+			flags.StringSliceP(name, short, viper.Get(name).([]string), description)
 		} else {
 			flags.StringP(name, short, fmt.Sprintf("%v", viper.Get(name)), description)
-			GlobalFlags.StringP(name, short, fmt.Sprintf("%v", viper.Get(name)), description)
 		}
 	}
+}
+
+// addGlobalArrayFlag makes a new repeatable global string flag. Unlike multi
+// flags from `AddGlobalFlag`, each value is used verbatim rather than being
+// split into a comma-separated list, so values may contain commas.
+func addGlobalArrayFlag(name, short, description string) {
+	viper.SetDefault(name, []string{})
+
+	v := viper.Get(name)
+	if s, ok := v.(string); ok {
+		// Probably loaded from the environment, which has no way to repeat a
+		// value, so it is still treated as a comma-separated list.
+		v = strings.Split(s, ",")
+		viper.Set(name, v)
+	}
+
+	flags := Root.PersistentFlags()
+	flags.StringArrayP(name, short, v.([]string), description)
+	GlobalFlags.StringArrayP(name, short, v.([]string), description)
 
 	viper.BindPFlag(name, flags.Lookup(name))
 }
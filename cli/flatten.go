@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// flatKeyIndexes matches the array indexes at the end of a flattened key
+// segment, e.g. `items[0][1]`.
+var flatKeyIndexes = regexp.MustCompile(`^(.*?)((?:\[\d+\])*)$`)
+
+// flattenValue adds the values of a nested document to `flat` using keys like
+// `user.name` or `items[0].id`. Empty objects and arrays are kept as values so
+// they survive a round trip.
+func flattenValue(prefix string, value interface{}, sep string, flat map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && prefix != "" {
+			flat[prefix] = v
+		}
+		for k, item := range v {
+			key := k
+			if prefix != "" {
+				key = prefix + sep + k
+			}
+			flattenValue(key, item, sep, flat)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			flat[prefix] = v
+		}
+		for i, item := range v {
+			flattenValue(fmt.Sprintf("%s[%d]", prefix, i), item, sep, flat)
+		}
+	default:
+		flat[prefix] = v
+	}
+}
+
+// flatten converts nested objects into a single level object with keys like
+// `user.name` or `items[0].id`. A list is flattened item by item, so a list of
+// objects becomes rows that work well as a table or CSV. Other values are
+// returned unchanged.
+func flatten(data interface{}, sep string) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		flat := map[string]interface{}{}
+		flattenValue("", v, sep, flat)
+		return flat
+	case []interface{}:
+		rows := make([]interface{}, len(v))
+		for i, item := range v {
+			rows[i] = flatten(item, sep)
+		}
+		return rows
+	}
+
+	return data
+}
+
+// flatKeyToken is a single object key or array index in a flattened key.
+type flatKeyToken struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseFlatKey splits a flattened key like `items[0].id` into its object keys
+// and array indexes.
+func parseFlatKey(key, sep string) []flatKeyToken {
+	tokens := []flatKeyToken{}
+	for _, part := range strings.Split(key, sep) {
+		m := flatKeyIndexes.FindStringSubmatch(part)
+		if m[1] != "" || m[2] == "" {
+			tokens = append(tokens, flatKeyToken{key: m[1]})
+		}
+
+		if m[2] != "" {
+			for _, index := range strings.Split(strings.Trim(m[2], "[]"), "][") {
+				i, _ := strconv.Atoi(index)
+				tokens = append(tokens, flatKeyToken{index: i, isIndex: true})
+			}
+		}
+	}
+	return tokens
+}
+
+// errFlatKeyConflict is returned when two flattened keys describe different
+// values at the same place, like `a` and `a.b`.
+var errFlatKeyConflict = errors.New("conflicting keys")
+
+// setFlatValue sets the value at a parsed key within a node, creating objects
+// and arrays as needed, and returns the updated node.
+func setFlatValue(node interface{}, tokens []flatKeyToken, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		if node != nil {
+			return nil, errFlatKeyConflict
+		}
+		return value, nil
+	}
+
+	token := tokens[0]
+	if token.isIndex {
+		list, ok := node.([]interface{})
+		if node != nil && !ok {
+			return nil, errFlatKeyConflict
+		}
+		for len(list) <= token.index {
+			list = append(list, nil)
+		}
+
+		item, err := setFlatValue(list[token.index], tokens[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		list[token.index] = item
+		return list, nil
+	}
+
+	m, ok := node.(map[string]interface{})
+	if node != nil && !ok {
+		return nil, errFlatKeyConflict
+	}
+	if m == nil {
+		m = map[string]interface{}{}
+	}
+
+	item, err := setFlatValue(m[token.key], tokens[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	m[token.key] = item
+	return m, nil
+}
+
+// unflatten reverses `flatten`, rebuilding nested objects and arrays from
+// keys like `user.name` or `items[0].id`. A list of flattened objects is
+// converted item by item.
+func unflatten(data interface{}, sep string) (interface{}, error) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var result interface{} = map[string]interface{}{}
+		for _, k := range keys {
+			var err error
+			if result, err = setFlatValue(result, parseFlatKey(k, sep), v[k]); err != nil {
+				return nil, fmt.Errorf("unable to unflatten key %s: %w", k, err)
+			}
+		}
+		return result, nil
+	case []interface{}:
+		rows := make([]interface{}, len(v))
+		for i, item := range v {
+			row, err := unflatten(item, sep)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+			rows[i] = row
+		}
+		return rows, nil
+	}
+
+	return nil, errors.New("unflatten expects an object or a list of objects")
+}
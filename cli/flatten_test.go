@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlattenRoundTrip(t *testing.T) {
+	nested := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name": "Alice",
+			"tags": []interface{}{"a", "b"},
+		},
+		"items": []interface{}{
+			map[string]interface{}{"id": 1.0},
+			map[string]interface{}{"id": 2.0, "empty": map[string]interface{}{}},
+		},
+		"grid":  []interface{}{[]interface{}{1.0, 2.0}},
+		"none":  []interface{}{},
+		"value": nil,
+	}
+
+	flat := flatten(nested, ".")
+	assert.Equal(t, map[string]interface{}{
+		"user.name":      "Alice",
+		"user.tags[0]":   "a",
+		"user.tags[1]":   "b",
+		"items[0].id":    1.0,
+		"items[1].id":    2.0,
+		"items[1].empty": map[string]interface{}{},
+		"grid[0][0]":     1.0,
+		"grid[0][1]":     2.0,
+		"none":           []interface{}{},
+		"value":          nil,
+	}, flat)
+
+	result, err := unflatten(flat, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, nested, result)
+
+	// Lists are flattened item by item.
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"a/b": 1.0},
+	}, flatten([]interface{}{map[string]interface{}{"a": map[string]interface{}{"b": 1.0}}}, "/"))
+}
+
+func TestUnflattenErrors(t *testing.T) {
+	_, err := unflatten(map[string]interface{}{"a": 1.0, "a.b": 2.0}, ".")
+	assert.EqualError(t, err, "unable to unflatten key a.b: conflicting keys")
+
+	_, err = unflatten(map[string]interface{}{"a[0]": 1.0, "a.b": 2.0}, ".")
+	assert.Error(t, err)
+
+	_, err = unflatten("foo", ".")
+	assert.Error(t, err)
+}
+
+func TestFormatFlatten(t *testing.T) {
+	filename := path.Join(t.TempDir(), "users.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`[
+		{"user": {"name": "Alice"}, "items": [{"id": 1}]},
+		{"user": {"name": "Bob, Jr."}, "items": []}
+	]`), 0600))
+
+	assert.JSONEq(t, `[
+		{"user.name": "Alice", "items[0].id": 1},
+		{"user.name": "Bob, Jr.", "items": []}
+	]`, run("format flatten "+filename))
+
+	assert.Equal(t, "items,items[0].id,user.name\n,1,Alice\n[],,\"Bob, Jr.\"\n", run("format flatten "+filename+" -o csv"))
+
+	WithFakeStdin([]byte("a_b: 1\na_c[1]: x\n"), 0, func() {
+		assert.Equal(t, "a:\n  b: 1\n  c:\n  - null\n  - x\n", run("format unflatten --rsh-separator _ -o yaml"))
+	})
+}
@@ -0,0 +1,411 @@
+package cli
+
+import (
+	"fmt"
+
+	jmespath "github.com/danielgtaylor/go-jmespath-plus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// formatResult prints data like a response. Filters apply as usual, e.g.
+// `-f body[0]`, but by default just the data is printed.
+func formatResult(data interface{}) {
+	if viper.GetString("rsh-filter") == "" && viper.GetString("rsh-jsonpath") == "" && viper.GetString("rsh-jq") == "" {
+		viper.Set("rsh-filter", "body")
+	}
+
+	if err := Formatter.Format(Response{Body: data}); err != nil {
+		panic(err)
+	}
+}
+
+// formatConverted prints converted data like a response, see formatResult,
+// as JSON by default.
+func formatConverted(data interface{}) {
+	if viper.GetString("rsh-output-format") == "auto" {
+		viper.Set("rsh-output-format", "json")
+	}
+
+	formatResult(data)
+}
+
+// fileArg returns the optional input filename argument.
+func fileArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return ""
+}
+
+// separatorCommand adds the key separator flag used by `flatten` and
+// `unflatten` to a command.
+func separatorCommand(cmd *cobra.Command) *cobra.Command {
+	cmd.Flags().String("rsh-separator", ".", "Separator between nested object keys")
+	return cmd
+}
+
+// readFormatInput reads structured data from a file, or from stdin if the
+// filename is empty or `-`. JSON is tried first, then YAML.
+func readFormatInput(filename string) (interface{}, error) {
+	b, err := readInput(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+	if err := (JSON{}).Unmarshal(b, &data); err == nil {
+		return data, nil
+	}
+
+	if err := (YAML{}).Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("unable to parse input as JSON or YAML: %w", err)
+	}
+
+	return data, nil
+}
+
+// runAggregate reads the input for an aggregate command like `format sum` and
+// prints the result as a plain number for use in scripts.
+func runAggregate(cmd *cobra.Command, args []string, aggregate func(data interface{}, field string) (string, error)) {
+	data, err := readFormatInput(fileArg(args))
+	if err != nil {
+		panic(err)
+	}
+
+	field, _ := cmd.Flags().GetString("rsh-field")
+	result, err := aggregate(makeJSONSafe(data, false), field)
+	if err != nil {
+		LogError("%v", err)
+		exitCode = 1
+		return
+	}
+
+	fmt.Fprintln(Stdout, result)
+}
+
+func formatCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "format",
+		Short: "Format structured data",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "jq expression [file|-]",
+		Short: "Apply a jq expression to a document",
+		Long:  "Apply a jq expression to a JSON or YAML document from a file or stdin, then print the result like a filtered response, so output formats like `-o yaml`, raw mode, and colors work as usual. Unlike `--rsh-jq`, the expression runs against the document itself rather than a response, so it doesn't start with `.body`.",
+		Example: fmt.Sprintf(`  # Get item IDs from a saved response
+  $ %s format jq '.items[].id' items.json
+
+  # Filter piped data
+  $ %s api.rest.sh/types -o json -f body | %s format jq '.number'`, Root.CommandPath(), Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			filename := ""
+			if len(args) > 1 {
+				filename = args[1]
+			}
+
+			data, err := readFormatInput(filename)
+			if err != nil {
+				panic(err)
+			}
+
+			result, err := searchJQ(args[0], makeJSONSafe(data, true))
+			if err != nil {
+				panic(err)
+			}
+
+			formatResult(result)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "csv-to-json [file|-]",
+		Short: "Convert CSV to a JSON array",
+		Long:  "Convert CSV from a file or stdin to a JSON array with an object for each row, using the header row for field names. Columns of numbers or booleans are converted to those types unless they have leading zeros, and tab or semicolon separated values also work. Use `-o yaml`, filters, or raw mode like with a response.",
+		Example: fmt.Sprintf(`  # Convert a CSV export
+  $ %s format csv-to-json users.csv
+
+  # Filter piped CSV
+  $ cat users.csv | %s format csv-to-json -f 'body[0].email'`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			b, err := readInput(fileArg(args))
+			if err != nil {
+				panic(err)
+			}
+
+			var rows interface{}
+			if err := (CSV{}).Unmarshal(b, &rows); err != nil {
+				panic(err)
+			}
+
+			formatConverted(rows)
+		},
+	})
+
+	sortCmd := &cobra.Command{
+		Use:   "sort [file|-]",
+		Short: "Sort a JSON array",
+		Long:  "Stably sort a JSON or YAML array from a file or stdin by JMESPath expressions applied to each item, like `--rsh-by name`. Repeat `--rsh-by` to break ties with more fields. Numbers are compared numerically and RFC 3339 timestamps by time, with `null` sorted first. Without any expressions the items themselves are compared.",
+		Example: fmt.Sprintf(`  # Sort users by name
+  $ %s format sort --rsh-by name users.json
+
+  # Newest first, then by name
+  $ %s api.rest.sh/images -f body | %s format sort --rsh-by created --rsh-by name --rsh-desc -t`, Root.CommandPath(), Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := readFormatInput(fileArg(args))
+			if err != nil {
+				panic(err)
+			}
+
+			by, _ := cmd.Flags().GetStringArray("rsh-by")
+			desc, _ := cmd.Flags().GetBool("rsh-desc")
+			sorted, err := sortItems(makeJSONSafe(data, false), by, desc)
+			if err != nil {
+				panic(err)
+			}
+
+			formatConverted(sorted)
+		},
+	}
+	sortCmd.Flags().StringArray("rsh-by", []string{}, "JMESPath expression to sort by, can be repeated")
+	sortCmd.Flags().Bool("rsh-desc", false, "Sort in descending order")
+	cmd.AddCommand(sortCmd)
+
+	unique := &cobra.Command{
+		Use:   "unique [file|-]",
+		Short: "Remove duplicates from a JSON array",
+		Long:  "Remove duplicate items from a JSON or YAML array from a file or stdin, keeping the last occurrence so newer records win. Use `--rsh-key` with a JMESPath expression like `.id` to compare items by a field, otherwise whole items are compared. Items where the key is missing or `null` are always kept.",
+		Example: fmt.Sprintf(`  # Remove duplicates from merged pages of results
+  $ %s format merge page1.json page2.json | %s format unique --rsh-key .id`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := readFormatInput(fileArg(args))
+			if err != nil {
+				panic(err)
+			}
+
+			key, _ := cmd.Flags().GetString("rsh-key")
+			result, err := uniqueItems(makeJSONSafe(data, false), key)
+			if err != nil {
+				panic(err)
+			}
+
+			formatConverted(result)
+		},
+	}
+	unique.Flags().String("rsh-key", "", "JMESPath expression to compare items by")
+	cmd.AddCommand(unique)
+
+	count := &cobra.Command{
+		Use:   "count [file|-]",
+		Short: "Count items in a JSON array, object, or string",
+		Long:  "Print the number of items in a JSON or YAML array, the number of keys in an object, or the length of a string in bytes from a file or stdin as a plain integer. A filter like `-f body.items` is applied first. Other values can't be counted and give a non-zero exit code.",
+		Example: fmt.Sprintf(`  # Count the items to process
+  $ %s api.rest.sh/images -f body | %s format count
+
+  # Count a nested list
+  $ %s format count -f body.items page.json`, Root.CommandPath(), Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := readFormatInput(fileArg(args))
+			if err != nil {
+				panic(err)
+			}
+
+			data = makeJSONSafe(data, true)
+			if filter := viper.GetString("rsh-filter"); filter != "" {
+				if data, err = jmespath.Search(filter, makeJSONSafe(Response{Body: data}.Map(), true)); err != nil {
+					panic(err)
+				}
+			}
+
+			n, err := countValue(data)
+			if err != nil {
+				LogError("%v", err)
+				exitCode = 1
+				return
+			}
+
+			fmt.Fprintln(Stdout, n)
+		},
+	}
+	cmd.AddCommand(count)
+
+	sum := &cobra.Command{
+		Use:   "sum [file|-]",
+		Short: "Sum numbers in a JSON array",
+		Long:  "Print the sum of the numbers in a JSON or YAML array from a file or stdin as a plain number. Use `--rsh-field` with a JMESPath expression like `.price` to sum a field of each item instead. Values which are `null` or missing are skipped. The result is an exact integer when all the numbers are integers.",
+		Example: fmt.Sprintf(`  # Total price of an order
+  $ %s format sum --rsh-field .price items.json`, Root.CommandPath()),
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runAggregate(cmd, args, sumNumbers)
+		},
+	}
+	sum.Flags().String("rsh-field", "", "JMESPath expression for the number in each item")
+	cmd.AddCommand(sum)
+
+	avg := &cobra.Command{
+		Use:   "avg [file|-]",
+		Short: "Average numbers in a JSON array",
+		Long:  "Print the mean of the numbers in a JSON or YAML array from a file or stdin as a plain number. Use `--rsh-field` with a JMESPath expression like `.price` to average a field of each item instead. Values which are `null` or missing are skipped rather than counted as zero.",
+		Example: fmt.Sprintf(`  # Average image size
+  $ %s api.rest.sh/images -f body | %s format avg --rsh-field .size`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runAggregate(cmd, args, avgNumbers)
+		},
+	}
+	avg.Flags().String("rsh-field", "", "JMESPath expression for the number in each item")
+	cmd.AddCommand(avg)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "select field [field...] [file|-]",
+		Short: "Pick fields from each item in a JSON array",
+		Long:  "Pick fields from each object in a JSON or YAML array from a file or stdin, giving an array of smaller objects like `SELECT id, name FROM items` in SQL. A last argument which is `-` or an existing file is the input file. Fields may be nested using dots like `user.name`, which keeps the nesting in the output. Missing fields are `null`. A single object works too.",
+		Example: fmt.Sprintf(`  # Get just the ID and name of each image
+  $ %s api.rest.sh/images -f body | %s format select id name
+
+  # Pick nested fields from a saved response
+  $ %s format select id user.name items.json`, Root.CommandPath(), Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			fields, filename := selectArgs(args)
+			data, err := readFormatInput(filename)
+			if err != nil {
+				panic(err)
+			}
+
+			selected, err := selectFields(makeJSONSafe(data, false), fields)
+			if err != nil {
+				panic(err)
+			}
+
+			formatConverted(selected)
+		},
+	})
+
+	rename := &cobra.Command{
+		Use:   "rename old:new [old:new...] [file|-]",
+		Short: "Rename keys in JSON objects",
+		Long:  "Rename keys in a JSON or YAML object, or in each object of an array, from a file or stdin. Renames are given as `old:new` pairs and/or read from a JSON or YAML file mapping old names to new ones with `--rsh-rename-file`. A last argument without a `:` is the input file. Only top-level keys are renamed unless `--rsh-recursive` is set. A renamed key replaces any existing key with the new name.",
+		Example: fmt.Sprintf(`  # Normalize field names from another API
+  $ %s format rename user_id:userId created_at:createdAt users.json
+
+  # Rename keys at every level using a mapping file
+  $ %s api.rest.sh/images -f body | %s format rename --rsh-rename-file map.yaml --rsh-recursive`, Root.CommandPath(), Root.CommandPath(), Root.CommandPath()),
+		Run: func(cmd *cobra.Command, args []string) {
+			mapFile, _ := cmd.Flags().GetString("rsh-rename-file")
+			renames, filename, err := renameArgs(args, mapFile)
+			if err != nil {
+				panic(err)
+			}
+
+			data, err := readFormatInput(filename)
+			if err != nil {
+				panic(err)
+			}
+
+			recursive, _ := cmd.Flags().GetBool("rsh-recursive")
+			renamed, err := renameKeys(makeJSONSafe(data, false), renames, recursive)
+			if err != nil {
+				panic(err)
+			}
+
+			formatConverted(renamed)
+		},
+	}
+	rename.Flags().String("rsh-rename-file", "", "JSON or YAML file mapping old key names to new ones")
+	rename.Flags().Bool("rsh-recursive", false, "Rename keys in nested objects too")
+	cmd.AddCommand(rename)
+
+	merge := &cobra.Command{
+		Use:   "merge file [file...]",
+		Short: "Deep merge JSON or YAML documents",
+		Long:  "Deep merge JSON or YAML documents from files or stdin from left to right, so values in later documents win, using `-` for stdin. Objects are merged recursively and arrays are concatenated, or replaced with `--rsh-merge-arrays replace`. The result is printed like a filtered response.",
+		Example: fmt.Sprintf(`  # Combine saved pages of results
+  $ %s format merge page1.json page2.json
+
+  # Apply a config patch
+  $ %s format merge base.yaml patch.yaml --rsh-merge-arrays replace -o yaml`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			arrays, _ := cmd.Flags().GetString("rsh-merge-arrays")
+			if arrays != "concat" && arrays != "replace" {
+				panic(fmt.Errorf("invalid --rsh-merge-arrays %s, must be concat or replace", arrays))
+			}
+
+			var merged interface{}
+			for i, filename := range args {
+				data, err := readFormatInput(filename)
+				if err != nil {
+					panic(fmt.Errorf("%s: %w", filename, err))
+				}
+
+				data = makeJSONSafe(data, false)
+				if i == 0 {
+					merged = data
+					continue
+				}
+				merged = mergeDocuments(merged, data, arrays == "concat")
+			}
+
+			formatConverted(merged)
+		},
+	}
+	merge.Flags().String("rsh-merge-arrays", "concat", "How to merge arrays [concat, replace]")
+	cmd.AddCommand(merge)
+
+	cmd.AddCommand(separatorCommand(&cobra.Command{
+		Use:   "flatten [file|-]",
+		Short: "Flatten nested objects into dotted keys",
+		Long:  "Flatten nested objects in a JSON or YAML document from a file or stdin into a single level with keys like `user.name` and `items[0].id`. A list is flattened item by item, so a list of objects can be shown as a table with `-t` or converted with `-o csv`.",
+		Example: fmt.Sprintf(`  # Flatten a saved response
+  $ %s format flatten user.json
+
+  # Export a list of objects as CSV
+  $ %s api.rest.sh/images -f body | %s format flatten -o csv`, Root.CommandPath(), Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := readFormatInput(fileArg(args))
+			if err != nil {
+				panic(err)
+			}
+
+			sep, _ := cmd.Flags().GetString("rsh-separator")
+			formatConverted(flatten(makeJSONSafe(data, false), sep))
+		},
+	}))
+
+	cmd.AddCommand(separatorCommand(&cobra.Command{
+		Use:   "unflatten [file|-]",
+		Short: "Rebuild nested objects from dotted keys",
+		Long:  "Rebuild nested objects and arrays from a JSON or YAML document with keys like `user.name` and `items[0].id`, reversing `format flatten`. A list of flattened objects is converted item by item.",
+		Example: fmt.Sprintf(`  # Rebuild a flattened document
+  $ %s format unflatten flat.json
+
+  # Use a different separator
+  $ %s format unflatten --rsh-separator / flat.json`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := readFormatInput(fileArg(args))
+			if err != nil {
+				panic(err)
+			}
+
+			sep, _ := cmd.Flags().GetString("rsh-separator")
+			result, err := unflatten(makeJSONSafe(data, false), sep)
+			if err != nil {
+				panic(err)
+			}
+
+			formatConverted(result)
+		},
+	}))
+
+	return cmd
+}
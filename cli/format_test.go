@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatJQ(t *testing.T) {
+	filename := path.Join(t.TempDir(), "items.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`{"items": [{"id": "a"}, {"id": "b"}]}`), 0600))
+
+	assert.JSONEq(t, `["a", "b"]`, run("format jq .items[].id "+filename))
+	assert.Equal(t, "- a\n- b\n", run("format jq .items[].id "+filename+" -o yaml"))
+	assert.Equal(t, "a\nb\n", run("format jq .items[].id "+filename+" -r"))
+
+	// Filters apply to the result like with a response.
+	assert.JSONEq(t, `"b"`, run("format jq .items "+filename+" -f body[1].id"))
+
+	WithFakeStdin([]byte("items:\n  - id: c\n"), 0, func() {
+		assert.JSONEq(t, `"c"`, run("format jq .items[0].id -"))
+	})
+
+	WithFakeStdin([]byte("{"), fs.ModeCharDevice, func() {
+		assert.Contains(t, run("format jq ."), "unable to parse input")
+	})
+}
+
+func TestFormatCSVToJSON(t *testing.T) {
+	filename := path.Join(t.TempDir(), "users.csv")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("name,age,active\nKari,33,true\nLeon,,false\n"), 0600))
+
+	assert.JSONEq(t, `[
+		{"name": "Kari", "age": 33, "active": true},
+		{"name": "Leon", "age": null, "active": false}
+	]`, run("format csv-to-json "+filename))
+	assert.JSONEq(t, `"Leon"`, run("format csv-to-json "+filename+" -f body[1].name"))
+
+	WithFakeStdin([]byte("id;score\na;1.5\n"), 0, func() {
+		assert.Equal(t, "- id: a\n  score: 1.5\n", run("format csv-to-json - -o yaml"))
+	})
+
+	// Leading zeros, like in zip codes, are kept.
+	WithFakeStdin([]byte("city,zip\nBoston,02134\nSan Francisco,94103\n"), 0, func() {
+		assert.JSONEq(t, `[
+			{"city": "Boston", "zip": "02134"},
+			{"city": "San Francisco", "zip": "94103"}
+		]`, run("format csv-to-json"))
+	})
+}
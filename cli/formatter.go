@@ -7,7 +7,9 @@ import (
 	"errors"
 	"fmt"
 	"image/color"
+	"math/big"
 	"net/http"
+	"os"
 	"reflect"
 	"sort"
 	"strconv"
@@ -20,6 +22,7 @@ import (
 	"github.com/alecthomas/chroma/styles"
 	"github.com/charmbracelet/glamour/ansi"
 	jmespath "github.com/danielgtaylor/go-jmespath-plus"
+	"github.com/fxamacker/cbor/v2"
 	"github.com/ghodss/yaml"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh/terminal"
@@ -288,6 +291,18 @@ var MarkdownStyle = ansi.StyleConfig{
 // create map[interface{}]interface{} which causes problems marshalling.
 // See https://github.com/fxamacker/cbor/issues/206
 func makeJSONSafe(obj interface{}, normalizeNumbers bool) interface{} {
+	if n, ok := obj.(json.Number); ok {
+		if normalizeNumbers {
+			return normalizeJSONNumber(n)
+		}
+		return obj
+	}
+
+	if _, ok := obj.(*big.Int); ok {
+		// Large integers from jq already encode correctly.
+		return obj
+	}
+
 	value := reflect.ValueOf(obj)
 
 	switch value.Kind() {
@@ -320,6 +335,14 @@ func makeJSONSafe(obj interface{}, normalizeNumbers bool) interface{} {
 			tmpData[kStr] = makeJSONSafe(value.MapIndex(k).Interface(), normalizeNumbers)
 		}
 		return tmpData
+	case reflect.Struct:
+		if tag, ok := obj.(cbor.Tag); ok {
+			// Unknown CBOR tags are represented by their number and content.
+			return map[string]interface{}{
+				"tag":   makeJSONSafe(tag.Number, normalizeNumbers),
+				"value": makeJSONSafe(tag.Content, normalizeNumbers),
+			}
+		}
 	// case reflect.Struct:
 	// 	for i := 0; i < value.NumField(); i++ {
 	// 		field := value.Field(i)
@@ -338,6 +361,32 @@ func makeJSONSafe(obj interface{}, normalizeNumbers bool) interface{} {
 	return obj
 }
 
+// maxExactFloat is the largest integer which float64 can represent exactly.
+const maxExactFloat = 1 << 53
+
+// normalizeJSONNumber converts a precisely decoded number to float64 for
+// filtering, unless that would lose precision, in which case the number is
+// kept as-is so that it is output exactly.
+func normalizeJSONNumber(n json.Number) interface{} {
+	if i, err := n.Int64(); err == nil {
+		if i >= -maxExactFloat && i <= maxExactFloat {
+			return float64(i)
+		}
+		return n
+	}
+
+	if !strings.ContainsAny(n.String(), ".eE") {
+		// An integer too large for int64.
+		return n
+	}
+
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+
+	return n
+}
+
 // printable returns true if the given body can be printed to a terminal
 // based on displayable unicode character ranges and whitespace. If true,
 // then the body is also returned as a byte slice ready to be written to
@@ -388,10 +437,11 @@ type ResponseFormatter interface {
 	Format(Response) error
 }
 
-// DefaultFormatter can apply JMESPath queries and can output prettyfied JSON
-// and YAML output. If Stdout is a TTY, then colorized output is provided. The
-// default formatter uses the `rsh-filter` and `rsh-output-format` configuration
-// values to perform JMESPath queries and set JSON (default) or YAML output.
+// DefaultFormatter can apply JMESPath or JSONPath queries and can output
+// prettyfied JSON and YAML output. If Stdout is a TTY, then colorized output is
+// provided. The default formatter uses the `rsh-filter`, `rsh-jsonpath`,
+// `rsh-jq`, and `rsh-output-format` configuration values to perform queries
+// and set JSON (default) or YAML output.
 type DefaultFormatter struct {
 	tty bool
 }
@@ -404,14 +454,57 @@ func NewDefaultFormatter(tty bool) *DefaultFormatter {
 	}
 }
 
+// emptyBody returns whether a response body has no content, like for a `204
+// No Content` response or a `HEAD` request.
+func emptyBody(body interface{}) bool {
+	switch b := body.(type) {
+	case nil:
+		return true
+	case []byte:
+		return len(b) == 0
+	case string:
+		return len(b) == 0
+	}
+	return false
+}
+
 // Format will filter, prettify, colorize and output the data.
 func (f *DefaultFormatter) Format(resp Response) error {
+	if viper.GetBool("rsh-headers-only") {
+		fmt.Fprint(Stdout, formatHeaderLines(resp))
+		return nil
+	}
+
+	if emptyBody(resp.Body) {
+		// Responses without content only show the status line and headers, and
+		// structured output always has a `null` body.
+		resp.Body = nil
+	}
+
 	outFormat := viper.GetString("rsh-output-format")
 
-	var data interface{} = resp.Map()
+	m := resp.Map()
+	includeTiming := viper.GetBool("rsh-include-timing") && resp.Duration > 0
+	if includeTiming {
+		m["duration_ms"] = float64(resp.Duration.Microseconds()) / 1000
+	}
+	var data interface{} = m
 
 	filter := viper.GetString("rsh-filter")
-	if filter == "" && viper.GetBool("rsh-raw") {
+	jsonPath := viper.GetString("rsh-jsonpath")
+	jq := viper.GetString("rsh-jq")
+
+	queries := 0
+	for _, q := range []string{filter, jsonPath, jq} {
+		if q != "" {
+			queries++
+		}
+	}
+	if queries > 1 {
+		return errors.New("only one of --rsh-filter, --rsh-jsonpath, or --rsh-jq may be used")
+	}
+
+	if queries == 0 && viper.GetBool("rsh-raw") {
 		if b, ok := resp.Body.([]byte); ok {
 			// The response wasn't decoded so we have a bunch of bytes and the user
 			// asked for raw output, so just write it. This enables file downloads.
@@ -442,6 +535,36 @@ func (f *DefaultFormatter) Format(resp Response) error {
 		data = result
 	}
 
+	if jsonPath != "" {
+		result, err := searchJSONPath(jsonPath, makeJSONSafe(data, true))
+		if err != nil {
+			return err
+		}
+
+		if outFormat == "auto" {
+			outFormat = "json"
+		}
+
+		if result == nil {
+			return nil
+		}
+
+		data = result
+	}
+
+	if jq != "" {
+		result, err := searchJQ(jq, makeJSONSafe(data, true))
+		if err != nil {
+			return err
+		}
+
+		if outFormat == "auto" {
+			outFormat = "json"
+		}
+
+		data = result
+	}
+
 	// Encode to the requested output format using nice formatting.
 	var encoded []byte
 	var err error
@@ -486,7 +609,7 @@ func (f *DefaultFormatter) Format(resp Response) error {
 		} else {
 			for _, item := range data.([]interface{}) {
 				switch item.(type) {
-				case nil, bool, int, int64, float64, string:
+				case nil, bool, int, int64, float64, string, json.Number:
 					// The above are scalars used by decoders
 				default:
 					scalars = false
@@ -525,6 +648,10 @@ func (f *DefaultFormatter) Format(resp Response) error {
 				text += name + ": " + resp.Headers[name] + "\n"
 			}
 
+			if includeTiming {
+				text += "# " + formatDuration(resp.Duration) + "\n"
+			}
+
 			var e []byte
 
 			ct := resp.Headers["Content-Type"]
@@ -546,7 +673,45 @@ func (f *DefaultFormatter) Format(resp Response) error {
 				}
 			}
 
-			if b, ok := printable(resp.Body); ok {
+			if !handled && isProblem(ct) {
+				// Show problem details as a summary rather than a generic document.
+				if problem, ok := problemDetails(resp.Body); ok {
+					if e, err = renderProblem(problem, resp.Status); err != nil {
+						return err
+					}
+					handled = true
+				}
+			}
+
+			if !handled {
+				// Prefer the original body since some formats like XML are decoded
+				// into structured data which no longer looks like the document.
+				// Auto-paginated responses have no original body, so the merged
+				// body is shown instead.
+				raw := resp.raw
+				if raw == nil {
+					if s, ok := resp.Body.(string); ok {
+						raw = []byte(s)
+					} else if b, ok := resp.Body.([]byte); ok {
+						raw = b
+					}
+				}
+
+				if len(bytes.TrimSpace(raw)) > 0 {
+					if pretty, prettyLexer, ok := prettyPrint(ct, raw); ok {
+						e = pretty
+						handled = true
+
+						if f.tty && prettyLexer != "" {
+							if e, err = Highlight(prettyLexer, e); err != nil {
+								return err
+							}
+						}
+					}
+				}
+			}
+
+			if b, ok := printable(resp.Body); !handled && ok {
 				e = b
 				handled = true
 			}
@@ -555,7 +720,7 @@ func (f *DefaultFormatter) Format(resp Response) error {
 				if s, ok := resp.Body.(string); ok {
 					text += "\n" + s
 				} else if reflect.ValueOf(resp.Body).Kind() != reflect.Invalid {
-					e, err = MarshalReadable(resp.Body)
+					e, err = marshalReadable("", resp.Body, resp.keyOrder)
 					if err != nil {
 						return err
 					}
@@ -582,6 +747,7 @@ func (f *DefaultFormatter) Format(resp Response) error {
 				if err != nil {
 					return err
 				}
+				encoded = highlightCorrelationHeaders(text, encoded)
 			} else {
 				encoded = []byte(text)
 			}
@@ -590,9 +756,35 @@ func (f *DefaultFormatter) Format(resp Response) error {
 				encoded = append(encoded, '\n')
 				encoded = append(encoded, e...)
 			}
+
+			if len(resp.Trailers) > 0 {
+				// Trailers are sent after the body, so show them last.
+				if len(encoded) > 0 && encoded[len(encoded)-1] != '\n' {
+					encoded = append(encoded, '\n')
+				}
+				encoded = append(encoded, '\n')
+
+				trailerNames := []string{}
+				for k := range resp.Trailers {
+					trailerNames = append(trailerNames, k)
+				}
+				sort.Strings(trailerNames)
+
+				for _, name := range trailerNames {
+					encoded = append(encoded, []byte(name+": "+resp.Trailers[name]+"\n")...)
+				}
+			}
+		} else if outFormat == "csv" {
+			if encoded, err = marshalCSV(makeJSONSafe(data, false)); err != nil {
+				return err
+			}
 		} else if outFormat == "yaml" {
 			data = makeJSONSafe(data, false)
-			encoded, err = yaml.Marshal(data)
+			if sortKeys() {
+				encoded, err = yaml.Marshal(data)
+			} else {
+				encoded, err = marshalOrderedYAML(data, resp.keyOrder)
+			}
 
 			if err != nil {
 				return err
@@ -600,7 +792,7 @@ func (f *DefaultFormatter) Format(resp Response) error {
 
 			lexer = "yaml"
 		} else {
-			data = makeJSONSafe(data, false)
+			data = withKeyOrder(makeJSONSafe(data, false), resp.keyOrder)
 
 			// The default encoder escapes '<', '>', and '&' which we don't want
 			// since we are not a browser. Disable this with an encoder instance.
@@ -642,6 +834,66 @@ func (f *DefaultFormatter) Format(resp Response) error {
 	return nil
 }
 
+// optionSet returns whether an option was explicitly given for this run via a
+// flag or environment variable.
+func optionSet(name string) bool {
+	if f := Root.PersistentFlags().Lookup(name); f != nil && f.Changed {
+		return true
+	}
+
+	// Environment variables are read by Viper, e.g. `RSH_OUTPUT_FORMAT`.
+	_, ok := os.LookupEnv(strings.ToUpper(strings.ReplaceAll(name, "-", "_")))
+	return ok
+}
+
+// applyOutputDefaults uses the output config of the API for `uri`, if any, for
+// options which weren't explicitly given.
+func applyOutputDefaults(uri string) {
+	_, config := findAPI(uri)
+	if config == nil || config.Output == nil {
+		return
+	}
+
+	if config.Output.Format != "" && !optionSet("rsh-output-format") {
+		viper.Set("rsh-output-format", config.Output.Format)
+	}
+
+	if config.Output.Filter != "" && !optionSet("rsh-filter") && !optionSet("rsh-jsonpath") && !optionSet("rsh-jq") {
+		viper.Set("rsh-filter", config.Output.Filter)
+	}
+
+	if config.Output.Raw && !optionSet("rsh-raw") {
+		viper.Set("rsh-raw", true)
+	}
+}
+
+// formatHeaderLines returns the response headers as tab-separated `name value`
+// lines sorted by name, with one line per value for easy use in scripts.
+func formatHeaderLines(resp Response) string {
+	header := resp.header
+	if header == nil {
+		header = http.Header{}
+		for k, v := range resp.Headers {
+			header.Set(k, v)
+		}
+	}
+
+	names := []string{}
+	for k := range header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	sb := &strings.Builder{}
+	for _, name := range names {
+		for _, v := range header[name] {
+			sb.WriteString(name + "\t" + v + "\n")
+		}
+	}
+
+	return sb.String()
+}
+
 // Only applicable to collection of repeating objects.
 // Filter down to a collection of objects first then apply --table.
 // Simpletable has much more styling that can be applied.
@@ -2,10 +2,15 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
 )
 
 func TestPrintable(t *testing.T) {
@@ -114,6 +119,37 @@ func TestFormatEmptyImage(t *testing.T) {
 	})
 }
 
+func TestFormatNoContent(t *testing.T) {
+	defer gock.Off()
+
+	// Empty and whitespace-only bodies only show the status line and headers.
+	for _, body := range []string{"", "\r\n"} {
+		gock.New("http://example.com").Delete("/items/1").Reply(http.StatusNoContent).BodyString(body)
+		assert.Equal(t, "HTTP/1.1 204 No Content\n", run("delete http://example.com/items/1"))
+
+		gock.New("http://example.com").Get("/items").Reply(http.StatusOK).SetHeader("Content-Type", "application/json").BodyString(body)
+		assert.Equal(t, "HTTP/1.1 200 OK\nContent-Type: application/json\n", run("http://example.com/items"))
+
+		gock.New("http://example.com").Get("/items").Reply(http.StatusOK).SetHeader("Content-Type", "text/plain").BodyString(body)
+		out := run("http://example.com/items -o json")
+		assert.JSONEq(t, `{"proto": "HTTP/1.1", "status": 200, "headers": {"Content-Type": "text/plain"}, "links": {}, "body": null}`, out)
+
+		gock.New("http://example.com").Get("/items").Reply(http.StatusOK).BodyString(body)
+		assert.Equal(t, "body: null\nheaders: {}\nlinks: {}\nproto: HTTP/1.1\nstatus: 200\n", run("http://example.com/items -o yaml"))
+	}
+
+	gock.New("http://example.com").Head("/items").Reply(http.StatusOK).SetHeader("Content-Type", "application/json")
+	assert.Equal(t, "HTTP/1.1 200 OK\nContent-Type: application/json\n", run("head http://example.com/items"))
+
+	// Zero-length bodies passed to the formatter directly are treated the same.
+	buf := &bytes.Buffer{}
+	Stdout = buf
+	viper.Set("rsh-output-format", "json")
+	defer viper.Set("rsh-output-format", "auto")
+	assert.NoError(t, NewDefaultFormatter(false).Format(Response{Proto: "HTTP/1.1", Status: http.StatusNoContent, Body: []byte{}}))
+	assert.Contains(t, buf.String(), `"body": null`)
+}
+
 func TestJSONEscape(t *testing.T) {
 	formatter := NewDefaultFormatter(false)
 	buf := &bytes.Buffer{}
@@ -134,3 +170,264 @@ func TestJSONEscape(t *testing.T) {
 
 	assert.Contains(t, buf.String(), "<em> and & shouldn't get escaped")
 }
+
+func TestJSONPath(t *testing.T) {
+	formatter := NewDefaultFormatter(false)
+	buf := &bytes.Buffer{}
+	Stdout = buf
+	viper.Set("rsh-raw", true)
+	viper.Set("rsh-filter", "")
+	viper.Set("rsh-jsonpath", "$.body.items[*].id")
+	defer viper.Set("rsh-jsonpath", "")
+
+	resp := Response{
+		Body: map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"id": "a"},
+				map[string]interface{}{"id": "b"},
+			},
+		},
+	}
+
+	assert.NoError(t, formatter.Format(resp))
+	assert.Equal(t, "a\nb\n", buf.String())
+
+	// Definite paths return a single value rather than a list.
+	buf.Reset()
+	viper.Set("rsh-jsonpath", "$.body.items[1].id")
+	assert.NoError(t, formatter.Format(resp))
+	assert.Equal(t, "b\n", buf.String())
+
+	// Cannot be combined with JMESPath.
+	viper.Set("rsh-filter", "body")
+	assert.Error(t, formatter.Format(resp))
+	viper.Set("rsh-filter", "")
+}
+
+func TestJQ(t *testing.T) {
+	formatter := NewDefaultFormatter(false)
+	buf := &bytes.Buffer{}
+	Stdout = buf
+	viper.Set("rsh-raw", true)
+	viper.Set("rsh-filter", "")
+	viper.Set("rsh-jq", ".body.items[].id")
+	defer viper.Set("rsh-jq", "")
+
+	resp := Response{
+		Body: map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"id": "a", "count": 1},
+				map[string]interface{}{"id": "b", "count": 2},
+			},
+		},
+	}
+
+	assert.NoError(t, formatter.Format(resp))
+	assert.Equal(t, "a\nb\n", buf.String())
+
+	// A single result is returned directly rather than as a list.
+	buf.Reset()
+	viper.Set("rsh-jq", "[.body.items[].count] | add")
+	assert.NoError(t, formatter.Format(resp))
+	assert.Equal(t, "3\n", buf.String())
+
+	// Errors include the failing expression.
+	viper.Set("rsh-jq", ".body.items[")
+	err := formatter.Format(resp)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `".body.items["`)
+
+	// Cannot be combined with other filters.
+	viper.Set("rsh-jq", ".body")
+	viper.Set("rsh-filter", "body")
+	assert.Error(t, formatter.Format(resp))
+	viper.Set("rsh-filter", "")
+}
+
+func TestPreciseNumbers(t *testing.T) {
+	reset(false)
+	viper.Set("rsh-precise-numbers", true)
+
+	var body interface{}
+	assert.NoError(t, JSON{}.Unmarshal([]byte(`{"id": 1234567890123456789, "small": 5, "ratio": 0.5}`), &body))
+
+	var invalid interface{}
+	assert.Error(t, JSON{}.Unmarshal([]byte(`{} {}`), &invalid))
+
+	readable, err := MarshalReadable(body)
+	assert.NoError(t, err)
+	assert.Contains(t, string(readable), "id: 1234567890123456789")
+
+	// Small numbers can still be compared when filtering.
+	assert.Equal(t, map[string]interface{}{
+		"id":    json.Number("1234567890123456789"),
+		"small": float64(5),
+		"ratio": 0.5,
+	}, makeJSONSafe(body, true))
+
+	formatter := NewDefaultFormatter(false)
+	buf := &bytes.Buffer{}
+	Stdout = buf
+
+	viper.Set("rsh-filter", "body.id")
+	formatter.Format(Response{Body: body})
+	assert.Equal(t, "1234567890123456789\n", buf.String())
+
+	buf.Reset()
+	viper.Set("rsh-filter", "")
+	viper.Set("rsh-jq", ".body.id + 1")
+	formatter.Format(Response{Body: body})
+	assert.Equal(t, "1234567890123456790\n", buf.String())
+}
+
+func TestHeadersOnly(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").
+		Head("/items").
+		Reply(200).
+		SetHeader("Content-Type", "application/json").
+		AddHeader("Set-Cookie", "a=1").
+		AddHeader("Set-Cookie", "b=2").
+		SetHeader("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+
+	out := run("head http://example.com/items --rsh-headers-only")
+	assert.Equal(t, "Content-Type\tapplication/json\nLast-Modified\tMon, 02 Jan 2006 15:04:05 GMT\nSet-Cookie\ta=1\nSet-Cookie\tb=2\n", out)
+}
+
+func TestOutputConfig(t *testing.T) {
+	defer gock.Off()
+
+	setup := func() {
+		reset(false)
+		configs["output-test"] = &APIConfig{
+			name: "output-test",
+			Base: "http://output.example.com",
+			Output: &OutputConfig{
+				Format: "json",
+				Filter: "body.items",
+			},
+		}
+	}
+
+	gock.New("http://output.example.com").
+		Get("/logs").
+		Times(2).
+		Reply(200).
+		JSON(map[string]interface{}{
+			"items": []interface{}{"a", "b"},
+		})
+
+	setup()
+	out := runNoReset("http://output.example.com/logs")
+	assert.JSONEq(t, `["a", "b"]`, out)
+
+	// Explicit flags take precedence over the API's config.
+	setup()
+	out = runNoReset("http://output.example.com/logs -f body.items[0]")
+	assert.JSONEq(t, `"a"`, out)
+}
+
+func TestSortKeys(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		gock.New("http://example.com").
+			Get("/ordered").
+			Reply(http.StatusOK).
+			SetHeader("Content-Type", "application/json").
+			BodyString(`{"zeta": 1, "alpha": {"yankee": true, "bravo": false}, "mike": [{"x": 1, "c": 2}]}`)
+	}
+
+	out := run("http://example.com/ordered -o json -f body")
+	assert.Less(t, strings.Index(out, `"alpha"`), strings.Index(out, `"zeta"`))
+
+	out = run("http://example.com/ordered -o json -f body --rsh-sort-keys=false")
+	assert.JSONEq(t, `{"zeta": 1, "alpha": {"yankee": true, "bravo": false}, "mike": [{"x": 1, "c": 2}]}`, out)
+	assert.Less(t, strings.Index(out, `"zeta"`), strings.Index(out, `"alpha"`))
+	assert.Less(t, strings.Index(out, `"yankee"`), strings.Index(out, `"bravo"`))
+	assert.Less(t, strings.Index(out, `"x"`), strings.Index(out, `"c"`))
+
+	out = run("http://example.com/ordered -o yaml -f body --rsh-sort-keys=false")
+	assert.Equal(t, "zeta: 1\nalpha:\n  yankee: true\n  bravo: false\nmike:\n- x: 1\n  c: 2\n", out)
+}
+
+func TestSortKeysPerResponse(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/first").Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		BodyString(`{"b": 1, "a": 2}`)
+	gock.New("http://example.com").Get("/second").Reply(http.StatusOK).
+		SetHeader("Content-Type", "application/json").
+		BodyString(`{"a": 3, "b": 4}`)
+
+	// Objects with the same keys in another response keep their own order.
+	assert.Equal(t, "{\n  \"b\": 1,\n  \"a\": 2\n}\n", run("http://example.com/first -o json -f body --rsh-sort-keys=false"))
+	assert.Equal(t, "{\n  \"a\": 3,\n  \"b\": 4\n}\n", run("http://example.com/second -o json -f body --rsh-sort-keys=false"))
+}
+
+func TestSortKeysReadable(t *testing.T) {
+	reset(false)
+	viper.Set("rsh-sort-keys", false)
+
+	doc := []byte(`{"second": 2, "first": {"b": 1, "a": 2}}`)
+	var data interface{}
+	assert.NoError(t, JSON{}.Unmarshal(doc, &data))
+
+	out, err := marshalReadable("", data, jsonKeyOrder("application/json", doc))
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  second: 2\n  first: {\n    b: 1\n    a: 2\n  }\n}", string(out))
+
+	// Orders are only known for the document they came from.
+	out, err = MarshalReadable(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  first: {\n    a: 2\n    b: 1\n  }\n  second: 2\n}", string(out))
+}
+
+func TestDecodeOrdered(t *testing.T) {
+	ordered, err := decodeOrdered([]byte(`{"z": [{"y": 1, "x": 2}], "a": null, "z": true}`))
+	assert.NoError(t, err)
+
+	m := ordered.(orderedMap)
+	assert.Equal(t, []string{"z", "a"}, m.keys)
+	assert.Equal(t, true, m.values["z"])
+
+	b, err := json.Marshal(ordered)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"z":true,"a":null}`, string(b))
+
+	_, err = decodeOrdered([]byte(`{} {}`))
+	assert.Error(t, err)
+}
+
+func TestIncludeTiming(t *testing.T) {
+	reset(false)
+	formatter := NewDefaultFormatter(false)
+	buf := &bytes.Buffer{}
+	Stdout = buf
+
+	resp := Response{
+		Proto:    "HTTP/1.1",
+		Status:   http.StatusOK,
+		Headers:  map[string]string{"Content-Type": "application/json"},
+		Body:     map[string]interface{}{"id": 1.0},
+		Duration: 123456 * time.Microsecond,
+	}
+
+	assert.NoError(t, formatter.Format(resp))
+	assert.NotContains(t, buf.String(), "123ms")
+
+	buf.Reset()
+	viper.Set("rsh-include-timing", true)
+	assert.NoError(t, formatter.Format(resp))
+	assert.Equal(t, "HTTP/1.1 200 OK\nContent-Type: application/json\n# 123.5ms\n\n{\n  id: 1\n}\n", buf.String())
+
+	buf.Reset()
+	viper.Set("rsh-output-format", "json")
+	viper.Set("rsh-filter", "duration_ms")
+	assert.NoError(t, formatter.Format(resp))
+	assert.Equal(t, "123.456\n", buf.String())
+
+	defer gock.Off()
+	gock.New("http://example.com").Get("/timed").Reply(http.StatusOK)
+	assert.Regexp(t, `"duration_ms": [0-9.]+`, run("http://example.com/timed -o json --rsh-include-timing"))
+}
@@ -0,0 +1,237 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// grpcCodeNames maps gRPC status codes to their names.
+var grpcCodeNames = []string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+// grpcStatus returns the gRPC status code and message of a response, if any.
+// These are normally sent as trailers, but trailers-only responses like
+// immediate errors send them as headers instead.
+func grpcStatus(resp Response) (int, string, bool) {
+	for _, values := range []map[string]string{resp.Trailers, resp.Headers} {
+		status, ok := values["Grpc-Status"]
+		if !ok {
+			continue
+		}
+
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			LogWarning("Invalid grpc-status %q", status)
+			return 0, "", false
+		}
+
+		// Messages are percent-encoded.
+		message := values["Grpc-Message"]
+		if decoded, err := url.PathUnescape(message); err == nil {
+			message = decoded
+		}
+
+		return code, message, true
+	}
+
+	return 0, "", false
+}
+
+// grpcHTTPStatus maps gRPC status codes to their closest HTTP status, as
+// listed in the gRPC docs, so they can use the same exit codes.
+var grpcHTTPStatus = map[int]int{
+	1:  499, // CANCELLED
+	2:  500, // UNKNOWN
+	3:  400, // INVALID_ARGUMENT
+	4:  504, // DEADLINE_EXCEEDED
+	5:  404, // NOT_FOUND
+	6:  409, // ALREADY_EXISTS
+	7:  403, // PERMISSION_DENIED
+	8:  429, // RESOURCE_EXHAUSTED
+	9:  400, // FAILED_PRECONDITION
+	10: 409, // ABORTED
+	11: 400, // OUT_OF_RANGE
+	12: 501, // UNIMPLEMENTED
+	13: 500, // INTERNAL
+	14: 503, // UNAVAILABLE
+	15: 500, // DATA_LOSS
+	16: 401, // UNAUTHENTICATED
+}
+
+// grpcExitCode returns the exit code for a non-zero gRPC status, which is the
+// client or server error code of the equivalent HTTP status.
+func grpcExitCode(code int) int {
+	if status, ok := grpcHTTPStatus[code]; ok {
+		return statusExitCode(status)
+	}
+	return ExitCodeServerError
+}
+
+// checkGRPCStatus displays an error for responses with a non-zero gRPC status
+// and sets the client or server error exit code to match.
+func checkGRPCStatus(resp Response) {
+	code, message, ok := grpcStatus(resp)
+	if !ok || code == 0 {
+		return
+	}
+
+	name := "UNKNOWN"
+	if code > 0 && code < len(grpcCodeNames) {
+		name = grpcCodeNames[code]
+	}
+
+	LogError("gRPC status %d %s: %s", code, name, message)
+
+	exitCode = grpcExitCode(code)
+}
+
+// grpcWebTrailerFlag marks a gRPC-Web frame which contains trailers rather
+// than a message.
+const grpcWebTrailerFlag = 0x80
+
+// grpcWebFrames splits a gRPC-Web body into its length-prefixed frames. Each
+// frame has a one byte flag, a four byte big-endian length, and then the
+// data. Messages are returned in order along with any trailers, which are
+// sent as a final frame in HTTP/1 header format.
+func grpcWebFrames(data []byte) ([][]byte, map[string]string, error) {
+	messages := [][]byte{}
+	var trailers map[string]string
+
+	for len(data) > 0 {
+		if len(data) < 5 {
+			return nil, nil, errors.New("truncated gRPC-Web frame header")
+		}
+
+		flags := data[0]
+		length := binary.BigEndian.Uint32(data[1:5])
+		if uint64(len(data)-5) < uint64(length) {
+			return nil, nil, errors.New("truncated gRPC-Web frame")
+		}
+		frame := data[5 : 5+length]
+		data = data[5+length:]
+
+		if flags&grpcWebTrailerFlag != 0 {
+			trailers = map[string]string{}
+			for _, line := range strings.Split(string(frame), "\r\n") {
+				parts := strings.SplitN(line, ":", 2)
+				if len(parts) != 2 {
+					continue
+				}
+				trailers[textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+			}
+			continue
+		}
+
+		if flags&0x01 != 0 {
+			return nil, nil, errors.New("compressed gRPC-Web messages are not supported")
+		}
+
+		messages = append(messages, frame)
+	}
+
+	return messages, trailers, nil
+}
+
+// GRPCWeb describes gRPC-Web content types like `application/grpc-web+json`
+// or `application/grpc-web+proto`. JSON messages are decoded, while protobuf
+// messages are kept as binary data since decoding them needs the schema. A
+// body with a single message decodes to that message, otherwise to a list of
+// messages.
+type GRPCWeb struct{}
+
+// Detect if the content type is gRPC-Web.
+func (g GRPCWeb) Detect(contentType string) bool {
+	first := strings.Split(contentType, ";")[0]
+	return first == "application/grpc-web" || strings.HasPrefix(first, "application/grpc-web+")
+}
+
+// Marshal the value to a single gRPC-Web message frame. Binary data is sent
+// as-is, anything else is encoded as JSON.
+func (g GRPCWeb) Marshal(value interface{}) ([]byte, error) {
+	message, ok := value.([]byte)
+	if !ok {
+		var err error
+		if message, err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte(0)
+	binary.Write(buf, binary.BigEndian, uint32(len(message)))
+	buf.Write(message)
+	return buf.Bytes(), nil
+}
+
+// Unmarshal the messages from a gRPC-Web body. JSON is detected from the
+// message contents since the content type isn't available here.
+func (g GRPCWeb) Unmarshal(data []byte, value interface{}) error {
+	messages, _, err := grpcWebFrames(data)
+	if err != nil {
+		return err
+	}
+
+	decoded := make([]interface{}, len(messages))
+	for i, message := range messages {
+		var v interface{}
+		if json.Valid(message) {
+			if err := json.Unmarshal(message, &v); err != nil {
+				return err
+			}
+		} else {
+			v = message
+		}
+		decoded[i] = v
+	}
+
+	ptr, ok := value.(*interface{})
+	if !ok {
+		return fmt.Errorf("value must be *interface{} but found %T", value)
+	}
+
+	if len(decoded) == 1 {
+		*ptr = decoded[0]
+	} else {
+		*ptr = decoded
+	}
+	return nil
+}
+
+// grpcWebTrailers returns the trailers sent in the body of a gRPC-Web
+// response, which are used in place of HTTP trailers.
+func grpcWebTrailers(header http.Header, data []byte) map[string]string {
+	if !(GRPCWeb{}).Detect(header.Get("Content-Type")) {
+		return nil
+	}
+
+	_, trailers, err := grpcWebFrames(data)
+	if err != nil {
+		return nil
+	}
+	return trailers
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGRPCTrailers(t *testing.T) {
+	reset(false)
+
+	u, _ := url.Parse("http://example.com/grpc")
+	resp, err := ParseResponse(&http.Response{
+		Proto:      "HTTP/2.0",
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"text/plain"}},
+		Body:       ioutil.NopCloser(strings.NewReader("hello")),
+		Trailer: http.Header{
+			"Grpc-Status":  []string{"5"},
+			"Grpc-Message": []string{"item%20not%20found"},
+			"X-Empty":      nil,
+		},
+		Request: &http.Request{URL: u},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Grpc-Status": "5", "Grpc-Message": "item%20not%20found"}, resp.Trailers)
+	assert.Equal(t, resp.Trailers, resp.Map()["trailers"])
+
+	code, message, ok := grpcStatus(resp)
+	assert.True(t, ok)
+	assert.Equal(t, 5, code)
+	assert.Equal(t, "item not found", message)
+
+	buf := &bytes.Buffer{}
+	Stdout = buf
+	Stderr = buf
+	assert.NoError(t, NewDefaultFormatter(false).Format(resp))
+	assert.Contains(t, buf.String(), "hello\n\nGrpc-Message: item%20not%20found\nGrpc-Status: 5\n")
+
+	checkGRPCStatus(resp)
+	assert.Equal(t, ExitCodeClientError, GetExitCode())
+	assert.Contains(t, buf.String(), "gRPC status 5 NOT_FOUND: item not found")
+
+	assert.Equal(t, ExitCodeServerError, grpcExitCode(14))
+	assert.Equal(t, ExitCodeClientError, grpcExitCode(3))
+	assert.Equal(t, ExitCodeServerError, grpcExitCode(99))
+
+	// Trailers-only responses send the status as headers.
+	_, _, ok = grpcStatus(Response{Headers: map[string]string{"Grpc-Status": "0"}})
+	assert.True(t, ok)
+
+	_, _, ok = grpcStatus(Response{Headers: map[string]string{}})
+	assert.False(t, ok)
+}
+
+func grpcWebFrame(flags byte, data string) string {
+	length := len(data)
+	return string([]byte{flags, byte(length >> 24), byte(length >> 16), byte(length >> 8), byte(length)}) + data
+}
+
+func TestGRPCWeb(t *testing.T) {
+	reset(false)
+
+	body := grpcWebFrame(0, `{"id": 1}`) + grpcWebFrame(0, `{"id": 2}`) + grpcWebFrame(0x80, "grpc-status: 0\r\ngrpc-message: ok\r\n")
+
+	u, _ := url.Parse("http://example.com/grpc")
+	resp, err := ParseResponse(&http.Response{
+		Proto:      "HTTP/1.1",
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/grpc-web+json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    &http.Request{URL: u},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": 1.0},
+		map[string]interface{}{"id": 2.0},
+	}, resp.Body)
+	assert.Equal(t, map[string]string{"Grpc-Status": "0", "Grpc-Message": "ok"}, resp.Trailers)
+
+	// Protobuf messages can't be decoded without the schema.
+	var parsed interface{}
+	assert.NoError(t, Unmarshal("application/grpc-web+proto", []byte(grpcWebFrame(0, "\x08\x96\x01")), &parsed))
+	assert.Equal(t, []byte{0x08, 0x96, 0x01}, parsed)
+
+	b, err := Marshal("application/grpc-web+json", map[string]interface{}{"id": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, grpcWebFrame(0, `{"id":1}`), string(b))
+
+	assert.Error(t, Unmarshal("application/grpc-web", []byte{0, 0, 0, 0, 5, '{'}, &parsed))
+	assert.Error(t, Unmarshal("application/grpc-web", []byte{1, 0, 0, 0, 0}, &parsed))
+	assert.NotContains(t, buildAcceptHeader(), "grpc-web")
+}
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// connBody closes the connection once the response body is closed, since
+// HTTP/1.0 connections aren't reused.
+type connBody struct {
+	io.ReadCloser
+	conn net.Conn
+}
+
+func (b connBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.conn.Close()
+	return err
+}
+
+type http10Transport struct{}
+
+// dial opens a connection to the request's host, using TLS for HTTPS with the
+// same TLS config as the request's API.
+func (t http10Transport) dial(req *http.Request) (net.Conn, error) {
+	host := req.URL.Host
+	if req.URL.Port() == "" {
+		if req.URL.Scheme == "https" {
+			host = net.JoinHostPort(req.URL.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(req.URL.Hostname(), "80")
+		}
+	}
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(req.Context(), "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL.Scheme != "https" {
+		return conn, nil
+	}
+
+	config := &tls.Config{}
+	if t, ok := requestTransport(req).(*http.Transport); ok && t.TLSClientConfig != nil {
+		config = t.TLSClientConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = req.URL.Hostname()
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(req.Context()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// writeRequest writes an HTTP/1.0 request. Bodies are sent with a
+// `Content-Length` since chunked encoding isn't part of HTTP/1.0.
+func (t http10Transport) writeRequest(w io.Writer, req *http.Request) error {
+	hasBody := req.Body != nil && req.Body != http.NoBody
+
+	var body []byte
+	if hasBody {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		body = b
+	}
+
+	header := req.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Del("Transfer-Encoding")
+	header.Del("Content-Length")
+	if header.Get("Connection") == "" {
+		header.Set("Connection", "close")
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s %s HTTP/1.0\r\n", req.Method, req.URL.RequestURI())
+	fmt.Fprintf(buf, "Host: %s\r\n", host)
+	if hasBody {
+		fmt.Fprintf(buf, "Content-Length: %d\r\n", len(body))
+	}
+	if err := header.Write(buf); err != nil {
+		return err
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (t http10Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported protocol scheme %s", req.URL.Scheme)
+	}
+
+	conn, err := t.dial(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.writeRequest(conn, req); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	resp.Body = connBody{ReadCloser: resp.Body, conn: conn}
+	return resp, nil
+}
+
+// HTTP10Transport returns an HTTP transport which speaks HTTP/1.0, for testing
+// compatibility with old servers. Each request uses a new connection, bodies
+// are never chunked, and `Connection: close` is sent unless another
+// `Connection` header is set.
+func HTTP10Transport() http.RoundTripper {
+	return http10Transport{}
+}
+
+// setConnectionHeader sets an explicit `Connection` header on the request. For
+// `close` the connection is not reused after the response.
+func setConnectionHeader(req *http.Request, value string) {
+	req.Header.Set("Connection", value)
+	if strings.EqualFold(value, "close") {
+		req.Close = true
+	}
+}
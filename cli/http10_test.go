@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// http10Request is a raw request received by the test server.
+type http10Request struct {
+	line   string
+	header textproto.MIMEHeader
+	body   string
+}
+
+// serveHTTP10 accepts a single connection, records the raw request, and
+// responds like an HTTP/1.0 server without a content length.
+func serveHTTP10(t *testing.T) (string, chan http10Request) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	received := make(chan http10Request, 1)
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := textproto.NewReader(bufio.NewReader(conn))
+		line, _ := reader.ReadLine()
+		header, _ := reader.ReadMIMEHeader()
+
+		body := make([]byte, 0)
+		if n, err := strconv.Atoi(header.Get("Content-Length")); err == nil {
+			body = make([]byte, n)
+			reader.R.Read(body)
+		}
+
+		received <- http10Request{line, header, string(body)}
+		conn.Write([]byte("HTTP/1.0 200 OK\r\nContent-Type: text/plain\r\n\r\nhello"))
+	}()
+
+	return l.Addr().String(), received
+}
+
+func TestHTTP10Transport(t *testing.T) {
+	addr, received := serveHTTP10(t)
+
+	req, _ := http.NewRequest(http.MethodPost, "http://"+addr+"/items?q=1", strings.NewReader("data"))
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := HTTP10Transport().RoundTrip(req)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, "HTTP/1.0", resp.Proto)
+	assert.Equal(t, "hello", string(body))
+
+	r := <-received
+	assert.Equal(t, "POST /items?q=1 HTTP/1.0", r.line)
+	assert.Equal(t, addr, r.header.Get("Host"))
+	assert.Equal(t, "4", r.header.Get("Content-Length"))
+	assert.Equal(t, "close", r.header.Get("Connection"))
+	assert.Empty(t, r.header.Get("Transfer-Encoding"))
+	assert.Equal(t, "data", r.body)
+}
+
+func TestHTTP10Flags(t *testing.T) {
+	addr, received := serveHTTP10(t)
+
+	out := run("--rsh-http10 --rsh-connection=keep-alive http://" + addr + "/items")
+	assert.Contains(t, out, "HTTP/1.0 200 OK")
+	assert.Contains(t, out, "hello")
+
+	r := <-received
+	assert.Equal(t, "GET /items HTTP/1.0", r.line)
+	assert.Equal(t, "keep-alive", r.header.Get("Connection"))
+	assert.Empty(t, r.header.Get("Content-Length"))
+}
+
+func TestConnectionHeader(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	setConnectionHeader(req, "Close")
+	assert.Equal(t, "Close", req.Header.Get("Connection"))
+	assert.True(t, req.Close)
+}
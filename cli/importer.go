@@ -0,0 +1,821 @@
+package cli
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/danielgtaylor/casing"
+	"github.com/spf13/cobra"
+)
+
+// importVariables maps each import format to the syntax it uses to reference
+// variables. The first submatch is the variable name, which is empty for
+// syntax that can't be converted, like Insomnia template tags.
+var importVariables = map[string]*regexp.Regexp{
+	// VS Code and JetBrains: `{{name}}`, plus dynamic variables like
+	// `{{$guid}}` or `{{$processEnv HOME}}` which can't be converted.
+	"http": regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`),
+
+	// Insomnia: `{{ name }}` or `{{ _.name }}`, plus template tags like
+	// `{% uuid 'v4' %}` which can't be converted.
+	"insomnia": regexp.MustCompile(`\{\{\s*(?:_\.)?([^{}]*?)\s*\}\}|\{%[^%]*%\}`),
+}
+
+// httpEnvironmentFiles are read from the directory of a `.http` file, with
+// later files overriding earlier ones. These are used by JetBrains IDEs.
+var httpEnvironmentFiles = []string{"http-client.env.json", "http-client.private.env.json"}
+
+// sharedEnvironment is the environment name used by both VS Code and
+// JetBrains for variables which apply to every environment.
+const sharedEnvironment = "$shared"
+
+// httpMethods are the methods which may start a request line in a `.http`
+// file. Without one the request is a `GET`.
+var httpMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodConnect: true,
+}
+
+// importedRequest is a request from another HTTP client. Values may still
+// contain variable references.
+type importedRequest struct {
+	Name    string
+	Method  string
+	URL     string
+	Headers [][2]string
+	Body    string
+}
+
+// importedCollection holds imported requests along with their variables.
+// Each environment includes the variables which apply to all environments,
+// which are also in `Variables` for collections without any environments.
+type importedCollection struct {
+	Syntax       *regexp.Regexp
+	Requests     []importedRequest
+	Variables    map[string]string
+	Environments map[string]map[string]string
+}
+
+// importString converts a variable value to a string. Values which aren't
+// strings are encoded as JSON.
+func importString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+// environmentNames returns the sorted environment names, if any.
+func (c *importedCollection) environmentNames() []string {
+	names := []string{}
+	for name := range c.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// vars returns the variables for an environment, or the collection's
+// variables if it has no environments.
+func (c *importedCollection) vars(env string) map[string]string {
+	if vars, ok := c.Environments[env]; ok {
+		return vars
+	}
+
+	return c.Variables
+}
+
+// resolve replaces variable references in a value using the variables of an
+// environment. Variables may reference other variables. References which
+// can't be resolved are kept as-is and returned.
+func (c *importedCollection) resolve(value, env string) (string, []string) {
+	vars := c.vars(env)
+
+	for i := 0; i < 10; i++ {
+		next := c.Syntax.ReplaceAllStringFunc(value, func(ref string) string {
+			name := c.Syntax.FindStringSubmatch(ref)[1]
+			if v, ok := vars[name]; ok && name != "" {
+				return v
+			}
+			return ref
+		})
+
+		if next == value {
+			break
+		}
+		value = next
+	}
+
+	return value, c.Syntax.FindAllString(value, -1)
+}
+
+// varies returns whether a value resolves differently between environments.
+func (c *importedCollection) varies(value string) bool {
+	first := ""
+	for i, env := range c.environmentNames() {
+		resolved, _ := c.resolve(value, env)
+		if i == 0 {
+			first = resolved
+		} else if resolved != first {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseHTTPFile parses requests and file variables like `@host = ...` from a
+// `.http` or `.rest` file as used by VS Code and JetBrains IDEs. Requests are
+// separated by `###`, which may be followed by the request name. Bodies from
+// files like `< ./body.json` are read relative to `dir`.
+func parseHTTPFile(data []byte, dir string) (*importedCollection, error) {
+	c := &importedCollection{
+		Syntax:    importVariables["http"],
+		Variables: map[string]string{},
+	}
+
+	var current *importedRequest
+	name := ""
+	inBody := false
+	inHandler := false
+	body := []string{}
+
+	finish := func() {
+		if current != nil {
+			current.Body = strings.TrimSpace(strings.Join(body, "\n"))
+			c.Requests = append(c.Requests, *current)
+		}
+		current, name, inBody, inHandler, body = nil, "", false, false, nil
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "###") {
+			finish()
+			name = strings.TrimSpace(strings.TrimPrefix(trimmed, "###"))
+			continue
+		}
+
+		if !inBody && (strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//")) {
+			comment := strings.TrimSpace(strings.TrimLeft(trimmed, "#/"))
+			if strings.HasPrefix(comment, "@name ") {
+				name = strings.TrimSpace(strings.TrimPrefix(comment, "@name "))
+			}
+			continue
+		}
+
+		switch {
+		case current == nil:
+			if trimmed == "" {
+				continue
+			}
+
+			if strings.HasPrefix(trimmed, "@") {
+				if k, v, ok := strings.Cut(trimmed[1:], "="); ok {
+					c.Variables[strings.TrimSpace(k)] = strings.TrimSpace(v)
+				}
+				continue
+			}
+
+			fields := strings.Fields(trimmed)
+			method := http.MethodGet
+			if httpMethods[fields[0]] {
+				method = fields[0]
+				fields = fields[1:]
+			}
+			if len(fields) > 1 && strings.HasPrefix(fields[len(fields)-1], "HTTP/") {
+				fields = fields[:len(fields)-1]
+			}
+
+			current = &importedRequest{Name: name, Method: method, URL: strings.Join(fields, "")}
+		case !inBody:
+			if trimmed == "" {
+				inBody = true
+				continue
+			}
+
+			if len(current.Headers) == 0 && (trimmed[0] == '?' || trimmed[0] == '&') {
+				// Query params split over multiple lines.
+				current.URL += trimmed
+				continue
+			}
+
+			if k, v, ok := strings.Cut(trimmed, ":"); ok {
+				current.Headers = append(current.Headers, [2]string{strings.TrimSpace(k), strings.TrimSpace(v)})
+			}
+		case inHandler || strings.HasPrefix(trimmed, "> {%"):
+			// Response handler scripts can't be converted.
+			inHandler = !strings.HasSuffix(trimmed, "%}")
+		case strings.HasPrefix(trimmed, ">") || strings.HasPrefix(trimmed, "<>"):
+			// Response handler files and response references are skipped.
+		case strings.HasPrefix(trimmed, "< "):
+			filename := strings.TrimSpace(trimmed[2:])
+			if !filepath.IsAbs(filename) {
+				filename = filepath.Join(dir, filename)
+			}
+
+			b, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return nil, fmt.Errorf("request body: %w", err)
+			}
+			body = append(body, string(b))
+		default:
+			body = append(body, line)
+		}
+	}
+	finish()
+
+	return c, nil
+}
+
+// loadHTTPEnvironments adds environments from a JetBrains
+// `http-client.env.json` style file, or from VS Code settings with
+// `rest-client.environmentVariables`, to `envs`.
+func loadHTTPEnvironments(filename string, envs map[string]map[string]string) error {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("environment file %s: %w", filename, err)
+	}
+
+	if vscode, ok := doc["rest-client.environmentVariables"].(map[string]interface{}); ok {
+		doc = vscode
+	}
+
+	for env, values := range doc {
+		m, ok := values.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if envs[env] == nil {
+			envs[env] = map[string]string{}
+		}
+
+		for k, v := range m {
+			envs[env][k] = importString(v)
+		}
+	}
+
+	return nil
+}
+
+// loadHTTPCollection parses a `.http` file along with its environments, which
+// are read from `http-client.env.json` files next to it and any extra files.
+// File variables take precedence over environment variables.
+func loadHTTPCollection(filename string, envFiles []string) (*importedCollection, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := parseHTTPFile(b, filepath.Dir(filename))
+	if err != nil {
+		return nil, err
+	}
+
+	envs := map[string]map[string]string{}
+	for _, name := range httpEnvironmentFiles {
+		envFile := filepath.Join(filepath.Dir(filename), name)
+		if err := loadHTTPEnvironments(envFile, envs); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	for _, envFile := range envFiles {
+		if err := loadHTTPEnvironments(envFile, envs); err != nil {
+			return nil, err
+		}
+	}
+
+	shared := envs[sharedEnvironment]
+	delete(envs, sharedEnvironment)
+
+	// Shared values are overridden by environments, which in turn are
+	// overridden by file variables.
+	merge := func(layers ...map[string]string) map[string]string {
+		merged := map[string]string{}
+		for _, layer := range layers {
+			for k, v := range layer {
+				merged[k] = v
+			}
+		}
+		return merged
+	}
+
+	for name, vars := range envs {
+		envs[name] = merge(shared, vars, c.Variables)
+	}
+	c.Variables = merge(shared, c.Variables)
+
+	if len(envs) > 0 {
+		c.Environments = envs
+	}
+
+	return c, nil
+}
+
+// insomniaPair is a header or query param in an Insomnia export.
+type insomniaPair struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled"`
+}
+
+// insomniaResource is a workspace, folder, request, or environment in an
+// Insomnia export.
+type insomniaResource struct {
+	ID       string `json:"_id"`
+	ParentID string `json:"parentId"`
+	Type     string `json:"_type"`
+	Name     string `json:"name"`
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+	Body     struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+	} `json:"body"`
+	Headers        []insomniaPair         `json:"headers"`
+	Parameters     []insomniaPair         `json:"parameters"`
+	Authentication map[string]interface{} `json:"authentication"`
+	Data           map[string]interface{} `json:"data"`
+}
+
+// insomniaVars converts environment data to variables. Nested objects use
+// dotted names like `api.url`, matching Insomnia's `{{ _.api.url }}`.
+func insomniaVars(data map[string]interface{}) map[string]string {
+	vars := map[string]string{}
+	for k, v := range flatten(makeJSONSafe(data, false), ".").(map[string]interface{}) {
+		vars[k] = importString(v)
+	}
+
+	return vars
+}
+
+// insomniaAuth converts a request's auth settings to a header. Only bearer
+// tokens and basic auth without variables are supported.
+func insomniaAuth(c *importedCollection, r insomniaResource) (string, bool) {
+	auth := r.Authentication
+	if len(auth) == 0 || auth["disabled"] == true {
+		return "", false
+	}
+
+	switch auth["type"] {
+	case "bearer":
+		prefix, _ := auth["prefix"].(string)
+		if prefix == "" {
+			prefix = "Bearer"
+		}
+		return prefix + " " + importString(auth["token"]), true
+	case "basic":
+		credentials := importString(auth["username"]) + ":" + importString(auth["password"])
+		if !c.Syntax.MatchString(credentials) {
+			return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials)), true
+		}
+	}
+
+	LogWarning("Request %s: unsupported %v authentication skipped", r.Name, auth["type"])
+	return "", false
+}
+
+// parseInsomnia parses requests and environments from an Insomnia JSON
+// export. Sub-environments become environments which include the variables
+// of their base environment.
+func parseInsomnia(data []byte) (*importedCollection, error) {
+	var export struct {
+		Format    int                `json:"__export_format"`
+		Resources []insomniaResource `json:"resources"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil || export.Format == 0 {
+		return nil, errors.New("not an Insomnia JSON export")
+	}
+
+	c := &importedCollection{
+		Syntax:    importVariables["insomnia"],
+		Variables: map[string]string{},
+	}
+
+	bases := map[string]map[string]string{}
+	for _, r := range export.Resources {
+		if r.Type == "environment" && strings.HasPrefix(r.ParentID, "wrk_") {
+			bases[r.ID] = insomniaVars(r.Data)
+			for k, v := range bases[r.ID] {
+				c.Variables[k] = v
+			}
+		}
+	}
+
+	for _, r := range export.Resources {
+		base, ok := bases[r.ParentID]
+		if r.Type != "environment" || !ok {
+			continue
+		}
+
+		if c.Environments == nil {
+			c.Environments = map[string]map[string]string{}
+		}
+
+		vars := map[string]string{}
+		for k, v := range base {
+			vars[k] = v
+		}
+		for k, v := range insomniaVars(r.Data) {
+			vars[k] = v
+		}
+		c.Environments[r.Name] = vars
+	}
+
+	for _, r := range export.Resources {
+		if r.Type != "request" {
+			continue
+		}
+
+		req := importedRequest{Name: r.Name, Method: strings.ToUpper(r.Method), URL: r.URL, Body: r.Body.Text}
+		if req.Method == "" {
+			req.Method = http.MethodGet
+		}
+
+		for _, p := range r.Parameters {
+			if !p.Disabled {
+				sep := "?"
+				if strings.Contains(req.URL, "?") {
+					sep = "&"
+				}
+				req.URL += sep + p.Name + "=" + p.Value
+			}
+		}
+
+		for _, h := range r.Headers {
+			if !h.Disabled && h.Name != "" {
+				req.Headers = append(req.Headers, [2]string{h.Name, h.Value})
+			}
+		}
+
+		if r.Body.MimeType != "" && !hasImportedHeader(req, "Content-Type") {
+			req.Headers = append(req.Headers, [2]string{"Content-Type", r.Body.MimeType})
+		}
+
+		if value, ok := insomniaAuth(c, r); ok {
+			req.Headers = append(req.Headers, [2]string{"Authorization", value})
+		}
+
+		c.Requests = append(c.Requests, req)
+	}
+
+	return c, nil
+}
+
+// hasImportedHeader returns whether a request has a header, ignoring case.
+func hasImportedHeader(req importedRequest, name string) bool {
+	for _, h := range req.Headers {
+		if strings.EqualFold(h[0], name) {
+			return true
+		}
+	}
+	return false
+}
+
+// importNameInvalid matches runs of characters which aren't allowed in
+// imported API, profile, and request names.
+var importNameInvalid = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// importName converts a name to something which can be used on the command
+// line, like `get-user`.
+func importName(name string) string {
+	name = casing.Kebab(strings.TrimSpace(name))
+	name = importNameInvalid.ReplaceAllString(strings.ToLower(name), "-")
+	return strings.Trim(name, "-")
+}
+
+// resolveImported resolves a value for the default environment, warning about
+// references which can't be converted.
+func resolveImported(c *importedCollection, r importedRequest, value, env string) string {
+	resolved, unresolved := c.resolve(value, env)
+	for _, ref := range unresolved {
+		LogWarning("Request %s: unsupported or undefined variable %s kept as-is", r.Name, ref)
+	}
+
+	if c.varies(value) {
+		LogWarning("Request %s: %s differs between environments, using the value from %s", r.Name, value, env)
+	}
+
+	return resolved
+}
+
+// convert turns an imported collection into an API config with a profile for
+// each environment, along with a saved request for each request. The
+// base URL comes from a variable all request URLs start with, like
+// `{{host}}`, or otherwise from the first request. Headers which differ
+// between environments, like auth tokens, are set in the profiles.
+func (c *importedCollection) convert(apiName string) (*APIConfig, map[string]savedRequest, error) {
+	if len(c.Requests) == 0 {
+		return nil, nil, errors.New("no requests found")
+	}
+
+	envs := c.environmentNames()
+	defaultEnv := ""
+	if len(envs) > 0 {
+		defaultEnv = envs[0]
+		for _, env := range envs {
+			if env == "default" {
+				defaultEnv = env
+			}
+		}
+	}
+
+	// Find a variable every URL starts with to use as the base.
+	basePrefix := ""
+	for i, r := range c.Requests {
+		prefix := ""
+		if loc := c.Syntax.FindStringIndex(r.URL); loc != nil && loc[0] == 0 {
+			prefix = r.URL[:loc[1]]
+		}
+
+		if i == 0 {
+			basePrefix = prefix
+		} else if prefix != basePrefix {
+			basePrefix = ""
+			break
+		}
+	}
+
+	base := ""
+	if basePrefix != "" {
+		resolved, unresolved := c.resolve(basePrefix, defaultEnv)
+		if len(unresolved) == 0 && strings.Contains(resolved, "://") {
+			base = resolved
+		} else {
+			basePrefix = ""
+		}
+	}
+
+	if base == "" {
+		first, _ := c.resolve(c.Requests[0].URL, defaultEnv)
+		u, err := url.Parse(first)
+		if err != nil || u.Host == "" {
+			return nil, nil, fmt.Errorf("unable to find the base URL from %s", first)
+		}
+		base = u.Scheme + "://" + u.Host
+	}
+	base = strings.TrimSuffix(base, "/")
+
+	// Headers which differ between environments go into the profiles, unless
+	// requests set them to different values.
+	profileHeaders := map[string]string{}
+	conflicts := map[string]bool{}
+	for _, r := range c.Requests {
+		for _, h := range r.Headers {
+			name := http.CanonicalHeaderKey(h[0])
+			if !c.varies(h[1]) {
+				continue
+			}
+
+			if existing, ok := profileHeaders[name]; ok && existing != h[1] {
+				conflicts[name] = true
+			}
+			profileHeaders[name] = h[1]
+		}
+	}
+	for name := range conflicts {
+		delete(profileHeaders, name)
+	}
+
+	config := &APIConfig{
+		name:     apiName,
+		Base:     base,
+		Profiles: map[string]*APIProfile{},
+	}
+
+	if len(envs) == 0 {
+		envs = []string{""}
+	}
+
+	for _, env := range envs {
+		profile := &APIProfile{}
+
+		if basePrefix != "" {
+			if envBase, _ := c.resolve(basePrefix, env); strings.TrimSuffix(envBase, "/") != base {
+				profile.Base = strings.TrimSuffix(envBase, "/")
+			}
+		}
+
+		for name, value := range profileHeaders {
+			if profile.Headers == nil {
+				profile.Headers = map[string]string{}
+			}
+			profile.Headers[name], _ = c.resolve(value, env)
+		}
+
+		name := "default"
+		if env != "" {
+			name = importName(env)
+		}
+		config.Profiles[name] = profile
+	}
+
+	if defaultEnv != "" {
+		config.DefaultProfile = importName(defaultEnv)
+	}
+
+	saved := map[string]savedRequest{}
+	for _, r := range c.Requests {
+		request := savedRequest{Method: strings.ToUpper(r.Method)}
+
+		// Requests use the API's short name so the profile's base is used,
+		// except for requests to other hosts which keep their full URL.
+		target := ""
+		if basePrefix != "" {
+			target = resolveImported(c, r, strings.TrimPrefix(r.URL, basePrefix), defaultEnv)
+		} else {
+			target = resolveImported(c, r, r.URL, defaultEnv)
+		}
+
+		if basePrefix == "" && !strings.HasPrefix(target, base) {
+			request.URL = target
+		} else {
+			target = strings.TrimPrefix(target, base)
+			if target != "" && !strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "?") {
+				target = "/" + target
+			}
+			request.URL = apiName + target
+		}
+
+		if r.Body != "" {
+			request.Body = resolveImported(c, r, r.Body, defaultEnv)
+		}
+
+		for _, h := range r.Headers {
+			name := http.CanonicalHeaderKey(h[0])
+			if _, ok := profileHeaders[name]; ok {
+				continue
+			}
+
+			value := resolveImported(c, r, h[1], defaultEnv)
+			request.Headers = append(request.Headers, name+": "+value)
+		}
+
+		if json.Valid([]byte(request.Body)) && !hasImportedHeader(r, "Content-Type") {
+			request.Headers = append(request.Headers, "Content-Type: application/json")
+		}
+
+		name := importName(r.Name)
+		if name == "" {
+			path, _ := c.resolve(r.URL, defaultEnv)
+			if u, err := url.Parse(path); err == nil {
+				path = u.Path
+			}
+			name = importName(strings.ToLower(r.Method) + " " + strings.ReplaceAll(path, "/", " "))
+		}
+
+		unique := name
+		for i := 2; ; i++ {
+			if _, ok := saved[unique]; !ok {
+				break
+			}
+			unique = fmt.Sprintf("%s-%d", name, i)
+		}
+		saved[unique] = request
+	}
+
+	return config, saved, nil
+}
+
+// importCollection converts and saves an imported collection as a new API
+// with saved requests for each of its requests.
+func importCollection(c *importedCollection, apiName string) error {
+	if apiName == "" {
+		return errors.New("unable to name the API, use --rsh-name to set one")
+	}
+
+	if configs[apiName] != nil {
+		return fmt.Errorf("API %s already exists, use --rsh-name to import with another name", apiName)
+	}
+
+	config, saved, err := c.convert(apiName)
+	if err != nil {
+		return err
+	}
+
+	if err := validateAPIConfig(apiName, config); err != nil {
+		return err
+	}
+
+	configs[apiName] = config
+	if err := config.Save(); err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range saved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := saveRequest(apiName+"/"+name, saved[name]); err != nil {
+			return err
+		}
+	}
+
+	LogInfo("Imported API %s with profiles %s and requests: %s", apiName, strings.Join(config.profileNames(), ", "), strings.Join(names, ", "))
+	return nil
+}
+
+// importAPIName returns the API name to import a file as, which defaults to
+// the file name without its extension.
+func importAPIName(cmd *cobra.Command, filename string) string {
+	if name, _ := cmd.Flags().GetString("rsh-name"); name != "" {
+		return name
+	}
+
+	return importName(strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)))
+}
+
+func importCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import requests from other HTTP clients",
+		Long:  "Import requests from other HTTP clients as a new API. Each environment becomes a profile, and each request becomes a saved request which can be run via `api-name request-name`.",
+	}
+
+	httpCmd := &cobra.Command{
+		Use:   "http file",
+		Short: "Import a .http or .rest file",
+		Long:  "Import requests from a `.http` or `.rest` file as used by VS Code and JetBrains IDEs. Environments are read from `http-client.env.json` and `http-client.private.env.json` next to the file, as well as from VS Code settings or other environment files passed via `--rsh-env-file`.",
+		Example: fmt.Sprintf(`  # Import requests and run one of them
+  $ %s import http requests.http --rsh-name my-api
+  $ %s my-api get-user
+
+  # Use environments from VS Code settings
+  $ %s import http requests.http --rsh-env-file .vscode/settings.json`, Root.CommandPath(), Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			envFiles, _ := cmd.Flags().GetStringSlice("rsh-env-file")
+			c, err := loadHTTPCollection(args[0], envFiles)
+			if err != nil {
+				panic(err)
+			}
+
+			if err := importCollection(c, importAPIName(cmd, args[0])); err != nil {
+				panic(err)
+			}
+		},
+	}
+	httpCmd.Flags().String("rsh-name", "", "Name of the API to create, defaults to the file name")
+	httpCmd.Flags().StringSlice("rsh-env-file", []string{}, "Extra environment file, can be repeated")
+	cmd.AddCommand(httpCmd)
+
+	insomniaCmd := &cobra.Command{
+		Use:   "insomnia file",
+		Short: "Import an Insomnia JSON export",
+		Long:  "Import requests from an Insomnia JSON export. Sub-environments become profiles which include the variables of the base environment.",
+		Example: fmt.Sprintf(`  # Import requests and run one of them
+  $ %s import insomnia export.json --rsh-name my-api
+  $ %s my-api list-items`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			b, err := readInput(args[0])
+			if err != nil {
+				panic(err)
+			}
+
+			c, err := parseInsomnia(b)
+			if err != nil {
+				panic(err)
+			}
+
+			if err := importCollection(c, importAPIName(cmd, args[0])); err != nil {
+				panic(err)
+			}
+		},
+	}
+	insomniaCmd.Flags().String("rsh-name", "", "Name of the API to create, defaults to the file name")
+	cmd.AddCommand(insomniaCmd)
+
+	return cmd
+}
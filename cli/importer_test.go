@@ -0,0 +1,269 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestImportVariableSyntax(t *testing.T) {
+	vars := map[string]string{
+		"host":  "https://api.example.com",
+		"api":   "{{host}}/v1",
+		"token": "abc",
+	}
+
+	for _, tc := range []struct {
+		format     string
+		input      string
+		expected   string
+		unresolved []string
+	}{
+		{"http", "{{host}}/items", "https://api.example.com/items", nil},
+		{"http", "{{ api }}/items", "https://api.example.com/v1/items", nil},
+		{"http", "Bearer {{token}}", "Bearer abc", nil},
+		{"http", "{{$guid}}", "{{$guid}}", []string{"{{$guid}}"}},
+		{"http", "{{$processEnv HOME}}", "{{$processEnv HOME}}", []string{"{{$processEnv HOME}}"}},
+		{"http", "{{missing}}", "{{missing}}", []string{"{{missing}}"}},
+		{"insomnia", "{{ _.host }}/items", "https://api.example.com/items", nil},
+		{"insomnia", "{{ token }}", "abc", nil},
+		{"insomnia", "{% uuid 'v4' %}", "{% uuid 'v4' %}", []string{"{% uuid 'v4' %}"}},
+	} {
+		t.Run(tc.format+" "+tc.input, func(t *testing.T) {
+			c := &importedCollection{Syntax: importVariables[tc.format], Variables: vars}
+			resolved, unresolved := c.resolve(tc.input, "")
+			assert.Equal(t, tc.expected, resolved)
+			assert.Equal(t, tc.unresolved, unresolved)
+		})
+	}
+}
+
+func TestParseHTTPFile(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(path.Join(dir, "body.json"), []byte(`{"name": "from file"}`), 0600))
+
+	c, err := parseHTTPFile([]byte(`@host = https://api.example.com
+@version = v1
+
+### List items
+GET {{host}}/{{version}}/items
+    ?limit=10
+    &sort=name
+Accept: application/json
+
+###
+
+# @name create-item
+POST {{host}}/{{version}}/items HTTP/1.1
+Content-Type: application/json
+
+{
+  "name": "test"
+}
+
+> {%
+  client.global.set("id", response.body.id);
+%}
+
+###
+// Without a method this is a GET
+{{host}}/{{version}}/status
+
+### Upload
+PUT {{host}}/items/1
+Content-Type: application/json
+
+< ./body.json
+`), dir)
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"host": "https://api.example.com", "version": "v1"}, c.Variables)
+	assert.Equal(t, []importedRequest{
+		{
+			Name:    "List items",
+			Method:  "GET",
+			URL:     "{{host}}/{{version}}/items?limit=10&sort=name",
+			Headers: [][2]string{{"Accept", "application/json"}},
+		},
+		{
+			Name:    "create-item",
+			Method:  "POST",
+			URL:     "{{host}}/{{version}}/items",
+			Headers: [][2]string{{"Content-Type", "application/json"}},
+			Body:    "{\n  \"name\": \"test\"\n}",
+		},
+		{
+			Method: "GET",
+			URL:    "{{host}}/{{version}}/status",
+		},
+		{
+			Name:    "Upload",
+			Method:  "PUT",
+			URL:     "{{host}}/items/1",
+			Headers: [][2]string{{"Content-Type", "application/json"}},
+			Body:    `{"name": "from file"}`,
+		},
+	}, c.Requests)
+}
+
+func TestImportHTTP(t *testing.T) {
+	defer gock.Off()
+
+	dir := t.TempDir()
+	filename := path.Join(dir, "requests.http")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`### Get user
+GET {{host}}/users/{{userId}}
+Authorization: Bearer {{token}}
+
+### Create user
+POST {{host}}/users
+Authorization: Bearer {{token}}
+Content-Type: application/json
+
+{"name": "Kari"}
+`), 0600))
+	assert.NoError(t, ioutil.WriteFile(path.Join(dir, "http-client.env.json"), []byte(`{
+		"$shared": {"userId": "1"},
+		"dev": {"host": "https://dev.import.example.com", "token": "dev-token"},
+		"prod": {"host": "https://import.example.com"}
+	}`), 0600))
+	assert.NoError(t, ioutil.WriteFile(path.Join(dir, "http-client.private.env.json"), []byte(`{
+		"prod": {"token": "prod-token"}
+	}`), 0600))
+
+	reset(false)
+	defer func() {
+		removeAPI("imported")
+		os.Remove(globalConfigFile())
+		reset(false)
+	}()
+
+	run("import http " + filename + " --rsh-name imported")
+
+	config := configs["imported"]
+	if assert.NotNil(t, config) {
+		assert.Equal(t, "https://dev.import.example.com", config.Base)
+		assert.Equal(t, "dev", config.DefaultProfile)
+		assert.Equal(t, map[string]*APIProfile{
+			"dev": {
+				Headers: map[string]string{"Authorization": "Bearer dev-token"},
+			},
+			"prod": {
+				Base:    "https://import.example.com",
+				Headers: map[string]string{"Authorization": "Bearer prod-token"},
+			},
+		}, config.Profiles)
+	}
+
+	saved, err := loadSavedRequests()
+	assert.NoError(t, err)
+	assert.Equal(t, savedRequest{Method: "GET", URL: "imported/users/1"}, saved["imported/get-user"])
+	assert.Equal(t, "POST", saved["imported/create-user"].Method)
+
+	gock.New("https://dev.import.example.com").Get("/users/1").MatchHeader("Authorization", "Bearer dev-token").Reply(200).JSON(map[string]interface{}{"id": 1})
+	gock.New("https://import.example.com").Post("/users").MatchHeader("Authorization", "Bearer prod-token").BodyString(`"name": "Kari"`).Reply(201).JSON(map[string]interface{}{"id": 2})
+
+	assert.Equal(t, "1\n", run("imported get-user -f body.id"))
+	assert.Equal(t, "2\n", run("imported create-user -p prod -f body.id"))
+	assert.True(t, gock.IsDone())
+
+	// Importing again would overwrite the API.
+	assert.Contains(t, run("import http "+filename+" --rsh-name imported"), "API imported already exists")
+}
+
+func TestImportBodies(t *testing.T) {
+	c, err := parseHTTPFile([]byte(`### Create order
+POST https://shop.example.com/orders
+
+{"items": [{"sku": "book-123", "quantity": 2}], "note": "Leave at the front door, thanks!"}
+
+### Add note
+POST https://shop.example.com/notes
+Content-Type: text/plain
+
+Deliveries after 5pm should go to the neighbour at number 12.
+`), "")
+	assert.NoError(t, err)
+
+	_, saved, err := c.convert("shop")
+	assert.NoError(t, err)
+
+	// Bodies are saved verbatim, however long or whatever their format.
+	assert.Equal(t, savedRequest{
+		Method:  "POST",
+		URL:     "shop/orders",
+		Headers: []string{"Content-Type: application/json"},
+		Body:    `{"items": [{"sku": "book-123", "quantity": 2}], "note": "Leave at the front door, thanks!"}`,
+	}, saved["create-order"])
+	assert.Equal(t, savedRequest{
+		Method:  "POST",
+		URL:     "shop/notes",
+		Headers: []string{"Content-Type: text/plain"},
+		Body:    "Deliveries after 5pm should go to the neighbour at number 12.",
+	}, saved["add-note"])
+}
+
+func TestImportInsomnia(t *testing.T) {
+	c, err := parseInsomnia([]byte(`{
+		"_type": "export",
+		"__export_format": 4,
+		"resources": [
+			{"_id": "wrk_1", "_type": "workspace", "name": "Shop"},
+			{"_id": "env_base", "_type": "environment", "parentId": "wrk_1", "data": {"api": {"url": "https://shop.example.com"}, "token": "base"}},
+			{"_id": "env_stage", "_type": "environment", "parentId": "env_base", "name": "Staging", "data": {"api": {"url": "https://stage.shop.example.com"}}},
+			{"_id": "env_prod", "_type": "environment", "parentId": "env_base", "name": "Production", "data": {"token": "prod"}},
+			{
+				"_id": "req_1", "_type": "request", "parentId": "wrk_1", "name": "List Orders", "method": "GET",
+				"url": "{{ _.api.url }}/orders",
+				"parameters": [{"name": "status", "value": "open"}, {"name": "debug", "value": "1", "disabled": true}],
+				"headers": [{"name": "X-Request-Id", "value": "{% uuid 'v4' %}"}, {"name": "Accept", "value": "application/json"}],
+				"authentication": {"type": "bearer", "token": "{{ _.token }}"}
+			},
+			{
+				"_id": "req_2", "_type": "request", "parentId": "wrk_1", "name": "Create Order", "method": "POST",
+				"url": "{{ _.api.url }}/orders",
+				"body": {"mimeType": "application/json", "text": "{\"item\": \"book\"}"},
+				"authentication": {"type": "bearer", "token": "{{ _.token }}"}
+			}
+		]
+	}`))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"Production", "Staging"}, c.environmentNames())
+	assert.Equal(t, "prod", c.Environments["Production"]["token"])
+	assert.Equal(t, "https://shop.example.com", c.Environments["Production"]["api.url"])
+
+	config, saved, err := c.convert("shop")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "https://shop.example.com", config.Base)
+	assert.Equal(t, "production", config.DefaultProfile)
+	assert.Equal(t, map[string]*APIProfile{
+		"production": {
+			Headers: map[string]string{"Authorization": "Bearer prod"},
+		},
+		"staging": {
+			Base:    "https://stage.shop.example.com",
+			Headers: map[string]string{"Authorization": "Bearer base"},
+		},
+	}, config.Profiles)
+
+	assert.Equal(t, savedRequest{
+		Method:  "GET",
+		URL:     "shop/orders?status=open",
+		Headers: []string{"X-Request-Id: {% uuid 'v4' %}", "Accept: application/json"},
+	}, saved["list-orders"])
+	assert.Equal(t, savedRequest{
+		Method:  "POST",
+		URL:     "shop/orders",
+		Headers: []string{"Content-Type: application/json"},
+		Body:    `{"item": "book"}`,
+	}, saved["create-order"])
+
+	_, err = parseInsomnia([]byte(`{"foo": "bar"}`))
+	assert.Error(t, err)
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -10,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/danielgtaylor/shorthand"
+	"github.com/spf13/viper"
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -19,13 +21,170 @@ var Stdin interface {
 	io.Reader
 } = os.Stdin
 
-// GetBody returns the request body if one was passed either as shorthand
-// arguments or via stdin.
+// readInput reads a file, or stdin if the filename is empty or `-`.
+func readInput(filename string) ([]byte, error) {
+	if filename == "" || filename == "-" {
+		return ioutil.ReadAll(Stdin)
+	}
+	return ioutil.ReadFile(filename)
+}
+
+// deepMerge merges `override` into `base`, recursing into objects present in
+// both. Any other values from `override` replace those in `base`.
+func deepMerge(base, override map[string]interface{}) map[string]interface{} {
+	for k, v := range override {
+		if baseMap, ok := base[k].(map[string]interface{}); ok {
+			if overrideMap, ok := v.(map[string]interface{}); ok {
+				base[k] = deepMerge(baseMap, overrideMap)
+				continue
+			}
+		}
+		base[k] = v
+	}
+
+	return base
+}
+
+// loadRequestTemplates loads and merges the JSON objects from the
+// `rsh-request-template` files from left to right. Environment variables like
+// `$TENANT_ID` in the files are expanded before parsing.
+func loadRequestTemplates() (map[string]interface{}, error) {
+	var merged map[string]interface{}
+
+	for _, filename := range viper.GetStringSlice("rsh-request-template") {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		var template map[string]interface{}
+		if err := json.Unmarshal([]byte(os.ExpandEnv(string(b))), &template); err != nil {
+			return nil, fmt.Errorf("request template %s must be a JSON object: %w", filename, err)
+		}
+
+		if merged == nil {
+			merged = map[string]interface{}{}
+		}
+		merged = deepMerge(merged, template)
+	}
+
+	return merged, nil
+}
+
+// headerFlag returns the last value of a header passed via `-H`, if any.
+func headerFlag(name string) string {
+	value := ""
+	for _, h := range viper.GetStringSlice("rsh-header") {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], name) {
+			value = strings.TrimSpace(parts[1])
+		}
+	}
+	return value
+}
+
+// isStructuredType returns whether the content type holds structured data
+// which input can be encoded to, like JSON, YAML, or CBOR, rather than raw
+// text or binary data.
+func isStructuredType(mediaType string) bool {
+	if strings.Contains(mediaType, "json") || strings.Contains(mediaType, "yaml") {
+		return true
+	}
+
+	_, err := Marshal(mediaType, map[string]interface{}{})
+	return err == nil
+}
+
+// encodeBody encodes structured input for the given content type.
+func encodeBody(mediaType string, input interface{}) (string, error) {
+	if isMultipart(mediaType) {
+		return encodeMultipart(input)
+	} else if strings.Contains(mediaType, "json") {
+		marshalled, err := json.Marshal(input)
+		if err != nil {
+			return "", err
+		}
+		return string(marshalled), nil
+	} else if strings.Contains(mediaType, "yaml") {
+		marshalled, err := yaml.Marshal(input)
+		if err != nil {
+			return "", err
+		}
+		return string(marshalled), nil
+	} else if marshalled, err := Marshal(mediaType, input); err == nil {
+		// Other registered content types like CBOR.
+		return string(marshalled), nil
+	}
+
+	return "", fmt.Errorf("Not sure how to marshal %s", mediaType)
+}
+
+// bodyFlagInput returns the request body from the `rsh-body` flag. For
+// structured content types the value is parsed as JSON or YAML, merged into
+// any request templates, and encoded for the content type. JSON which needs
+// no changes is sent as-is. Other content types get the value unchanged.
+func bodyFlagInput(mediaType, value string, template map[string]interface{}) (string, error) {
+	if ct := headerFlag("content-type"); ct != "" {
+		mediaType = ct
+	}
+
+	if !isStructuredType(mediaType) {
+		return value, nil
+	}
+
+	if template == nil && strings.Contains(mediaType, "json") && json.Valid([]byte(value)) {
+		return value, nil
+	}
+
+	var input interface{}
+	if err := json.Unmarshal([]byte(value), &input); err != nil {
+		if err := (YAML{}).Unmarshal([]byte(value), &input); err != nil {
+			return "", fmt.Errorf("unable to parse --rsh-body as JSON or YAML: %w", err)
+		}
+		input = makeJSONSafe(input, false)
+	}
+
+	if template != nil {
+		m, ok := input.(map[string]interface{})
+		if !ok {
+			return "", errors.New("--rsh-body must be an object to merge into request templates")
+		}
+		input = deepMerge(template, m)
+	}
+
+	return encodeBody(mediaType, input)
+}
+
+// GetBody returns the request body if one was passed either via the
+// `rsh-body` flag, as shorthand arguments, or via stdin, in that order of
+// precedence. Any request templates are used as the base which the input is
+// merged into. Errors are usage errors, e.g. invalid shorthand or a body file
+// which can't be read.
 func GetBody(mediaType string, args []string) (string, error) {
-	var body string
+	body, err := readBody(mediaType, args)
+	if err != nil {
+		return "", exitError(ExitCodeUsage, err, "invalid request body")
+	}
+
+	return body, nil
+}
+
+// readBody returns the request body, see `GetBody`.
+func readBody(mediaType string, args []string) (string, error) {
+	template, err := loadRequestTemplates()
+	if err != nil {
+		return "", err
+	}
+
+	if value := viper.GetString("rsh-body"); value != "" {
+		if len(args) > 0 {
+			LogWarning("Ignoring body arguments because --rsh-body is set: %s", strings.Join(args, " "))
+		}
+		return bodyFlagInput(mediaType, value, template)
+	}
 
 	if info, err := Stdin.Stat(); err == nil {
-		if len(args) == 0 && (info.Mode()&os.ModeCharDevice) == 0 {
+		if len(args) == 0 && template == nil && (info.Mode()&os.ModeCharDevice) == 0 {
 			// There are no args but there is data on stdin. Just read it and
 			// pass it through as it may not be structured data we can parse or
 			// could be binary (e.g. file uploads).
@@ -42,23 +201,13 @@ func GetBody(mediaType string, args []string) (string, error) {
 		return "", err
 	}
 
-	if input != nil {
-		if strings.Contains(mediaType, "json") {
-			marshalled, err := json.Marshal(input)
-			if err != nil {
-				return "", err
-			}
-			body = string(marshalled)
-		} else if strings.Contains(mediaType, "yaml") {
-			marshalled, err := yaml.Marshal(input)
-			if err != nil {
-				return "", err
-			}
-			body = string(marshalled)
-		} else {
-			return "", fmt.Errorf("Not sure how to marshal %s", mediaType)
-		}
+	if template != nil {
+		input = deepMerge(template, input)
 	}
 
-	return body, nil
+	if input == nil {
+		return "", nil
+	}
+
+	return encodeBody(mediaType, input)
 }
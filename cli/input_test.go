@@ -2,11 +2,15 @@ package cli
 
 import (
 	"io/fs"
+	"io/ioutil"
 	"os"
+	"path"
 	"testing"
 	"testing/fstest"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
 )
 
 func WithFakeStdin(data []byte, mode fs.FileMode, f func()) {
@@ -52,3 +56,96 @@ func TestInputInvalidType(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestInputRequestTemplate(t *testing.T) {
+	dir := t.TempDir()
+	base := path.Join(dir, "base.json")
+	region := path.Join(dir, "region.json")
+	assert.NoError(t, ioutil.WriteFile(base, []byte(`{"tenant_id": "$TEMPLATE_TENANT", "meta": {"region": "us-east-1", "tier": "free"}}`), 0600))
+	assert.NoError(t, ioutil.WriteFile(region, []byte(`{"meta": {"region": "eu-west-1"}}`), 0600))
+
+	os.Setenv("TEMPLATE_TENANT", "t1")
+	defer os.Unsetenv("TEMPLATE_TENANT")
+
+	viper.Set("rsh-request-template", []string{base, region})
+	defer viper.Set("rsh-request-template", []string{})
+
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		body, err := GetBody("application/json", []string{"meta.tier: pro, name: foo"})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"tenant_id": "t1", "name": "foo", "meta": {"region": "eu-west-1", "tier": "pro"}}`, body)
+
+		// The templates alone are used as the body without any input.
+		body, err = GetBody("application/json", []string{})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"tenant_id": "t1", "meta": {"region": "eu-west-1", "tier": "free"}}`, body)
+	})
+
+	viper.Set("rsh-request-template", []string{path.Join(dir, "missing.json")})
+	_, err := GetBody("application/json", []string{"name: foo"})
+	assert.Error(t, err)
+}
+
+func TestInputBodyFlag(t *testing.T) {
+	defer viper.Set("rsh-body", "")
+	defer viper.Set("rsh-header", []string{})
+
+	WithFakeStdin([]byte("ignored"), 0, func() {
+		// JSON is sent as-is and takes precedence over shorthand and stdin.
+		viper.Set("rsh-body", "{\n  \"name\": \"foo\",\n  \"big\": 12345678901234567890\n}")
+		body, err := GetBody("application/json", []string{"name: bar"})
+		assert.NoError(t, err)
+		assert.Equal(t, "{\n  \"name\": \"foo\",\n  \"big\": 12345678901234567890\n}", body)
+
+		// YAML is converted for the content type.
+		viper.Set("rsh-body", "name: foo\ntags:\n  - a\n  - b\n")
+		body, err = GetBody("application/json", []string{})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"name": "foo", "tags": ["a", "b"]}`, body)
+
+		viper.Set("rsh-body", `{"name": "foo"}`)
+		body, err = GetBody("application/yaml", []string{})
+		assert.NoError(t, err)
+		assert.Equal(t, "name: foo\n", body)
+
+		// Other content types get the raw value, including one set via `-H`.
+		viper.Set("rsh-body", "name: foo\n")
+		body, err = GetBody("text/plain", []string{})
+		assert.NoError(t, err)
+		assert.Equal(t, "name: foo\n", body)
+
+		viper.Set("rsh-header", []string{"Content-Type: text/plain"})
+		body, err = GetBody("application/json", []string{})
+		assert.NoError(t, err)
+		assert.Equal(t, "name: foo\n", body)
+	})
+}
+
+func TestInputBodyFlagTemplate(t *testing.T) {
+	filename := path.Join(t.TempDir(), "base.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`{"meta": {"region": "us-east-1", "tier": "free"}}`), 0600))
+
+	viper.Set("rsh-request-template", []string{filename})
+	defer viper.Set("rsh-request-template", []string{})
+	defer viper.Set("rsh-body", "")
+
+	viper.Set("rsh-body", `{"meta": {"tier": "pro"}}`)
+	body, err := GetBody("application/json", []string{})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"meta": {"region": "us-east-1", "tier": "pro"}}`, body)
+
+	viper.Set("rsh-body", `[1, 2]`)
+	_, err = GetBody("application/json", []string{})
+	assert.Error(t, err)
+}
+
+func TestBodyFlagRequest(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Post("/items").BodyString(`"id":1`).Reply(201)
+
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		assert.Contains(t, run(`post http://example.com/items --rsh-body {"id":1} name:ignored`), "Ignoring body arguments")
+	})
+	assert.True(t, gock.IsDone())
+}
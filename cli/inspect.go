@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// inspectRequests prints requests instead of sending them when set.
+var inspectRequests bool
+
+// errRequestInspected is returned by `MakeRequest` for inspected requests,
+// which are printed rather than sent.
+var errRequestInspected = errors.New("request inspected")
+
+// maskValue redacts a header or query param value if its name suggests it
+// holds a secret. The scheme of auth headers like `Bearer` is kept.
+func maskValue(name, value string) string {
+	if value == "" || !isSecretName(name) {
+		return value
+	}
+
+	if strings.HasSuffix(strings.ToLower(name), "authorization") {
+		if scheme, _, ok := strings.Cut(value, " "); ok {
+			return scheme + " REDACTED"
+		}
+	}
+
+	return "REDACTED"
+}
+
+// maskURL returns the URL with secret query params redacted.
+func maskURL(u *url.URL) string {
+	masked := *u
+	query := masked.Query()
+	for name, values := range query {
+		for i, v := range values {
+			values[i] = maskValue(name, v)
+		}
+		query[name] = values
+	}
+	masked.RawQuery = query.Encode()
+
+	return masked.String()
+}
+
+// inspectBody returns the request body for display without consuming it.
+// JSON is indented while binary data is summarized.
+func inspectBody(req *http.Request) ([]byte, string, error) {
+	if req.Body == nil || req.GetBody == nil {
+		return nil, "", nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, "", err
+	}
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(b) == 0 {
+		return nil, "", nil
+	}
+
+	if (JSON{}).Detect(req.Header.Get("Content-Type")) {
+		buf := &bytes.Buffer{}
+		if json.Indent(buf, b, "", "  ") == nil {
+			return buf.Bytes(), "json", nil
+		}
+	}
+
+	if !utf8.Valid(b) {
+		return []byte(fmt.Sprintf("<%d bytes of binary data>", len(b))), "", nil
+	}
+
+	return b, "", nil
+}
+
+// printInspectedRequest prints a request as an HTTP message with secrets in
+// headers and query params redacted.
+func printInspectedRequest(req *http.Request) error {
+	head := fmt.Sprintf("%s %s HTTP/1.1\nHost: %s\n", req.Method, maskURL(req.URL), req.URL.Host)
+
+	names := []string{}
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range req.Header[name] {
+			head += name + ": " + maskValue(name, value) + "\n"
+		}
+	}
+
+	body, lexer, err := inspectBody(req)
+	if err != nil {
+		return err
+	}
+
+	out := []byte(head)
+	if tty {
+		if out, err = Highlight("http", out); err != nil {
+			return err
+		}
+	}
+
+	if len(body) > 0 {
+		if tty && lexer != "" {
+			if body, err = Highlight(lexer, body); err != nil {
+				return err
+			}
+		}
+
+		out = append(out, '\n')
+		out = append(out, body...)
+		if !bytes.HasSuffix(body, []byte("\n")) {
+			out = append(out, '\n')
+		}
+	}
+
+	_, err = Stdout.Write(out)
+	return err
+}
+
+func requestCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "request",
+		Short: "Request debugging commands",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "inspect api-name operation [args...]",
+		Short: "Show the request an operation would send",
+		Long:  "Build the request for an API operation with all profile, operation, and global options applied and print it as an HTTP message instead of sending it. Secrets in headers and query params are redacted.",
+		Example: fmt.Sprintf(`  # Show the request for creating an item
+  $ %s request inspect my-api create-item name: Kari -H X-Debug:1`, Root.CommandPath()),
+		Args:               cobra.MinimumNArgs(2),
+		DisableFlagParsing: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			op, rest, err := Root.Find(args)
+			if err != nil || op == Root || op.Parent() == Root {
+				panic(fmt.Errorf("operation %s not found for API %s", args[1], args[0]))
+			}
+
+			if err := op.ParseFlags(rest); err != nil {
+				panic(err)
+			}
+
+			opArgs := op.Flags().Args()
+			if err := op.ValidateArgs(opArgs); err != nil {
+				panic(err)
+			}
+
+			inspectRequests = true
+			defer func() {
+				inspectRequests = false
+			}()
+			if err := op.RunE(op, opArgs); err != nil {
+				panic(err)
+			}
+		},
+	})
+
+	return cmd
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestInspect(t *testing.T) {
+	reset(false)
+
+	configs["inspect-test"] = &APIConfig{
+		name: "inspect-test",
+		Base: "http://inspect.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {
+				Headers: map[string]string{"X-API-Key": "abc", "X-Team": "core"},
+				Query:   map[string]string{"access_token": "def"},
+				Auth: &APIAuth{
+					Name:   "http-basic",
+					Params: map[string]string{"username": "user", "password": "pass"},
+				},
+			},
+		},
+	}
+
+	op := Operation{
+		Name:          "create-item",
+		Method:        http.MethodPost,
+		URITemplate:   "http://inspect.example.com/items",
+		BodyMediaType: "application/json",
+		QueryParams:   []*Param{{Type: "integer", Name: "limit"}},
+	}
+	api := &cobra.Command{Use: "inspect-test"}
+	api.AddCommand(op.command())
+	Root.AddCommand(api)
+
+	capture := &strings.Builder{}
+	Stdout = capture
+	Stderr = capture
+
+	inspect, _, err := Root.Find([]string{"request", "inspect"})
+	assert.NoError(t, err)
+	inspect.Run(inspect, []string{"inspect-test", "create-item", "--limit", "5", "-H", "X-Debug:1", "name: Kari"})
+
+	out := capture.String()
+	// Auth isn't run, so it has no side effects like opening a browser.
+	assert.True(t, strings.HasPrefix(out, "INFO: Auth http-basic from profile default is added when the request is sent\nPOST http://inspect.example.com/items?access_token=REDACTED&limit=5 HTTP/1.1\nHost: inspect.example.com\n"), out)
+	assert.NotContains(t, out, "Authorization")
+	assert.Contains(t, out, "\nContent-Type: application/json\n")
+	assert.Contains(t, out, "\nX-Api-Key: REDACTED\n")
+	assert.Contains(t, out, "\nX-Team: core\n")
+	assert.Contains(t, out, "\nX-Debug: 1\n")
+	assert.True(t, strings.HasSuffix(out, "\n\n{\n  \"name\": \"Kari\"\n}\n"), out)
+	assert.False(t, inspectRequests)
+
+	assert.PanicsWithError(t, "operation missing not found for API inspect-test", func() {
+		inspect.Run(inspect, []string{"inspect-test", "missing"})
+	})
+}
@@ -1,9 +1,14 @@
 package cli
 
 import (
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/AlecAivazis/survey/v2/terminal"
@@ -70,8 +75,114 @@ func (a defaultAsker) askSelect(message string, options []string, def interface{
 	return resp
 }
 
+// baseProbeTimeout limits each request made while checking a new base URI,
+// so a typo or unreachable host is reported quickly.
+const baseProbeTimeout = 5 * time.Second
+
+// normalizeBaseURI adds a scheme to a base URI if it is missing, using HTTP
+// for local traffic like requests do, and strips any trailing slashes.
+func normalizeBaseURI(uri string) string {
+	uri = strings.TrimSpace(uri)
+
+	if strings.HasPrefix(uri, ":") {
+		uri = "localhost" + uri
+	}
+
+	if !strings.Contains(uri, "://") {
+		if strings.HasPrefix(uri, "localhost") {
+			uri = "http://" + uri
+		} else {
+			uri = "https://" + uri
+		}
+	}
+
+	return strings.TrimRight(uri, "/")
+}
+
+// probeBaseURI checks a base URI for an API description and describes what
+// was found. An error is returned only if the server couldn't be reached.
+func probeBaseURI(name string, config *APIConfig) (string, error) {
+	uri, err := url.Parse(config.Base + "/")
+	if err != nil {
+		return "", err
+	}
+
+	specs, err := findSpecs(name, config, uri, baseProbeTimeout)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return "", err
+		}
+		LogDebug("No API description found: %v", err)
+	}
+
+	if len(specs) == 0 {
+		return "No API description found, generic mode only", nil
+	}
+
+	spec := specs[0]
+	kind := "API description"
+	if s, ok := spec.loader.(fmt.Stringer); ok {
+		kind = s.String() + " spec"
+	}
+
+	location := spec.location.String()
+	if len(config.SpecFiles) > 0 {
+		location = strings.Join(config.SpecFiles, ", ")
+	} else if spec.location.Host == uri.Host {
+		location = spec.location.RequestURI()
+	}
+
+	api, err := spec.loader.Load(*uri, spec.location, spec.response())
+	if err != nil {
+		return fmt.Sprintf("%s detected at %s but could not be loaded: %v", kind, location, err), nil
+	}
+
+	details := fmt.Sprintf("%d operations", len(api.Operations))
+	if len(api.Auth) > 0 {
+		auth := []string{}
+		for _, a := range api.Auth {
+			auth = append(auth, a.Name)
+		}
+		details += ", auth: " + strings.Join(auth, ", ")
+	}
+
+	return fmt.Sprintf("%s detected at %s (%s)", kind, location, details), nil
+}
+
+// isUnknownAuthority returns whether a request failed because the server's
+// certificate isn't signed by a trusted CA, like a company's private CA.
+func isUnknownAuthority(err error) bool {
+	var unknown x509.UnknownAuthorityError
+	return errors.As(err, &unknown)
+}
+
 func askBaseURI(a asker, config *APIConfig) {
-	config.Base = a.askInput("Base URI", config.Base, true, "The entrypoint of the API, where Restish can look for an API description document and apply authentication.\nExample: https://api.example.com")
+	help := "The entrypoint of the API, where Restish can look for an API description document and apply authentication.\nExample: https://api.example.com"
+	config.Base = normalizeBaseURI(a.askInput("Base URI", config.Base, true, help))
+
+	for {
+		summary, err := probeBaseURI(config.name, config)
+		if err == nil {
+			fmt.Println(summary)
+			break
+		}
+
+		if isUnknownAuthority(err) && a.askConfirm(fmt.Sprintf("The certificate for %s is signed by an unknown authority, set a CA certificate for this API?", config.Base), true, "Internal APIs often use a private CA. Its PEM encoded certificate is trusted only for requests to this API, which is safer than disabling TLS checks with --rsh-insecure.") {
+			if config.TLS == nil {
+				config.TLS = &TLSConfig{}
+			}
+			config.TLS.CACert = a.askInput("CA Certificate path", config.TLS.CACert, true, "")
+			continue
+		}
+
+		if a.askConfirm(fmt.Sprintf("Could not reach %s (%v), keep it anyway?", config.Base, err), false, "The API may be offline or need a VPN. Say no to enter a different base URI.") {
+			// There is nothing to load settings from.
+			return
+		}
+
+		config.Base = normalizeBaseURI(a.askInput("Base URI", config.Base, true, help))
+	}
 
 	askLoadBaseAPI(a, config)
 }
@@ -211,7 +322,267 @@ func askAuth(a asker, auth *APIAuth) {
 	}
 }
 
-func askEditProfile(a asker, name string, profile *APIProfile) {
+// hasHeader returns whether a header is set, ignoring case.
+func hasHeader(headers map[string]string, name string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// inheritedKeys returns the sorted keys of the parent values which are not
+// overridden by the child values.
+func inheritedKeys(parent, child map[string]string, fold bool) []string {
+	keys := []string{}
+	for k := range parent {
+		if _, ok := child[k]; ok || (fold && hasHeader(child, k)) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// printInherited shows the values a profile inherits from its parent dimmed,
+// as they can only be overridden rather than edited in place.
+func printInherited(profile, inherited *APIProfile) {
+	lines := []string{}
+
+	if profile.Base == "" && inherited.Base != "" {
+		lines = append(lines, "server: "+inherited.Base)
+	}
+
+	headers := redactValues(inherited.Headers)
+	for _, k := range inheritedKeys(inherited.Headers, profile.Headers, true) {
+		lines = append(lines, "header "+k+": "+headers[k])
+	}
+
+	query := redactValues(inherited.Query)
+	for _, k := range inheritedKeys(inherited.Query, profile.Query, false) {
+		lines = append(lines, "query param "+k+": "+query[k])
+	}
+
+	if profile.Auth == nil && inherited.Auth != nil && inherited.Auth.Name != "" {
+		lines = append(lines, "auth: "+inherited.Auth.Name)
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Println(au.Faint("Inherited from `" + profile.Extends + "` (override to change):"))
+	for _, line := range lines {
+		fmt.Println(au.Faint("  " + line))
+	}
+}
+
+// askExtends sets the parent profile to inherit from, keeping the previous
+// value if the parent doesn't exist or would create a cycle.
+func askExtends(a asker, config *APIConfig, name string, profile *APIProfile) {
+	prev := profile.Extends
+	profile.Extends = a.askInput("Parent profile to inherit from (leave empty for none)", prev, false, "Headers, query params, auth, and the server override are inherited from the parent profile unless set in this profile.")
+
+	if profile.Extends == "" {
+		return
+	}
+
+	if config.Profiles[profile.Extends] == nil {
+		LogError("Unknown profile %s", profile.Extends)
+		profile.Extends = prev
+		return
+	}
+
+	profiles := map[string]*APIProfile{name: profile}
+	for k, v := range config.Profiles {
+		if k != name {
+			profiles[k] = v
+		}
+	}
+
+	if _, err := (APIConfig{Profiles: profiles}).resolveProfile(name); err != nil {
+		LogError("%v", err)
+		profile.Extends = prev
+	}
+}
+
+// askProfileAuth sets up auth for a profile. When the auth is inherited, the
+// parent's values are used as defaults and only changed params are kept.
+func askProfileAuth(a asker, profile, inherited *APIProfile) {
+	var parent *APIAuth
+	if inherited != nil && inherited.Auth != nil && inherited.Auth.Name != "" {
+		parent = inherited.Auth
+	}
+
+	if profile.Auth == nil {
+		profile.Auth = &APIAuth{}
+		if parent != nil {
+			profile.Auth.Name = parent.Name
+		}
+	}
+
+	if parent != nil && (profile.Auth.Name == "" || profile.Auth.Name == parent.Name) {
+		profile.Auth.Params = mergeValues(parent.Params, profile.Auth.Params, false)
+	}
+
+	askAuth(a, profile.Auth)
+
+	if parent != nil && profile.Auth.Name == parent.Name {
+		for k, v := range profile.Auth.Params {
+			if parent.Params[k] == v {
+				delete(profile.Auth.Params, k)
+			}
+		}
+
+		if len(profile.Auth.Params) == 0 {
+			profile.Auth = nil
+		}
+	}
+}
+
+// profileAction is what a profile editor menu item does when selected.
+type profileAction int
+
+const (
+	actionAddHeader profileAction = iota
+	actionEditHeader
+	actionDeleteHeader
+	actionAddQuery
+	actionEditQuery
+	actionDeleteQuery
+	actionOverrideHeader
+	actionOverrideQuery
+	actionSetParent
+	actionSetServer
+	actionSetupAuth
+	actionRenameProfile
+	actionDeleteProfile
+	actionFinished
+)
+
+// profileMenuItem is an option in the profile editor. The header or query
+// param name it applies to is kept separately from the label shown to the
+// user, so it never has to be parsed back out of the label.
+type profileMenuItem struct {
+	label  string
+	action profileAction
+	key    string
+}
+
+// sortedValueKeys returns the keys of the values in sorted order.
+func sortedValueKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// profileMenu returns the profile editor options for the current state of
+// the profile.
+func profileMenu(profile, inherited *APIProfile) []profileMenuItem {
+	items := []profileMenuItem{{label: "Add header", action: actionAddHeader}}
+
+	headers := sortedValueKeys(profile.Headers)
+	for _, k := range headers {
+		items = append(items, profileMenuItem{"Edit header " + k, actionEditHeader, k})
+	}
+	for _, k := range headers {
+		items = append(items, profileMenuItem{"Delete header " + k, actionDeleteHeader, k})
+	}
+
+	items = append(items, profileMenuItem{label: "Add query param", action: actionAddQuery})
+
+	query := sortedValueKeys(profile.Query)
+	for _, k := range query {
+		items = append(items, profileMenuItem{"Edit query param " + k, actionEditQuery, k})
+	}
+	for _, k := range query {
+		items = append(items, profileMenuItem{"Delete query param " + k, actionDeleteQuery, k})
+	}
+
+	if inherited != nil {
+		for _, k := range inheritedKeys(inherited.Headers, profile.Headers, true) {
+			items = append(items, profileMenuItem{"Override inherited header " + k, actionOverrideHeader, k})
+		}
+		for _, k := range inheritedKeys(inherited.Query, profile.Query, false) {
+			items = append(items, profileMenuItem{"Override inherited query param " + k, actionOverrideQuery, k})
+		}
+	}
+
+	return append(items,
+		profileMenuItem{label: "Set parent profile", action: actionSetParent},
+		profileMenuItem{label: "Set server override", action: actionSetServer},
+		profileMenuItem{label: "Setup auth", action: actionSetupAuth},
+		profileMenuItem{label: "Rename profile", action: actionRenameProfile},
+		profileMenuItem{label: "Delete profile", action: actionDeleteProfile},
+		profileMenuItem{label: "Finished with profile", action: actionFinished},
+	)
+}
+
+// renameProfile renames a profile, updating the default profile and any
+// profiles which extend it to use the new name.
+func renameProfile(config *APIConfig, from, to string) error {
+	if to == from {
+		return nil
+	}
+
+	if config.Profiles[to] != nil {
+		return fmt.Errorf("profile %s already exists", to)
+	}
+
+	if config.Profiles == nil {
+		config.Profiles = map[string]*APIProfile{}
+	}
+
+	config.Profiles[to] = config.Profiles[from]
+	delete(config.Profiles, from)
+
+	for _, p := range config.Profiles {
+		if p != nil && p.Extends == from {
+			p.Extends = to
+		}
+	}
+
+	// Keep the same profile selected by default. The `default` profile is used
+	// when none is set, so there is no need to store it.
+	if config.DefaultProfile == from || (config.DefaultProfile == "" && from == "default") {
+		config.DefaultProfile = to
+	}
+	if config.DefaultProfile == "default" {
+		config.DefaultProfile = ""
+	}
+
+	return nil
+}
+
+// deleteProfile removes a profile unless other profiles extend it.
+func deleteProfile(config *APIConfig, name string) error {
+	children := []string{}
+	for k, p := range config.Profiles {
+		if p != nil && p.Extends == name {
+			children = append(children, k)
+		}
+	}
+
+	if len(children) > 0 {
+		sort.Strings(children)
+		return fmt.Errorf("profile %s is extended by %s", name, strings.Join(children, ", "))
+	}
+
+	delete(config.Profiles, name)
+
+	if config.DefaultProfile == name {
+		config.DefaultProfile = ""
+	}
+
+	return nil
+}
+
+func askEditProfile(a asker, config *APIConfig, name string, profile *APIProfile) {
 	if profile.Headers == nil {
 		profile.Headers = map[string]string{}
 	}
@@ -221,61 +592,89 @@ func askEditProfile(a asker, name string, profile *APIProfile) {
 	}
 
 	for {
-		options := []string{
-			"Add header",
+		var inherited *APIProfile
+		if profile.Extends != "" {
+			inherited, _ = config.resolveProfile(profile.Extends)
 		}
 
-		for k := range profile.Headers {
-			options = append(options, "Edit header "+k)
+		if inherited != nil {
+			printInherited(profile, inherited)
 		}
-		for k := range profile.Headers {
-			options = append(options, "Delete header "+k)
+
+		items := profileMenu(profile, inherited)
+		options := make([]string, 0, len(items))
+		for _, item := range items {
+			options = append(options, item.label)
 		}
 
-		options = append(options, "Add query param")
+		choice := a.askSelect("Select option for profile `"+name+"`", options, nil, "")
 
-		for k := range profile.Query {
-			options = append(options, "Edit query param "+k)
-		}
-		for k := range profile.Query {
-			options = append(options, "Delete query param "+k)
+		var item profileMenuItem
+		found := false
+		for _, i := range items {
+			if i.label == choice {
+				item = i
+				found = true
+				break
+			}
 		}
 
-		options = append(options, "Setup auth", "Finished with profile")
-
-		choice := a.askSelect("Select option for profile `"+name+"`", options, nil, "")
+		if !found {
+			continue
+		}
 
-		switch {
-		case choice == "Add header":
+		switch item.action {
+		case actionAddHeader:
 			key := a.askInput("Header name", "", true, "")
 			profile.Headers[key] = a.askInput("Header value", "", false, "")
-		case strings.HasPrefix(choice, "Edit header"):
-			h := strings.SplitN(choice, " ", 3)[2]
-			key := a.askInput("Header name", h, true, "")
-			profile.Headers[key] = a.askInput("Header value", profile.Headers[key], false, "")
-		case strings.HasPrefix(choice, "Delete header"):
-			h := strings.SplitN(choice, " ", 3)[2]
-			if a.askConfirm("Are you sure you want to delete the "+h+" header?", false, "") {
-				delete(profile.Headers, h)
+		case actionEditHeader:
+			key := a.askInput("Header name", item.key, true, "")
+			value := a.askInput("Header value", profile.Headers[item.key], false, "")
+			delete(profile.Headers, item.key)
+			profile.Headers[key] = value
+		case actionDeleteHeader:
+			if a.askConfirm("Are you sure you want to delete the "+item.key+" header?", false, "") {
+				delete(profile.Headers, item.key)
 			}
-		case choice == "Add query param":
+		case actionAddQuery:
 			key := a.askInput("Query param name", "", true, "")
 			profile.Query[key] = a.askInput("Query param value", "", false, "")
-		case strings.HasPrefix(choice, "Edit query param"):
-			q := strings.SplitN(choice, " ", 4)[3]
-			key := a.askInput("Query param name", q, true, "")
-			profile.Headers[key] = a.askInput("Query param value", profile.Query[key], false, "")
-		case strings.HasPrefix(choice, "Delete query param"):
-			q := strings.SplitN(choice, " ", 4)[3]
-			if a.askConfirm("Are you sure you want to delete the "+q+" query param?", false, "") {
-				delete(profile.Query, q)
+		case actionEditQuery:
+			key := a.askInput("Query param name", item.key, true, "")
+			value := a.askInput("Query param value", profile.Query[item.key], false, "")
+			delete(profile.Query, item.key)
+			profile.Query[key] = value
+		case actionDeleteQuery:
+			if a.askConfirm("Are you sure you want to delete the "+item.key+" query param?", false, "") {
+				delete(profile.Query, item.key)
+			}
+		case actionOverrideHeader:
+			profile.Headers[item.key] = a.askInput("Header value", inherited.Headers[item.key], false, "")
+		case actionOverrideQuery:
+			profile.Query[item.key] = a.askInput("Query param value", inherited.Query[item.key], false, "")
+		case actionSetParent:
+			askExtends(a, config, name, profile)
+		case actionSetServer:
+			profile.Base = a.askInput("Base URI for this profile (leave empty to use the API base)", profile.Base, false, "Requests for operations go to this server instead while the profile is selected. The API description is still loaded from the API base.")
+		case actionSetupAuth:
+			askProfileAuth(a, profile, inherited)
+		case actionRenameProfile:
+			to := a.askInput("New profile name", name, true, "")
+			if err := renameProfile(config, name, to); err != nil {
+				LogError("%v", err)
+				continue
+			}
+			name = to
+		case actionDeleteProfile:
+			if !a.askConfirm("Are you sure you want to delete the "+name+" profile?", false, "") {
+				continue
 			}
-		case choice == "Setup auth":
-			if profile.Auth == nil {
-				profile.Auth = &APIAuth{}
+			if err := deleteProfile(config, name); err != nil {
+				LogError("%v", err)
+				continue
 			}
-			askAuth(a, profile.Auth)
-		case choice == "Finished with profile":
+			return
+		case actionFinished:
 			return
 		}
 	}
@@ -289,7 +688,22 @@ func askAddProfile(a asker, config *APIConfig) {
 	}
 
 	config.Profiles[name] = &APIProfile{}
-	askEditProfile(a, name, config.Profiles[name])
+	askEditProfile(a, config, name, config.Profiles[name])
+}
+
+// askDefaultProfile selects the profile used for the API when none is given
+// via `-p` or `RSH_PROFILE`.
+func askDefaultProfile(a asker, config *APIConfig) {
+	var def interface{}
+	if config.DefaultProfile != "" {
+		def = config.DefaultProfile
+	}
+
+	config.DefaultProfile = a.askSelect("Default profile", config.profileNames(), def, "This profile is used unless another is selected with `-p` or the `RSH_PROFILE` environment variable.")
+	if config.DefaultProfile == "default" {
+		// This is already the default, so there is no need to store it.
+		config.DefaultProfile = ""
+	}
 }
 
 func askTLSConfig(a asker, config *APIConfig) {
@@ -375,7 +789,7 @@ func askInitAPI(a asker, cmd *cobra.Command, args []string) {
 		if len(args) == 1 {
 			askBaseURI(a, config)
 		} else {
-			config.Base = args[1]
+			config.Base = normalizeBaseURI(args[1])
 			askLoadBaseAPI(a, config)
 		}
 
@@ -383,7 +797,7 @@ func askInitAPI(a asker, cmd *cobra.Command, args []string) {
 			fmt.Println("Setting up a `default` profile")
 			config.Profiles["default"] = &APIProfile{}
 
-			askEditProfile(a, "default", config.Profiles["default"])
+			askEditProfile(a, config, "default", config.Profiles["default"])
 		}
 	}
 
@@ -397,8 +811,18 @@ func askInitAPI(a asker, cmd *cobra.Command, args []string) {
 			options = append(options, "Edit profile "+k)
 		}
 
+		if len(config.Profiles) > 0 {
+			def := config.DefaultProfile
+			if def == "" {
+				def = "default"
+			}
+			options = append(options, "Set default profile ("+def+")")
+		}
+
 		if (config.TLS != nil) && (*config.TLS != TLSConfig{}) {
 			options = append(options, "Edit TLS configuration")
+		} else {
+			options = append(options, "Set up TLS (CA or client certificate)")
 		}
 
 		options = append(options, "Save and exit")
@@ -412,8 +836,10 @@ func askInitAPI(a asker, cmd *cobra.Command, args []string) {
 			askAddProfile(a, config)
 		case strings.HasPrefix(choice, "Edit profile"):
 			profile := strings.SplitN(choice, " ", 3)[2]
-			askEditProfile(a, profile, config.Profiles[profile])
-		case choice == "Edit TLS configuration":
+			askEditProfile(a, config, profile, config.Profiles[profile])
+		case strings.HasPrefix(choice, "Set default profile"):
+			askDefaultProfile(a, config)
+		case choice == "Edit TLS configuration" || strings.HasPrefix(choice, "Set up TLS"):
 			askTLSConfig(a, config)
 		case choice == "Save and exit":
 			config.Save()
@@ -1,12 +1,15 @@
 package cli
 
 import (
+	"errors"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"testing"
 
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
 	"gopkg.in/h2non/gock.v1"
 )
 
@@ -36,25 +39,27 @@ func (a *mockAsker) askSelect(message string, options []string, def interface{},
 
 func TestInteractive(t *testing.T) {
 	// Remove existing config if present...
-	os.Remove(path.Join(userHomeDir(), ".test", "apis.json"))
-	os.Remove(path.Join(userHomeDir(), ".test", "cache.json"))
+	configPath, cachePath := appDirs("test")
+	os.Remove(path.Join(configPath, "apis.json"))
+	os.Remove(path.Join(cachePath, "cache.json"))
 
 	reset(false)
 
 	defer gock.Off()
 
-	gock.New("http://api.example.com").Get("/").Reply(200).JSON(map[string]interface{}{
+	// The base URI is checked each time it is entered.
+	gock.New("http://api.example.com").Get("/").Persist().Reply(200).JSON(map[string]interface{}{
 		"Hello": "World",
 	})
 
-	gock.New("http://api.example.com").Get("/openapi.json").Reply(404)
-	gock.New("http://api.example.com").Get("/openapi.yaml").Reply(404)
+	gock.New("http://api.example.com").Get("/openapi.json").Persist().Reply(404)
+	gock.New("http://api.example.com").Get("/openapi.yaml").Persist().Reply(404)
 
 	mock := &mockAsker{
 		t: t,
 		responses: []string{
 			// TODO: Add a bunch more responses for various code paths.
-			"http://api.example.com",
+			"http://api.example.com/",
 			"Add header",
 			"Foo",
 			"bar",
@@ -78,6 +83,7 @@ func TestInteractive(t *testing.T) {
 	}
 
 	askInitAPI(mock, Root, []string{"example"})
+	assert.Equal(t, "http://api.example.com", configs["example"].Base)
 }
 
 type testLoader struct {
@@ -99,8 +105,9 @@ func (l *testLoader) Load(entrypoint, spec url.URL, resp *http.Response) (API, e
 
 func TestInteractiveAutoConfig(t *testing.T) {
 	// Remove existing config if present...
-	os.Remove(path.Join(userHomeDir(), ".test", "apis.json"))
-	os.Remove(path.Join(userHomeDir(), ".test", "cache.json"))
+	configPath, cachePath := appDirs("test")
+	os.Remove(path.Join(configPath, "apis.json"))
+	os.Remove(path.Join(cachePath, "cache.json"))
 
 	reset(false)
 	AddLoader(&testLoader{
@@ -198,3 +205,245 @@ func TestInteractiveAutoConfig(t *testing.T) {
 
 	askInitAPI(mock, Root, []string{"autoconfig", "http://api2.example.com"})
 }
+
+func TestInteractiveProfileBase(t *testing.T) {
+	profile := &APIProfile{}
+	askEditProfile(&mockAsker{t: t, responses: []string{
+		"Set server override",
+		"https://staging.example.com",
+		"Finished with profile",
+	}}, &APIConfig{}, "staging", profile)
+
+	if profile.Base != "https://staging.example.com" {
+		t.Fatalf("unexpected profile base %s", profile.Base)
+	}
+}
+
+func TestInteractiveProfileExtends(t *testing.T) {
+	config := &APIConfig{
+		Profiles: map[string]*APIProfile{
+			"default": {
+				Headers: map[string]string{"X-Tenant": "acme"},
+			},
+		},
+	}
+	profile := &APIProfile{}
+	config.Profiles["staging"] = profile
+
+	askEditProfile(&mockAsker{t: t, responses: []string{
+		"Set parent profile",
+		"missing",
+		"Set parent profile",
+		"default",
+		"Override inherited header X-Tenant",
+		"staging-tenant",
+		"Finished with profile",
+	}}, config, "staging", profile)
+
+	assert.Equal(t, "default", profile.Extends)
+	assert.Equal(t, map[string]string{"X-Tenant": "staging-tenant"}, profile.Headers)
+	assert.Equal(t, map[string]string{"X-Tenant": "acme"}, config.Profiles["default"].Headers)
+
+	// A profile can't extend itself.
+	askEditProfile(&mockAsker{t: t, responses: []string{
+		"Set parent profile",
+		"staging",
+		"Finished with profile",
+	}}, config, "staging", profile)
+	assert.Equal(t, "default", profile.Extends)
+}
+
+func TestInteractiveDefaultProfile(t *testing.T) {
+	config := &APIConfig{
+		Profiles: map[string]*APIProfile{
+			"default": {},
+			"staging": {},
+		},
+	}
+
+	askDefaultProfile(&mockAsker{t: t, responses: []string{"staging"}}, config)
+	assert.Equal(t, "staging", config.DefaultProfile)
+
+	askDefaultProfile(&mockAsker{t: t, responses: []string{"default"}}, config)
+	assert.Equal(t, "", config.DefaultProfile)
+}
+
+func TestInteractiveProfileEditor(t *testing.T) {
+	config := &APIConfig{
+		DefaultProfile: "staging",
+		Profiles: map[string]*APIProfile{
+			"staging": {
+				Headers: map[string]string{"X-Old Name": "1", "Accept": "application/json"},
+				Query:   map[string]string{"page size": "10"},
+			},
+			"child": {Extends: "staging"},
+		},
+	}
+	profile := config.Profiles["staging"]
+
+	askEditProfile(&mockAsker{t: t, responses: []string{
+		// Keys with spaces are edited & renamed.
+		"Edit header X-Old Name",
+		"X-New",
+		"2",
+		"Edit query param page size",
+		"page size",
+		"20",
+		"Add query param",
+		"search",
+		"foo",
+		"Delete query param search",
+		"n",
+		"Delete header Accept",
+		"y",
+		// Renaming to an existing profile fails, then succeeds.
+		"Rename profile",
+		"child",
+		"Rename profile",
+		"stage",
+		// The profile can't be deleted while another profile extends it.
+		"Delete profile",
+		"y",
+		"Finished with profile",
+	}}, config, "staging", profile)
+
+	assert.Equal(t, map[string]string{"X-New": "2"}, profile.Headers)
+	assert.Equal(t, map[string]string{"page size": "20", "search": "foo"}, profile.Query)
+	assert.Nil(t, config.Profiles["staging"])
+	assert.Same(t, profile, config.Profiles["stage"])
+	assert.Equal(t, "stage", config.Profiles["child"].Extends)
+	assert.Equal(t, "stage", config.DefaultProfile)
+
+	// Once nothing extends it, the profile can be deleted.
+	config.Profiles["child"].Extends = ""
+	askEditProfile(&mockAsker{t: t, responses: []string{
+		"Delete profile",
+		"n",
+		"Delete profile",
+		"y",
+	}}, config, "stage", profile)
+
+	assert.Nil(t, config.Profiles["stage"])
+	assert.Equal(t, "", config.DefaultProfile)
+}
+
+func TestProfileMenu(t *testing.T) {
+	items := profileMenu(&APIProfile{
+		Headers: map[string]string{"B": "2", "A": "1"},
+		Query:   map[string]string{"q": "x"},
+	}, &APIProfile{Headers: map[string]string{"a": "0", "C": "3"}})
+
+	labels := []string{}
+	for _, item := range items {
+		labels = append(labels, item.label)
+	}
+
+	assert.Equal(t, []string{
+		"Add header",
+		"Edit header A",
+		"Edit header B",
+		"Delete header A",
+		"Delete header B",
+		"Add query param",
+		"Edit query param q",
+		"Delete query param q",
+		"Override inherited header C",
+		"Set parent profile",
+		"Set server override",
+		"Setup auth",
+		"Rename profile",
+		"Delete profile",
+		"Finished with profile",
+	}, labels)
+
+	assert.Equal(t, profileMenuItem{"Edit query param q", actionEditQuery, "q"}, items[6])
+}
+
+func TestRenameDefaultProfile(t *testing.T) {
+	config := &APIConfig{Profiles: map[string]*APIProfile{"default": {}}}
+
+	// The renamed profile stays selected by default.
+	assert.NoError(t, renameProfile(config, "default", "prod"))
+	assert.Equal(t, "prod", config.DefaultProfile)
+
+	assert.NoError(t, renameProfile(config, "prod", "default"))
+	assert.Equal(t, "", config.DefaultProfile)
+	assert.NotNil(t, config.Profiles["default"])
+}
+
+func TestNormalizeBaseURI(t *testing.T) {
+	assert.Equal(t, "https://api.example.com", normalizeBaseURI(" api.example.com/ "))
+	assert.Equal(t, "https://api.example.com/v1", normalizeBaseURI("https://api.example.com/v1/"))
+	assert.Equal(t, "http://localhost:8000", normalizeBaseURI("localhost:8000"))
+	assert.Equal(t, "http://localhost:8000", normalizeBaseURI(":8000"))
+	assert.Equal(t, "http://api.example.com", normalizeBaseURI("http://api.example.com"))
+}
+
+func TestProbeBaseURI(t *testing.T) {
+	reset(false)
+	defer gock.Off()
+
+	gock.New("http://probe.example.com").Get("/").Persist().Reply(200).JSON(map[string]interface{}{})
+	gock.New("http://probe.example.com").Get("/openapi.json").Reply(200).BodyString("dummy")
+
+	AddLoader(&testLoader{
+		API: API{
+			Operations: []Operation{{Name: "list-items"}, {Name: "get-item"}},
+			Auth:       []APIAuth{{Name: "oauth-client-credentials"}},
+		},
+	})
+	defer func() { loaders = loaders[:len(loaders)-1] }()
+
+	summary, err := probeBaseURI("", &APIConfig{Base: "http://probe.example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, "API description detected at /openapi.json (2 operations, auth: oauth-client-credentials)", summary)
+
+	// Unreachable hosts ask whether to keep the value anyway.
+	gock.New("http://down.example.com").Get("/").ReplyError(errors.New("connection refused"))
+
+	config := &APIConfig{name: "probe-test"}
+	askBaseURI(&mockAsker{t: t, responses: []string{
+		"down.example.com",
+		"n",
+		"http://probe.example.com/",
+	}}, config)
+	assert.Equal(t, "http://probe.example.com", config.Base)
+
+	gock.New("https://down.example.com").Get("/").ReplyError(errors.New("connection refused"))
+	askBaseURI(&mockAsker{t: t, responses: []string{
+		"down.example.com",
+		"y",
+	}}, config)
+	assert.Equal(t, "https://down.example.com", config.Base)
+}
+
+func TestAskBaseURICACert(t *testing.T) {
+	reset(false)
+	gock.Off()
+
+	server, caFile := newPrivateCAServer(t)
+	defer server.Close()
+
+	config := &APIConfig{name: "probe-ca", Profiles: map[string]*APIProfile{}}
+	configs["probe-ca"] = config
+
+	askBaseURI(&mockAsker{t: t, responses: []string{
+		server.URL,
+		"y",
+		caFile,
+	}}, config)
+	assert.Equal(t, server.URL, config.Base)
+	assert.Equal(t, caFile, config.TLS.CACert)
+
+	// TLS can be set up from the edit menu even when nothing is set yet.
+	config.TLS = nil
+	defer removeAPI("probe-ca")
+	askInitAPI(&mockAsker{t: t, responses: []string{
+		"Set up TLS (CA or client certificate)",
+		"Set CA certificate",
+		"~/ca.pem",
+		"Finished with TLS configuration",
+		"Save and exit",
+	}}, &cobra.Command{}, []string{"probe-ca"})
+	assert.Equal(t, "~/ca.pem", config.TLS.CACert)
+}
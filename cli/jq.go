@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/itchyny/gojq"
+)
+
+// searchJQ runs a jq program against the data. A program which emits a single
+// value returns it directly, while programs emitting no or multiple values
+// return a list of them, e.g. `.body.items[].id`.
+func searchJQ(expr string, data interface{}) (interface{}, error) {
+	query, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("jq expression %q: %w", expr, err)
+	}
+
+	code, err := gojq.Compile(query)
+	if err != nil {
+		return nil, fmt.Errorf("jq expression %q: %w", expr, err)
+	}
+
+	results := []interface{}{}
+	iter := code.Run(data)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := v.(error); ok {
+			return nil, fmt.Errorf("jq expression %q: %w", expr, err)
+		}
+
+		results = append(results, v)
+	}
+
+	if len(results) == 1 {
+		return results[0], nil
+	}
+
+	return results, nil
+}
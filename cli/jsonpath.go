@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"github.com/ohler55/ojg/jp"
+)
+
+// isDefinite returns whether a JSONPath expression can match at most one
+// value, i.e. it only contains names and indexes.
+func isDefinite(x jp.Expr) bool {
+	for _, frag := range x {
+		switch frag.(type) {
+		case jp.Root, jp.At, jp.Child, jp.Nth:
+			// These select at most one value.
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// searchJSONPath runs a JSONPath query against the data. Unlike JMESPath,
+// JSONPath always produces a list of matches, so definite paths like
+// `$.body.id` are unwrapped to return the single value or nil if nothing
+// matched, while any other query returns the list of matches.
+func searchJSONPath(expr string, data interface{}) (interface{}, error) {
+	x, err := jp.ParseString(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	results := x.Get(data)
+
+	if isDefinite(x) {
+		if len(results) == 0 {
+			return nil, nil
+		}
+		return results[0], nil
+	}
+
+	if results == nil {
+		results = []interface{}{}
+	}
+
+	return results, nil
+}
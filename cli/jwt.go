@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// jwtParams are the JWT auth parameters which are not extra claims.
+var jwtParams = map[string]bool{
+	"algorithm": true,
+	"key":       true,
+	"key_file":  true,
+	"issuer":    true,
+	"subject":   true,
+	"audience":  true,
+	"ttl":       true,
+}
+
+// JWTAuth signs a new JWT for each request and sends it as a bearer token,
+// which is useful for service-to-service auth where callers mint their own
+// tokens.
+type JWTAuth struct{}
+
+// Parameters define the JWT auth parameter names.
+func (a *JWTAuth) Parameters() []AuthParam {
+	return []AuthParam{
+		{Name: "algorithm", Required: true, Help: "Signing algorithm, one of HS256, RS256, or ES256"},
+		{Name: "key", Help: "Shared secret for HS256 or PEM-encoded private key"},
+		{Name: "key_file", Help: "Path to a file containing the key instead of setting it directly"},
+		{Name: "issuer", Help: "Optional `iss` claim"},
+		{Name: "subject", Help: "Optional `sub` claim"},
+		{Name: "audience", Help: "Optional `aud` claim"},
+		{Name: "ttl", Help: "How long tokens are valid, defaults to 5m"},
+	}
+}
+
+// OnRequest gets run before the request goes out on the wire.
+func (a *JWTAuth) OnRequest(req *http.Request, key string, params map[string]string) error {
+	token, err := signJWT(params, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// jwtKey returns the configured key, loading it from a file if needed.
+func jwtKey(params map[string]string) ([]byte, error) {
+	if params["key"] != "" {
+		return []byte(params["key"]), nil
+	}
+
+	if params["key_file"] != "" {
+		return ioutil.ReadFile(params["key_file"])
+	}
+
+	return nil, errors.New("JWT auth requires a key or key_file")
+}
+
+// jwtClaims returns the standard and extra claims for a token issued at
+// `now`. Extra claim values which are valid JSON, like numbers or lists, are
+// sent as-is while anything else is sent as a string.
+func jwtClaims(params map[string]string, now time.Time) (map[string]interface{}, error) {
+	ttl := 5 * time.Minute
+	if params["ttl"] != "" {
+		d, err := time.ParseDuration(params["ttl"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT ttl %s: %w", params["ttl"], err)
+		}
+		ttl = d
+	}
+
+	claims := map[string]interface{}{}
+	for k, v := range params {
+		if jwtParams[k] {
+			continue
+		}
+
+		var value interface{}
+		if err := json.Unmarshal([]byte(v), &value); err != nil {
+			value = v
+		}
+		claims[k] = value
+	}
+
+	for param, claim := range map[string]string{"issuer": "iss", "subject": "sub", "audience": "aud"} {
+		if params[param] != "" {
+			claims[claim] = params[param]
+		}
+	}
+
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+
+	return claims, nil
+}
+
+// parsePrivateKey decodes a PEM-encoded PKCS #1, PKCS #8, or EC private key.
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("JWT key is not PEM-encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse JWT private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported JWT private key type %T", key)
+	}
+
+	return signer, nil
+}
+
+// signJWT creates a compact serialized JWT issued at `now`.
+func signJWT(params map[string]string, now time.Time) (string, error) {
+	alg := strings.ToUpper(params["algorithm"])
+
+	key, err := jwtKey(params)
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := jwtClaims(params, now)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case "RS256":
+		signer, err := parsePrivateKey(key)
+		if err != nil {
+			return "", err
+		}
+
+		rsaKey, ok := signer.(*rsa.PrivateKey)
+		if !ok {
+			return "", errors.New("RS256 requires an RSA private key")
+		}
+
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:]); err != nil {
+			return "", err
+		}
+	case "ES256":
+		signer, err := parsePrivateKey(key)
+		if err != nil {
+			return "", err
+		}
+
+		ecKey, ok := signer.(*ecdsa.PrivateKey)
+		if !ok || ecKey.Curve.Params().BitSize != 256 {
+			return "", errors.New("ES256 requires a P-256 EC private key")
+		}
+
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		if err != nil {
+			return "", err
+		}
+
+		// JWS uses the fixed-size concatenation of r and s rather than ASN.1.
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	default:
+		return "", fmt.Errorf("unsupported JWT algorithm %s, expected HS256, RS256, or ES256", params["algorithm"])
+	}
+
+	return signingInput + "." + enc.EncodeToString(signature), nil
+}
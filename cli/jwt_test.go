@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeJWT(t *testing.T, token string) (string, []byte, map[string]interface{}) {
+	parts := strings.Split(token, ".")
+	assert.Len(t, parts, 3)
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	assert.NoError(t, err)
+
+	claims := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(payload, &claims))
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.NoError(t, err)
+
+	return parts[0] + "." + parts[1], signature, claims
+}
+
+func TestJWTHS256(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	token, err := signJWT(map[string]string{
+		"algorithm": "HS256",
+		"key":       "secret",
+		"issuer":    "restish",
+		"subject":   "svc",
+		"audience":  "api",
+		"ttl":       "1m",
+		"scope":     "read",
+		"level":     "3",
+	}, now)
+	assert.NoError(t, err)
+
+	input, signature, claims := decodeJWT(t, token)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(input))
+	assert.Equal(t, mac.Sum(nil), signature)
+
+	assert.Equal(t, map[string]interface{}{
+		"iss":   "restish",
+		"sub":   "svc",
+		"aud":   "api",
+		"scope": "read",
+		"level": 3.0,
+		"iat":   1600000000.0,
+		"exp":   1600000060.0,
+	}, claims)
+}
+
+func TestJWTRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	filename := path.Join(t.TempDir(), "key.pem")
+	assert.NoError(t, os.WriteFile(filename, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}), 0600))
+
+	token, err := signJWT(map[string]string{"algorithm": "RS256", "key_file": filename}, time.Now())
+	assert.NoError(t, err)
+
+	input, signature, _ := decodeJWT(t, token)
+	digest := sha256.Sum256([]byte(input))
+	assert.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+
+	_, err = signJWT(map[string]string{"algorithm": "ES256", "key_file": filename}, time.Now())
+	assert.Error(t, err)
+}
+
+func TestJWTES256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	token, err := signJWT(map[string]string{
+		"algorithm": "ES256",
+		"key":       string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	}, time.Now())
+	assert.NoError(t, err)
+
+	input, signature, _ := decodeJWT(t, token)
+	assert.Len(t, signature, 64)
+	digest := sha256.Sum256([]byte(input))
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	assert.True(t, ecdsa.Verify(&key.PublicKey, digest[:], r, s))
+}
+
+func TestJWTAuth(t *testing.T) {
+	auth := &JWTAuth{}
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+
+	params := map[string]string{"algorithm": "HS256", "key": "secret"}
+	assert.NoError(t, auth.OnRequest(req, "jwt-test", params))
+	assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"), "Bearer "))
+
+	_, _, claims := decodeJWT(t, strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+	assert.Equal(t, 300.0, claims["exp"].(float64)-claims["iat"].(float64))
+
+	assert.Error(t, auth.OnRequest(req, "jwt-test", map[string]string{"algorithm": "HS256"}))
+	assert.Error(t, auth.OnRequest(req, "jwt-test", map[string]string{"algorithm": "none", "key": "secret"}))
+}
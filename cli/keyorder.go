@@ -0,0 +1,263 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// keyOrder holds the original order of the keys of each object in a decoded
+// document. Objects are matched by their set of keys rather than by identity
+// so that the order survives copying and filtering. If multiple objects in
+// the same document have the same keys, the first order wins. It is built per
+// response and passed along with it rather than shared.
+type keyOrder map[string][]string
+
+// keySignature returns a lookup key for a sorted list of object keys.
+func keySignature(sorted []string) string {
+	return strings.Join(sorted, "\x00")
+}
+
+// decodeOrdered decodes a JSON document token by token, returning objects as
+// `orderedMap` values which keep the order of their keys.
+func decodeOrdered(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	value, err := decodeOrderedValue(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid JSON: unexpected data after top-level value")
+	}
+
+	return value, nil
+}
+
+func decodeOrderedValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		m := orderedMap{values: map[string]interface{}{}}
+		for dec.More() {
+			kt, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			key, _ := kt.(string)
+			value, err := decodeOrderedValue(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := m.values[key]; !ok {
+				m.keys = append(m.keys, key)
+			}
+			m.values[key] = value
+		}
+
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+
+		return m, nil
+	case json.Delim('['):
+		items := []interface{}{}
+		for dec.More() {
+			item, err := decodeOrderedValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+
+		return items, nil
+	}
+
+	return tok, nil
+}
+
+// add records the key order of each object in an ordered document.
+func (o keyOrder) add(data interface{}) {
+	switch v := data.(type) {
+	case orderedMap:
+		sorted := append([]string{}, v.keys...)
+		sort.Strings(sorted)
+		if signature := keySignature(sorted); o[signature] == nil {
+			o[signature] = v.keys
+		}
+
+		for _, k := range v.keys {
+			o.add(v.values[k])
+		}
+	case []interface{}:
+		for _, item := range v {
+			o.add(item)
+		}
+	}
+}
+
+// merge adds the key orders of another document, e.g. the next page of a
+// paginated response, keeping existing orders.
+func (o keyOrder) merge(other keyOrder) {
+	for signature, keys := range other {
+		if o[signature] == nil {
+			o[signature] = keys
+		}
+	}
+}
+
+// jsonKeyOrder returns the key order of a JSON document, or nil if keys are
+// sorted in output anyway or the document can't be decoded.
+func jsonKeyOrder(ct string, data []byte) keyOrder {
+	if sortKeys() || !(JSON{}).Detect(ct) {
+		return nil
+	}
+
+	ordered, err := decodeOrdered(data)
+	if err != nil {
+		return nil
+	}
+
+	order := keyOrder{}
+	order.add(ordered)
+	return order
+}
+
+// sortKeys returns whether object keys should be sorted in output, which is
+// the default.
+func sortKeys() bool {
+	return !viper.IsSet("rsh-sort-keys") || viper.GetBool("rsh-sort-keys")
+}
+
+// orderedKeys returns object keys in the order they should be output, which is
+// sorted unless `rsh-sort-keys` is disabled and the order of an object with
+// the same keys is known.
+func orderedKeys(keys []string, order keyOrder) []string {
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+
+	if sortKeys() {
+		return sorted
+	}
+
+	if keys, ok := order[keySignature(sorted)]; ok {
+		return append([]string{}, keys...)
+	}
+
+	return sorted
+}
+
+// orderedMap is an object which is encoded as JSON with its keys in order.
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// MarshalJSON encodes the object with its keys in order.
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+
+	out := []byte{'{'}
+	for i, k := range m.keys {
+		if i > 0 {
+			out = append(out, ',')
+		}
+
+		for _, v := range []interface{}{k, m.values[k]} {
+			buf.Reset()
+			if err := enc.Encode(v); err != nil {
+				return nil, err
+			}
+			out = append(out, bytes.TrimRight(buf.Bytes(), "\n")...)
+
+			if v == k {
+				out = append(out, ':')
+			}
+		}
+	}
+
+	return append(out, '}'), nil
+}
+
+// withKeyOrder prepares JSON-safe data for JSON encoding, preserving the
+// original key order of objects when `rsh-sort-keys` is disabled.
+func withKeyOrder(data interface{}, order keyOrder) interface{} {
+	if sortKeys() {
+		return data
+	}
+
+	switch v := data.(type) {
+	case map[string]interface{}:
+		keys := []string{}
+		values := map[string]interface{}{}
+		for k, item := range v {
+			keys = append(keys, k)
+			values[k] = withKeyOrder(item, order)
+		}
+		return orderedMap{keys: orderedKeys(keys, order), values: values}
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = withKeyOrder(item, order)
+		}
+		return items
+	}
+
+	return data
+}
+
+// marshalOrderedYAML encodes JSON-safe data as YAML, keeping the original key
+// order of objects.
+func marshalOrderedYAML(data interface{}, order keyOrder) ([]byte, error) {
+	return yaml.Marshal(yamlWithKeyOrder(data, order))
+}
+
+// yamlWithKeyOrder converts JSON-safe data to use YAML map slices, which keep
+// the original key order of objects. Binary data is base64 encoded like it is
+// for JSON.
+func yamlWithKeyOrder(data interface{}, order keyOrder) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		keys := []string{}
+		for k := range v {
+			keys = append(keys, k)
+		}
+
+		items := yaml.MapSlice{}
+		for _, k := range orderedKeys(keys, order) {
+			items = append(items, yaml.MapItem{Key: k, Value: yamlWithKeyOrder(v[k], order)})
+		}
+		return items
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = yamlWithKeyOrder(item, order)
+		}
+		return items
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	}
+
+	return data
+}
@@ -0,0 +1,369 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/alexeyco/simpletable"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// lastResponse is the metadata of the most recent response, saved so that it
+// can be inspected by later commands. `Request` describes the request which
+// was made, so that it can be saved and run again. `Body` is the original
+// response body so it can be formatted again, or the merged body as JSON for
+// auto-paginated responses, in which case `ContentType` is set.
+type lastResponse struct {
+	URL         string            `json:"url"`
+	Proto       string            `json:"proto"`
+	Status      int               `json:"status"`
+	Headers     map[string]string `json:"headers"`
+	Request     *savedRequest     `json:"request,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+}
+
+func lastResponseFile() string {
+	return path.Join(cacheDir(), "last-response.json")
+}
+
+// lastResponseKey is used as additional data when encrypting the last
+// response, so that other encrypted data can't be swapped in.
+const lastResponseKey = "last-response"
+
+// lastResponseCipher returns the cipher used to encrypt the last response at
+// rest when `rsh-cache-encrypt` is set, using the same key as cached
+// responses.
+func lastResponseCipher() (*encryptedCache, error) {
+	key, err := responseCacheKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return newEncryptedCache(nil, key)
+}
+
+// maskParam redacts the value of a `name<sep>value` header or query param if
+// its name suggests it holds a secret.
+func maskParam(param, sep string) string {
+	name, value, ok := strings.Cut(param, sep)
+	if !ok {
+		return param
+	}
+
+	trimmed := strings.TrimSpace(value)
+	if masked := maskValue(strings.TrimSpace(name), trimmed); masked != trimmed {
+		return name + sep + strings.TrimSuffix(value, strings.TrimLeft(value, " \t")) + masked
+	}
+
+	return param
+}
+
+// saveLastResponse stores the metadata of a response made on behalf of the
+// user along with the request which was made. Secrets in the URLs and request
+// headers are redacted, and everything is encrypted if `rsh-cache-encrypt` is
+// set. Failures are only logged since they should not prevent output.
+func saveLastResponse(u *url.URL, resp Response, request *savedRequest) {
+	last := lastResponse{
+		URL:     maskURL(u),
+		Proto:   resp.Proto,
+		Status:  resp.Status,
+		Headers: resp.Headers,
+		Body:    resp.raw,
+	}
+
+	if request != nil {
+		masked := request.masked()
+		last.Request = &masked
+	}
+
+	if resp.raw == nil && resp.Body != nil {
+		// Merged pages have no original body, so save the merged one instead.
+		body, err := json.Marshal(makeJSONSafe(resp.Body, false))
+		if err != nil {
+			LogWarning("Unable to save last response: %v", err)
+			return
+		}
+		last.ContentType = "application/json"
+		last.Body = body
+	}
+
+	b, err := json.Marshal(last)
+	if err != nil {
+		LogWarning("Unable to save last response: %v", err)
+		return
+	}
+
+	if viper.GetBool("rsh-cache-encrypt") {
+		c, err := lastResponseCipher()
+		if err == nil {
+			b, err = c.seal(lastResponseKey, b)
+		}
+		if err != nil {
+			LogWarning("Unable to encrypt last response: %v", err)
+			return
+		}
+	}
+
+	if err := writeFileAtomic(lastResponseFile(), b, 0600); err != nil {
+		LogWarning("Unable to save last response: %v", err)
+	}
+}
+
+// loadLastResponse loads the metadata of the most recent response.
+func loadLastResponse() (*lastResponse, error) {
+	b, err := ioutil.ReadFile(lastResponseFile())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, errors.New("no response has been saved yet, make a request first")
+		}
+		return nil, err
+	}
+
+	if viper.GetBool("rsh-cache-encrypt") {
+		c, err := lastResponseCipher()
+		if err != nil {
+			return nil, err
+		}
+
+		// Responses saved before encryption was enabled are read as-is.
+		if opened, ok := c.open(lastResponseKey, b); ok {
+			b = opened
+		}
+	}
+
+	last := &lastResponse{}
+	if err := json.Unmarshal(b, last); err != nil {
+		return nil, fmt.Errorf("unable to read the last response, it may have been encrypted via rsh-cache-encrypt: %w", err)
+	}
+
+	return last, nil
+}
+
+// headerTable renders headers as a table sorted by name.
+func headerTable(headers map[string]string) string {
+	names := []string{}
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	table := simpletable.New()
+	table.Header = &simpletable.Header{
+		Cells: []*simpletable.Cell{
+			{Align: simpletable.AlignCenter, Text: "Name"},
+			{Align: simpletable.AlignCenter, Text: "Value"},
+		},
+	}
+
+	for _, name := range names {
+		table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+			{Text: name},
+			{Text: headers[name]},
+		})
+	}
+
+	table.SetStyle(simpletable.StyleCompactLite)
+	return table.String() + "\n"
+}
+
+// matchHeaders returns the headers whose names match a case-insensitive glob
+// pattern like `x-rate-*`. An empty pattern matches all headers.
+func matchHeaders(headers map[string]string, pattern string) (map[string]string, error) {
+	matched := map[string]string{}
+	for name, value := range headers {
+		if pattern != "" {
+			ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		matched[name] = value
+	}
+
+	return matched, nil
+}
+
+func headersCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "headers [pattern]",
+		Short: "Show headers from the last response",
+		Long:  "Show the headers of the most recent response as a table sorted by name. An optional glob pattern only shows headers with matching names, ignoring case. Use `-o json` to print the headers as a JSON object instead.",
+		Example: fmt.Sprintf(`  # Show all headers
+  $ %s headers
+
+  # Show rate limit headers
+  $ %s headers 'x-ratelimit-*'`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			last, err := loadLastResponse()
+			if err != nil {
+				panic(err)
+			}
+
+			pattern := ""
+			if len(args) > 0 {
+				pattern = args[0]
+			}
+
+			headers, err := matchHeaders(last.Headers, pattern)
+			if err != nil {
+				panic(err)
+			}
+
+			if viper.GetString("rsh-output-format") == "json" {
+				b, err := json.MarshalIndent(headers, "", "  ")
+				if err != nil {
+					panic(err)
+				}
+				fmt.Fprintln(Stdout, string(b))
+				return
+			}
+
+			if len(headers) == 0 {
+				LogWarning("No headers match %s in response from %s", pattern, last.URL)
+				return
+			}
+
+			fmt.Fprint(Stdout, headerTable(headers))
+		},
+	}
+}
+
+// formatLastResponse decodes the body of the last response again and formats
+// it using the current output options.
+func formatLastResponse(last *lastResponse) error {
+	u, err := url.Parse(last.URL)
+	if err != nil {
+		return err
+	}
+
+	applyOutputDefaults(last.URL)
+
+	ct := last.Headers["Content-Type"]
+	if last.ContentType != "" {
+		ct = last.ContentType
+	}
+
+	ct, forced := responseContentType(ct, viper.GetString("rsh-response-type"))
+	body, err := decodeBody(u, ct, forced, last.Body)
+	if err != nil {
+		return err
+	}
+
+	return Formatter.Format(Response{
+		Proto:    last.Proto,
+		Status:   last.Status,
+		Headers:  last.Headers,
+		Links:    Links{},
+		Body:     body,
+		raw:      last.Body,
+		keyOrder: jsonKeyOrder(ct, last.Body),
+	})
+}
+
+func lastBodyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "last",
+		Short: "Format the last response again",
+		Long:  "Format the most recent response again using the current output options like `-o` and `-f`, without making a new request. The original response body is saved after each request in the cache directory.",
+		Example: fmt.Sprintf(`  # Show the last response as JSON
+  $ %s body last -o json
+
+  # Filter the last response
+  $ %s body last -f body.items`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			last, err := loadLastResponse()
+			if err != nil {
+				panic(err)
+			}
+
+			if err := formatLastResponse(last); err != nil {
+				panic(err)
+			}
+		},
+	}
+}
+
+func statusCommand() *cobra.Command {
+	var withText *bool
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Print the status code of the last response",
+		Long:  "Print the HTTP status code of the most recent response, which is useful for scripting. Use `--rsh-status-text` to include the status text, e.g. `200 OK`.",
+		Example: fmt.Sprintf(`  # Check whether the last request succeeded
+  $ if [ $(%s status) -ne 200 ]; then echo "Request failed"; fi`, Root.CommandPath()),
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			last, err := loadLastResponse()
+			if err != nil {
+				panic(err)
+			}
+
+			if *withText {
+				fmt.Fprintf(Stdout, "%d %s\n", last.Status, http.StatusText(last.Status))
+				return
+			}
+
+			fmt.Fprintln(Stdout, last.Status)
+		},
+	}
+
+	withText = cmd.Flags().Bool("rsh-status-text", false, "Include the status text, e.g. 200 OK")
+
+	return cmd
+}
+
+func responseCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "response",
+		Short: "Inspect the last response",
+	}
+
+	headers := &cobra.Command{
+		Use:   "headers [--last] [header-name]",
+		Short: "Print headers from the last response",
+		Long:  "Print the headers of the most recent response as a table sorted by name. If a header name is given then only its value is printed, which is useful for scripting.",
+		Example: fmt.Sprintf(`  # Show all headers
+  $ %s response headers
+
+  # Use the location of a newly created resource
+  $ %s get $(%s response headers Location)`, Root.CommandPath(), Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			last, err := loadLastResponse()
+			if err != nil {
+				panic(err)
+			}
+
+			if len(args) == 0 {
+				fmt.Fprint(Stdout, headerTable(last.Headers))
+				return
+			}
+
+			value, ok := last.Headers[http.CanonicalHeaderKey(args[0])]
+			if !ok {
+				panic(fmt.Errorf("header %s not found in response from %s", args[0], last.URL))
+			}
+			fmt.Fprintln(Stdout, value)
+		},
+	}
+	headers.Flags().Bool("last", true, "Use the most recent response, which is currently the only one saved")
+	cmd.AddCommand(headers)
+
+	return cmd
+}
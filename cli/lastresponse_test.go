@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestResponseHeaders(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Post("/items").Reply(201).SetHeader("Location", "/items/1").SetHeader("X-Request-Id", "abc")
+
+	run("post http://example.com/items")
+
+	assert.Equal(t, "/items/1\n", runNoReset("response headers location"))
+
+	out := runNoReset("response headers")
+	assert.Contains(t, out, "X-Request-Id")
+	assert.Less(t, strings.Index(out, "Location"), strings.Index(out, "X-Request-Id"))
+
+	assert.Contains(t, runNoReset("response headers missing"), "header missing not found")
+}
+
+func TestHeadersCommand(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/items").Reply(200).SetHeader("X-RateLimit-Limit", "10").SetHeader("X-RateLimit-Remaining", "9").SetHeader("X-Request-Id", "abc")
+
+	run("get http://example.com/items")
+
+	out := runNoReset("headers")
+	assert.Contains(t, out, "X-Request-Id")
+	assert.Less(t, strings.Index(out, "X-Ratelimit-Limit"), strings.Index(out, "X-Request-Id"))
+
+	out = runNoReset("headers x-ratelimit-*")
+	assert.Contains(t, out, "X-Ratelimit-Remaining")
+	assert.NotContains(t, out, "X-Request-Id")
+
+	out = runNoReset("headers x-ratelimit-* -o json")
+	assert.JSONEq(t, `{"X-Ratelimit-Limit": "10", "X-Ratelimit-Remaining": "9"}`, out)
+
+	assert.Contains(t, runNoReset("headers ["), "invalid pattern")
+}
+
+func TestStatusCommand(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/missing").Reply(404)
+
+	run("get http://example.com/missing")
+
+	assert.Equal(t, "404\n", runNoReset("status"))
+	assert.Equal(t, "404 Not Found\n", runNoReset("status --rsh-status-text"))
+}
+
+func TestBodyLast(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/items").Reply(200).JSON([]interface{}{
+		map[string]interface{}{"id": 1},
+		map[string]interface{}{"id": 2},
+	})
+
+	run("get http://example.com/items")
+
+	assert.JSONEq(t, `[{"id": 1}, {"id": 2}]`, run("body last -o json -f body"))
+	assert.Contains(t, run("body last -o json"), `"status": 200`)
+}
+
+func TestLastResponseMasksSecrets(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/items").Reply(200).JSON(map[string]interface{}{"id": 1})
+
+	run("get http://example.com/items?api_key=abc123 -H Authorization:Bearer-secret -H X-Debug:1 --rsh-header=X-Api-Key:def456 -q token=ghi789")
+
+	b, err := os.ReadFile(lastResponseFile())
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "abc123")
+	assert.NotContains(t, string(b), "Bearer-secret")
+	assert.NotContains(t, string(b), "def456")
+	assert.NotContains(t, string(b), "ghi789")
+
+	last, err := loadLastResponse()
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/items?api_key=REDACTED&token=REDACTED", last.URL)
+	if assert.NotNil(t, last.Request) {
+		assert.Equal(t, "http://example.com/items?api_key=REDACTED&token=REDACTED", last.Request.URL)
+		assert.Equal(t, []string{"Authorization:REDACTED", "X-Debug:1", "X-Api-Key:REDACTED"}, last.Request.Headers)
+	}
+}
+
+func TestLastResponseEncrypted(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/items").Reply(200).SetHeader("X-Request-Id", "abc").JSON(map[string]interface{}{"secret": "value"})
+
+	reset(false)
+	viper.Set("rsh-cache-encrypt", true)
+	runNoReset("get http://example.com/items")
+
+	b, err := os.ReadFile(lastResponseFile())
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "value")
+	assert.NotContains(t, string(b), "X-Request-Id")
+
+	assert.Equal(t, "abc\n", runNoReset("response headers --last X-Request-Id"))
+	viper.Set("rsh-cache-encrypt", false)
+}
+
+func TestLastResponseUserRequestsOnly(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/items").Reply(200).SetHeader("X-Request-Id", "user")
+	gock.New("http://example.com").Get("/other").Reply(200).SetHeader("X-Request-Id", "internal")
+
+	run("get http://example.com/items")
+
+	// Internal requests like fetching links don't replace the last response.
+	runNoReset("links http://example.com/other")
+	assert.Equal(t, "user\n", runNoReset("response headers X-Request-Id"))
+}
+
+func TestBodyLastPaginated(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/items").Reply(200).SetHeader("Link", `</items?page=2>; rel="next"`).JSON([]interface{}{
+		map[string]interface{}{"id": 1},
+	})
+	gock.New("http://example.com").Get("/items").MatchParam("page", "2").Reply(200).JSON([]interface{}{
+		map[string]interface{}{"id": 2},
+	})
+
+	run("get http://example.com/items")
+
+	// All pages are saved, not just the first one.
+	assert.JSONEq(t, `[{"id": 1}, {"id": 2}]`, runNoReset("body last -o json -f body"))
+}
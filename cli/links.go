@@ -2,9 +2,11 @@ package cli
 
 import (
 	"fmt"
+	"math/big"
 	"net/url"
 	"reflect"
 
+	jmespath "github.com/danielgtaylor/go-jmespath-plus"
 	"github.com/mitchellh/mapstructure"
 	link "github.com/tent/http-link-go"
 )
@@ -13,6 +15,11 @@ import (
 type Link struct {
 	Rel string `json:"rel"`
 	URI string `json:"uri"`
+
+	// IsTemplate is set for RFC 6570 URI templates which could not be expanded
+	// because none of their variables were found in the response body. The URI
+	// is then left as-is rather than resolved.
+	IsTemplate bool `json:"template,omitempty"`
 }
 
 // Links represents a map of `rel` => list of linke relations.
@@ -31,7 +38,25 @@ func AddLinkParser(parser LinkParser) {
 	linkParsers = append(linkParsers, parser)
 }
 
+// expandLinkTemplate expands a URI template link using variables from the
+// response body, where each variable name is a JMESPath expression.
+func expandLinkTemplate(l *Link, body interface{}) {
+	body = makeJSONSafe(body, false)
+
+	uri, ok := expandURITemplate(l.URI, func(name string) interface{} {
+		value, err := jmespath.Search(name, body)
+		if err != nil {
+			return nil
+		}
+		return value
+	})
+
+	l.URI = uri
+	l.IsTemplate = !ok
+}
+
 // ParseLinks uses all registered LinkParsers to parse links for a response.
+// URI templates are expanded using the response body when possible.
 func ParseLinks(base *url.URL, resp *Response) error {
 	for _, parser := range linkParsers {
 		if err := parser.ParseLinks(resp); err != nil {
@@ -41,6 +66,13 @@ func ParseLinks(base *url.URL, resp *Response) error {
 
 	for _, links := range resp.Links {
 		for _, l := range links {
+			if isURITemplate(l.URI) {
+				expandLinkTemplate(l, resp.Body)
+				if l.IsTemplate {
+					continue
+				}
+			}
+
 			p, err := url.Parse(l.URI)
 			if err != nil {
 				return err
@@ -83,12 +115,42 @@ type halLink struct {
 
 // halBody represents the top-level HAL response body.
 type halBody struct {
-	Links map[string]halLink `mapstructure:"_links"`
+	Links    map[string]halLink     `mapstructure:"_links"`
+	Embedded map[string]interface{} `mapstructure:"_embedded"`
 }
 
-// HALParser parses HAL hypermedia links. Ignores curies.
+// HALParser parses HAL hypermedia links. Ignores curies. The `self` links of
+// embedded resources are added with an `embedded-` prefix on their relation,
+// e.g. `embedded-orders`.
 type HALParser struct{}
 
+// walkEmbedded recursively adds the `self` links of embedded resources, which
+// may be a single resource or a list of resources for each relation.
+func (h HALParser) walkEmbedded(resp *Response, embedded map[string]interface{}) {
+	for rel, value := range embedded {
+		resources, ok := value.([]interface{})
+		if !ok {
+			resources = []interface{}{value}
+		}
+
+		for _, resource := range resources {
+			hal := halBody{}
+			if err := mapstructure.Decode(resource, &hal); err != nil {
+				continue
+			}
+
+			if self := hal.Links["self"]; self.Href != "" {
+				resp.Links["embedded-"+rel] = append(resp.Links["embedded-"+rel], &Link{
+					Rel: "embedded-" + rel,
+					URI: self.Href,
+				})
+			}
+
+			h.walkEmbedded(resp, hal.Embedded)
+		}
+	}
+}
+
 // ParseLinks processes the links in a parsed response.
 func (h HALParser) ParseLinks(resp *Response) error {
 	entries := []interface{}{}
@@ -96,6 +158,12 @@ func (h HALParser) ParseLinks(resp *Response) error {
 		entries = l
 	} else {
 		entries = append(entries, resp.Body)
+
+		if b, ok := resp.Body.(map[string]interface{}); ok {
+			if embedded, ok := b["_embedded"].(map[string]interface{}); ok {
+				resp.Embedded = embedded
+			}
+		}
 	}
 
 	for _, entry := range entries {
@@ -112,12 +180,75 @@ func (h HALParser) ParseLinks(resp *Response) error {
 					URI: link.Href,
 				})
 			}
+
+			h.walkEmbedded(resp, hal.Embedded)
 		}
 	}
 
 	return nil
 }
 
+// embeddedComplete returns whether a HAL response already embeds every item
+// of a collection, based on a `total` or Spring-style `page.totalElements`
+// count in the body, so there is no need to fetch further pages.
+func embeddedComplete(resp Response) bool {
+	b, ok := resp.Body.(map[string]interface{})
+	if !ok || len(resp.Embedded) == 0 {
+		return false
+	}
+
+	value, ok := b["total"]
+	if !ok {
+		if page, isMap := b["page"].(map[string]interface{}); isMap {
+			value, ok = page["totalElements"]
+		}
+	}
+
+	if !ok {
+		return false
+	}
+
+	total, _, err := numberValue(value)
+	if err != nil {
+		return false
+	}
+
+	count := 0
+	for _, value := range resp.Embedded {
+		if l, ok := value.([]interface{}); ok {
+			count += len(l)
+		}
+	}
+
+	return big.NewRat(int64(count), 1).Cmp(total) >= 0
+}
+
+// mergeEmbedded appends the embedded resource lists from the next page of a
+// HAL collection to those of the response, returning whether anything could
+// be merged.
+func mergeEmbedded(resp *Response, next Response) bool {
+	if _, ok := resp.Body.(map[string]interface{}); !ok || len(resp.Embedded) == 0 {
+		return false
+	}
+
+	merged := false
+	for rel, value := range next.Embedded {
+		items, ok := value.([]interface{})
+		if !ok {
+			continue
+		}
+
+		existing, _ := resp.Embedded[rel].([]interface{})
+		resp.Embedded[rel] = append(existing, items...)
+		merged = true
+	}
+
+	// The embedded resources are part of the body when printing.
+	resp.Body.(map[string]interface{})["_embedded"] = resp.Embedded
+
+	return merged
+}
+
 // TerrificallySimpleJSONParser parses `self` links from JSON-like formats.
 type TerrificallySimpleJSONParser struct{}
 
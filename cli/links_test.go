@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"testing"
@@ -184,3 +185,119 @@ func TestJSONAPIParser(t *testing.T) {
 	assert.Equal(t, r.Links["self"][0].URI, "/self")
 	assert.Equal(t, r.Links["item"][0].URI, "/item")
 }
+
+func TestExpandURITemplate(t *testing.T) {
+	vars := map[string]interface{}{
+		"cursor": "abc 123",
+		"limit":  float64(10),
+		"path":   "foo/bar",
+		"ids":    []interface{}{"a", "b"},
+		"keys":   map[string]interface{}{"x": float64(1), "y": "two"},
+		"empty":  "",
+		"id":     json.Number("12345678901234567890"),
+	}
+	lookup := func(name string) interface{} {
+		return vars[name]
+	}
+
+	for _, item := range []struct {
+		template string
+		expected string
+	}{
+		{"/items/{cursor}", "/items/abc%20123"},
+		{"/items{?cursor,limit,missing}", "/items?cursor=abc%20123&limit=10"},
+		{"/items?a=1{&limit}", "/items?a=1&limit=10"},
+		{"/files{/path}", "/files/foo%2Fbar"},
+		{"/files/{+path}", "/files/foo/bar"},
+		{"/docs{#path}", "/docs#foo/bar"},
+		{"/items{?ids}", "/items?ids=a,b"},
+		{"/items{?ids*}", "/items?ids=a&ids=b"},
+		{"/items{/ids*}", "/items/a/b"},
+		{"/items{?keys*}", "/items?x=1&y=two"},
+		{"/items{;keys}", "/items;keys=x,1,y,two"},
+		{"/items{?empty}", "/items?empty="},
+		{"/items{;empty}", "/items;empty"},
+		{"/items/{cursor:3}", "/items/abc"},
+		{"/items{.limit}", "/items.10"},
+		{"/items/{id}", "/items/12345678901234567890"},
+	} {
+		t.Run(item.template, func(t *testing.T) {
+			expanded, ok := expandURITemplate(item.template, lookup)
+			assert.True(t, ok)
+			assert.Equal(t, item.expected, expanded)
+		})
+	}
+
+	expanded, ok := expandURITemplate("/items{?missing}", lookup)
+	assert.False(t, ok)
+	assert.Equal(t, "/items{?missing}", expanded)
+}
+
+func TestLinkHeaderTemplate(t *testing.T) {
+	r := &Response{
+		Links: Links{},
+		Headers: map[string]string{
+			"Link": `</items{?cursor}>; rel="next", </items/{id}>; rel="item"`,
+		},
+		Body: map[string]interface{}{
+			"cursor": "abc123",
+		},
+	}
+
+	assert.NoError(t, LinkHeaderParser{}.ParseLinks(r))
+
+	for _, links := range r.Links {
+		for _, l := range links {
+			expandLinkTemplate(l, r.Body)
+		}
+	}
+
+	assert.Equal(t, "/items?cursor=abc123", r.Links["next"][0].URI)
+	assert.False(t, r.Links["next"][0].IsTemplate)
+	assert.Equal(t, "/items/{id}", r.Links["item"][0].URI)
+	assert.True(t, r.Links["item"][0].IsTemplate)
+}
+
+func TestHALParserEmbedded(t *testing.T) {
+	orders := []interface{}{
+		map[string]interface{}{
+			"_links": map[string]interface{}{
+				"self": map[string]interface{}{"href": "/orders/1"},
+			},
+			"_embedded": map[string]interface{}{
+				"customer": map[string]interface{}{
+					"_links": map[string]interface{}{
+						"self": map[string]interface{}{"href": "/customers/7"},
+					},
+				},
+			},
+		},
+		map[string]interface{}{
+			"_links": map[string]interface{}{
+				"self": map[string]interface{}{"href": "/orders/2"},
+			},
+		},
+	}
+
+	r := &Response{
+		Links: Links{},
+		Body: map[string]interface{}{
+			"_links": map[string]interface{}{
+				"self": map[string]interface{}{"href": "/orders"},
+			},
+			"_embedded": map[string]interface{}{
+				"orders": orders,
+			},
+		},
+	}
+
+	p := HALParser{}
+	err := p.ParseLinks(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "/orders", r.Links["self"][0].URI)
+	assert.Len(t, r.Links["embedded-orders"], 2)
+	assert.Equal(t, "/orders/1", r.Links["embedded-orders"][0].URI)
+	assert.Equal(t, "/orders/2", r.Links["embedded-orders"][1].URI)
+	assert.Equal(t, "/customers/7", r.Links["embedded-customer"][0].URI)
+	assert.Equal(t, orders, r.Embedded["orders"])
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// lockTimeout is how long to wait for another process to finish with a file.
+const lockTimeout = 10 * time.Second
+
+// withFileLock runs `fn` while holding an exclusive lock on `filename`, so
+// that Restish processes running in parallel take turns reading, modifying,
+// and writing the file rather than losing each other's changes. The lock is
+// an OS advisory lock on a `.lock` file next to it, which the OS releases if
+// the process holding it crashes.
+func withFileLock(filename string, fn func() error) error {
+	if filename == "" {
+		return fn()
+	}
+
+	// The lock file is never removed. Removing it would let a process still
+	// waiting on the old file and a process which creates a new one both hold
+	// the lock at the same time.
+	lockName := filename + ".lock"
+	f, err := os.OpenFile(lockName, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			return fmt.Errorf("unable to lock %s: %w", lockName, err)
+		}
+
+		if locked {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for lock %s, another Restish command is still using it", lockName)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer unlockFile(f)
+
+	return fn()
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLockHelperProcess is run as a separate process by TestFileLockProcesses
+// to increment a counter in a shared file.
+func TestLockHelperProcess(t *testing.T) {
+	filename := os.Getenv("RSH_LOCK_TEST_FILE")
+	if filename == "" {
+		return
+	}
+
+	for i := 0; i < 20; i++ {
+		err := withFileLock(filename, func() error {
+			b, _ := ioutil.ReadFile(filename)
+			count, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+			return writeFileAtomic(filename, []byte(strconv.Itoa(count+1)), 0600)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFileLockProcesses(t *testing.T) {
+	filename := path.Join(t.TempDir(), "counter")
+
+	// Other tests replace `os.Args`, so find the test binary directly.
+	executable, err := os.Executable()
+	assert.NoError(t, err)
+
+	processes := []*exec.Cmd{}
+	for i := 0; i < 5; i++ {
+		cmd := exec.Command(executable, "-test.run=^TestLockHelperProcess$")
+		cmd.Env = append(os.Environ(), "RSH_LOCK_TEST_FILE="+filename)
+		assert.NoError(t, cmd.Start())
+		processes = append(processes, cmd)
+	}
+
+	for _, cmd := range processes {
+		assert.NoError(t, cmd.Wait())
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "100", string(b))
+}
+
+func TestFileLockLeftover(t *testing.T) {
+	// A lock file left behind by a crashed process is no longer locked.
+	filename := path.Join(t.TempDir(), "data")
+	assert.NoError(t, ioutil.WriteFile(filename+".lock", []byte("1\n"), 0600))
+
+	called := false
+	assert.NoError(t, withFileLock(filename, func() error {
+		called = true
+		return nil
+	}))
+	assert.True(t, called)
+}
+
+func TestFileLockWaits(t *testing.T) {
+	filename := path.Join(t.TempDir(), "data")
+
+	held, err := os.OpenFile(filename+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	assert.NoError(t, err)
+	defer held.Close()
+
+	locked, err := tryLockFile(held)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+
+	released := make(chan time.Time, 1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		released <- time.Now()
+		unlockFile(held)
+	}()
+
+	var ran time.Time
+	assert.NoError(t, withFileLock(filename, func() error {
+		ran = time.Now()
+		return nil
+	}))
+	assert.False(t, ran.Before(<-released))
+}
+
+func TestSaveMergesConcurrentChanges(t *testing.T) {
+	reset(false)
+	defer func() {
+		removeAPI("lock-a")
+		removeAPI("lock-b")
+		reset(false)
+	}()
+
+	// Another process saves an API and some cache values after this one has
+	// loaded the config and cache.
+	other := `{"lock-b": {"base": "https://lock-b.example.com"}}`
+	b, err := ioutil.ReadFile(apis.ConfigFileUsed())
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(apis.ConfigFileUsed(), []byte(strings.Replace(string(b), "{", other[:len(other)-1]+",", 1)), 0600))
+
+	cached, err := ioutil.ReadFile(cacheFile())
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(cacheFile(), []byte(strings.Replace(string(cached), "{", `{"other-process": {"value": "kept"},`, 1)), 0600))
+
+	config := &APIConfig{name: "lock-a", Base: "https://lock-a.example.com"}
+	configs["lock-a"] = config
+	assert.NoError(t, config.Save())
+
+	Cache.Set("this-process.value", "saved")
+	assert.NoError(t, SaveCache())
+
+	b, err = ioutil.ReadFile(apis.ConfigFileUsed())
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "lock-a.example.com")
+	assert.Contains(t, string(b), "lock-b.example.com")
+
+	assert.Equal(t, "kept", Cache.GetString("other-process.value"))
+	assert.Equal(t, "saved", Cache.GetString("this-process.value"))
+
+	// Removing values works too.
+	assert.NoError(t, rewriteCache(func(data map[string]interface{}) bool {
+		delete(data, "other-process")
+		delete(data, "this-process")
+		return true
+	}))
+	assert.False(t, Cache.IsSet("this-process.value"), fmt.Sprintf("%v", Cache.AllSettings()))
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogEntry describes a single request/response for log hooks.
+type LogEntry struct {
+	Method  string
+	URL     string
+	Status  int
+	Latency time.Duration
+	API     string
+	Profile string
+}
+
+// String returns the entry as space-separated `key=value` pairs.
+func (e LogEntry) String() string {
+	return fmt.Sprintf("method=%s url=%s status=%d latency=%s api=%s profile=%s", e.Method, e.URL, e.Status, e.Latency, e.API, e.Profile)
+}
+
+var logHook func(entry LogEntry)
+var logHooksRunning sync.WaitGroup
+
+// SetLogHook registers a function which is called with an entry for every
+// request made on behalf of the user that gets a response, e.g. to send audit
+// logs to an external system. Secrets in URLs are redacted. The hook runs in the background so it doesn't slow down requests,
+// and may be called concurrently. Pass `nil` to remove the hook.
+func SetLogHook(fn func(entry LogEntry)) {
+	logHook = fn
+}
+
+// callLogHook runs the registered log hook, if any, in a new goroutine.
+func callLogHook(entry LogEntry) {
+	hook := logHook
+	if hook == nil {
+		return
+	}
+
+	logHooksRunning.Add(1)
+	go func() {
+		defer logHooksRunning.Done()
+		hook(entry)
+	}()
+}
+
+// waitLogHooks blocks until all running log hooks have finished.
+func waitLogHooks() {
+	logHooksRunning.Wait()
+}
+
+// SyslogLogHook returns a log hook which sends each entry as a syslog message
+// with the `user` facility to a server at `address`, where `network` is `udp`
+// or `tcp`. Responses with an error status are sent as warnings.
+func SyslogLogHook(network, address, tag string) (func(entry LogEntry), error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	var lock sync.Mutex
+	return func(entry LogEntry) {
+		// Priority is the facility (user = 1) times 8 plus the severity.
+		priority := 8 + 6
+		if entry.Status >= 400 {
+			priority = 8 + 4
+		}
+
+		msg := fmt.Sprintf("<%d>%s %s %s[%d]: %s", priority, time.Now().Format(time.RFC3339), hostname, tag, os.Getpid(), entry)
+		if strings.HasPrefix(network, "tcp") {
+			// Stream transports need framing between messages.
+			msg += "\n"
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			LogWarning("Unable to send syslog message: %v", err)
+		}
+	}, nil
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestLogHook(t *testing.T) {
+	defer gock.Off()
+	defer SetLogHook(nil)
+
+	gock.New("http://example.com").Get("/audited").Reply(http.StatusCreated)
+
+	entries := make(chan LogEntry, 1)
+	SetLogHook(func(entry LogEntry) {
+		entries <- entry
+	})
+
+	run("http://example.com/audited")
+	waitLogHooks()
+
+	entry := <-entries
+	assert.Equal(t, http.MethodGet, entry.Method)
+	assert.Equal(t, "http://example.com/audited", entry.URL)
+	assert.Equal(t, http.StatusCreated, entry.Status)
+	assert.Equal(t, "default", entry.Profile)
+	assert.True(t, entry.Latency > 0)
+}
+
+func TestLogHookUserRequestsOnly(t *testing.T) {
+	defer gock.Off()
+	defer SetLogHook(nil)
+
+	gock.New("http://example.com").Get("/internal").Reply(http.StatusOK)
+	gock.New("http://example.com").Get("/secret").MatchParam("api_key", "abc123").Reply(http.StatusOK)
+
+	entries := make(chan LogEntry, 2)
+	SetLogHook(func(entry LogEntry) {
+		entries <- entry
+	})
+
+	reset(false)
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/internal", nil)
+	_, err := MakeRequest(req)
+	assert.NoError(t, err)
+
+	run("http://example.com/secret?api_key=abc123")
+	waitLogHooks()
+
+	// Only the user's request is logged, without its secrets.
+	assert.Len(t, entries, 1)
+	entry := <-entries
+	assert.Equal(t, "http://example.com/secret?api_key=REDACTED", entry.URL)
+}
+
+func TestSyslogLogHook(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer server.Close()
+
+	hook, err := SyslogLogHook("udp", server.LocalAddr().String(), "restish")
+	assert.NoError(t, err)
+
+	hook(LogEntry{Method: http.MethodPost, URL: "https://api.example.com/items", Status: http.StatusNotFound, Latency: time.Second, API: "example", Profile: "default"})
+
+	buf := make([]byte, 1024)
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := server.ReadFrom(buf)
+	assert.NoError(t, err)
+
+	msg := string(buf[:n])
+	assert.Regexp(t, `^<12>\S+ \S+ restish\[\d+\]: `, msg)
+	assert.Contains(t, msg, "method=POST url=https://api.example.com/items status=404 latency=1s api=example profile=default")
+}
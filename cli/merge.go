@@ -0,0 +1,26 @@
+package cli
+
+// mergeDocuments deep merges `override` into `base`, recursing into objects
+// present in both. Arrays in both are concatenated, or replaced when
+// `concatArrays` is false. Any other values from `override` win.
+func mergeDocuments(base, override interface{}, concatArrays bool) interface{} {
+	switch o := override.(type) {
+	case map[string]interface{}:
+		if b, ok := base.(map[string]interface{}); ok {
+			for k, v := range o {
+				if existing, ok := b[k]; ok {
+					b[k] = mergeDocuments(existing, v, concatArrays)
+				} else {
+					b[k] = v
+				}
+			}
+			return b
+		}
+	case []interface{}:
+		if b, ok := base.([]interface{}); ok && concatArrays {
+			return append(b, o...)
+		}
+	}
+
+	return override
+}
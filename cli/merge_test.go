@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeDocuments(t *testing.T) {
+	base := map[string]interface{}{
+		"name":  "a",
+		"tags":  []interface{}{"x"},
+		"owner": map[string]interface{}{"id": 1.0, "name": "Kari"},
+		"keep":  true,
+	}
+	override := map[string]interface{}{
+		"name":  "b",
+		"tags":  []interface{}{"y"},
+		"owner": map[string]interface{}{"name": "Leon"},
+		"new":   nil,
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"name":  "b",
+		"tags":  []interface{}{"x", "y"},
+		"owner": map[string]interface{}{"id": 1.0, "name": "Leon"},
+		"keep":  true,
+		"new":   nil,
+	}, mergeDocuments(base, override, true))
+
+	assert.Equal(t, map[string]interface{}{"tags": []interface{}{"z"}},
+		mergeDocuments(map[string]interface{}{"tags": []interface{}{"x"}}, map[string]interface{}{"tags": []interface{}{"z"}}, false))
+
+	// Mismatched types are replaced.
+	assert.Equal(t, "foo", mergeDocuments(map[string]interface{}{}, "foo", true))
+	assert.Equal(t, []interface{}{1.0, 2.0}, mergeDocuments([]interface{}{1.0}, []interface{}{2.0}, true))
+}
+
+func TestFormatMerge(t *testing.T) {
+	dir := t.TempDir()
+	first := path.Join(dir, "first.json")
+	second := path.Join(dir, "second.yaml")
+	assert.NoError(t, ioutil.WriteFile(first, []byte(`{"items": [1], "meta": {"page": 1, "total": 2}}`), 0600))
+	assert.NoError(t, ioutil.WriteFile(second, []byte("items: [2]\nmeta:\n  page: 2\n"), 0600))
+
+	assert.JSONEq(t, `{"items": [1, 2], "meta": {"page": 2, "total": 2}}`, run("format merge "+first+" "+second))
+	assert.JSONEq(t, `{"items": [2], "meta": {"page": 2, "total": 2}}`, run("format merge "+first+" "+second+" --rsh-merge-arrays replace"))
+
+	WithFakeStdin([]byte(`{"extra": true}`), 0, func() {
+		assert.JSONEq(t, `true`, run("format merge "+first+" - -f body.extra"))
+	})
+
+	assert.Contains(t, run("format merge "+first+" --rsh-merge-arrays bad"), "must be concat or replace")
+}
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// operationKey is the request context key for the CLI operation name.
+type operationKey struct{}
+
+// WithOperation returns a copy of the request tagged with the name of the API
+// operation which generated it, used for metrics labels.
+func WithOperation(req *http.Request, name string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), operationKey{}, name))
+}
+
+// requestMetric records information about a single request/response.
+type requestMetric struct {
+	method    string
+	operation string
+	status    int
+	duration  time.Duration
+	bytes     int64
+}
+
+// recordedMetrics holds metrics for every request made during this run.
+var recordedMetrics []*requestMetric
+
+// countingReadCloser counts the number of bytes read from a response body.
+type countingReadCloser struct {
+	io.ReadCloser
+	metric *requestMetric
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	atomic.AddInt64(&c.metric.bytes, int64(n))
+	return n, err
+}
+
+// idSegmentRegex matches path segments which look like IDs, e.g. numbers,
+// UUIDs, or long hex strings, to keep label cardinality low.
+var idSegmentRegex = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F-]{16,})$`)
+
+// sanitizeMetricURL turns a URL into a low-cardinality label value by
+// dropping the scheme, query, and fragment and replacing ID-like segments.
+func sanitizeMetricURL(u string) string {
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+
+	if i := strings.Index(u, "://"); i >= 0 {
+		u = u[i+3:]
+	}
+
+	parts := strings.Split(u, "/")
+	for i, part := range parts {
+		if i > 0 && idSegmentRegex.MatchString(part) {
+			parts[i] = "{id}"
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// recordMetric starts recording metrics for a response and wraps its body so
+// the number of bytes received is counted.
+func recordMetric(req *http.Request, resp *http.Response, duration time.Duration) {
+	operation, _ := req.Context().Value(operationKey{}).(string)
+	if operation == "" {
+		operation = sanitizeMetricURL(req.URL.String())
+	}
+
+	m := &requestMetric{
+		method:    req.Method,
+		operation: operation,
+		status:    resp.StatusCode,
+		duration:  duration,
+	}
+	recordedMetrics = append(recordedMetrics, m)
+
+	resp.Body = &countingReadCloser{ReadCloser: resp.Body, metric: m}
+}
+
+// escapeLabel escapes a Prometheus label value.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+var metricFamilies = []struct {
+	name string
+	help string
+	get  func(m *requestMetric) string
+}{
+	{"restish_request_duration_seconds", "Time from sending the request until response headers were received.", func(m *requestMetric) string {
+		return fmt.Sprintf("%g", m.duration.Seconds())
+	}},
+	{"restish_response_status", "HTTP status code of the most recent response.", func(m *requestMetric) string {
+		return fmt.Sprintf("%d", m.status)
+	}},
+	{"restish_response_bytes", "Number of response body bytes received over the wire.", func(m *requestMetric) string {
+		return fmt.Sprintf("%d", atomic.LoadInt64(&m.bytes))
+	}},
+}
+
+// readMetricSamples reads existing metric samples from a textfile, keyed by
+// the metric name including labels.
+func readMetricSamples(filename string) map[string]string {
+	samples := map[string]string{}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return samples
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if i := strings.LastIndex(line, " "); i > 0 {
+			samples[line[:i]] = line[i+1:]
+		}
+	}
+
+	return samples
+}
+
+// WriteMetrics writes the metrics recorded during this run to the file given
+// by `rsh-metrics` in the Prometheus textfile format used by the node_exporter
+// textfile collector. Series from previous runs are kept, and series with the
+// same labels are updated with the latest values. The file is replaced
+// atomically so the collector never reads a partial file.
+func WriteMetrics() error {
+	filename := viper.GetString("rsh-metrics")
+	if filename == "" || len(recordedMetrics) == 0 {
+		return nil
+	}
+
+	samples := readMetricSamples(filename)
+	for _, m := range recordedMetrics {
+		labels := fmt.Sprintf(`{method="%s",operation="%s"}`, escapeLabel(m.method), escapeLabel(m.operation))
+		for _, family := range metricFamilies {
+			samples[family.name+labels] = family.get(m)
+		}
+	}
+	recordedMetrics = nil
+
+	keys := []string{}
+	for k := range samples {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	sb := &strings.Builder{}
+	for _, family := range metricFamilies {
+		fmt.Fprintf(sb, "# HELP %s %s\n# TYPE %s gauge\n", family.name, family.help, family.name)
+		for _, k := range keys {
+			if strings.HasPrefix(k, family.name+"{") || k == family.name {
+				fmt.Fprintf(sb, "%s %s\n", k, samples[k])
+			}
+		}
+	}
+
+	return writeFileAtomic(filename, []byte(sb.String()), 0644)
+}
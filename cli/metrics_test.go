@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestSanitizeMetricURL(t *testing.T) {
+	assert.Equal(t, "example.com/items/{id}/tags", sanitizeMetricURL("https://example.com/items/123/tags?limit=5"))
+	assert.Equal(t, "example.com/users/{id}", sanitizeMetricURL("http://example.com/users/0b5e3c9a-6f7d-4d1e-9a2b-3c4d5e6f7a8b#top"))
+	assert.Equal(t, "example.com/v2/items", sanitizeMetricURL("example.com/v2/items"))
+}
+
+func TestMetrics(t *testing.T) {
+	defer gock.Off()
+
+	filename := filepath.Join(t.TempDir(), "restish.prom")
+
+	gock.New("http://example.com").Get("/items/1").Reply(200).BodyString("hello")
+	run("http://example.com/items/1 --rsh-metrics " + filename)
+
+	gock.New("http://example.com").Get("/items/2").Reply(404).BodyString("not found")
+	run("http://example.com/items/2 --rsh-metrics " + filename)
+
+	b, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+
+	out := string(b)
+	assert.Contains(t, out, "# TYPE restish_request_duration_seconds gauge\n")
+	assert.Contains(t, out, `restish_request_duration_seconds{method="GET",operation="example.com/items/{id}"}`)
+	assert.Contains(t, out, `restish_response_status{method="GET",operation="example.com/items/{id}"} 404`)
+	assert.Contains(t, out, `restish_response_bytes{method="GET",operation="example.com/items/{id}"} 9`)
+	assert.NotContains(t, out, " 200\n")
+}
+
+func TestMetricsSkipInternalRequests(t *testing.T) {
+	defer gock.Off()
+	reset(false)
+	recordedMetrics = nil
+
+	// Requests made by Restish itself, e.g. to load API descriptions, aren't
+	// recorded.
+	gock.New("http://example.com").Get("/openapi.json").Reply(200).BodyString("{}")
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/openapi.json", nil)
+	_, err := GetParsedResponse(req)
+	assert.NoError(t, err)
+	assert.Empty(t, recordedMetrics)
+
+	gock.New("http://example.com").Get("/items").Reply(200).BodyString("[]")
+	req, _ = http.NewRequest(http.MethodGet, "http://example.com/items", nil)
+	assert.NoError(t, MakeRequestAndFormat(req))
+	assert.Len(t, recordedMetrics, 1)
+	recordedMetrics = nil
+}
@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/alexeyco/simpletable"
+	"github.com/spf13/cobra"
+)
+
+// migrateResult is the outcome of patching a single resource.
+type migrateResult struct {
+	URI    string
+	Status int
+	Err    error
+}
+
+// itemSelfLink returns the `self` link of an item in a list response using
+// the registered link parsers, e.g. from HAL `_links` or JSON:API `links`.
+func itemSelfLink(base *url.URL, item interface{}) string {
+	resp := &Response{
+		Headers: map[string]string{},
+		Links:   Links{},
+		Body:    item,
+	}
+
+	if err := ParseLinks(base, resp); err != nil {
+		return ""
+	}
+
+	if self := resp.Links["self"]; len(self) > 0 {
+		return self[0].URI
+	}
+
+	return ""
+}
+
+// runMigrate patches each item's `self` link with the body, using up to
+// `concurrency` requests at a time. With `dryRun` no requests are made.
+// Results are returned in the same order as the items.
+func runMigrate(base *url.URL, items []interface{}, body string, dryRun bool, concurrency int) []migrateResult {
+	results := make([]migrateResult, len(items))
+
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	done := 0
+	lock := sync.Mutex{}
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = migrateItem(base, items[i], body, dryRun)
+
+				lock.Lock()
+				done++
+				if !dryRun {
+					if results[i].Err != nil {
+						LogInfo("[%d/%d] %s: %v", done, len(items), results[i].URI, results[i].Err)
+					} else {
+						LogInfo("[%d/%d] %s: %d", done, len(items), results[i].URI, results[i].Status)
+					}
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+	for i := range items {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// migrateItem patches a single item.
+func migrateItem(base *url.URL, item interface{}, body string, dryRun bool) migrateResult {
+	uri := itemSelfLink(base, item)
+	if uri == "" {
+		return migrateResult{Err: fmt.Errorf("no self link found")}
+	}
+
+	result := migrateResult{URI: uri}
+	if dryRun {
+		return result
+	}
+
+	req, err := http.NewRequest(http.MethodPatch, uri, strings.NewReader(body))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := MakeRequest(req)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	result.Status = resp.StatusCode
+	if resp.StatusCode >= 400 {
+		result.Err = fmt.Errorf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+
+	return result
+}
+
+// migrateTable renders the results along with a summary.
+func migrateTable(results []migrateResult, dryRun bool) string {
+	table := simpletable.New()
+	table.Header = &simpletable.Header{}
+	for _, title := range []string{"Resource", "Status", "Result"} {
+		table.Header.Cells = append(table.Header.Cells, &simpletable.Cell{Align: simpletable.AlignCenter, Text: title})
+	}
+
+	succeeded, failed := 0, 0
+	for _, r := range results {
+		status := ""
+		if r.Status != 0 {
+			status = fmt.Sprintf("%d", r.Status)
+		}
+
+		text := "updated"
+		if dryRun {
+			text = "would update"
+		}
+		if r.Err != nil {
+			text = r.Err.Error()
+			failed++
+		} else {
+			succeeded++
+		}
+
+		uri := r.URI
+		if uri == "" {
+			uri = "(unknown)"
+		}
+
+		table.Body.Cells = append(table.Body.Cells, []*simpletable.Cell{
+			{Text: uri},
+			{Align: simpletable.AlignRight, Text: status},
+			{Text: text},
+		})
+	}
+
+	out := ""
+	if len(table.Body.Cells) > 0 {
+		table.SetStyle(simpletable.StyleCompactLite)
+		out = table.String() + "\n\n"
+	}
+
+	if dryRun {
+		return out + fmt.Sprintf("%d would be updated, %d failed\n", succeeded, failed)
+	}
+
+	return out + fmt.Sprintf("%d updated, %d failed\n", succeeded, failed)
+}
+
+func migrateCommand() *cobra.Command {
+	var patch *string
+	var dryRun *bool
+	var concurrency *int
+
+	cmd := &cobra.Command{
+		Use:   "migrate list-url",
+		Short: "Bulk update resources from a list",
+		Long:  "Fetch all resources from a list URL, following pagination, and send a PATCH request to the `self` link of each with the body given via `--patch` in CLI shorthand. Use `--rsh-dry-run` to see which resources would be changed without modifying them. A table of the results is shown at the end and the exit code is non-zero if any updates failed.",
+		Example: fmt.Sprintf(`  # Activate all users
+  $ %s migrate api.example.com/users --patch 'status: active'
+
+  # Preview which resources would change
+  $ %s migrate api.example.com/users --patch 'status: active' --rsh-dry-run`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if *patch == "" {
+				panic(fmt.Errorf("a body is required via --patch"))
+			}
+
+			if *concurrency < 1 {
+				panic(fmt.Errorf("concurrency must be at least 1"))
+			}
+
+			body, err := GetBody("application/json", []string{*patch})
+			if err != nil {
+				panic(err)
+			}
+
+			req, _ := http.NewRequest(http.MethodGet, fixAddress(args[0]), nil)
+			resp, err := GetParsedResponse(req)
+			if err != nil {
+				panic(err)
+			}
+
+			if resp.Status >= 400 {
+				panic(fmt.Errorf("unable to list resources: %d %s", resp.Status, http.StatusText(resp.Status)))
+			}
+
+			items, ok := resp.Body.([]interface{})
+			if !ok {
+				panic(fmt.Errorf("response body is not a list"))
+			}
+
+			if *dryRun {
+				LogInfo("Dry run: would PATCH %d resources with %s", len(items), body)
+			}
+
+			results := runMigrate(req.URL, items, body, *dryRun, *concurrency)
+			fmt.Fprint(Stdout, migrateTable(results, *dryRun))
+
+			for _, r := range results {
+				if r.Err != nil {
+					exitCode = 1
+					break
+				}
+			}
+		},
+	}
+
+	patch = cmd.Flags().String("patch", "", "Body to PATCH each resource with in CLI shorthand, e.g. 'status: active'")
+	dryRun = cmd.Flags().Bool("rsh-dry-run", false, "Show what would be changed without sending PATCH requests")
+	concurrency = cmd.Flags().Int("rsh-concurrency", 1, "Number of resources to patch in parallel")
+
+	return cmd
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func migrateItems(ids ...string) []interface{} {
+	items := []interface{}{}
+	for _, id := range ids {
+		items = append(items, map[string]interface{}{
+			"id": id,
+			"_links": map[string]interface{}{
+				"self": map[string]interface{}{"href": "/users/" + id},
+			},
+		})
+	}
+	return items
+}
+
+func TestMigrate(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/users").Reply(200).
+		SetHeader("Link", `</users?page=2>; rel="next"`).
+		JSON(append(migrateItems("a", "b"), map[string]interface{}{"id": "c"}))
+	gock.New("http://example.com").Get("/users").MatchParam("page", "2").Reply(200).JSON(migrateItems("d"))
+
+	for _, id := range []string{"a", "b"} {
+		gock.New("http://example.com").Patch("/users/" + id).JSON(map[string]interface{}{"status": "active"}).Reply(200)
+	}
+	gock.New("http://example.com").Patch("/users/d").Reply(500)
+
+	out := run("migrate http://example.com/users --patch=status:active --rsh-concurrency 2")
+	assert.Regexp(t, `http://example.com/users/a\s+200\s+updated`, out)
+	assert.Regexp(t, `http://example.com/users/b\s+200\s+updated`, out)
+	assert.Regexp(t, `\(unknown\)\s+no self link found`, out)
+	assert.Regexp(t, `http://example.com/users/d\s+500\s+500 Internal Server Error`, out)
+	assert.Contains(t, out, "2 updated, 2 failed")
+	assert.Equal(t, 1, GetExitCode())
+	assert.True(t, gock.IsDone())
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/users").Reply(200).JSON(migrateItems("a"))
+
+	out := run("migrate http://example.com/users --patch=status:active --rsh-dry-run")
+	assert.Contains(t, out, `would PATCH 1 resources with {"status":"active"}`)
+	assert.Regexp(t, `http://example.com/users/a\s+would update`, out)
+	assert.Contains(t, out, "1 would be updated, 0 failed")
+	assert.Equal(t, 0, GetExitCode())
+	assert.True(t, gock.IsDone())
+}
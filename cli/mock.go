@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// mockRoute matches requests for a single operation.
+type mockRoute struct {
+	op      Operation
+	pattern *regexp.Regexp
+}
+
+// mockRoutes builds routes for each operation from the path of its URI
+// template, where each `{param}` matches a single path segment.
+func mockRoutes(ops []Operation) []mockRoute {
+	routes := []mockRoute{}
+	for _, op := range ops {
+		p := op.URITemplate
+		if i := strings.Index(p, "://"); i >= 0 {
+			p = p[i+3:]
+			if j := strings.Index(p, "/"); j >= 0 {
+				p = p[j:]
+			} else {
+				p = "/"
+			}
+		}
+
+		literals := templateVarRegex.Split(p, -1)
+		for i := range literals {
+			literals[i] = regexp.QuoteMeta(literals[i])
+		}
+
+		routes = append(routes, mockRoute{
+			op:      op,
+			pattern: regexp.MustCompile("^" + strings.Join(literals, "[^/]+") + "$"),
+		})
+	}
+	return routes
+}
+
+// mockResponse picks the response to serve for an operation. If `status` is
+// non-zero then that status is used, falling back to the default response.
+// Otherwise the first success response is used.
+func mockResponse(op Operation, status int) OperationResponse {
+	var fallback *OperationResponse
+	for i, resp := range op.Responses {
+		if resp.Status == 0 {
+			fallback = &op.Responses[i]
+			continue
+		}
+
+		if status != 0 && resp.Status == status {
+			return resp
+		}
+
+		if status == 0 && resp.Status >= 200 && resp.Status < 300 {
+			return resp
+		}
+	}
+
+	if status == 0 {
+		status = http.StatusOK
+		if fallback == nil {
+			return OperationResponse{Status: http.StatusNoContent}
+		}
+	}
+
+	if fallback != nil {
+		resp := *fallback
+		resp.Status = status
+		return resp
+	}
+
+	return OperationResponse{Status: status}
+}
+
+// mockHandler serves mock responses for API operations. Bodies are generated
+// from the response schema, or taken from the API description's examples when
+// `useExamples` is set and an example is available.
+func mockHandler(ops []Operation, useExamples bool, status int) http.Handler {
+	routes := mockRoutes(ops)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, route := range routes {
+			if !strings.EqualFold(route.op.Method, r.Method) || !route.pattern.MatchString(r.URL.Path) {
+				continue
+			}
+
+			resp := mockResponse(route.op, status)
+			LogInfo("%s %s -> %d (%s)", r.Method, r.URL.Path, resp.Status, route.op.Name)
+
+			body := resp.Generated
+			if useExamples && resp.Example != nil {
+				body = resp.Example
+			}
+
+			if body == nil || resp.ContentType == "" {
+				w.WriteHeader(resp.Status)
+				return
+			}
+
+			encoded, err := Marshal(resp.ContentType, body)
+			if err != nil {
+				s, ok := body.(string)
+				if !ok {
+					LogError("Unable to encode mock response: %v", err)
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				encoded = []byte(s)
+			}
+
+			w.Header().Set("Content-Type", resp.ContentType)
+			w.WriteHeader(resp.Status)
+			w.Write(encoded)
+			return
+		}
+
+		LogWarning("%s %s -> 404 (no matching operation)", r.Method, r.URL.Path)
+		http.NotFound(w, r)
+	})
+}
+
+func mockCommand() *cobra.Command {
+	var port *int
+	var useExamples *bool
+	var status *int
+	var record *bool
+	var cassetteFile *string
+	var chaosFile *string
+	var chaosRate *float64
+	var chaosP50 *time.Duration
+	var chaosP99 *time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "mock short-name",
+		Short: "Serve a mock of an API",
+		Long:  "Start a local server which responds to each API operation with a fake response generated from its response schema. With `--rsh-openapi-examples` the examples from the API description are served instead when available. Use `--rsh-mock-status` to always respond with a specific status code, e.g. to test error handling.\n\nWith `--rsh-record-passthrough` requests are instead forwarded to the real API and each request and response is recorded to a cassette file. Later runs replay recorded responses from the cassette, falling back to generated responses.\n\nTo test how clients handle failures, `--rsh-chaos-rate` randomly fails that fraction of requests with a `500` or `503` status or by dropping the connection, while `--rsh-chaos-latency-p50` and `--rsh-chaos-latency-p99` add artificial latency to every response. Use `--rsh-chaos-config` to load these settings, the failures to inject, and per-route overrides from a YAML file.",
+		Example: fmt.Sprintf(`  # Serve examples from the API description
+  $ %s mock my-api --rsh-openapi-examples
+
+  # Respond to every request with an error
+  $ %s mock my-api --rsh-mock-status 500
+
+  # Record real responses, then replay them
+  $ %s mock my-api --rsh-record-passthrough
+  $ %s mock my-api
+
+  # Fail 10%% of requests and add latency
+  $ %s mock my-api --rsh-chaos-rate 0.1 --rsh-chaos-latency-p50 100ms --rsh-chaos-latency-p99 5s`, Root.CommandPath(), Root.CommandPath(), Root.CommandPath(), Root.CommandPath(), Root.CommandPath()),
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeAPINames,
+		Run: func(cmd *cobra.Command, args []string) {
+			config := configs[args[0]]
+			if config == nil {
+				panic(fmt.Errorf("API %s not found", args[0]))
+			}
+
+			filename := *cassetteFile
+			if filename == "" {
+				filename = args[0] + ".cassette.json"
+			}
+
+			c, err := loadCassette(filename)
+			if err != nil {
+				panic(err)
+			}
+
+			var handler http.Handler
+			if *record {
+				base, err := url.Parse(config.Base)
+				if err != nil {
+					panic(err)
+				}
+
+				LogInfo("Recording %s to %s", config.Base, filename)
+				handler = recordHandler(c, base)
+			} else {
+				api, err := Load(config.Base, &cobra.Command{})
+				if err != nil {
+					panic(err)
+				}
+
+				handler = mockHandler(api.Operations, *useExamples, *status)
+				if *status == 0 && len(c.Interactions) > 0 {
+					LogInfo("Replaying %d recorded responses from %s", len(c.Interactions), filename)
+					handler = replayHandler(c, handler)
+				}
+			}
+
+			chaosSetup := &chaosConfig{}
+			if *chaosFile != "" {
+				if chaosSetup, err = loadChaosConfig(*chaosFile); err != nil {
+					panic(err)
+				}
+			}
+			if cmd.Flags().Changed("rsh-chaos-rate") {
+				chaosSetup.Rate = chaosRate
+			}
+			if cmd.Flags().Changed("rsh-chaos-latency-p50") {
+				chaosSetup.LatencyP50 = (*chaosDuration)(chaosP50)
+			}
+			if cmd.Flags().Changed("rsh-chaos-latency-p99") {
+				chaosSetup.LatencyP99 = (*chaosDuration)(chaosP99)
+			}
+			if chaosSetup.enabled() {
+				injector, err := newChaos(chaosSetup, rand.NewSource(time.Now().UnixNano()))
+				if err != nil {
+					panic(err)
+				}
+				handler = chaosHandler(injector, handler)
+			}
+
+			LogInfo("Serving mock %s at http://localhost:%d", args[0], *port)
+			panic(http.ListenAndServe(fmt.Sprintf(":%d", *port), handler))
+		},
+	}
+
+	port = cmd.Flags().Int("rsh-mock-port", 8080, "Port to listen on")
+	useExamples = cmd.Flags().Bool("rsh-openapi-examples", false, "Serve examples from the API description when available")
+	status = cmd.Flags().Int("rsh-mock-status", 0, "Always respond with this status code")
+	record = cmd.Flags().Bool("rsh-record-passthrough", false, "Forward requests to the real API and record responses to the cassette")
+	cassetteFile = cmd.Flags().String("rsh-cassette", "", "Cassette file for recorded responses, defaults to short-name.cassette.json")
+	chaosFile = cmd.Flags().String("rsh-chaos-config", "", "YAML file with chaos settings and per-route overrides")
+	chaosRate = cmd.Flags().Float64("rsh-chaos-rate", 0, "Fraction of requests to fail, from 0 to 1")
+	chaosP50 = cmd.Flags().Duration("rsh-chaos-latency-p50", 0, "Median latency to add to responses, e.g. 100ms")
+	chaosP99 = cmd.Flags().Duration("rsh-chaos-latency-p99", 0, "99th percentile latency to add to responses, e.g. 5s")
+
+	return cmd
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestMock(t *testing.T) {
+	reset(false)
+
+	ops := []Operation{
+		{
+			Name:        "get-item",
+			Method:      http.MethodGet,
+			URITemplate: "https://api.example.com/v1/items/{item-id}",
+			Responses: []OperationResponse{
+				{Status: 200, ContentType: "application/json", Example: map[string]interface{}{"id": "example"}, Generated: map[string]interface{}{"id": "string"}},
+				{Status: 404, ContentType: "application/problem+json", Generated: map[string]interface{}{"title": "string"}},
+				{Status: 0, ContentType: "application/json", Generated: map[string]interface{}{"error": "string"}},
+			},
+		},
+		{
+			Name:        "delete-item",
+			Method:      http.MethodDelete,
+			URITemplate: "https://api.example.com/v1/items/{item-id}",
+		},
+	}
+
+	serve := func(method, path string, useExamples bool, status int) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mockHandler(ops, useExamples, status).ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	w := serve(http.MethodGet, "/v1/items/abc", false, 0)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"id": "string"}`, w.Body.String())
+
+	w = serve(http.MethodGet, "/v1/items/abc", true, 0)
+	assert.JSONEq(t, `{"id": "example"}`, w.Body.String())
+
+	w = serve(http.MethodGet, "/v1/items/abc", true, 404)
+	assert.Equal(t, 404, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	// Undescribed statuses use the default response.
+	w = serve(http.MethodGet, "/v1/items/abc", true, 500)
+	assert.Equal(t, 500, w.Code)
+	assert.JSONEq(t, `{"error": "string"}`, w.Body.String())
+
+	w = serve(http.MethodDelete, "/v1/items/abc", false, 0)
+	assert.Equal(t, 204, w.Code)
+
+	w = serve(http.MethodGet, "/v1/items/abc/extra", false, 0)
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestMockRecordReplay(t *testing.T) {
+	defer gock.Off()
+	reset(false)
+
+	gock.New("http://example.com").Get("/v1/items").MatchParam("limit", "2").Reply(200).SetHeader("X-Real", "true").JSON([]interface{}{"a", "b"})
+
+	c, err := loadCassette(filepath.Join(t.TempDir(), "test.cassette.json"))
+	assert.NoError(t, err)
+
+	base, _ := url.Parse("http://example.com/v1")
+	w := httptest.NewRecorder()
+	recordHandler(c, base).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/items?limit=2", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "true", w.Header().Get("X-Real"))
+	assert.JSONEq(t, `["a", "b"]`, w.Body.String())
+	assert.True(t, gock.IsDone())
+
+	// Replay from the saved cassette without the real API.
+	c, err = loadCassette(c.filename)
+	assert.NoError(t, err)
+	assert.Len(t, c.Interactions, 1)
+
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	w = httptest.NewRecorder()
+	replayHandler(c, fallback).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/items?limit=2", nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "true", w.Header().Get("X-Real"))
+	assert.JSONEq(t, `["a", "b"]`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	replayHandler(c, fallback).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/items?limit=3", nil))
+	assert.Equal(t, http.StatusTeapot, w.Code)
+
+	// Binary bodies round-trip via base64.
+	assert.Equal(t, []byte{0xff, 0x00}, newCassetteBody([]byte{0xff, 0x00}).bytes())
+	assert.Equal(t, cassetteBody{Text: "hi"}, newCassetteBody([]byte("hi")))
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// isMultipart returns whether a content type is `multipart/form-data`.
+func isMultipart(mediaType string) bool {
+	return strings.HasPrefix(strings.ToLower(mediaType), "multipart/form-data")
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// encodeMultipart encodes an object as `multipart/form-data` with a part per
+// field, sorted by name. Like curl's `-F`, strings like `@photo.png`, e.g.
+// `photo:~ @photo.png` in shorthand, upload that file with its name and a
+// content type from its extension. Other strings are sent as-is and other
+// values as JSON.
+func encodeMultipart(input interface{}) (string, error) {
+	fields, ok := input.(map[string]interface{})
+	if !ok {
+		return "", errors.New("multipart bodies must be objects")
+	}
+
+	names := []string{}
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+
+	for _, name := range names {
+		value := fields[name]
+
+		if s, ok := value.(string); ok && len(s) > 1 && strings.HasPrefix(s, "@") {
+			filename := expandHome(s[1:])
+			data, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return "", err
+			}
+
+			ct := mime.TypeByExtension(filepath.Ext(filename))
+			if ct == "" {
+				ct = "application/octet-stream"
+			}
+
+			h := textproto.MIMEHeader{}
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(name), quoteEscaper.Replace(filepath.Base(filename))))
+			h.Set("Content-Type", ct)
+			part, err := w.CreatePart(h)
+			if err != nil {
+				return "", err
+			}
+			if _, err := part.Write(data); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		s, ok := value.(string)
+		if !ok {
+			b, err := json.Marshal(value)
+			if err != nil {
+				return "", err
+			}
+			s = string(b)
+		}
+
+		if err := w.WriteField(name, s); err != nil {
+			return "", err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// multipartBoundary returns the boundary of an encoded multipart body, which
+// is on its first line.
+func multipartBoundary(body string) string {
+	if !strings.HasPrefix(body, "--") {
+		return ""
+	}
+
+	boundary, _, _ := strings.Cut(body[2:], "\r\n")
+	return boundary
+}
+
+// bodyContentType returns the content type to send an encoded body with,
+// which for multipart bodies includes their boundary.
+func bodyContentType(mediaType, body string) string {
+	if isMultipart(mediaType) {
+		if boundary := multipartBoundary(body); boundary != "" {
+			return mime.FormatMediaType("multipart/form-data", map[string]string{"boundary": boundary})
+		}
+	}
+
+	return mediaType
+}
+
+// previewMultipart renders each part of a multipart body with its headers,
+// like the field name, file name, and content type, followed by its content.
+// Binary content is summarized rather than printed.
+func previewMultipart(body string) (string, error) {
+	boundary := multipartBoundary(body)
+	if boundary == "" {
+		return body, nil
+	}
+
+	preview := []string{}
+	r := multipart.NewReader(strings.NewReader(body), boundary)
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return "", err
+		}
+
+		names := []string{}
+		for name := range part.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		text := "--" + boundary + "\n"
+		for _, name := range names {
+			for _, value := range part.Header[name] {
+				text += name + ": " + value + "\n"
+			}
+		}
+		text += "\n"
+
+		if utf8.Valid(data) {
+			text += string(data)
+		} else {
+			text += fmt.Sprintf("<%d bytes of binary data>", len(data))
+		}
+
+		preview = append(preview, text)
+	}
+
+	return strings.Join(preview, "\n") + "\n--" + boundary + "--", nil
+}
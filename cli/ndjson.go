@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/spf13/viper"
+)
+
+// NDJSON describes newline-delimited JSON content types like
+// `application/x-ndjson` or `application/jsonl`. Documents are decoded into a
+// list with one item per line. Malformed lines are reported and skipped
+// unless `rsh-ndjson-strict` is set.
+type NDJSON struct{}
+
+// Detect if the content type is newline-delimited JSON.
+func (n NDJSON) Detect(contentType string) bool {
+	switch mediaType(contentType) {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines", "application/jsonlines":
+		return true
+	}
+
+	return false
+}
+
+// Marshal the value as one line of JSON per item in a list.
+func (n NDJSON) Marshal(value interface{}) ([]byte, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		items = []interface{}{value}
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	for _, item := range items {
+		if err := enc.Encode(makeJSONSafe(item, false)); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal the value from newline-delimited JSON.
+func (n NDJSON) Unmarshal(data []byte, value interface{}) error {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr {
+		return fmt.Errorf("value must be pointer but found %s", v.Kind())
+	}
+
+	items, err := decodeNDJSON(bytes.NewReader(data), nil)
+	if err != nil {
+		return err
+	}
+
+	v.Elem().Set(reflect.ValueOf(items))
+	return nil
+}
+
+// decodeNDJSON reads one JSON value per line as data arrives, calling `onItem`
+// (if set) for each one before returning them all. Blank lines are ignored.
+func decodeNDJSON(r io.Reader, onItem func(item interface{})) ([]interface{}, error) {
+	items := []interface{}{}
+	reader := bufio.NewReader(r)
+
+	for number := 1; ; number++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var item interface{}
+			if decodeErr := (JSON{}).Unmarshal(trimmed, &item); decodeErr != nil {
+				if viper.GetBool("rsh-ndjson-strict") {
+					return nil, fmt.Errorf("invalid JSON on line %d: %w", number, decodeErr)
+				}
+				LogWarning("Skipping invalid JSON on line %d: %v", number, decodeErr)
+			} else {
+				items = append(items, item)
+				if onItem != nil {
+					onItem(item)
+				}
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return items, nil
+}
+
+// canStreamItems returns whether items of streamed responses can be printed
+// as they arrive. This is only done for the default output, since structured
+// formats, filtering, and tables need the whole response.
+func canStreamItems() bool {
+	if viper.GetString("rsh-output-format") != "auto" {
+		return false
+	}
+
+	for _, key := range []string{"rsh-filter", "rsh-jsonpath", "rsh-jq"} {
+		if viper.GetString(key) != "" {
+			return false
+		}
+	}
+
+	for _, key := range []string{"rsh-table", "rsh-raw", "rsh-quiet", "rsh-headers-only"} {
+		if viper.GetBool(key) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// formatStreamItem prints a single streamed item as readable output.
+func formatStreamItem(item interface{}) error {
+	encoded, err := MarshalReadable(item)
+	if err != nil {
+		return err
+	}
+	encoded = append(encoded, '\n')
+
+	if tty {
+		if encoded, err = Highlight("readable", encoded); err != nil {
+			return err
+		}
+	}
+
+	_, err = Stdout.Write(encoded)
+	return err
+}
@@ -3,7 +3,6 @@ package cli
 import (
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
@@ -15,24 +14,128 @@ import (
 
 // Operation represents an API action, e.g. list-things or create-user
 type Operation struct {
-	Name          string   `json:"name"`
-	Aliases       []string `json:"aliases,omitempty"`
-	Short         string   `json:"short,omitempty"`
-	Long          string   `json:"long,omitempty"`
-	Method        string   `json:"method,omitempty"`
-	URITemplate   string   `json:"uriTemplate"`
-	PathParams    []*Param `json:"pathParams,omitempty"`
-	QueryParams   []*Param `json:"queryParams,omitempty"`
-	HeaderParams  []*Param `json:"headerParams,omitempty"`
-	BodyMediaType string   `json:"bodyMediaType,omitempty"`
-	Examples      []string `json:"examples,omitempty"`
-	Hidden        bool     `json:"hidden,omitempty"`
+	Name          string                 `json:"name"`
+	Aliases       []string               `json:"aliases,omitempty"`
+	Short         string                 `json:"short,omitempty"`
+	Long          string                 `json:"long,omitempty"`
+	Method        string                 `json:"method,omitempty"`
+	URITemplate   string                 `json:"uriTemplate"`
+	PathParams    []*Param               `json:"pathParams,omitempty"`
+	QueryParams   []*Param               `json:"queryParams,omitempty"`
+	HeaderParams  []*Param               `json:"headerParams,omitempty"`
+	BodyMediaType string                 `json:"bodyMediaType,omitempty"`
+	BodySchemas   map[string]interface{} `json:"bodySchemas,omitempty"`
+	Examples      []string               `json:"examples,omitempty"`
+	Tags          []string               `json:"tags,omitempty"`
+	Hidden        bool                   `json:"hidden,omitempty"`
+	Responses     []OperationResponse    `json:"responses,omitempty"`
+}
+
+// OperationResponse describes a possible response for an operation, which is
+// used to serve mock responses. A status of zero is the default response used
+// for any status code that isn't described.
+type OperationResponse struct {
+	Status      int         `json:"status"`
+	ContentType string      `json:"contentType,omitempty"`
+	Example     interface{} `json:"example,omitempty"`
+	Generated   interface{} `json:"generated,omitempty"`
+}
+
+// overrideMethods are the HTTP methods which can replace an operation's method.
+var overrideMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+	http.MethodTrace,
+	http.MethodConnect,
+}
+
+// overrideMethod returns the method to use for an operation, which is the
+// operation's own method unless an override is given.
+func overrideMethod(method, override string) (string, error) {
+	if override == "" {
+		return method, nil
+	}
+
+	override = strings.ToUpper(override)
+	for _, m := range overrideMethods {
+		if m == override {
+			if override != method {
+				LogWarning("Sending %s instead of %s, the operation's body and params may not apply", override, method)
+			}
+			return override, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid HTTP method %s, expected one of %s", override, strings.Join(overrideMethods, ", "))
+}
+
+// exampleArg returns a parameter's example or default value for use in a
+// shell command, or a placeholder like `<item-id>` if it has neither.
+func exampleArg(p *Param) string {
+	value, ok := exampleValue(p)
+	if !ok {
+		return "<" + p.OptionName() + ">"
+	}
+
+	if items, ok := value.([]interface{}); ok {
+		// Array flags take comma-separated values.
+		values := []string{}
+		for _, item := range items {
+			values = append(values, fmt.Sprintf("%v", item))
+		}
+		return shellQuote(strings.Join(values, ","))
+	}
+
+	return shellQuote(fmt.Sprintf("%v", value))
+}
+
+// exampleCommands returns ready-to-run commands for this operation, one for
+// each body example or a single one when there are none. Path params and
+// required query & header params are filled in from their example values.
+func (o Operation) exampleCommands(commandPath string) []string {
+	base := commandPath
+	for _, p := range o.PathParams {
+		base += " " + exampleArg(p)
+	}
+
+	for _, params := range [][]*Param{o.QueryParams, o.HeaderParams} {
+		for _, p := range params {
+			if p.Required {
+				base += " --" + p.OptionName() + " " + exampleArg(p)
+			}
+		}
+	}
+
+	if len(o.Examples) == 0 {
+		return []string{base}
+	}
+
+	commands := []string{}
+	for _, ex := range o.Examples {
+		commands = append(commands, base+" "+ex)
+	}
+	return commands
 }
 
 // command returns a Cobra command instance for this operation.
 func (o Operation) command() *cobra.Command {
+	return o.commandWithHandler(MakeRequestAndFormat)
+}
+
+// commandWithHandler returns a Cobra command instance for this operation which
+// builds the request from the arguments & flags, then passes it to `handler`.
+func (o Operation) commandWithHandler(handler func(req *http.Request) error) *cobra.Command {
 	flags := map[string]interface{}{}
 
+	// run builds and sends the request, and is set after the command as it
+	// needs its flags.
+	var run func(cmd *cobra.Command, args []string) error
+
 	use := slug.Make(o.Name)
 	for _, p := range o.PathParams {
 		use += " " + slug.Make(p.Name)
@@ -56,87 +159,154 @@ func (o Operation) command() *cobra.Command {
 		Short:   o.Short,
 		Long:    long,
 		Example: examples,
-		Args:    argSpec,
-		Hidden:  o.Hidden,
-		Run: func(cmd *cobra.Command, args []string) {
-			uri := o.URITemplate
-
-			for i, param := range o.PathParams {
-				value, err := param.Parse(args[i])
-				if err != nil {
-					value := param.Serialize(args[i])[0]
-					log.Fatalf("could not parse param %s with input %s: %v", param.Name, value, err)
+		Args: func(cmd *cobra.Command, args []string) error {
+			example, _ := cmd.Flags().GetBool("rsh-example")
+			interactive, _ := cmd.Flags().GetBool("rsh-interactive")
+			if example || interactive {
+				// Path params aren't needed to print examples or when prompting.
+				return nil
+			}
+			return argSpec(cmd, args)
+		},
+		Hidden: o.Hidden,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if example, _ := cmd.Flags().GetBool("rsh-example"); example {
+				for _, line := range o.exampleCommands(cmd.CommandPath()) {
+					fmt.Fprintln(Stdout, line)
 				}
-				// Replaces URL-encoded `{`+name+`}` in the template.
-				uri = strings.Replace(uri, "{"+param.Name+"}", fmt.Sprintf("%v", value), 1)
+				return nil
 			}
 
-			query := url.Values{}
-			for _, param := range o.QueryParams {
-				if !cmd.Flags().Changed(param.OptionName()) {
-					// This option was not passed from the shell, so there is no need to
-					// send it, even if it is the default or zero value.
-					continue
+			if interactive, _ := cmd.Flags().GetBool("rsh-interactive"); interactive {
+				var command string
+				args, command = o.askRequest(operationAsker, cmd, args)
+
+				// Show the request before sending it, along with how to make it
+				// again without the prompts.
+				err := func() error {
+					inspectRequests = true
+					defer func() {
+						inspectRequests = false
+					}()
+					return run(cmd, args)
+				}()
+				if err != nil {
+					return err
 				}
+				fmt.Fprintf(Stdout, "\nRun this again with:\n  %s\n", command)
 
-				flag := flags[param.Name]
-				for _, v := range param.Serialize(flag) {
-					query.Add(param.Name, v)
+				if !operationAsker.askConfirm("Send the request?", true, "") {
+					return nil
 				}
 			}
-			queryEncoded := query.Encode()
-			if queryEncoded != "" {
-				if strings.Contains(uri, "?") {
-					uri += "&"
-				} else {
-					uri += "?"
-				}
-				uri += queryEncoded
+
+			return run(cmd, args)
+		},
+	}
+
+	run = func(cmd *cobra.Command, args []string) error {
+		uri := o.URITemplate
+
+		for i, param := range o.PathParams {
+			value, err := param.Parse(args[i])
+			if err != nil {
+				return exitError(ExitCodeUsage, err, "could not parse param %s with input %s", param.Name, param.Serialize(args[i])[0])
 			}
+			// Replaces URL-encoded `{`+name+`}` in the template.
+			uri = strings.Replace(uri, "{"+param.Name+"}", fmt.Sprintf("%v", value), 1)
+		}
 
-			customServer := viper.GetString("rsh-server")
-			if customServer != "" {
-				// Adjust the server based on the customized input.
-				orig, _ := url.Parse(uri)
-				custom, _ := url.Parse(customServer)
+		query := url.Values{}
+		for _, param := range o.QueryParams {
+			if !cmd.Flags().Changed(param.OptionName()) {
+				// This option was not passed from the shell, so there is no need to
+				// send it, even if it is the default or zero value.
+				continue
+			}
 
-				orig.Scheme = custom.Scheme
-				orig.Host = custom.Host
+			flag := flags[param.Name]
+			for _, v := range param.Serialize(flag) {
+				query.Add(param.Name, v)
+			}
+		}
+		queryEncoded := query.Encode()
+		if queryEncoded != "" {
+			if strings.Contains(uri, "?") {
+				uri += "&"
+			} else {
+				uri += "?"
+			}
+			uri += queryEncoded
+		}
 
-				if custom.Path != "" && custom.Path != "/" {
-					orig.Path = strings.TrimSuffix(custom.Path, "/") + orig.Path
-				}
+		customServer := viper.GetString("rsh-server")
+		if customServer == "" {
+			// The selected profile may use a different server.
+			_, config := findAPI(uri)
+			uri = profileURI(uri, selectedProfile(config))
+		} else {
+			// Adjust the server based on the customized input.
+			orig, _ := url.Parse(uri)
+			custom, _ := url.Parse(customServer)
+
+			orig.Scheme = custom.Scheme
+			orig.Host = custom.Host
 
-				uri = orig.String()
+			if custom.Path != "" && custom.Path != "/" {
+				orig.Path = strings.TrimSuffix(custom.Path, "/") + orig.Path
 			}
 
-			headers := http.Header{}
-			for _, param := range o.HeaderParams {
-				if !cmd.Flags().Changed(param.OptionName()) {
-					// This option was not passed from the shell, so there is no need to
-					// send it, even if it is the default or zero value.
-					continue
-				}
+			uri = orig.String()
+		}
 
-				for _, v := range param.Serialize(flags[param.Name]) {
-					headers.Add(param.Name, v)
-				}
+		headers := http.Header{}
+		for _, param := range o.HeaderParams {
+			if !cmd.Flags().Changed(param.OptionName()) {
+				// This option was not passed from the shell, so there is no need to
+				// send it, even if it is the default or zero value.
+				continue
 			}
 
-			var body io.Reader
+			for _, v := range param.Serialize(flags[param.Name]) {
+				headers.Add(param.Name, v)
+			}
+		}
 
-			if o.BodyMediaType != "" {
-				b, err := GetBody(o.BodyMediaType, args[len(o.PathParams):])
-				if err != nil {
-					panic(err)
+		var body io.Reader
+
+		if o.BodyMediaType != "" {
+			b, err := GetBody(o.BodyMediaType, args[len(o.PathParams):])
+			if err != nil {
+				return err
+			}
+			body = strings.NewReader(b)
+
+			if headers.Get("Content-Type") == "" {
+				// Send the body with the content type it was encoded as.
+				headers.Set("Content-Type", bodyContentType(o.BodyMediaType, b))
+			}
+
+			if viper.GetBool("rsh-validate") && len(o.BodySchemas) > 0 {
+				// Catch invalid bodies before they are sent to the API.
+				if err := validateBody(o.BodySchemas, headers.Get("Content-Type"), []byte(b)); err != nil {
+					return &ExitError{Code: ExitCodeUsage, Err: err}
 				}
-				body = strings.NewReader(b)
 			}
+		}
 
-			req, _ := http.NewRequest(o.Method, uri, body)
-			req.Header = headers
-			MakeRequestAndFormat(req)
-		},
+		override, _ := cmd.Flags().GetString("rsh-method")
+		method, err := overrideMethod(o.Method, override)
+		if err != nil {
+			return &ExitError{Code: ExitCodeUsage, Err: err}
+		}
+
+		req, err := http.NewRequest(method, uri, body)
+		if err != nil {
+			return exitError(ExitCodeUsage, err, "invalid URI %s", uri)
+		}
+		req.Header = headers
+
+		return handler(WithOperation(req, o.Name))
 	}
 
 	for _, p := range o.QueryParams {
@@ -147,5 +317,9 @@ func (o Operation) command() *cobra.Command {
 		flags[p.Name] = p.AddFlag(sub.Flags())
 	}
 
+	sub.Flags().String("rsh-method", "", "Override the HTTP method, e.g. OPTIONS to test CORS handling")
+	sub.Flags().Bool("rsh-example", false, "Print example commands for this operation instead of sending a request")
+	sub.Flags().BoolP("rsh-interactive", "i", false, "Prompt for params and the request body, then confirm before sending")
+
 	return sub
 }
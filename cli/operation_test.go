@@ -96,7 +96,167 @@ func TestOperation(t *testing.T) {
 	cmd.SetOutput(Stdout)
 	viper.Set("rsh-server", "http://example2.com/prefix")
 	cmd.Flags().Parse([]string{"--search=foo", "--def-3=abc", "--accept=application/json"})
-	cmd.Run(cmd, []string{"id1"})
+	assert.NoError(t, cmd.RunE(cmd, []string{"id1"}))
 
 	assert.Equal(t, "HTTP/1.1 200 OK\nContent-Type: application/json\n\n{\n  hello: \"world\"\n}\n", capture.String())
 }
+
+func TestOperationValidate(t *testing.T) {
+	defer gock.Off()
+
+	gock.
+		New("http://example.com").
+		Post("/items").
+		Reply(201)
+
+	op := Operation{
+		Name:          "create-item",
+		Method:        http.MethodPost,
+		URITemplate:   "http://example.com/items",
+		BodyMediaType: "application/json",
+		BodySchemas: map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"type":     "object",
+				"required": []interface{}{"name"},
+				"properties": map[string]interface{}{
+					"name":  map[string]interface{}{"type": "string"},
+					"count": map[string]interface{}{"type": "integer", "minimum": 0},
+				},
+			},
+		},
+	}
+
+	reset(false)
+	viper.Set("rsh-validate", true)
+	capture := &strings.Builder{}
+	Stdout = capture
+	Stderr = capture
+
+	// Invalid bodies are not sent.
+	cmd := op.command()
+	err := cmd.RunE(cmd, []string{"count: -1"})
+	assert.Equal(t, ExitCodeUsage, exitCodeFor(err))
+	assert.Contains(t, err.Error(), "property \"name\" is missing")
+	assert.Contains(t, err.Error(), "/count: number must be at least 0")
+	assert.True(t, gock.IsPending())
+
+	// Valid bodies are sent as usual.
+	reset(false)
+	viper.Set("rsh-validate", true)
+	cmd = op.command()
+	assert.NoError(t, cmd.RunE(cmd, []string{"name: foo, count: 1"}))
+	assert.Equal(t, 0, GetExitCode())
+	assert.True(t, gock.IsDone())
+}
+
+func TestOperationMethodOverride(t *testing.T) {
+	defer gock.Off()
+
+	mock := gock.New("http://example.com").Path("/items")
+	mock.Method = http.MethodOptions
+	mock.Reply(204).SetHeader("Allow", "GET, OPTIONS")
+
+	op := Operation{
+		Name:        "list-items",
+		Method:      http.MethodGet,
+		URITemplate: "http://example.com/items",
+	}
+
+	reset(false)
+	capture := &strings.Builder{}
+	Stdout = capture
+	Stderr = capture
+
+	cmd := op.command()
+	cmd.Flags().Parse([]string{"--rsh-method=options"})
+	assert.NoError(t, cmd.RunE(cmd, []string{}))
+	assert.True(t, gock.IsDone())
+	assert.Contains(t, capture.String(), "Sending OPTIONS instead of GET")
+	assert.Contains(t, capture.String(), "Allow: GET, OPTIONS")
+
+	cmd = op.command()
+	cmd.Flags().Parse([]string{"--rsh-method=bad"})
+	err := cmd.RunE(cmd, []string{})
+	assert.EqualError(t, err, "invalid HTTP method BAD, expected one of GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS, TRACE, CONNECT")
+	assert.Equal(t, ExitCodeUsage, exitCodeFor(err))
+}
+
+func TestOperationProfileBase(t *testing.T) {
+	defer gock.Off()
+
+	reset(false)
+	configs["profile-base"] = &APIConfig{
+		name: "profile-base",
+		Base: "https://profile-base.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {Base: "https://local.example.com"},
+			"staging": {
+				Base:    "https://staging.example.com/v1/",
+				Headers: map[string]string{"X-Env": "staging"},
+			},
+		},
+	}
+
+	op := Operation{
+		Name:        "list-items",
+		Method:      http.MethodGet,
+		URITemplate: "https://profile-base.example.com/items",
+	}
+
+	capture := &strings.Builder{}
+	Stdout = capture
+	Stderr = capture
+
+	// The profile's base is used along with its headers.
+	gock.New("https://staging.example.com").Get("/v1/items").MatchHeader("X-Env", "staging").Reply(http.StatusOK)
+	viper.Set("rsh-profile", "staging")
+	cmd := op.command()
+	assert.NoError(t, cmd.RunE(cmd, []string{}))
+	assert.True(t, gock.IsDone())
+	assert.Equal(t, "https://profile-base.example.com/items", profileURI("https://staging.example.com/v1/items", "missing"))
+
+	// A custom server still takes precedence.
+	gock.New("https://custom.example.com").Get("/items").Reply(http.StatusOK)
+	viper.Set("rsh-profile", "default")
+	viper.Set("rsh-server", "https://custom.example.com")
+	cmd = op.command()
+	assert.NoError(t, cmd.RunE(cmd, []string{}))
+	assert.True(t, gock.IsDone())
+
+	assert.Equal(t, "https://local.example.com/items", fixAddress("profile-base/items"))
+}
+
+func TestOperationExample(t *testing.T) {
+	op := Operation{
+		Name:        "get-user",
+		Method:      http.MethodPut,
+		URITemplate: "http://example.com/orgs/{org}/users/{user-id}",
+		PathParams: []*Param{
+			{Type: "string", Name: "org", Example: "my org"},
+			{Type: "integer", Name: "user-id"},
+		},
+		QueryParams: []*Param{
+			{Type: "array[string]", Name: "fields", Required: true, Example: []interface{}{"id", "name"}},
+			{Type: "integer", Name: "limit", Default: 10},
+		},
+		HeaderParams: []*Param{
+			{Type: "string", Name: "X-Request-ID", Required: true},
+		},
+		BodyMediaType: "application/json",
+		Examples:      []string{"name: Kari", "<input.json"},
+	}
+
+	reset(false)
+	capture := &strings.Builder{}
+	Stdout = capture
+
+	cmd := op.command()
+	cmd.SetArgs([]string{"--rsh-example"})
+	assert.NoError(t, cmd.Execute())
+	assert.Equal(t, "get-user 'my org' <user-id> --fields id,name --x-request-id <x-request-id> name: Kari\nget-user 'my org' <user-id> --fields id,name --x-request-id <x-request-id> <input.json\n", capture.String())
+
+	// Without body examples a single command is shown.
+	op.Examples = nil
+	op.PathParams[1].Default = 5
+	assert.Equal(t, []string{"restish my-api get-user 'my org' 5 --fields id,name --x-request-id <x-request-id>"}, op.exampleCommands("restish my-api get-user"))
+}
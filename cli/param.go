@@ -26,14 +26,16 @@ func typeConvert(from, to interface{}) interface{} {
 
 // Param represents an API operation input parameter.
 type Param struct {
-	Type        string      `json:"type"`
-	Name        string      `json:"name"`
-	DisplayName string      `json:"displayName,omitempty"`
-	Description string      `json:"description,omitempty"`
-	Style       Style       `json:"style,omitempty"`
-	Explode     bool        `json:"explode,omitempty"`
-	Default     interface{} `json:"default,omitempty"`
-	Example     interface{} `json:"example,omitempty"`
+	Type        string        `json:"type"`
+	Name        string        `json:"name"`
+	DisplayName string        `json:"displayName,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Style       Style         `json:"style,omitempty"`
+	Explode     bool          `json:"explode,omitempty"`
+	Default     interface{}   `json:"default,omitempty"`
+	Example     interface{}   `json:"example,omitempty"`
+	Enum        []interface{} `json:"enum,omitempty"`
 }
 
 // Parse the parameter from a string input (e.g. command line argument)
@@ -0,0 +1,282 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexeyco/simpletable"
+	"github.com/danielgtaylor/shorthand"
+	"github.com/spf13/cobra"
+)
+
+// perfResult contains the response times for all runs of a single operation.
+// Operations which could not be run have `Skipped` set to the reason why.
+type perfResult struct {
+	Name      string
+	Durations []time.Duration
+	Errors    int
+	Skipped   string
+}
+
+// percentile returns the nearest-rank percentile of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// isWriteMethod returns whether an HTTP method may modify server state.
+func isWriteMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// hasTag returns whether the operation is part of the given tag.
+func hasTag(op Operation, tag string) bool {
+	for _, t := range op.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// exampleValue returns an example value for a parameter, falling back to its
+// default value.
+func exampleValue(p *Param) (interface{}, bool) {
+	if p.Example != nil {
+		return p.Example, true
+	}
+	if p.Default != nil {
+		return p.Default, true
+	}
+	return nil, false
+}
+
+// perfRequest builds a request for an operation using the example values from
+// the API description. Optional parameters are only sent when they have an
+// example, while a missing example for a required value is an error.
+func perfRequest(op Operation) (*http.Request, error) {
+	uri := op.URITemplate
+	for _, p := range op.PathParams {
+		value, ok := exampleValue(p)
+		if !ok {
+			return nil, fmt.Errorf("no example for path param %s", p.Name)
+		}
+		uri = strings.Replace(uri, "{"+p.Name+"}", url.PathEscape(fmt.Sprintf("%v", value)), 1)
+	}
+
+	query := url.Values{}
+	for _, p := range op.QueryParams {
+		value, ok := exampleValue(p)
+		if !ok {
+			if p.Required {
+				return nil, fmt.Errorf("no example for query param %s", p.Name)
+			}
+			continue
+		}
+		for _, v := range p.Serialize(value) {
+			query.Add(p.Name, v)
+		}
+	}
+	if encoded := query.Encode(); encoded != "" {
+		if strings.Contains(uri, "?") {
+			uri += "&"
+		} else {
+			uri += "?"
+		}
+		uri += encoded
+	}
+
+	headers := http.Header{}
+	for _, p := range op.HeaderParams {
+		value, ok := exampleValue(p)
+		if !ok {
+			if p.Required {
+				return nil, fmt.Errorf("no example for header %s", p.Name)
+			}
+			continue
+		}
+		for _, v := range p.Serialize(value) {
+			headers.Add(p.Name, v)
+		}
+	}
+
+	var body io.Reader
+	if op.BodyMediaType != "" {
+		if len(op.Examples) == 0 || strings.HasPrefix(op.Examples[0], "<") {
+			return nil, fmt.Errorf("no example for request body")
+		}
+
+		if !strings.Contains(op.BodyMediaType, "json") {
+			return nil, fmt.Errorf("unsupported request body type %s", op.BodyMediaType)
+		}
+
+		input, err := shorthand.ParseAndBuild("example", op.Examples[0])
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := json.Marshal(input)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(string(b))
+		headers.Set("Content-Type", op.BodyMediaType)
+	}
+
+	req, err := http.NewRequest(op.Method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = headers
+
+	return req, nil
+}
+
+// runPerf runs each matching operation `runs` times and measures the response
+// times. Requests bypass the HTTP cache so each one hits the server. Failed
+// requests and error responses count toward the error rate.
+func runPerf(ops []Operation, tag string, runs int, includeWrite bool) []perfResult {
+	results := []perfResult{}
+	client := &http.Client{}
+
+	for _, op := range ops {
+		if tag != "" && !hasTag(op, tag) {
+			continue
+		}
+
+		result := perfResult{Name: op.Name}
+
+		if isWriteMethod(op.Method) && !includeWrite {
+			result.Skipped = op.Method + " is a write method"
+			results = append(results, result)
+			continue
+		}
+
+		for i := 0; i < runs; i++ {
+			req, err := perfRequest(op)
+			if err != nil {
+				result.Skipped = err.Error()
+				break
+			}
+
+			start := time.Now()
+			resp, err := MakeRequest(WithOperation(req, op.Name), WithClient(client), WithoutLog())
+			if err == nil {
+				_, err = io.Copy(ioutil.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			result.Durations = append(result.Durations, time.Since(start))
+
+			if err != nil || resp.StatusCode >= 400 {
+				result.Errors++
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// formatDuration formats a duration as milliseconds for the results table.
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
+
+// perfTable renders perf results as a table, followed by a list of any
+// skipped operations.
+func perfTable(results []perfResult) string {
+	table := simpletable.New()
+	table.Header = &simpletable.Header{}
+	for _, title := range []string{"Operation", "p50", "p95", "p99", "Min", "Max", "Errors"} {
+		table.Header.Cells = append(table.Header.Cells, &simpletable.Cell{Align: simpletable.AlignCenter, Text: title})
+	}
+
+	skipped := []string{}
+	for _, r := range results {
+		if r.Skipped != "" {
+			skipped = append(skipped, fmt.Sprintf("  %s: %s", r.Name, r.Skipped))
+			continue
+		}
+
+		sorted := append([]time.Duration{}, r.Durations...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		cells := []*simpletable.Cell{{Text: r.Name}}
+		for _, d := range []time.Duration{percentile(sorted, 50), percentile(sorted, 95), percentile(sorted, 99), sorted[0], sorted[len(sorted)-1]} {
+			cells = append(cells, &simpletable.Cell{Align: simpletable.AlignRight, Text: formatDuration(d)})
+		}
+		cells = append(cells, &simpletable.Cell{Align: simpletable.AlignRight, Text: fmt.Sprintf("%.1f%%", 100*float64(r.Errors)/float64(len(r.Durations)))})
+		table.Body.Cells = append(table.Body.Cells, cells)
+	}
+
+	out := ""
+	if len(table.Body.Cells) > 0 {
+		table.SetStyle(simpletable.StyleCompactLite)
+		out = table.String() + "\n"
+	}
+
+	if len(skipped) > 0 {
+		out += "\nSkipped:\n" + strings.Join(skipped, "\n") + "\n"
+	}
+
+	return out
+}
+
+func perfCommand() *cobra.Command {
+	var tag *string
+	var runs *int
+	var includeWrite *bool
+
+	cmd := &cobra.Command{
+		Use:   "perf short-name",
+		Short: "Profile API response times",
+		Long:  "Call each operation of an API using example values from its API description and report response time percentiles and the error rate for each. Operations which use write methods like POST or DELETE are skipped unless `--rsh-include-write` is passed.",
+		Example: fmt.Sprintf(`  # Call each operation tagged with 'users' 20 times
+  $ %s perf my-api --rsh-tag users --rsh-runs 20`, Root.CommandPath()),
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeAPINames,
+		Run: func(cmd *cobra.Command, args []string) {
+			config := configs[args[0]]
+			if config == nil {
+				panic(fmt.Errorf("API %s not found", args[0]))
+			}
+
+			if *runs < 1 {
+				panic(fmt.Errorf("runs must be at least 1"))
+			}
+
+			api, err := Load(config.Base, &cobra.Command{})
+			if err != nil {
+				panic(err)
+			}
+
+			fmt.Fprint(Stdout, perfTable(runPerf(api.Operations, *tag, *runs, *includeWrite)))
+		},
+	}
+
+	tag = cmd.Flags().String("rsh-tag", "", "Only run operations with this tag")
+	runs = cmd.Flags().Int("rsh-runs", 10, "Number of times to call each operation")
+	includeWrite = cmd.Flags().Bool("rsh-include-write", false, "Include operations using write methods like POST, PUT, or DELETE")
+
+	return cmd
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{}
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+
+	assert.Equal(t, 50*time.Millisecond, percentile(sorted, 50))
+	assert.Equal(t, 95*time.Millisecond, percentile(sorted, 95))
+	assert.Equal(t, 99*time.Millisecond, percentile(sorted, 99))
+	assert.Equal(t, time.Duration(0), percentile(nil, 50))
+}
+
+func TestPerfRequest(t *testing.T) {
+	req, err := perfRequest(Operation{
+		Method:      "GET",
+		URITemplate: "https://example.com/items/{item-id}",
+		PathParams:  []*Param{{Type: "string", Name: "item-id", Example: "abc"}},
+		QueryParams: []*Param{
+			{Type: "integer", Name: "limit", Default: 5},
+			{Type: "string", Name: "cursor"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/items/abc?limit=5", req.URL.String())
+
+	_, err = perfRequest(Operation{
+		Method:      "GET",
+		URITemplate: "https://example.com/items/{item-id}",
+		PathParams:  []*Param{{Type: "string", Name: "item-id"}},
+	})
+	assert.Error(t, err)
+}
+
+func TestPerf(t *testing.T) {
+	reset(false)
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/items").Times(3).Reply(200).BodyString("[]")
+	gock.New("http://example.com").Get("/users").Times(2).Reply(500)
+	gock.New("http://example.com").Get("/users").Reply(200)
+
+	ops := []Operation{
+		{Name: "list-items", Method: "GET", URITemplate: "http://example.com/items", Tags: []string{"items"}},
+		{Name: "list-users", Method: "GET", URITemplate: "http://example.com/users", Tags: []string{"users"}},
+		{Name: "create-item", Method: "POST", URITemplate: "http://example.com/items", Tags: []string{"items"}},
+	}
+
+	results := runPerf(ops, "", 3, false)
+	assert.Len(t, results, 3)
+	assert.Len(t, results[0].Durations, 3)
+	assert.Equal(t, 0, results[0].Errors)
+	assert.Equal(t, 2, results[1].Errors)
+	assert.NotEmpty(t, results[2].Skipped)
+	assert.True(t, gock.IsDone())
+
+	out := perfTable(results)
+	assert.Contains(t, out, "list-items")
+	assert.Contains(t, out, "66.7%")
+	assert.Contains(t, out, "create-item: POST is a write method")
+
+	// Tags filter the operations which are run.
+	results = runPerf(ops, "users", 1, false)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "list-users", results[0].Name)
+}
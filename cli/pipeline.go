@@ -0,0 +1,393 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	jmespath "github.com/danielgtaylor/go-jmespath-plus"
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+)
+
+// pipelineRequest describes a request made by a pipeline step. The URL,
+// header values, and body may contain `${expr}` templates.
+type pipelineRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+}
+
+// pipelineForeach runs nested steps once for each item in a list, which is
+// available to the nested steps via the `as` variable (default `item`).
+type pipelineForeach struct {
+	List string         `json:"list"`
+	As   string         `json:"as,omitempty"`
+	Do   []pipelineStep `json:"do"`
+}
+
+// pipelinePair is a single `key: value` pair in a list, which unlike a map
+// keeps the order the pairs were written in.
+type pipelinePair struct {
+	Key   string
+	Value interface{}
+}
+
+func (p *pipelinePair) UnmarshalJSON(b []byte) error {
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil || len(m) != 1 {
+		return fmt.Errorf("expected a single `key: value` pair but got %s", b)
+	}
+
+	for k, v := range m {
+		p.Key, p.Value = k, v
+	}
+	return nil
+}
+
+// pipelineStep is a single step of a pipeline. A step makes its request
+// first, if any, and then captures variables, checks assertions, and finally
+// runs any `foreach` loop against the latest response. Captures and
+// assertions run in order, so later captures may use earlier ones.
+type pipelineStep struct {
+	Name    string           `json:"name,omitempty"`
+	Request *pipelineRequest `json:"request,omitempty"`
+	Capture []pipelinePair   `json:"capture,omitempty"`
+	Assert  []pipelinePair   `json:"assert,omitempty"`
+	Foreach *pipelineForeach `json:"foreach,omitempty"`
+}
+
+// pipeline is a declarative multi-step API workflow.
+type pipeline struct {
+	Steps []pipelineStep `json:"steps"`
+}
+
+// pipelineTemplateRegex finds `${expr}` templates in pipeline strings.
+var pipelineTemplateRegex = regexp.MustCompile(`\$\{(.+?)\}`)
+
+// pipelineRunner runs pipeline steps, keeping track of captured variables,
+// the latest response, and the results so far.
+type pipelineRunner struct {
+	vars   map[string]interface{}
+	last   Response
+	passed int
+	failed int
+	out    io.Writer
+}
+
+func newPipelineRunner(out io.Writer) *pipelineRunner {
+	return &pipelineRunner{
+		vars: map[string]interface{}{},
+		out:  out,
+	}
+}
+
+// context returns the document used for pipeline expressions, which is the
+// latest response plus the captured variables under `vars`.
+func (r *pipelineRunner) context() interface{} {
+	data := r.last.Map()
+	data["vars"] = r.vars
+	return makeJSONSafe(data, true)
+}
+
+// search evaluates a JMESPath expression. Captured variables may be used
+// directly by name, e.g. `user.id`, or via `vars.user.id`.
+func (r *pipelineRunner) search(expr string) (interface{}, error) {
+	name := strings.SplitN(strings.SplitN(expr, ".", 2)[0], "[", 2)[0]
+	if _, ok := r.vars[name]; ok {
+		expr = "vars." + expr
+	}
+
+	return jmespath.Search(expr, r.context())
+}
+
+// expand replaces templates in a value. A string which consists of a single
+// template is replaced by the raw value, keeping its type, while templates
+// within a larger string are replaced by their string representation.
+func (r *pipelineRunner) expand(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if m := pipelineTemplateRegex.FindStringSubmatch(v); m != nil && m[0] == v {
+			return r.search(m[1])
+		}
+
+		var err error
+		expanded := pipelineTemplateRegex.ReplaceAllStringFunc(v, func(match string) string {
+			result, searchErr := r.search(match[2 : len(match)-1])
+			if searchErr != nil {
+				err = searchErr
+				return match
+			}
+			if result == nil {
+				return ""
+			}
+			if s, ok := result.(string); ok {
+				return s
+			}
+			b, _ := json.Marshal(result)
+			return string(b)
+		})
+		return expanded, err
+	case []interface{}:
+		expanded := make([]interface{}, len(v))
+		for i, item := range v {
+			e, err := r.expand(item)
+			if err != nil {
+				return nil, err
+			}
+			expanded[i] = e
+		}
+		return expanded, nil
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			e, err := r.expand(item)
+			if err != nil {
+				return nil, err
+			}
+			expanded[k] = e
+		}
+		return expanded, nil
+	}
+
+	return value, nil
+}
+
+func (r *pipelineRunner) expandString(s string) (string, error) {
+	expanded, err := r.expand(s)
+	if err != nil {
+		return "", err
+	}
+	if str, ok := expanded.(string); ok {
+		return str, nil
+	}
+	b, err := json.Marshal(expanded)
+	return string(b), err
+}
+
+// request makes the request for a step and stores the parsed response.
+func (r *pipelineRunner) request(pr *pipelineRequest) error {
+	method := strings.ToUpper(pr.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	uri, err := r.expandString(pr.URL)
+	if err != nil {
+		return err
+	}
+
+	var body io.Reader
+	if pr.Body != nil {
+		expanded, err := r.expand(pr.Body)
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(expanded)
+		if err != nil {
+			return err
+		}
+		body = strings.NewReader(string(b))
+	}
+
+	req, err := http.NewRequest(method, fixAddress(uri), body)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range pr.Headers {
+		expanded, err := r.expandString(value)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(name, expanded)
+	}
+
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := GetParsedResponse(req)
+	if err != nil {
+		return err
+	}
+
+	r.last = resp
+	return nil
+}
+
+// check compares the result of each assertion expression with its expected
+// value, returning an error describing the first mismatch.
+func (r *pipelineRunner) check(assertions []pipelinePair) error {
+	for _, assertion := range assertions {
+		expr := assertion.Key
+		actual, err := r.search(expr)
+		if err != nil {
+			return err
+		}
+
+		expected, err := r.expand(assertion.Value)
+		if err != nil {
+			return err
+		}
+
+		if !reflect.DeepEqual(makeJSONSafe(actual, true), makeJSONSafe(expected, true)) {
+			a, _ := json.Marshal(actual)
+			e, _ := json.Marshal(expected)
+			return fmt.Errorf("assertion failed: %s is %s, expected %s", expr, a, e)
+		}
+	}
+
+	return nil
+}
+
+// runStep runs a single step, printing its progress indented to `depth`.
+func (r *pipelineRunner) runStep(step pipelineStep, depth int) error {
+	name := step.Name
+	if name == "" && step.Request != nil {
+		method := strings.ToUpper(step.Request.Method)
+		if method == "" {
+			method = http.MethodGet
+		}
+		name = method + " " + step.Request.URL
+	}
+	if expanded, err := r.expandString(name); err == nil {
+		name = expanded
+	}
+	indent := strings.Repeat("  ", depth)
+
+	err := func() error {
+		if step.Request != nil {
+			if err := r.request(step.Request); err != nil {
+				return err
+			}
+		}
+
+		for _, capture := range step.Capture {
+			expr, ok := capture.Value.(string)
+			if !ok {
+				return fmt.Errorf("capture %s must be an expression", capture.Key)
+			}
+
+			value, err := r.search(expr)
+			if err != nil {
+				return err
+			}
+			LogDebug("Captured %s = %v", capture.Key, value)
+			r.vars[capture.Key] = value
+		}
+
+		return r.check(step.Assert)
+	}()
+
+	if err != nil {
+		r.failed++
+		fmt.Fprintf(r.out, "%s✘ %s: %v\n", indent, name, err)
+		return err
+	}
+
+	r.passed++
+	if step.Request != nil {
+		fmt.Fprintf(r.out, "%s✔ %s (%d)\n", indent, name, r.last.Status)
+	} else if name != "" {
+		fmt.Fprintf(r.out, "%s✔ %s\n", indent, name)
+	}
+
+	if step.Foreach != nil {
+		return r.foreach(step.Foreach, depth+1)
+	}
+
+	return nil
+}
+
+// foreach runs the nested steps for each item in the list.
+func (r *pipelineRunner) foreach(loop *pipelineForeach, depth int) error {
+	result, err := r.search(loop.List)
+	if err != nil {
+		return err
+	}
+
+	items, ok := result.([]interface{})
+	if !ok {
+		err := fmt.Errorf("foreach list %s is not a list", loop.List)
+		r.failed++
+		fmt.Fprintf(r.out, "%s✘ %v\n", strings.Repeat("  ", depth), err)
+		return err
+	}
+
+	as := loop.As
+	if as == "" {
+		as = "item"
+	}
+
+	for _, item := range items {
+		r.vars[as] = item
+		if err := r.run(loop.Do, depth); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// run runs steps in order, stopping at the first failure.
+func (r *pipelineRunner) run(steps []pipelineStep, depth int) error {
+	for _, step := range steps {
+		if err := r.runStep(step, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPipeline reads and parses a pipeline file.
+func loadPipeline(filename string) (*pipeline, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pipeline{}
+	if err := yaml.Unmarshal(b, p); err != nil {
+		return nil, err
+	}
+
+	if len(p.Steps) == 0 {
+		return nil, errors.New("pipeline has no steps")
+	}
+
+	return p, nil
+}
+
+func pipelineCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "pipeline filename",
+		Short: "Run a multi-step API workflow",
+		Long:  "Run the steps from a pipeline file in order. Steps can make requests, capture values from responses into variables, assert on response values, and loop over lists. Captured variables can be used in later URLs, headers, and bodies via `${name}` templates. The pipeline stops at the first failure and exits with a non-zero code.",
+		Example: fmt.Sprintf(`  # Run a pipeline
+  $ %s pipeline signup.pipeline.yaml`, Root.CommandPath()),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			p, err := loadPipeline(args[0])
+			if err != nil {
+				panic(err)
+			}
+
+			runner := newPipelineRunner(Stdout)
+			err = runner.run(p.Steps, 0)
+
+			fmt.Fprintf(Stdout, "\n%d passed, %d failed\n", runner.passed, runner.failed)
+
+			if err != nil {
+				exitCode = 1
+			}
+		},
+	}
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const testPipeline = `
+steps:
+  - name: Create user
+    request:
+      method: post
+      url: http://example.com/users
+      body:
+        name: Alice
+    capture:
+      - user: body
+      - id: user.id
+    assert:
+      - status: 201
+  - request:
+      url: http://example.com/users/${id}
+      headers:
+        X-User: ${user.name}
+    assert:
+      - body.tags: [a, b]
+    foreach:
+      list: body.tags
+      as: tag
+      do:
+        - name: Get tag ${tag}
+          request:
+            url: http://example.com/tags/${tag}
+          assert:
+            - status: 200
+            - body.count: 1
+            - body.name: ${tag}
+`
+
+func TestPipeline(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Post("/users").JSON(map[string]interface{}{"name": "Alice"}).Reply(201).JSON(map[string]interface{}{"id": 5, "name": "Alice"})
+	gock.New("http://example.com").Get("/users/5").MatchHeader("X-User", "Alice").Reply(200).JSON(map[string]interface{}{"tags": []string{"a", "b"}})
+	gock.New("http://example.com").Get("/tags/a").Reply(200).JSON(map[string]interface{}{"count": 1, "name": "a"})
+	gock.New("http://example.com").Get("/tags/b").Reply(200).JSON(map[string]interface{}{"count": 2, "name": "b"})
+
+	filename := filepath.Join(t.TempDir(), "test.pipeline.yaml")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(testPipeline), 0600))
+
+	out := run("pipeline " + filename)
+	assert.Contains(t, out, "✔ Create user (201)\n✔ GET http://example.com/users/5 (200)\n")
+	assert.Contains(t, out, "  ✔ Get tag a (200)\n")
+	assert.Contains(t, out, "  ✘ Get tag b: assertion failed: body.count is 2, expected 1\n")
+	assert.Contains(t, out, "3 passed, 1 failed")
+	assert.Equal(t, 1, GetExitCode())
+	assert.True(t, gock.IsDone())
+}
+
+func TestPipelinePairs(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.pipeline.yaml")
+
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("steps:\n  - capture:\n      - a: body\n      - b: body.id\n"), 0600))
+	p, err := loadPipeline(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, []pipelinePair{{Key: "a", Value: "body"}, {Key: "b", Value: "body.id"}}, p.Steps[0].Capture)
+
+	// Each list item must be a single pair.
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("steps:\n  - capture:\n      - a: body\n        b: body.id\n"), 0600))
+	_, err = loadPipeline(filename)
+	assert.Error(t, err)
+
+	// Assertions are checked in order.
+	r := newPipelineRunner(ioutil.Discard)
+	r.vars["x"] = 1.0
+	err = r.check([]pipelinePair{{Key: "x", Value: 2.0}, {Key: "vars.x", Value: 3.0}})
+	assert.EqualError(t, err, "assertion failed: x is 1, expected 2")
+}
+
+func TestPipelineExpand(t *testing.T) {
+	r := newPipelineRunner(ioutil.Discard)
+	r.vars["user"] = map[string]interface{}{"id": 5.0, "tags": []interface{}{"a"}}
+
+	// Whole-string templates keep their type.
+	v, err := r.expand(map[string]interface{}{
+		"id":   "${user.id}",
+		"path": "/users/${user.id}/tags/${user.tags[0]}",
+		"list": []interface{}{"${vars.user.tags}"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"id":   5.0,
+		"path": "/users/5/tags/a",
+		"list": []interface{}{[]interface{}{"a"}},
+	}, v)
+}
@@ -0,0 +1,358 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alexeyco/simpletable"
+	"golang.org/x/net/html"
+)
+
+// PrettyPrinter reformats response bodies of some content type for display
+// when using the default `auto` output format, e.g. to indent markup.
+type PrettyPrinter interface {
+	Detect(contentType string) bool
+	Pretty(data []byte) ([]byte, error)
+}
+
+type prettyPrinterEntry struct {
+	lexer   string
+	printer PrettyPrinter
+}
+
+// prettyPrinters is a list of registered pretty printers.
+var prettyPrinters = []prettyPrinterEntry{}
+
+// AddPrettyPrinter adds a new pretty printer. The output is highlighted with
+// the given lexer name when writing to a terminal, or not at all if the lexer
+// is empty.
+func AddPrettyPrinter(lexer string, printer PrettyPrinter) {
+	prettyPrinters = append(prettyPrinters, prettyPrinterEntry{
+		lexer:   lexer,
+		printer: printer,
+	})
+}
+
+// prettyPrint reformats the data using the first pretty printer which can
+// handle the content type. Returns false if there is no such printer or it
+// fails, in which case the data should be displayed as usual.
+func prettyPrint(contentType string, data []byte) ([]byte, string, bool) {
+	for _, entry := range prettyPrinters {
+		if !entry.printer.Detect(contentType) {
+			continue
+		}
+
+		pretty, err := entry.printer.Pretty(data)
+		if err != nil {
+			LogDebug("Unable to pretty print %s: %v", contentType, err)
+			return nil, "", false
+		}
+
+		return pretty, entry.lexer, true
+	}
+
+	return nil, "", false
+}
+
+// mediaType returns the content type without any parameters.
+func mediaType(contentType string) string {
+	return strings.TrimSpace(strings.Split(contentType, ";")[0])
+}
+
+// XMLPrinter indents XML documents.
+type XMLPrinter struct{}
+
+// Detect if the content type is XML.
+func (p XMLPrinter) Detect(contentType string) bool {
+	return XML{}.Detect(mediaType(contentType))
+}
+
+// xmlRawName returns a name including its namespace prefix as written in the
+// original document.
+func xmlRawName(name xml.Name) string {
+	if name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+var (
+	xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+// Pretty indents the XML so that each element is on its own line, keeping
+// namespace prefixes, comments, and directives as-is while dropping
+// whitespace between elements. Elements containing only text are kept on a
+// single line.
+func (p XMLPrinter) Pretty(data []byte) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = true
+
+	// Raw tokens don't resolve namespaces, so prefixes are kept as written, but
+	// they also don't check that elements are balanced so track that here.
+	tokens := []xml.Token{}
+	open := []string{}
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			open = append(open, xmlRawName(t.Name))
+		case xml.EndElement:
+			if len(open) == 0 || open[len(open)-1] != xmlRawName(t.Name) {
+				return nil, fmt.Errorf("unexpected end element </%s>", xmlRawName(t.Name))
+			}
+			open = open[:len(open)-1]
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) == 0 {
+				continue
+			}
+		}
+
+		tokens = append(tokens, xml.CopyToken(token))
+	}
+
+	if len(open) > 0 {
+		return nil, fmt.Errorf("element <%s> is not closed", open[len(open)-1])
+	}
+
+	sb := &strings.Builder{}
+	depth := 0
+	line := func(s string) {
+		sb.WriteString(strings.Repeat("  ", depth))
+		sb.WriteString(s)
+		sb.WriteByte('\n')
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		switch t := tokens[i].(type) {
+		case xml.StartElement:
+			tag := "<" + xmlRawName(t.Name)
+			for _, attr := range t.Attr {
+				tag += " " + xmlRawName(attr.Name) + `="` + xmlAttrEscaper.Replace(attr.Value) + `"`
+			}
+
+			if i+1 < len(tokens) {
+				if _, ok := tokens[i+1].(xml.EndElement); ok {
+					line(tag + "/>")
+					i++
+					continue
+				}
+			}
+
+			if i+2 < len(tokens) {
+				text, isText := tokens[i+1].(xml.CharData)
+				_, isEnd := tokens[i+2].(xml.EndElement)
+				if isText && isEnd {
+					line(tag + ">" + xmlTextEscaper.Replace(string(bytes.TrimSpace(text))) + "</" + xmlRawName(t.Name) + ">")
+					i += 2
+					continue
+				}
+			}
+
+			line(tag + ">")
+			depth++
+		case xml.EndElement:
+			depth--
+			line("</" + xmlRawName(t.Name) + ">")
+		case xml.CharData:
+			line(xmlTextEscaper.Replace(string(bytes.TrimSpace(t))))
+		case xml.Comment:
+			line("<!--" + string(t) + "-->")
+		case xml.ProcInst:
+			line("<?" + t.Target + " " + string(t.Inst) + "?>")
+		case xml.Directive:
+			line("<!" + string(t) + ">")
+		}
+	}
+
+	if sb.Len() == 0 {
+		return nil, errors.New("no XML content found")
+	}
+
+	return []byte(sb.String()), nil
+}
+
+// htmlVoidElements never have content or an end tag.
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlPreformatted elements have content where whitespace is significant.
+var htmlPreformatted = map[string]bool{
+	"pre": true, "script": true, "style": true, "textarea": true,
+}
+
+// HTMLPrinter reflows HTML documents so that each element is on its own line
+// and indented by its depth.
+type HTMLPrinter struct{}
+
+// Detect if the content type is HTML.
+func (p HTMLPrinter) Detect(contentType string) bool {
+	ct := mediaType(contentType)
+	return ct == "text/html" || ct == "application/xhtml+xml"
+}
+
+// htmlToken is a token along with its original source.
+type htmlToken struct {
+	html.Token
+	raw string
+}
+
+// Pretty reflows the HTML. Whitespace in text is collapsed, except within
+// preformatted elements like `<pre>` and `<script>` which are left as-is.
+// Elements containing only text are kept on a single line.
+func (p HTMLPrinter) Pretty(data []byte) ([]byte, error) {
+	tokens := []htmlToken{}
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			if err := tokenizer.Err(); err != io.EOF {
+				return nil, err
+			}
+			break
+		}
+		raw := string(tokenizer.Raw())
+		tokens = append(tokens, htmlToken{Token: tokenizer.Token(), raw: raw})
+	}
+
+	sb := &strings.Builder{}
+	depth := 0
+	line := func(s string) {
+		sb.WriteString(strings.Repeat("  ", depth))
+		sb.WriteString(s)
+		sb.WriteByte('\n')
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.Type {
+		case html.StartTagToken:
+			if htmlVoidElements[t.Data] {
+				line(t.raw)
+				continue
+			}
+
+			if htmlPreformatted[t.Data] {
+				// Copy everything up to the end tag verbatim.
+				s := t.raw
+				for i+1 < len(tokens) {
+					i++
+					s += tokens[i].raw
+					if tokens[i].Type == html.EndTagToken && tokens[i].Data == t.Data {
+						break
+					}
+				}
+				line(s)
+				continue
+			}
+
+			if i+1 < len(tokens) && tokens[i+1].Type == html.EndTagToken && tokens[i+1].Data == t.Data {
+				line(t.raw + tokens[i+1].raw)
+				i++
+				continue
+			}
+
+			if i+2 < len(tokens) && tokens[i+1].Type == html.TextToken && tokens[i+2].Type == html.EndTagToken && tokens[i+2].Data == t.Data {
+				line(t.raw + htmlCollapse(tokens[i+1].raw) + tokens[i+2].raw)
+				i += 2
+				continue
+			}
+
+			line(t.raw)
+			depth++
+		case html.EndTagToken:
+			if depth > 0 {
+				depth--
+			}
+			line(t.raw)
+		case html.TextToken:
+			if text := htmlCollapse(t.raw); text != "" {
+				line(text)
+			}
+		default:
+			line(t.raw)
+		}
+	}
+
+	if sb.Len() == 0 {
+		return nil, errors.New("no HTML content found")
+	}
+
+	return []byte(sb.String()), nil
+}
+
+// htmlCollapse collapses runs of whitespace in text into single spaces.
+func htmlCollapse(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// CSVPrinter aligns the columns of CSV documents into a table, using the
+// first row as the header.
+type CSVPrinter struct{}
+
+// Detect if the content type is CSV.
+func (p CSVPrinter) Detect(contentType string) bool {
+	return CSV{}.Detect(contentType)
+}
+
+// Pretty formats the CSV as a table.
+func (p CSVPrinter) Pretty(data []byte) ([]byte, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = csvDelimiter(data)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("no CSV rows found")
+	}
+
+	// Rows may have a different number of fields, but tables must not.
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	cells := func(row []string, align int) []*simpletable.Cell {
+		result := make([]*simpletable.Cell, columns)
+		for i := range result {
+			text := ""
+			if i < len(row) {
+				text = row[i]
+			}
+			result[i] = &simpletable.Cell{Align: align, Text: text}
+		}
+		return result
+	}
+
+	table := simpletable.New()
+	table.Header = &simpletable.Header{Cells: cells(rows[0], simpletable.AlignCenter)}
+	for _, row := range rows[1:] {
+		table.Body.Cells = append(table.Body.Cells, cells(row, simpletable.AlignLeft))
+	}
+	table.SetStyle(simpletable.StyleCompactLite)
+
+	return []byte(table.String()), nil
+}
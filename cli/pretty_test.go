@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXMLPrinter(t *testing.T) {
+	pretty, err := XMLPrinter{}.Pretty([]byte(`<?xml version="1.0"?>
+<a:items xmlns:a="urn:a" count="2"><!-- list --><a:item id="1">One</a:item>
+  <a:item id="2">Two &amp; more</a:item><empty/></a:items>`))
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0"?>
+<a:items xmlns:a="urn:a" count="2">
+  <!-- list -->
+  <a:item id="1">One</a:item>
+  <a:item id="2">Two &amp; more</a:item>
+  <empty/>
+</a:items>
+`, string(pretty))
+
+	_, err = XMLPrinter{}.Pretty([]byte(`<a><b></a>`))
+	assert.Error(t, err)
+}
+
+func TestHTMLPrinter(t *testing.T) {
+	pretty, err := HTMLPrinter{}.Pretty([]byte(`<!DOCTYPE html><html><head><title>Test</title>
+<meta charset="utf-8"></head><body><div class="main"><p>Hello,
+   <b>world</b>!</p><pre>  keep
+  this</pre><br></div></body></html>`))
+	assert.NoError(t, err)
+	assert.Equal(t, `<!DOCTYPE html>
+<html>
+  <head>
+    <title>Test</title>
+    <meta charset="utf-8">
+  </head>
+  <body>
+    <div class="main">
+      <p>
+        Hello,
+        <b>world</b>
+        !
+      </p>
+      <pre>  keep
+  this</pre>
+      <br>
+    </div>
+  </body>
+</html>
+`, string(pretty))
+}
+
+func TestCSVPrinter(t *testing.T) {
+	pretty, err := CSVPrinter{}.Pretty([]byte("name,count\nfoo,1\n\"long, name\",100,extra\n"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(pretty), "long, name")
+	assert.Contains(t, string(pretty), "extra")
+
+	_, err = CSVPrinter{}.Pretty([]byte(""))
+	assert.Error(t, err)
+}
+
+func TestFormatPretty(t *testing.T) {
+	reset(false)
+	viper.Set("rsh-output-format", "auto")
+
+	buf := &bytes.Buffer{}
+	Stdout = buf
+	formatter := NewDefaultFormatter(false)
+
+	// Decoded XML is displayed using the original document.
+	formatter.Format(Response{
+		Proto:   "HTTP/1.1",
+		Status:  200,
+		Headers: map[string]string{"Content-Type": "application/xml"},
+		Body:    map[string]interface{}{"a": map[string]interface{}{"b": "1"}},
+		raw:     []byte("<a><b>1</b></a>"),
+	})
+	assert.Contains(t, buf.String(), "<a>\n  <b>1</b>\n</a>\n")
+
+	// Unknown types fall back to the default behavior.
+	buf.Reset()
+	formatter.Format(Response{
+		Proto:   "HTTP/1.1",
+		Status:  200,
+		Headers: map[string]string{"Content-Type": "text/plain"},
+		Body:    "<a><b>1</b></a>",
+	})
+	assert.Contains(t, buf.String(), "\n<a><b>1</b></a>\n")
+
+	// Invalid documents are shown as-is.
+	buf.Reset()
+	formatter.Format(Response{
+		Proto:   "HTTP/1.1",
+		Status:  200,
+		Headers: map[string]string{"Content-Type": "text/xml"},
+		Body:    []byte("<a><b>1</a>"),
+	})
+	assert.Contains(t, buf.String(), "\n<a><b>1</a>\n")
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// problemMembers are the standard RFC 7807 problem details members, which
+// are rendered specially rather than as extension members.
+var problemMembers = map[string]bool{
+	"type":     true,
+	"title":    true,
+	"status":   true,
+	"detail":   true,
+	"instance": true,
+}
+
+// isProblem returns whether the content type is an RFC 7807 problem document.
+func isProblem(contentType string) bool {
+	switch mediaType(contentType) {
+	case "application/problem+json", "application/problem+xml":
+		return true
+	}
+
+	return false
+}
+
+// problemDetails returns the members of a decoded problem document. XML
+// problems are wrapped in a `problem` root element whose attributes, like the
+// namespace declaration, are skipped.
+func problemDetails(body interface{}) (map[string]interface{}, bool) {
+	problem, ok := body.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	if root, ok := problem["problem"].(map[string]interface{}); ok && len(problem) == 1 {
+		problem = map[string]interface{}{}
+		for k, v := range root {
+			if !strings.HasPrefix(k, "@") {
+				problem[k] = v
+			}
+		}
+	}
+
+	return problem, true
+}
+
+// problemTypeURL returns the problem type if it is a URL which can be fetched
+// for more information, otherwise an empty string.
+func problemTypeURL(problem map[string]interface{}) string {
+	t, ok := problem["type"].(string)
+	if !ok {
+		return ""
+	}
+
+	u, err := url.Parse(t)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+
+	return t
+}
+
+// renderProblem renders a concise summary of a problem document with the
+// title and status first, followed by the detail, instance, and any extension
+// members.
+func renderProblem(problem map[string]interface{}, status int) ([]byte, error) {
+	title := fmt.Sprintf("%v", problem["title"])
+	if problem["title"] == nil {
+		title = http.StatusText(status)
+	}
+
+	if s, ok := problem["status"]; ok && s != nil {
+		title += fmt.Sprintf(" (%v)", s)
+	} else if status != 0 {
+		title += fmt.Sprintf(" (%d)", status)
+	}
+
+	sb := &strings.Builder{}
+	sb.WriteString(au.Red(strings.TrimSpace(title)).Bold().String() + "\n")
+
+	if detail, ok := problem["detail"]; ok && detail != nil {
+		sb.WriteString(fmt.Sprintf("%v\n", detail))
+	}
+
+	keys := []string{}
+	for k := range problem {
+		if !problemMembers[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	if instance, ok := problem["instance"]; ok && instance != nil {
+		keys = append([]string{"instance"}, keys...)
+	}
+
+	if len(keys) > 0 {
+		sb.WriteString("\n")
+	}
+
+	for _, k := range keys {
+		value := ""
+		if s, ok := problem[k].(string); ok {
+			value = s
+		} else {
+			b, err := MarshalReadable(problem[k])
+			if err != nil {
+				return nil, err
+			}
+			value = string(b)
+		}
+
+		sb.WriteString(fmt.Sprintf("%s %s\n", au.Yellow(k+":"), value))
+	}
+
+	if t := problemTypeURL(problem); t != "" {
+		sb.WriteString(fmt.Sprintf("\nFor more information run: %s %s\n", Root.CommandPath(), t))
+	}
+
+	return []byte(sb.String()), nil
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestProblemJSON(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").
+		Get("/items/abc").
+		Reply(404).
+		SetHeader("Content-Type", "application/problem+json").
+		BodyString(`{"type": "https://example.com/probs/not-found", "title": "Not Found", "status": 404, "detail": "Item abc does not exist", "instance": "/items/abc", "id": "abc"}`)
+
+	out := run("http://example.com/items/abc")
+	assert.Contains(t, out, "Not Found (404)\nItem abc does not exist\n\ninstance: /items/abc\nid: abc\n")
+	assert.Contains(t, out, "For more information run: restish https://example.com/probs/not-found")
+	assert.Equal(t, 3, GetExitCode())
+}
+
+func TestProblemStructured(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").
+		Get("/fail").
+		Reply(503).
+		SetHeader("Content-Type", "application/problem+json").
+		BodyString(`{"title": "Unavailable", "status": 503}`)
+
+	out := run("-o json -f body http://example.com/fail")
+	assert.JSONEq(t, `{"title": "Unavailable", "status": 503}`, out)
+	assert.Equal(t, 4, GetExitCode())
+}
+
+func TestProblemXML(t *testing.T) {
+	problem, ok := problemDetails(map[string]interface{}{
+		"problem": map[string]interface{}{
+			"@xmlns": "urn:ietf:rfc:7807",
+			"type":   "about:blank",
+			"title":  "Forbidden",
+			"status": "403",
+		},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, map[string]interface{}{
+		"type":   "about:blank",
+		"title":  "Forbidden",
+		"status": "403",
+	}, problem)
+	assert.Equal(t, "", problemTypeURL(problem))
+
+	reset(false)
+	out, err := renderProblem(problem, 403)
+	assert.NoError(t, err)
+	assert.Equal(t, "Forbidden (403)\n", string(out))
+}
@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ProtobufConfig describes how to decode protobuf responses for an API using
+// a compiled `FileDescriptorSet`. `Messages` maps URL path patterns like
+// `/items/*` to a message type, with `Message` used when none match.
+type ProtobufConfig struct {
+	Descriptors string            `json:"descriptors"`
+	Message     string            `json:"message,omitempty"`
+	Messages    map[string]string `json:"messages,omitempty"`
+}
+
+// errNoDescriptors explains how to set up protobuf decoding.
+var errNoDescriptors = errors.New("decoding protobuf requires a descriptor set, generate one with `protoc --include_imports --descriptor_set_out=api.pb *.proto` and set `protobuf.descriptors` and `protobuf.message` in the API config")
+
+// Protobuf describes content types like `application/x-protobuf`. Decoding
+// requires a message descriptor, which comes from the API config.
+type Protobuf struct {
+	Message protoreflect.MessageDescriptor
+}
+
+// Detect if the content type is protobuf.
+func (p Protobuf) Detect(contentType string) bool {
+	first := strings.Split(contentType, ";")[0]
+	return first == "application/x-protobuf" || first == "application/protobuf" || first == "application/vnd.google.protobuf"
+}
+
+// Marshal is not supported for protobuf.
+func (p Protobuf) Marshal(value interface{}) ([]byte, error) {
+	return nil, errors.New("encoding protobuf is not supported")
+}
+
+// Unmarshal the value from encoded protobuf using the message descriptor.
+// Fields use their JSON names and unknown fields are kept using their field
+// numbers as keys.
+func (p Protobuf) Unmarshal(data []byte, value interface{}) error {
+	if p.Message == nil {
+		return errNoDescriptors
+	}
+
+	ptr, ok := value.(*interface{})
+	if !ok {
+		return fmt.Errorf("value must be *interface{} but found %T", value)
+	}
+
+	msg := dynamicpb.NewMessage(p.Message)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return err
+	}
+
+	*ptr = protoMessageMap(msg)
+	return nil
+}
+
+// protoMessageMap converts a message into a map.
+func protoMessageMap(m protoreflect.Message) map[string]interface{} {
+	result := map[string]interface{}{}
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			list := v.List()
+			items := make([]interface{}, list.Len())
+			for i := 0; i < list.Len(); i++ {
+				items[i] = protoValue(fd, list.Get(i))
+			}
+			result[fd.JSONName()] = items
+		case fd.IsMap():
+			entries := map[string]interface{}{}
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				entries[k.String()] = protoValue(fd.MapValue(), mv)
+				return true
+			})
+			result[fd.JSONName()] = entries
+		default:
+			result[fd.JSONName()] = protoValue(fd, v)
+		}
+		return true
+	})
+
+	for k, v := range protoUnknownFields(m.GetUnknown()) {
+		result[k] = v
+	}
+
+	return result
+}
+
+// protoValue converts a single field value.
+func protoValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return protoMessageMap(v.Message())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return int32(v.Enum())
+	}
+
+	return v.Interface()
+}
+
+// protoUnknownFields decodes fields which aren't in the message descriptor,
+// keyed by field number. Without a schema, length-delimited values are shown
+// as strings if they are valid UTF-8 and otherwise as bytes. Repeated fields
+// become lists.
+func protoUnknownFields(b []byte) map[string]interface{} {
+	result := map[string]interface{}{}
+
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			break
+		}
+		b = b[n:]
+
+		var value interface{}
+		switch typ {
+		case protowire.VarintType:
+			value, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			value, n = protowire.ConsumeFixed32(b)
+		case protowire.Fixed64Type:
+			value, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			var raw []byte
+			raw, n = protowire.ConsumeBytes(b)
+			if utf8.Valid(raw) {
+				value = string(raw)
+			} else {
+				value = raw
+			}
+		case protowire.StartGroupType:
+			value, n = protowire.ConsumeGroup(num, b)
+		default:
+			n = -1
+		}
+		if n < 0 {
+			break
+		}
+		b = b[n:]
+
+		key := strconv.Itoa(int(num))
+		switch existing := result[key].(type) {
+		case nil:
+			result[key] = value
+		case []interface{}:
+			result[key] = append(existing, value)
+		default:
+			result[key] = []interface{}{existing, value}
+		}
+	}
+
+	return result
+}
+
+// protobufMessage loads the message descriptor to use for a URL path from
+// the API's protobuf config.
+func protobufMessage(config *ProtobufConfig, urlPath string) (protoreflect.MessageDescriptor, error) {
+	if config == nil || config.Descriptors == "" {
+		return nil, errNoDescriptors
+	}
+
+	name := config.Message
+	patterns := []string{}
+	for pattern := range config.Messages {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, urlPath); ok {
+			name = config.Messages[pattern]
+			break
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no protobuf message type configured for %s", urlPath)
+	}
+
+	b, err := ioutil.ReadFile(config.Descriptors)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, errNoDescriptors)
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("invalid descriptor set %s: %w", config.Descriptors, err)
+	}
+
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set %s: %w", config.Descriptors, err)
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("protobuf message %s not found in %s", name, config.Descriptors)
+	}
+
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a protobuf message", name)
+	}
+
+	return md, nil
+}
+
+// unmarshalProtobuf decodes a protobuf response using the protobuf config of
+// the API which the URL belongs to.
+func unmarshalProtobuf(u *url.URL, data []byte, value interface{}) error {
+	var config *ProtobufConfig
+	if _, api := findAPI(u.String()); api != nil {
+		config = api.Protobuf
+	}
+
+	md, err := protobufMessage(config, u.Path)
+	if err != nil {
+		return err
+	}
+
+	return Protobuf{Message: md}.Unmarshal(data, value)
+}
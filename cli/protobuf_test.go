@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// testDescriptors writes a descriptor set with an `example.Item` message and
+// returns its filename.
+func testDescriptors(t *testing.T) string {
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(num),
+			Type:     typ.Enum(),
+			Label:    label.Enum(),
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			{
+				Name:    proto.String("example.proto"),
+				Package: proto.String("example"),
+				Syntax:  proto.String("proto3"),
+				MessageType: []*descriptorpb.DescriptorProto{
+					{
+						Name: proto.String("Item"),
+						Field: []*descriptorpb.FieldDescriptorProto{
+							field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL),
+							field("count", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL),
+							field("tags", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_LABEL_REPEATED),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	b, err := proto.Marshal(set)
+	assert.NoError(t, err)
+
+	filename := filepath.Join(t.TempDir(), "api.pb")
+	assert.NoError(t, ioutil.WriteFile(filename, b, 0600))
+	return filename
+}
+
+func TestProtobuf(t *testing.T) {
+	reset(false)
+
+	filename := testDescriptors(t)
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, "Foo")
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, 5)
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendString(b, "a")
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendString(b, "b")
+	// Unknown fields
+	b = protowire.AppendTag(b, 9, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1)
+	b = protowire.AppendTag(b, 9, protowire.VarintType)
+	b = protowire.AppendVarint(b, 2)
+	b = protowire.AppendTag(b, 10, protowire.BytesType)
+	b = protowire.AppendBytes(b, []byte{0xff})
+
+	config := &ProtobufConfig{
+		Descriptors: filename,
+		Messages:    map[string]string{"/items/*": "example.Item"},
+	}
+
+	md, err := protobufMessage(config, "/items/foo")
+	assert.NoError(t, err)
+
+	var decoded interface{}
+	assert.NoError(t, Protobuf{Message: md}.Unmarshal(b, &decoded))
+	assert.Equal(t, map[string]interface{}{
+		"name":  "Foo",
+		"count": int32(5),
+		"tags":  []interface{}{"a", "b"},
+		"9":     []interface{}{uint64(1), uint64(2)},
+		"10":    []byte{0xff},
+	}, decoded)
+
+	_, err = protobufMessage(config, "/other")
+	assert.Error(t, err)
+
+	config.Message = "example.Missing"
+	_, err = protobufMessage(config, "/other")
+	assert.Error(t, err)
+
+	// Missing config explains how to create a descriptor set.
+	_, err = protobufMessage(nil, "/items/foo")
+	assert.ErrorIs(t, err, errNoDescriptors)
+	_, err = protobufMessage(&ProtobufConfig{Descriptors: filename + ".missing", Message: "example.Item"}, "/items/foo")
+	assert.ErrorIs(t, err, errNoDescriptors)
+
+	// Responses without config are shown as bytes with a warning.
+	u, _ := url.Parse("http://example.com/items/foo")
+	captured := &strings.Builder{}
+	Stderr = captured
+	resp, err := ParseResponse(&http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/x-protobuf"}},
+		Body:       ioutil.NopCloser(strings.NewReader(string(b))),
+		Request:    &http.Request{URL: u},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, b, resp.Body)
+	assert.Contains(t, captured.String(), "protoc --include_imports")
+}
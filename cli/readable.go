@@ -2,21 +2,35 @@ package cli
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math"
+	"math/big"
 	"reflect"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/fxamacker/cbor/v2"
 )
 
 // MarshalReadable marshals a value into a human-friendly readable format.
 func MarshalReadable(v interface{}) ([]byte, error) {
-	return marshalReadable("", v)
+	return marshalReadable("", v, nil)
 }
 
-func marshalReadable(indent string, v interface{}) ([]byte, error) {
+// marshalReadable marshals a value like `MarshalReadable`, keeping the given
+// original order of object keys when `rsh-sort-keys` is disabled.
+func marshalReadable(indent string, v interface{}, order keyOrder) ([]byte, error) {
+	if n, ok := v.(json.Number); ok {
+		// Precisely decoded numbers are written exactly as they were received.
+		return []byte(n.String()), nil
+	}
+
+	if i, ok := v.(*big.Int); ok && i != nil {
+		return []byte(i.String()), nil
+	}
+
 	rv := reflect.ValueOf(v)
 	switch rv.Kind() {
 	case reflect.Invalid:
@@ -26,7 +40,7 @@ func marshalReadable(indent string, v interface{}) ([]byte, error) {
 			return []byte("null"), nil
 		}
 
-		return marshalReadable(indent, rv.Elem().Interface())
+		return marshalReadable(indent, rv.Elem().Interface(), order)
 	case reflect.Bool:
 		if v.(bool) == true {
 			return []byte("true"), nil
@@ -70,7 +84,7 @@ func marshalReadable(indent string, v interface{}) ([]byte, error) {
 
 		return []byte(`"` + s + `"`), nil
 	case reflect.Array:
-		return marshalReadable(indent, rv.Slice(0, rv.Len()).Interface())
+		return marshalReadable(indent, rv.Slice(0, rv.Len()).Interface(), order)
 	case reflect.Slice:
 		// Special case: empty slice should go in-line.
 		if rv.Len() == 0 {
@@ -93,7 +107,7 @@ func marshalReadable(indent string, v interface{}) ([]byte, error) {
 		hasNewlines := false
 		lines := []string{}
 		for i := 0; i < rv.Len(); i++ {
-			encoded, err := marshalReadable(indent+"  ", rv.Index(i).Interface())
+			encoded, err := marshalReadable(indent+"  ", rv.Index(i).Interface(), order)
 			if err != nil {
 				return nil, err
 			}
@@ -121,7 +135,7 @@ func marshalReadable(indent string, v interface{}) ([]byte, error) {
 
 		m := "{\n"
 
-		// Sort the keys
+		// Sort the keys, unless the original order should be kept.
 		keys := rv.MapKeys()
 		stringKeys := []string{}
 		reverse := map[string]reflect.Value{}
@@ -131,12 +145,10 @@ func marshalReadable(indent string, v interface{}) ([]byte, error) {
 			reverse[ks] = k
 		}
 
-		sort.Strings(stringKeys)
-
 		// Write out each key/value pair.
-		for _, k := range stringKeys {
+		for _, k := range orderedKeys(stringKeys, order) {
 			v := rv.MapIndex(reverse[k])
-			encoded, err := marshalReadable(indent+"  ", v.Interface())
+			encoded, err := marshalReadable(indent+"  ", v.Interface(), order)
 			if err != nil {
 				return nil, err
 			}
@@ -155,6 +167,15 @@ func marshalReadable(indent string, v interface{}) ([]byte, error) {
 			return []byte(t.UTC().Format(time.RFC3339Nano)), nil
 		}
 
+		if t, ok := v.(cbor.Tag); ok {
+			// Unknown CBOR tags use the diagnostic notation, e.g. `1000("foo")`.
+			encoded, err := marshalReadable(indent, t.Content, order)
+			if err != nil {
+				return nil, err
+			}
+			return []byte(fmt.Sprintf("%d(%s)", t.Number, encoded)), nil
+		}
+
 		// TODO: user-defined structs, go through each field.
 	}
 
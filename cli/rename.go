@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// parseRenames adds `old:new` key rename pairs to `renames`.
+func parseRenames(renames map[string]string, pairs []string) error {
+	for _, pair := range pairs {
+		from, to, ok := strings.Cut(pair, ":")
+		if !ok || from == "" || to == "" {
+			return fmt.Errorf("invalid rename %q, expected old:new", pair)
+		}
+		renames[from] = to
+	}
+	return nil
+}
+
+// loadRenameFile adds the key renames from a JSON or YAML object mapping old
+// names to new ones.
+func loadRenameFile(renames map[string]string, filename string) error {
+	data, err := readFormatInput(filename)
+	if err != nil {
+		return err
+	}
+
+	mapping, ok := makeJSONSafe(data, false).(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s must be an object mapping old names to new names", filename)
+	}
+
+	for from, v := range mapping {
+		to, ok := v.(string)
+		if !ok || to == "" {
+			return fmt.Errorf("%s: new name for %s must be a string", filename, from)
+		}
+		renames[from] = to
+	}
+	return nil
+}
+
+// renameArgs returns the key renames from the `old:new` pair arguments and
+// the optional mapping file, along with the input filename, which is a last
+// argument without a `:`.
+func renameArgs(args []string, mapFile string) (map[string]string, string, error) {
+	filename := ""
+	if len(args) > 0 && !strings.Contains(args[len(args)-1], ":") {
+		filename = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+
+	renames := map[string]string{}
+	if mapFile != "" {
+		if err := loadRenameFile(renames, mapFile); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := parseRenames(renames, args); err != nil {
+		return nil, "", err
+	}
+
+	if len(renames) == 0 {
+		return nil, "", errors.New("no renames given, pass old:new pairs or --rsh-rename-file")
+	}
+
+	return renames, filename, nil
+}
+
+// renameObjectKeys returns a copy of the object with keys renamed. Renamed
+// keys replace existing keys with the same name, so the result doesn't depend
+// on the order of the keys.
+func renameObjectKeys(obj map[string]interface{}, renames map[string]string, recursive bool) map[string]interface{} {
+	renamed := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		if _, ok := renames[k]; !ok {
+			renamed[k] = renameNested(v, renames, recursive)
+		}
+	}
+	for k, v := range obj {
+		if to, ok := renames[k]; ok {
+			renamed[to] = renameNested(v, renames, recursive)
+		}
+	}
+	return renamed
+}
+
+// renameNested renames keys in nested objects, including those in arrays, if
+// `recursive` is set.
+func renameNested(value interface{}, renames map[string]string, recursive bool) interface{} {
+	if !recursive {
+		return value
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return renameObjectKeys(v, renames, true)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = renameNested(item, renames, true)
+		}
+		return items
+	}
+	return value
+}
+
+// renameKeys renames keys in a top-level object or in each object of a
+// top-level array, and in all nested objects if `recursive` is set.
+func renameKeys(data interface{}, renames map[string]string, recursive bool) (interface{}, error) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		return renameObjectKeys(v, renames, recursive), nil
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			if obj, ok := item.(map[string]interface{}); ok {
+				items[i] = renameObjectKeys(obj, renames, recursive)
+			} else {
+				items[i] = renameNested(item, renames, recursive)
+			}
+		}
+		return items, nil
+	}
+
+	return nil, errors.New("input must be an object or array")
+}
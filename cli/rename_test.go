@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenameKeys(t *testing.T) {
+	data := []interface{}{
+		map[string]interface{}{
+			"user_id": 1.0,
+			"userId":  "replaced",
+			"profile": map[string]interface{}{"user_id": 2.0},
+			"items":   []interface{}{map[string]interface{}{"user_id": 3.0}},
+		},
+		"not an object",
+	}
+	renames := map[string]string{"user_id": "userId"}
+
+	renamed, err := renameKeys(data, renames, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"userId":  1.0,
+			"profile": map[string]interface{}{"user_id": 2.0},
+			"items":   []interface{}{map[string]interface{}{"user_id": 3.0}},
+		},
+		"not an object",
+	}, renamed)
+
+	renamed, err = renameKeys(data[0], renames, true)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"userId":  1.0,
+		"profile": map[string]interface{}{"userId": 2.0},
+		"items":   []interface{}{map[string]interface{}{"userId": 3.0}},
+	}, renamed)
+
+	// Keys can be swapped.
+	renamed, err = renameKeys(map[string]interface{}{"a": 1.0, "b": 2.0}, map[string]string{"a": "b", "b": "a"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": 2.0, "b": 1.0}, renamed)
+
+	_, err = renameKeys("text", renames, false)
+	assert.Error(t, err)
+}
+
+func TestRenameArgs(t *testing.T) {
+	mapFile := path.Join(t.TempDir(), "map.yaml")
+	assert.NoError(t, ioutil.WriteFile(mapFile, []byte("first_name: firstName\nlast_name: lastName\n"), 0600))
+
+	renames, filename, err := renameArgs([]string{"a:b", "last_name:surname", "data.json"}, mapFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "data.json", filename)
+	assert.Equal(t, map[string]string{"a": "b", "first_name": "firstName", "last_name": "surname"}, renames)
+
+	_, filename, err = renameArgs([]string{"a:b"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "", filename)
+
+	_, _, err = renameArgs([]string{"a:"}, "")
+	assert.EqualError(t, err, `invalid rename "a:", expected old:new`)
+
+	_, _, err = renameArgs([]string{"-"}, "")
+	assert.Error(t, err)
+}
+
+func TestFormatRename(t *testing.T) {
+	filename := path.Join(t.TempDir(), "users.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`[{"user_id": 1, "meta": {"created_at": "now"}}]`), 0600))
+
+	assert.JSONEq(t, `[{"userId": 1, "meta": {"created_at": "now"}}]`, run("format rename user_id:userId created_at:createdAt "+filename))
+	assert.JSONEq(t, `[{"userId": 1, "meta": {"createdAt": "now"}}]`, run("format rename user_id:userId created_at:createdAt --rsh-recursive "+filename))
+
+	WithFakeStdin([]byte(`{"a": 1}`), 0, func() {
+		assert.JSONEq(t, `{"b": 1}`, run("format rename a:b"))
+	})
+}
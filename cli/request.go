@@ -1,15 +1,21 @@
 package cli
 
 import (
+	"bytes"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/viper"
@@ -27,7 +33,7 @@ func fixAddress(addr string) string {
 		parts := strings.Split(addr, "/")
 		c := configs[parts[0]]
 		if c != nil && c.Base != "" {
-			parts[0] = c.Base
+			parts[0] = c.profileBase(selectedProfile(c))
 			return strings.Join(parts, "/")
 		}
 
@@ -43,8 +49,12 @@ func fixAddress(addr string) string {
 }
 
 type requestOption struct {
-	client     *http.Client
-	disableLog bool
+	client       *http.Client
+	disableLog   bool
+	profile      string
+	onItem       func(item interface{})
+	metrics      bool
+	responseType string
 }
 
 // WithClient sets the client to use for the request.
@@ -61,6 +71,70 @@ func WithoutLog() requestOption {
 	}
 }
 
+// WithProfile sets the API auth profile to use for the request instead of
+// the one selected via `rsh-profile`.
+func WithProfile(name string) requestOption {
+	return requestOption{
+		profile: name,
+	}
+}
+
+// withItemHandler streams items of newline-delimited JSON responses to
+// `handler` as they arrive.
+func withItemHandler(handler func(item interface{})) requestOption {
+	return requestOption{
+		onItem: handler,
+	}
+}
+
+// withMetrics records metrics for the request and response and calls the log
+// hook, which is only done for requests made on behalf of the user rather
+// than internal ones like loading API descriptions or fetching auth tokens.
+func withMetrics() requestOption {
+	return requestOption{
+		metrics: true,
+	}
+}
+
+// withResponseType decodes the response as the given content type instead of
+// the one sent by the server.
+func withResponseType(ct string) requestOption {
+	return requestOption{
+		responseType: ct,
+	}
+}
+
+// requestSetupLock guards shared state which is modified while preparing
+// requests, like auth token caches and TLS transports, so that requests can
+// be made concurrently.
+var requestSetupLock sync.Mutex
+
+// userAgent returns the `User-Agent` header to send to an API, which is set
+// via `--rsh-user-agent`, the API's `user_agent` config, or defaults to
+// `restish/<version>`, in that order of precedence.
+func userAgent(config *APIConfig) string {
+	if agent := viper.GetString("rsh-user-agent"); agent != "" {
+		return agent
+	}
+
+	if config != nil && config.UserAgent != "" {
+		return os.ExpandEnv(config.UserAgent)
+	}
+
+	return "restish/" + Root.Version
+}
+
+// authError describes an auth handler failure. Failing to reach a token
+// endpoint is a transport error, while anything else, like missing params or
+// rejected credentials, is treated as a client error.
+func authError(handler string, err error) error {
+	code := exitCodeFor(err)
+	if code == ExitCodeError {
+		code = ExitCodeClientError
+	}
+	return exitError(code, err, "auth %s failed", handler)
+}
+
 // MakeRequest makes an HTTP request using the default client. It adds the
 // user-agent, auth, and any passed headers or query params to the request
 // before sending it out on the wire. If verbose mode is enabled, it will
@@ -70,47 +144,48 @@ func MakeRequest(req *http.Request, options ...requestOption) (*http.Response, e
 
 	name, config := findAPI(req.URL.String())
 
+	profileName := selectedProfile(config)
+
 	if config == nil {
 		config = &APIConfig{Profiles: map[string]*APIProfile{
 			"default": {},
 		}}
 	}
 
-	profile := config.Profiles[viper.GetString("rsh-profile")]
-
-	if profile == nil {
-		if viper.GetString("rsh-profile") != "default" {
-			panic("Invalid profile " + viper.GetString("rsh-profile"))
+	for _, option := range options {
+		if option.profile != "" {
+			profileName = option.profile
 		}
-
-		profile = &APIProfile{}
 	}
 
-	// Now that we have the profile, set up profile-based headers/params.
-	query := req.URL.Query()
-	for k, v := range profile.Headers {
-		if req.Header.Get(k) == "" {
-			req.Header.Add(k, os.ExpandEnv(v))
-		}
+	profile, err := config.resolveProfile(profileName)
+	if err != nil {
+		return nil, &ExitError{Code: ExitCodeUsage, Err: err}
 	}
 
-	for k, v := range profile.Query {
-		if query.Get(k) == "" {
-			query.Add(k, v)
+	if profile == nil {
+		if profileName != "default" {
+			return nil, &ExitError{Code: ExitCodeUsage, Err: unknownProfileError(name, config, profileName)}
 		}
+
+		profile = &APIProfile{}
 	}
 
-	// Allow env vars and commandline arguments to override config.
+	// Allow env vars and commandline arguments to override config. Repeating a
+	// header adds multiple values, while a comma-separated list in a single
+	// value is sent as-is. Either way, these replace any existing values.
+	custom := http.Header{}
 	for _, h := range viper.GetStringSlice("rsh-header") {
 		parts := strings.SplitN(h, ":", 2)
 		value := ""
 		if len(parts) > 1 {
-			value = parts[1]
+			value = strings.TrimSpace(parts[1])
 		}
 
-		req.Header.Add(parts[0], value)
+		custom.Add(parts[0], value)
 	}
 
+	customQuery := url.Values{}
 	for _, q := range viper.GetStringSlice("rsh-query") {
 		parts := strings.SplitN(q, "=", 2)
 		value := ""
@@ -118,109 +193,155 @@ func MakeRequest(req *http.Request, options ...requestOption) (*http.Response, e
 			value = parts[1]
 		}
 
-		query.Add(parts[0], value)
+		customQuery.Add(parts[0], value)
 	}
 
-	// Save modified query string arguments.
-	req.URL.RawQuery = query.Encode()
+	// Now that we have the profile, set up headers/params from the global
+	// config and profile unless the request or flags already set them.
+	origins := []string{}
+	headers, headerSources := configDefaults(globalDefaults.Headers, profile.Headers, profileName, true)
+	for k, v := range headers {
+		if _, ok := custom[http.CanonicalHeaderKey(k)]; !ok && req.Header.Get(k) == "" {
+			req.Header.Add(k, os.ExpandEnv(v))
+			origins = append(origins, fmt.Sprintf("Header %s from %s", k, headerSources[k]))
+		}
+	}
 
-	// Add auth if needed.
-	if profile.Auth != nil && profile.Auth.Name != "" {
-		auth, ok := authHandlers[profile.Auth.Name]
-		if ok {
-			err := auth.OnRequest(req, name+":"+viper.GetString("rsh-profile"), profile.Auth.Params)
-			if err != nil {
-				panic(err)
-			}
+	query := req.URL.Query()
+	params, paramSources := configDefaults(globalDefaults.Query, profile.Query, profileName, false)
+	for k, v := range params {
+		if _, ok := customQuery[k]; !ok && query.Get(k) == "" {
+			query.Add(k, v)
+			origins = append(origins, fmt.Sprintf("Query param %s from %s", k, paramSources[k]))
 		}
 	}
 
-	if req.Header.Get("user-agent") == "" {
-		req.Header.Set("user-agent", "restish-"+Root.Version)
+	// A header flag with an empty value removes the header.
+	for name, values := range custom {
+		if strings.Join(values, "") == "" {
+			req.Header.Del(name)
+			origins = append(origins, fmt.Sprintf("Header %s removed by flag", name))
+			continue
+		}
+		req.Header[name] = values
+		origins = append(origins, fmt.Sprintf("Header %s from flag", name))
+	}
+
+	addTraceHeader(req)
+
+	for name, values := range customQuery {
+		for _, value := range values {
+			query.Add(name, value)
+		}
+		origins = append(origins, fmt.Sprintf("Query param %s from flag", name))
+	}
+	sort.Strings(origins)
+
+	// Save modified query string arguments.
+	req.URL.RawQuery = query.Encode()
+
+	if values, ok := custom["User-Agent"]; ok && strings.Join(values, "") == "" {
+		// Go sends its own agent unless the header is present, so keep it empty
+		// to send none at all.
+		req.Header["User-Agent"] = []string{""}
+	} else if req.Header.Get("user-agent") == "" {
+		req.Header.Set("user-agent", userAgent(config))
 	}
 
 	if req.Header.Get("accept") == "" {
-		req.Header.Set("accept", buildAcceptHeader())
+		req.Header.Set("accept", buildAcceptHeader(config.AcceptTypes...))
 	}
 
 	if req.Header.Get("accept-encoding") == "" {
 		req.Header.Set("accept-encoding", buildAcceptEncodingHeader())
 	}
 
+	if conn := viper.GetString("rsh-connection"); conn != "" {
+		setConnectionHeader(req, conn)
+	}
+
 	if req.Header.Get("content-type") == "" && req.Body != nil {
 		// We have a body but no content-type; default to JSON.
 		req.Header.Set("content-type", "application/json; charset=utf-8")
 	}
 
-	client := CachedTransport().Client()
+	if inspectRequests {
+		// Inspecting never runs auth, which may e.g. open a browser to log in.
+		if profile.Auth != nil && profile.Auth.Name != "" {
+			LogInfo("Auth %s from profile %s is added when the request is sent", profile.Auth.Name, profileName)
+		}
+		if err := printInspectedRequest(req); err != nil {
+			return nil, err
+		}
+		return nil, errRequestInspected
+	}
+
+	// Add auth if needed.
+	if profile.Auth != nil && profile.Auth.Name != "" {
+		auth, ok := authHandlers[profile.Auth.Name]
+		if ok {
+			requestSetupLock.Lock()
+			err := auth.OnRequest(req, authCacheKey(name, profileName), profile.Auth.Params)
+			requestSetupLock.Unlock()
+			if err != nil {
+				return nil, authError(profile.Auth.Name, err)
+			}
+			origins = append(origins, fmt.Sprintf("Auth %s from profile %s", profile.Auth.Name, profileName))
+		}
+	}
+
+	cached := CachedTransport()
+	var transport http.RoundTripper = StaleWhileRevalidateTransport(cached)
 	if viper.GetBool("rsh-no-cache") {
-		client = &http.Client{Transport: InvalidateCachedTransport()}
+		transport = &invalidateCachedTransport{transport: cached}
+	}
+
+	ttl := viper.GetDuration("rsh-cache-ttl")
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	client := &http.Client{Transport: ResponseCacheTransport(transport, cached.Cache, viper.GetBool("rsh-cache-responses"), viper.GetBool("rsh-no-cache"), ttl)}
+
+	if viper.GetBool("rsh-http10") {
+		// Responses are not cached so every request exercises the server.
+		req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/1.0", 1, 0
+		client = &http.Client{Transport: HTTP10Transport()}
 	}
 
 	log := true
+	metrics := false
 	for _, option := range options {
 		if option.client != nil {
 			client = option.client
+			if client.Transport == nil {
+				// Use the API's TLS options rather than the default transport.
+				copied := *client
+				copied.Transport = apiTransport{}
+				client = &copied
+			}
 		}
 
 		if option.disableLog {
 			log = false
 		}
-	}
 
-	// The assumption is that all Transport implementations eventually use the
-	// default HTTP transport.
-	// We can therefore inject the TLS config once here, along with all the other
-	// config options, instead of modifying all the places where Transports are
-	// created
-	LogDebug("Adding TLS configuration")
-	if t, ok := http.DefaultTransport.(*http.Transport); ok {
-		if t.TLSClientConfig == nil {
-			t.TLSClientConfig = &tls.Config{}
-		}
-		if config.TLS == nil {
-			config.TLS = &TLSConfig{}
-		}
-
-		// CLI flags overwrite profile options
-		if viper.GetBool("rsh-insecure") {
-			config.TLS.InsecureSkipVerify = true
-		}
-		if cert := viper.GetString("rsh-client-cert"); cert != "" {
-			config.TLS.Cert = cert
-		}
-		if key := viper.GetString("rsh-client-key"); key != "" {
-			config.TLS.Key = key
-		}
-		if caCert := viper.GetString("rsh-ca-cert"); caCert != "" {
-			config.TLS.CACert = caCert
+		if option.metrics {
+			metrics = true
 		}
+	}
 
-		if config.TLS.InsecureSkipVerify {
-			LogWarning("Disabling TLS security checks")
-			t.TLSClientConfig.InsecureSkipVerify = config.TLS.InsecureSkipVerify
-		}
-		if config.TLS.Cert != "" {
-			cert, err := tls.LoadX509KeyPair(config.TLS.Cert, config.TLS.Key)
-			if err != nil {
-				return nil, err
-			}
-			t.TLSClientConfig.Certificates = append(t.TLSClientConfig.Certificates, cert)
-		}
-		if config.TLS.CACert != "" {
-			caCert, err := ioutil.ReadFile(config.TLS.CACert)
-			if err != nil {
-				return nil, err
-			}
-			systemCerts := BestEffortSystemCertPool()
-			if !systemCerts.AppendCertsFromPEM(caCert) {
-				return nil, fmt.Errorf("Failed to append CACert %s RootCA list", config.TLS.CACert)
-			}
-			t.TLSClientConfig.RootCAs = systemCerts
-		}
+	tlsTransport, err := configureTLS(config)
+	if err != nil {
+		return nil, err
+	}
+	if tlsTransport != nil {
+		req = withAPITransport(req, tlsTransport)
 	}
 
 	if log {
+		for _, origin := range origins {
+			LogDebug("%s", origin)
+		}
 		LogDebugRequest(req)
 	}
 
@@ -229,6 +350,18 @@ func MakeRequest(req *http.Request, options ...requestOption) (*http.Response, e
 		return nil, err
 	}
 
+	if metrics {
+		recordMetric(req, resp, time.Since(start))
+		callLogHook(LogEntry{
+			Method:  req.Method,
+			URL:     maskURL(req.URL),
+			Status:  resp.StatusCode,
+			Latency: time.Since(start),
+			API:     name,
+			Profile: profileName,
+		})
+	}
+
 	if log {
 		LogDebugResponse(start, resp)
 	}
@@ -236,14 +369,123 @@ func MakeRequest(req *http.Request, options ...requestOption) (*http.Response, e
 	return resp, nil
 }
 
+// tlsTransports holds a transport per set of TLS options, so connections
+// are reused between requests to the same API but never shared with others.
+var tlsTransports = map[TLSConfig]*http.Transport{}
+
+// configureTLS returns a transport with the API and CLI TLS options, cloned
+// from the default transport so that e.g. one API's private CA is not
+// trusted for requests to other APIs. It returns nil when there are no
+// options or the default transport has been replaced, e.g. by a mock.
+func configureTLS(config *APIConfig) (*http.Transport, error) {
+	// CLI flags overwrite API options without modifying the saved config.
+	options := TLSConfig{}
+	if config.TLS != nil {
+		options = *config.TLS
+	}
+	if viper.GetBool("rsh-insecure") {
+		options.InsecureSkipVerify = true
+	}
+	if cert := viper.GetString("rsh-client-cert"); cert != "" {
+		options.Cert = cert
+	}
+	if key := viper.GetString("rsh-client-key"); key != "" {
+		options.Key = key
+	}
+	if caCert := viper.GetString("rsh-ca-cert"); caCert != "" {
+		options.CACert = caCert
+	}
+
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok || options == (TLSConfig{}) {
+		return nil, nil
+	}
+
+	requestSetupLock.Lock()
+	defer requestSetupLock.Unlock()
+
+	if t, ok := tlsTransports[options]; ok {
+		return t, nil
+	}
+
+	LogDebug("Adding TLS configuration")
+
+	var certificates []tls.Certificate
+	if options.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(expandHome(options.Cert), expandHome(options.Key))
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, cert)
+	}
+
+	// A nil pool means the system roots are used.
+	var rootCAs *x509.CertPool
+	if options.CACert != "" {
+		caCert, err := ioutil.ReadFile(expandHome(options.CACert))
+		if err != nil {
+			return nil, err
+		}
+		rootCAs = BestEffortSystemCertPool()
+		if !rootCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("Failed to append CACert %s RootCA list", options.CACert)
+		}
+	}
+
+	if options.InsecureSkipVerify {
+		LogWarning("Disabling TLS security checks")
+	}
+
+	t := base.Clone()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.InsecureSkipVerify = options.InsecureSkipVerify
+	t.TLSClientConfig.Certificates = certificates
+	t.TLSClientConfig.RootCAs = rootCAs
+
+	tlsTransports[options] = t
+	return t, nil
+}
+
+// expandHome replaces a leading `~` in a path with the user's home directory
+// so paths in the config file can be shared between machines.
+func expandHome(filename string) string {
+	if filename == "~" || strings.HasPrefix(filename, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, filename[1:])
+		}
+	}
+	return filename
+}
+
 // Response describes a parsed HTTP response which can be marshalled to enable
 // printing and filtering/projection.
 type Response struct {
-	Proto   string            `json:"proto"`
-	Status  int               `json:"status"`
-	Headers map[string]string `json:"headers"`
-	Links   Links             `json:"links"`
-	Body    interface{}       `json:"body"`
+	Proto    string            `json:"proto"`
+	Status   int               `json:"status"`
+	Headers  map[string]string `json:"headers"`
+	Links    Links             `json:"links"`
+	Body     interface{}       `json:"body"`
+	Trailers map[string]string `json:"trailers,omitempty"`
+
+	// Embedded holds any HAL `_embedded` resources from the body by relation.
+	// They are still part of the body when printing the response.
+	Embedded map[string]interface{} `json:"-"`
+
+	// Duration is the total time taken to make the request(s) and read the
+	// response, including any pagination.
+	Duration time.Duration `json:"-"`
+
+	// raw is the original body before decoding, used for pretty printing.
+	raw []byte
+
+	// keyOrder is the original order of object keys in the body, which is only
+	// set when keys should not be sorted in output.
+	keyOrder keyOrder
+
+	// header holds the original headers with multiple values kept separate.
+	header http.Header
 }
 
 // Map returns a map representing this response matching the encoded JSON.
@@ -256,55 +498,155 @@ func (r Response) Map() map[string]interface{} {
 		}
 
 		for _, l := range list {
-			links[rel] = append(links[rel], map[string]interface{}{
+			link := map[string]interface{}{
 				"rel": l.Rel,
 				"uri": l.URI,
-			})
+			}
+			if l.IsTemplate {
+				link["template"] = true
+			}
+			links[rel] = append(links[rel], link)
 		}
 	}
 
-	return map[string]interface{}{
+	m := map[string]interface{}{
 		"proto":   r.Proto,
 		"status":  r.Status,
 		"headers": r.Headers,
 		"links":   links,
 		"body":    r.Body,
 	}
+
+	if len(r.Trailers) > 0 {
+		m["trailers"] = r.Trailers
+	}
+
+	return m
 }
 
 // ParseResponse takes an HTTP response and tries to parse it using the
 // registered content types. It returns a map representing the request,
 func ParseResponse(resp *http.Response) (Response, error) {
+	return parseResponse(resp)
+}
+
+// responseContentType returns the content type to decode a response with and
+// whether it was forced by `override`. The server may send the wrong content
+// type, so users can force a specific decoder instead.
+func responseContentType(ct, override string) (string, bool) {
+	if override != "" {
+		LogDebug("Overriding response content type %s with %s", ct, override)
+		return override, true
+	}
+
+	return ct, false
+}
+
+// decodeBody decodes a response body from the given URL and content type,
+// returning the original data if it can't or shouldn't be decoded. Failing to
+// decode a `forced` content type is logged as a warning.
+func decodeBody(u *url.URL, ct string, forced bool, data []byte) (interface{}, error) {
+	var parsed interface{}
+
+	if len(data) == 0 {
+		return parsed, nil
+	}
+
+	if viper.GetBool("rsh-headers-only") {
+		// Only the headers will be shown, so there is no need to decode.
+		return data, nil
+	}
+
+	if viper.GetBool("rsh-raw") && viper.GetString("rsh-filter") == "" && viper.GetString("rsh-jsonpath") == "" && viper.GetString("rsh-jq") == "" && viper.GetString("rsh-assert") == "" {
+		// Raw mode without filtering, don't parse the response.
+		return data, nil
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		// Some servers send a newline or spaces instead of an empty body.
+		return parsed, nil
+	}
+
+	if (Protobuf{}).Detect(ct) {
+		// Protobuf needs the message type from the API config.
+		if err := unmarshalProtobuf(u, data, &parsed); err != nil {
+			LogWarning("Unable to decode protobuf response: %v", err)
+			return data, nil
+		}
+		return parsed, nil
+	}
+
+	if err := Unmarshal(ct, data, &parsed); err != nil {
+		if (NDJSON{}).Detect(ct) && viper.GetBool("rsh-ndjson-strict") {
+			return nil, err
+		}
+		if forced {
+			LogWarning("Unable to decode response as %s: %v", ct, err)
+		}
+		return data, nil
+	}
+
+	return parsed, nil
+}
+
+// parseResponse parses the response like `ParseResponse`. With an item
+// handler, newline-delimited JSON responses without a known length are
+// decoded line-by-line as data arrives, passing each item to it.
+func parseResponse(resp *http.Response, options ...requestOption) (Response, error) {
 	var parsed interface{}
 
+	var onItem func(item interface{})
+	responseType := ""
+	for _, option := range options {
+		if option.onItem != nil {
+			onItem = option.onItem
+		}
+
+		if option.responseType != "" {
+			responseType = option.responseType
+		}
+	}
+
 	// Handle content encodings
 	defer resp.Body.Close()
 	if err := DecodeResponse(resp); err != nil {
 		return Response{}, err
 	}
 
-	data, _ := ioutil.ReadAll(resp.Body)
+	ct, forced := responseContentType(resp.Header.Get("content-type"), responseType)
 
-	if len(data) > 0 {
-		if viper.GetBool("rsh-raw") && viper.GetString("rsh-filter") == "" {
-			// Raw mode without filtering, don't parse the response.
-			parsed = data
-		} else {
-			ct := resp.Header.Get("content-type")
-			if err := Unmarshal(ct, data, &parsed); err != nil {
-				parsed = data
-			}
+	var data []byte
+	streamed := false
+	if onItem != nil && resp.ContentLength < 0 && (NDJSON{}).Detect(ct) {
+		// Keep a copy of the original body for saving & pretty printing.
+		buf := &bytes.Buffer{}
+		items, err := decodeNDJSON(io.TeeReader(resp.Body, buf), onItem)
+		if err != nil {
+			return Response{}, err
+		}
+		parsed, data, streamed = items, buf.Bytes(), true
+	} else {
+		data, _ = ioutil.ReadAll(resp.Body)
+	}
+
+	if !streamed {
+		var err error
+		if parsed, err = decodeBody(resp.Request.URL, ct, forced, data); err != nil {
+			return Response{}, err
 		}
 	}
 
 	// Wrap the body to describe the entire response
 	headers := map[string]string{}
 	output := Response{
-		Proto:   resp.Proto,
-		Status:  resp.StatusCode,
-		Headers: headers,
-		Links:   Links{},
-		Body:    parsed,
+		Proto:    resp.Proto,
+		Status:   resp.StatusCode,
+		Headers:  headers,
+		Links:    Links{},
+		Body:     parsed,
+		raw:      data,
+		keyOrder: jsonKeyOrder(ct, data),
+		header:   resp.Header,
 	}
 
 	for k, v := range resp.Header {
@@ -315,6 +657,26 @@ func ParseResponse(resp *http.Response) (Response, error) {
 		headers[k] = strings.Join(v, joiner)
 	}
 
+	// Trailers are only available once the body has been read above.
+	if len(resp.Trailer) > 0 {
+		output.Trailers = map[string]string{}
+		for k, v := range resp.Trailer {
+			if len(v) > 0 {
+				output.Trailers[k] = strings.Join(v, ", ")
+			}
+		}
+	}
+
+	// gRPC-Web sends trailers as the last frame of the body instead.
+	if trailers := grpcWebTrailers(resp.Header, data); len(trailers) > 0 {
+		if output.Trailers == nil {
+			output.Trailers = map[string]string{}
+		}
+		for k, v := range trailers {
+			output.Trailers[k] = v
+		}
+	}
+
 	if err := ParseLinks(resp.Request.URL, &output); err != nil {
 		LogWarning("Parse links failed")
 		return Response{}, err
@@ -326,13 +688,14 @@ func ParseResponse(resp *http.Response) (Response, error) {
 // GetParsedResponse makes a request and gets the parsed response back. It
 // handles any auto-pagination or linking that needs to be done and may
 // return a psuedo-responsse that is a combination of all responses.
-func GetParsedResponse(req *http.Request) (Response, error) {
-	resp, err := MakeRequest(req)
+func GetParsedResponse(req *http.Request, options ...requestOption) (Response, error) {
+	start := time.Now()
+	resp, err := MakeRequest(req, options...)
 	if err != nil {
 		return Response{}, err
 	}
 
-	parsed, err := ParseResponse(resp)
+	parsed, err := parseResponse(resp, options...)
 	if err != nil {
 		LogError("Parse response error")
 		return Response{}, err
@@ -347,13 +710,25 @@ func GetParsedResponse(req *http.Request) (Response, error) {
 	allLinks := parsed.Links
 	for {
 		links := parsed.Links
-		if len(links["next"]) == 0 || viper.GetBool("rsh-no-paginate") {
+		if len(links["next"]) == 0 || viper.GetBool("rsh-no-paginate") || viper.GetBool("rsh-headers-only") {
+			break
+		}
+
+		if links["next"][0].IsTemplate {
+			LogWarning("Skipping auto-pagination: unable to expand rel=next link template %s", links["next"][0].URI)
 			break
 		}
 
 		LogDebug("Found pagination via rel=next link: %s", links["next"][0].URI)
 
-		if _, ok := parsed.Body.([]interface{}); !ok {
+		if embeddedComplete(parsed) {
+			LogDebug("Skipping auto-pagination: all items are already embedded")
+			break
+		}
+
+		// Lists are merged, as are the embedded resources of HAL collections.
+		_, isList := parsed.Body.([]interface{})
+		if !isList && len(parsed.Embedded) == 0 {
 			// TODO: support non-list formats like JSON:API
 			LogWarning("Skipping auto-pagination: response body not a list, not sure how to merge")
 			break
@@ -364,39 +739,55 @@ func GetParsedResponse(req *http.Request) (Response, error) {
 		next = base.ResolveReference(next)
 		req, _ = http.NewRequest(http.MethodGet, next.String(), nil)
 
-		resp, err = MakeRequest(req)
+		resp, err = MakeRequest(req, options...)
 		if err != nil {
 			return Response{}, err
 		}
 
 		// Merge the responses
-		parsedNext, err := ParseResponse(resp)
+		parsedNext, err := parseResponse(resp, options...)
 		if err != nil {
 			return Response{}, err
 		}
 
-		if l, ok := parsedNext.Body.([]interface{}); ok {
-			// The last request in the chain will be the one that gets displayed
-			// for the proto/status/headers, plus the merged body/links.
-			parsed.Proto = parsedNext.Proto
-			parsed.Status = parsedNext.Status
-			parsed.Headers = parsedNext.Headers
-			parsed.Links = parsedNext.Links
+		merged := false
+		if l, ok := parsedNext.Body.([]interface{}); ok && isList {
 			parsed.Body = append(parsed.Body.([]interface{}), l...)
+			merged = true
+		} else if !isList {
+			merged = mergeEmbedded(&parsed, parsedNext)
+		}
 
-			for name, links := range parsedNext.Links {
-				allLinks[name] = append(allLinks[name], links...)
-			}
-
-			// Update the total computed size to include the size of each individual
-			// request if the content size is available.
-			if s, err := strconv.ParseInt(parsedNext.Headers["Content-Length"], 10, 64); err == nil {
-				computedSize += s
-			}
-		} else {
+		if !merged {
 			LogWarning("Auto-pagination next page is not a list, aborting")
 			break
 		}
+
+		// The last request in the chain will be the one that gets displayed
+		// for the proto/status/headers, plus the merged body/links.
+		parsed.Proto = parsedNext.Proto
+		parsed.Status = parsedNext.Status
+		parsed.Headers = parsedNext.Headers
+		parsed.header = parsedNext.header
+		parsed.Links = parsedNext.Links
+		parsed.Trailers = parsedNext.Trailers
+
+		// The original body is only that of the first page, so drop it to
+		// use the merged body instead.
+		parsed.raw = nil
+		if parsed.keyOrder != nil {
+			parsed.keyOrder.merge(parsedNext.keyOrder)
+		}
+
+		for name, links := range parsedNext.Links {
+			allLinks[name] = append(allLinks[name], links...)
+		}
+
+		// Update the total computed size to include the size of each individual
+		// request if the content size is available.
+		if s, err := strconv.ParseInt(parsedNext.Headers["Content-Length"], 10, 64); err == nil {
+			computedSize += s
+		}
 	}
 
 	// Set the final response links as a combination of all.
@@ -406,21 +797,58 @@ func GetParsedResponse(req *http.Request) (Response, error) {
 		parsed.Headers["Content-Length"] = fmt.Sprintf("%d", computedSize)
 	}
 
+	parsed.Duration = time.Since(start)
+
 	return parsed, nil
 }
 
 // MakeRequestAndFormat is a convenience function for calling `GetParsedResponse`
 // and then calling the default formatter's `Format` function with the parsed
-// response. Panics on error.
-func MakeRequestAndFormat(req *http.Request) {
-	parsed, err := GetParsedResponse(req)
+// response. Error responses set a non-zero exit code.
+func MakeRequestAndFormat(req *http.Request) error {
+	applyOutputDefaults(req.URL.String())
+
+	options := []requestOption{withMetrics(), withResponseType(viper.GetString("rsh-response-type"))}
+	streamed := false
+	if canStreamItems() {
+		options = append(options, withItemHandler(func(item interface{}) {
+			streamed = true
+			if err := formatStreamItem(item); err != nil {
+				panic(err)
+			}
+		}))
+	}
+
+	// Capture the request before auth and other config values are added, so
+	// it can be saved and run again.
+	captured := captureRequest(req)
+
+	parsed, err := GetParsedResponse(req, options...)
+	if errors.Is(err, errRequestInspected) {
+		return nil
+	}
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	if err := Formatter.Format(parsed); err != nil {
-		panic(err)
+	// Only responses to the user's own requests are saved, not internal ones
+	// like fetching links or pipeline steps.
+	saveLastResponse(req.URL, parsed, captured)
+
+	if !viper.GetBool("rsh-quiet") && !streamed {
+		if err := Formatter.Format(parsed); err != nil {
+			return err
+		}
 	}
+
+	if err := checkAssert(parsed); err != nil {
+		return exitError(ExitCodeUsage, err, "invalid --rsh-assert")
+	}
+
+	checkGRPCStatus(parsed)
+	checkStatus(parsed)
+
+	return nil
 }
 
 // BestEffortSystemCertPool returns system cert pool as best effort, otherwise an empty cert pool
@@ -1,10 +1,17 @@
 package cli
 
 import (
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/h2non/gock.v1"
 )
@@ -57,6 +64,27 @@ func TestRequestPagination(t *testing.T) {
 	assert.Equal(t, []interface{}{1.0, 2.0, 3.0, 4.0, 5.0, 6.0}, resp.Body)
 }
 
+func TestRequestPaginationFormatted(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").
+		Get("/people").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "text/csv").
+		SetHeader("Link", "</people2>; rel=\"next\"").
+		BodyString("name,age\nalice,30\n")
+	gock.New("http://example.com").
+		Get("/people2").
+		Reply(http.StatusOK).
+		SetHeader("Content-Type", "text/csv").
+		BodyString("name,age\nbob,40\n")
+
+	// Every page is shown rather than only the original body of the first.
+	out := run("get http://example.com/people")
+	assert.Contains(t, out, "alice")
+	assert.Contains(t, out, "bob")
+}
+
 type authHookFailure struct{}
 
 func (a *authHookFailure) Parameters() []AuthParam {
@@ -81,7 +109,361 @@ func TestAuthHookFailure(t *testing.T) {
 	authHandlers["hook-fail"] = &authHookFailure{}
 
 	r, _ := http.NewRequest(http.MethodGet, "/test", nil)
-	assert.PanicsWithError(t, "some-error", func() {
-		MakeRequest(r)
+	_, err := MakeRequest(r)
+	assert.EqualError(t, err, "auth hook-fail failed: some-error")
+	assert.Equal(t, ExitCodeClientError, exitCodeFor(err))
+}
+
+func TestAssert(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("http://example.com").Get("/health").Times(2).Reply(200).JSON(map[string]interface{}{
+		"status": "ok",
+		"errors": []interface{}{},
 	})
+
+	out := run("http://example.com/health --rsh-quiet --rsh-assert body.status")
+	assert.Equal(t, "", out)
+	assert.Equal(t, 0, GetExitCode())
+
+	// Empty lists are false.
+	out = run("http://example.com/health --rsh-assert body.errors")
+	assert.Contains(t, out, "Assertion failed: body.errors")
+	assert.Equal(t, 1, GetExitCode())
+}
+
+func TestNDJSONStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, line := range []string{`{"id": 1}`, `oops`, `{"id": 2}`} {
+			w.Write([]byte(line + "\n"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	// Items are printed as they arrive without the response headers.
+	out := run(server.URL)
+	assert.NotContains(t, out, "HTTP/1.1 200 OK")
+	assert.Contains(t, out, "{\n  id: 1\n}\nWARN: Skipping invalid JSON on line 2")
+	assert.Contains(t, out, "\n{\n  id: 2\n}\n")
+
+	// Structured output still gets the whole response.
+	out = run("-o json -f body " + server.URL)
+	assert.JSONEq(t, `[{"id": 1}, {"id": 2}]`, out[strings.Index(out, "["):])
+}
+
+func TestCustomHeaderValues(t *testing.T) {
+	defer gock.Off()
+
+	var captured http.Header
+	capture := func(req *http.Request, ereq *gock.Request) (bool, error) {
+		captured = req.Header.Clone()
+		return true, nil
+	}
+
+	// A single comma-separated list is sent verbatim as one value.
+	gock.New("http://example.com").Get("/headers").AddMatcher(capture).Reply(http.StatusOK)
+	run("http://example.com/headers -H Accept:a,b")
+	assert.Equal(t, []string{"a,b"}, captured.Values("Accept"))
+
+	// Repeating a header sends each value separately.
+	gock.New("http://example.com").Get("/headers").AddMatcher(capture).Reply(http.StatusOK)
+	run("http://example.com/headers -H X-Tag:a -H x-tag:b,c")
+	assert.Equal(t, []string{"a", "b,c"}, captured.Values("X-Tag"))
+
+	// Custom headers replace profile headers with the same name, while other
+	// profile headers are kept.
+	reset(false)
+	configs["header-merge"] = &APIConfig{
+		name: "header-merge",
+		Base: "http://header-merge.example.com",
+		Profiles: map[string]*APIProfile{
+			"default": {
+				Headers: map[string]string{"X-Tag": "profile", "X-Other": "kept"},
+			},
+		},
+	}
+	gock.New("http://header-merge.example.com").Get("/").AddMatcher(capture).Reply(http.StatusOK)
+	runNoReset("http://header-merge.example.com/ -H X-Tag:a -H X-Tag:b")
+	assert.Equal(t, []string{"a", "b"}, captured.Values("X-Tag"))
+	assert.Equal(t, []string{"kept"}, captured.Values("X-Other"))
+}
+
+func TestUserAgent(t *testing.T) {
+	defer gock.Off()
+
+	var captured http.Header
+	capture := func(req *http.Request, ereq *gock.Request) (bool, error) {
+		captured = req.Header.Clone()
+		return true, nil
+	}
+
+	gock.New("http://example.com").Get("/").AddMatcher(capture).Reply(http.StatusOK)
+	run("http://example.com/")
+	assert.Equal(t, "restish/"+Root.Version, captured.Get("User-Agent"))
+
+	gock.New("http://example.com").Get("/").AddMatcher(capture).Reply(http.StatusOK)
+	run("http://example.com/ --rsh-user-agent flag-agent/1.0")
+	assert.Equal(t, "flag-agent/1.0", captured.Get("User-Agent"))
+
+	setup := func() {
+		reset(false)
+		configs["agent-test"] = &APIConfig{
+			name:      "agent-test",
+			Base:      "http://agent.example.com",
+			UserAgent: "config-agent/1.0",
+		}
+	}
+
+	setup()
+	gock.New("http://agent.example.com").Get("/").AddMatcher(capture).Reply(http.StatusOK)
+	runNoReset("http://agent.example.com/")
+	assert.Equal(t, "config-agent/1.0", captured.Get("User-Agent"))
+
+	// Headers still take precedence, and an empty one sends no agent at all.
+	setup()
+	gock.New("http://agent.example.com").Get("/").AddMatcher(capture).Reply(http.StatusOK)
+	runNoReset("http://agent.example.com/ -H User-Agent:header-agent/1.0")
+	assert.Equal(t, "header-agent/1.0", captured.Get("User-Agent"))
+
+	setup()
+	gock.New("http://agent.example.com").Get("/").AddMatcher(capture).Reply(http.StatusOK)
+	runNoReset("http://agent.example.com/ -H User-Agent:")
+	assert.Equal(t, []string{""}, captured.Values("User-Agent"))
+}
+
+func TestConfigDefaults(t *testing.T) {
+	global := map[string]string{"X-Source": "cli", "X-Team": "core", "X-Removed": "global"}
+	profile := map[string]string{"x-team": "api", "X-Removed": ""}
+
+	values, sources := configDefaults(global, profile, "prod", true)
+	assert.Equal(t, map[string]string{"X-Source": "cli", "x-team": "api"}, values)
+	assert.Equal(t, map[string]string{"X-Source": "global config", "x-team": "profile prod"}, sources)
+
+	// Query param names are case-sensitive.
+	values, _ = configDefaults(map[string]string{"userId": "1"}, map[string]string{"userid": "2"}, "default", false)
+	assert.Equal(t, map[string]string{"userId": "1", "userid": "2"}, values)
+
+	values, _ = configDefaults(nil, nil, "default", true)
+	assert.Empty(t, values)
+}
+
+func TestGlobalDefaults(t *testing.T) {
+	defer gock.Off()
+
+	var captured *http.Request
+	capture := func(req *http.Request, ereq *gock.Request) (bool, error) {
+		captured = req
+		return true, nil
+	}
+
+	setup := func() {
+		reset(false)
+		globalDefaults = APIProfile{
+			Headers: map[string]string{"X-Request-Source": "cli", "X-Team": "core", "X-Removed": "global"},
+			Query:   map[string]string{"team": "core", "teamId": "1"},
+		}
+		configs["global-defaults"] = &APIConfig{
+			name: "global-defaults",
+			Base: "http://global-defaults.example.com",
+			Profiles: map[string]*APIProfile{
+				"default": {
+					Headers: map[string]string{"x-team": "api", "X-Removed": ""},
+					Query:   map[string]string{"teamId": "2"},
+				},
+			},
+		}
+	}
+	defer func() {
+		globalDefaults = APIProfile{}
+		enableVerbose = false
+	}()
+
+	// Global values are sent with every request, even without an API.
+	setup()
+	gock.New("http://other.example.com").Get("/").AddMatcher(capture).Reply(http.StatusOK)
+	runNoReset("http://other.example.com/")
+	assert.Equal(t, "cli", captured.Header.Get("X-Request-Source"))
+	assert.Equal(t, "core", captured.URL.Query().Get("team"))
+
+	// Profile values override global ones and flags override both, while an
+	// empty value removes an inherited one.
+	setup()
+	gock.New("http://global-defaults.example.com").Get("/").AddMatcher(capture).Reply(http.StatusOK)
+	out := runNoReset("http://global-defaults.example.com/ -H X-Request-Source: -q team=flag -v")
+	assert.Equal(t, []string{"api"}, captured.Header.Values("X-Team"))
+	assert.Empty(t, captured.Header.Values("X-Removed"))
+	assert.Empty(t, captured.Header.Values("X-Request-Source"))
+	assert.Equal(t, []string{"flag"}, captured.URL.Query()["team"])
+	assert.Equal(t, []string{"2"}, captured.URL.Query()["teamId"])
+
+	// Verbose output shows where each value came from.
+	assert.Contains(t, out, "Header x-team from profile default")
+	assert.Contains(t, out, "Query param teamId from profile default")
+	assert.Contains(t, out, "Query param team from flag")
+	assert.Contains(t, out, "Header X-Request-Source removed by flag")
+	assert.NotContains(t, out, "X-Request-Source from")
+}
+
+func TestLoadGlobalDefaults(t *testing.T) {
+	reset(false)
+	defer func() {
+		reset(false)
+		globalDefaults = APIProfile{}
+	}()
+
+	filename := path.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("rsh-verbose: false\nheaders:\n  X-Request-Source: cli\nquery:\n  teamId: \"7\"\n"), 0600))
+	viper.SetConfigFile(filename)
+	assert.NoError(t, viper.ReadInConfig())
+
+	loadGlobalDefaults()
+	assert.Equal(t, map[string]string{"X-Request-Source": "cli"}, globalDefaults.Headers)
+	assert.Equal(t, map[string]string{"teamId": "7"}, globalDefaults.Query)
+}
+
+func TestHALEmbeddedPagination(t *testing.T) {
+	defer gock.Off()
+	reset(false)
+
+	// All items are embedded in the first page, so the next page isn't fetched.
+	gock.New("http://example.com").
+		Get("/hal").
+		Reply(http.StatusOK).
+		JSON(map[string]interface{}{
+			"total": 2,
+			"_links": map[string]interface{}{
+				"next": map[string]interface{}{"href": "/hal?page=2"},
+			},
+			"_embedded": map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"_links": map[string]interface{}{"self": map[string]interface{}{"href": "/items/1"}}},
+					map[string]interface{}{"_links": map[string]interface{}{"self": map[string]interface{}{"href": "/items/2"}}},
+				},
+			},
+		})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/hal", nil)
+	resp, err := GetParsedResponse(req)
+	assert.NoError(t, err)
+	assert.True(t, gock.IsDone())
+	assert.Len(t, resp.Embedded["items"], 2)
+	assert.Equal(t, "http://example.com/items/2", resp.Links["embedded-items"][1].URI)
+	assert.True(t, embeddedComplete(resp))
+
+	resp.Body.(map[string]interface{})["total"] = 3.0
+	assert.False(t, embeddedComplete(resp))
+}
+
+func halItems(ids ...int) []interface{} {
+	items := []interface{}{}
+	for _, id := range ids {
+		items = append(items, map[string]interface{}{
+			"id":     id,
+			"_links": map[string]interface{}{"self": map[string]interface{}{"href": fmt.Sprintf("/items/%d", id)}},
+		})
+	}
+	return items
+}
+
+func TestHALEmbeddedPaginationMerge(t *testing.T) {
+	defer gock.Off()
+	reset(false)
+	viper.Set("rsh-precise-numbers", true)
+
+	// Embedded items are collected across pages until the total is reached,
+	// even though the last page still links to another one.
+	gock.New("http://example.com").
+		Get("/hal").
+		Reply(http.StatusOK).
+		JSON(map[string]interface{}{
+			"page":      map[string]interface{}{"totalElements": 3},
+			"_links":    map[string]interface{}{"next": map[string]interface{}{"href": "/hal?page=2"}},
+			"_embedded": map[string]interface{}{"items": halItems(1, 2)},
+		})
+	gock.New("http://example.com").
+		Get("/hal").
+		MatchParam("page", "2").
+		Reply(http.StatusOK).
+		JSON(map[string]interface{}{
+			"page":      map[string]interface{}{"totalElements": 3},
+			"_links":    map[string]interface{}{"next": map[string]interface{}{"href": "/hal?page=3"}},
+			"_embedded": map[string]interface{}{"items": halItems(3)},
+		})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/hal", nil)
+	resp, err := GetParsedResponse(req)
+	assert.NoError(t, err)
+	assert.True(t, gock.IsDone())
+	assert.Len(t, resp.Embedded["items"], 3)
+	assert.Len(t, resp.Body.(map[string]interface{})["_embedded"].(map[string]interface{})["items"], 3)
+	assert.Len(t, resp.Links["embedded-items"], 3)
+}
+
+// newPrivateCAServer starts a TLS server whose certificate isn't trusted by
+// the system and writes that certificate to a file to use as a CA.
+func newPrivateCAServer(t *testing.T) (*httptest.Server, string) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	// Every request must do a TLS handshake to check the trusted CAs.
+	server.Config.SetKeepAlivesEnabled(false)
+	server.StartTLS()
+
+	filename := path.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	return server, filename
+}
+
+func TestAPICACert(t *testing.T) {
+	reset(false)
+	gock.Off()
+
+	server, caFile := newPrivateCAServer(t)
+	defer server.Close()
+
+	configs["private-ca"] = &APIConfig{
+		name: "private-ca",
+		Base: server.URL + "/api",
+		TLS:  &TLSConfig{CACert: caFile},
+	}
+	defer delete(configs, "private-ca")
+
+	// Both generic requests and requests for the API trust its CA.
+	assert.Contains(t, runNoReset(server.URL+"/api/items"), "ok: true")
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/items/1", nil)
+	resp, err := MakeRequest(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Other URLs on the same host don't trust it.
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/other", nil)
+	_, err = MakeRequest(req)
+	assert.True(t, isUnknownAuthority(err), "%v", err)
+
+	// The CLI flag works without an API but isn't saved to other APIs.
+	viper.Set("rsh-ca-cert", caFile)
+	defer viper.Set("rsh-ca-cert", "")
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/other", nil)
+	resp, err = MakeRequest(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, &TLSConfig{CACert: caFile}, configs["private-ca"].TLS)
+
+	configs["private-ca"].TLS.CACert = path.Join(t.TempDir(), "missing.pem")
+	viper.Set("rsh-ca-cert", "")
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/api/items", nil)
+	_, err = MakeRequest(req)
+	assert.Error(t, err)
+
+	// Clients which aren't made for an API never trust its CA.
+	if dt, ok := http.DefaultTransport.(*http.Transport); ok && dt.TLSClientConfig != nil {
+		assert.Nil(t, dt.TLSClientConfig.RootCAs)
+	}
+	_, err = http.Get(server.URL + "/api/items")
+	assert.True(t, isUnknownAuthority(err), "%v", err)
 }
@@ -0,0 +1,339 @@
+package cli
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gbl08ma/httpcache"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// responseCacheDir returns the directory where HTTP responses are cached.
+func responseCacheDir() string {
+	return path.Join(cacheDir(), "responses")
+}
+
+// encryptedCache encrypts response cache entries at rest using AES-GCM. The
+// cache key is used as additional data so entries can't be swapped. Entries
+// which fail to decrypt, e.g. because they are corrupted, were written without
+// encryption, or used a different key, are treated as misses and removed so
+// they get overwritten.
+type encryptedCache struct {
+	cache httpcache.Cache
+	aead  cipher.AEAD
+}
+
+func newEncryptedCache(cache httpcache.Cache, key []byte) (*encryptedCache, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptedCache{cache: cache, aead: aead}, nil
+}
+
+// seal encrypts `b` for the given key, prefixing it with a random nonce.
+func (c *encryptedCache) seal(key string, b []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return c.aead.Seal(nonce, nonce, b, []byte(key)), nil
+}
+
+// open decrypts data encrypted via `seal` for the given key.
+func (c *encryptedCache) open(key string, sealed []byte) ([]byte, bool) {
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, false
+	}
+
+	b, err := c.aead.Open(nil, sealed[:size], sealed[size:], []byte(key))
+	return b, err == nil
+}
+
+func (c *encryptedCache) Get(key string) ([]byte, bool) {
+	sealed, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	if b, ok := c.open(key, sealed); ok {
+		return b, true
+	}
+
+	LogDebug("Unable to decrypt cached response for %s, ignoring", key)
+	c.cache.Delete(key)
+	return nil, false
+}
+
+func (c *encryptedCache) Set(key string, b []byte) {
+	sealed, err := c.seal(key, b)
+	if err != nil {
+		LogWarning("Unable to encrypt cached response: %v", err)
+		return
+	}
+
+	c.cache.Set(key, sealed)
+}
+
+func (c *encryptedCache) Delete(key string) {
+	c.cache.Delete(key)
+}
+
+// responseCacheKey loads the local key used to encrypt cached responses,
+// generating a new one if needed. The key lives in the config directory so
+// that it isn't stored next to the data it protects. Restish has no OS
+// keychain integration to manage it instead, so it is a file readable only by
+// the current user.
+func responseCacheKey() ([]byte, error) {
+	filename := path.Join(viper.GetString("config-directory"), "cache.key")
+
+	key, err := ioutil.ReadFile(filename)
+	if err == nil {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("invalid cache key %s: expected 32 bytes", filename)
+		}
+		return key, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(path.Dir(filename), 0700); err != nil {
+		return nil, err
+	}
+
+	if err := writeFileAtomic(filename, key, 0600); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// cappedCache enforces a maximum total size for the on-disk response cache,
+// removing the oldest entries once it grows past the limit. Rather than
+// walking the cache directory after every write, the total size is computed
+// on the first write and then tracked as entries are added, so the directory
+// is only walked again once the tracked size passes the limit. Overwritten
+// entries are counted twice, which at worst causes an extra walk.
+type cappedCache struct {
+	httpcache.Cache
+	dir string
+	max int64
+
+	mu   sync.Mutex
+	size int64
+}
+
+func newCappedCache(cache httpcache.Cache, dir string, max int64) *cappedCache {
+	return &cappedCache{Cache: cache, dir: dir, max: max, size: -1}
+}
+
+func (c *cappedCache) Set(key string, b []byte) {
+	c.Cache.Set(key, b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size >= 0 && c.size+int64(len(b)) <= c.max {
+		c.size += int64(len(b))
+		return
+	}
+
+	_, _, size, err := trimResponseCache(c.dir, c.max)
+	if err != nil {
+		LogWarning("Unable to enforce cache size: %v", err)
+		return
+	}
+	c.size = size
+}
+
+// parseByteSize parses sizes like `512KB` or `100MB` into a number of bytes.
+// Units are powers of 1024 and a plain number is in bytes.
+func parseByteSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	return n * multiplier, nil
+}
+
+// formatByteSize formats a number of bytes for display, e.g. `1.5MB`.
+func formatByteSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
+
+// responseCacheMaxSize returns the configured response cache size cap, or
+// zero if there is no cap.
+func responseCacheMaxSize() (int64, error) {
+	size := viper.GetString("rsh-cache-max-size")
+	if size == "" {
+		return 0, nil
+	}
+
+	return parseByteSize(size)
+}
+
+// gcResponseCache removes the oldest cached responses in `dir` until the total
+// size is at most `max` bytes, returning the number of removed entries and
+// bytes freed.
+func gcResponseCache(dir string, max int64) (int, int64, error) {
+	removed, freed, _, err := trimResponseCache(dir, max)
+	return removed, freed, err
+}
+
+// trimResponseCache works like gcResponseCache but also returns the total size
+// of the remaining entries.
+func trimResponseCache(dir string, max int64) (int, int64, int64, error) {
+	type entry struct {
+		path string
+		info fs.FileInfo
+	}
+
+	entries := []entry{}
+	var total int64
+
+	err := filepath.Walk(dir, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			entries = append(entries, entry{p, info})
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	if total <= max {
+		return 0, 0, total, nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].info.ModTime().Before(entries[j].info.ModTime())
+	})
+
+	removed := 0
+	var freed int64
+	for _, e := range entries {
+		if total <= max {
+			break
+		}
+
+		if err := os.Remove(e.path); err != nil {
+			return removed, freed, total, err
+		}
+
+		removed++
+		freed += e.info.Size()
+		total -= e.info.Size()
+	}
+
+	return removed, freed, total, nil
+}
+
+func cacheCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Cache management commands",
+	}
+	cmd.AddCommand(userCacheCommands()...)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "gc",
+		Short: "Clean up cached data",
+		Long:  "Remove cached API descriptions and auth tokens for APIs which are no longer configured. If `rsh-cache-max-size` is set, the oldest cached responses are also removed until the cache is within that size.",
+		Example: fmt.Sprintf(`  # Remove data for APIs which no longer exist
+  $ %s cache gc
+
+  # Also shrink the response cache to at most 50MB
+  $ %s cache gc --rsh-cache-max-size 50MB`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			pruned, err := pruneCache()
+			if err != nil {
+				panic(err)
+			}
+
+			fmt.Fprintf(Stdout, "Removed cached data for %d APIs\n", len(pruned))
+
+			max, err := responseCacheMaxSize()
+			if err != nil {
+				panic(err)
+			}
+
+			if max == 0 {
+				return
+			}
+
+			removed, freed, err := gcResponseCache(responseCacheDir(), max)
+			if err != nil {
+				panic(err)
+			}
+
+			fmt.Fprintf(Stdout, "Removed %d cached responses (%s)\n", removed, formatByteSize(freed))
+		},
+	})
+
+	return cmd
+}
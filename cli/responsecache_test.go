@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptedCache(t *testing.T) {
+	inner := memoryCache{}
+	key := bytes.Repeat([]byte{1}, 32)
+
+	cache, err := newEncryptedCache(inner, key)
+	assert.NoError(t, err)
+
+	cache.Set("http://example.com/", []byte("secret response"))
+	assert.NotContains(t, string(inner["http://example.com/"]), "secret")
+
+	b, ok := cache.Get("http://example.com/")
+	assert.True(t, ok)
+	assert.Equal(t, "secret response", string(b))
+
+	// A different key can't read the entry, which is removed.
+	other, err := newEncryptedCache(inner, bytes.Repeat([]byte{2}, 32))
+	assert.NoError(t, err)
+	_, ok = other.Get("http://example.com/")
+	assert.False(t, ok)
+	assert.NotContains(t, inner, "http://example.com/")
+
+	// Corrupted and plaintext entries are misses.
+	inner["http://example.com/plain"] = []byte("HTTP/1.1 200 OK\r\n\r\n")
+	_, ok = cache.Get("http://example.com/plain")
+	assert.False(t, ok)
+
+	inner["http://example.com/short"] = []byte("x")
+	_, ok = cache.Get("http://example.com/short")
+	assert.False(t, ok)
+}
+
+func TestParseByteSize(t *testing.T) {
+	for input, expected := range map[string]int64{
+		"100":    100,
+		"10B":    10,
+		"512KB":  512 * 1024,
+		"50mb":   50 * 1024 * 1024,
+		"2 GB":   2 * 1024 * 1024 * 1024,
+		"  1KB ": 1024,
+	} {
+		size, err := parseByteSize(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, size, input)
+	}
+
+	_, err := parseByteSize("lots")
+	assert.Error(t, err)
+	_, err = parseByteSize("-1MB")
+	assert.Error(t, err)
+}
+
+func TestGCResponseCache(t *testing.T) {
+	dir := t.TempDir()
+
+	for i, name := range []string{"oldest", "middle", "newest"} {
+		filename := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(filename, bytes.Repeat([]byte("a"), 100), 0600))
+		mtime := time.Now().Add(time.Duration(i-3) * time.Hour)
+		assert.NoError(t, os.Chtimes(filename, mtime, mtime))
+	}
+
+	removed, freed, err := gcResponseCache(dir, 250)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, int64(100), freed)
+	assert.NoFileExists(t, filepath.Join(dir, "oldest"))
+	assert.FileExists(t, filepath.Join(dir, "newest"))
+
+	// Already under the limit.
+	removed, _, err = gcResponseCache(dir, 250)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	// Missing directories are treated as empty.
+	removed, _, err = gcResponseCache(filepath.Join(dir, "missing"), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+}
+
+// fileCache stores entries as files named after their keys.
+type fileCache string
+
+func (c fileCache) Get(key string) ([]byte, bool) {
+	b, err := ioutil.ReadFile(filepath.Join(string(c), key))
+	return b, err == nil
+}
+
+func (c fileCache) Set(key string, b []byte) {
+	ioutil.WriteFile(filepath.Join(string(c), key), b, 0600)
+}
+
+func (c fileCache) Delete(key string) {
+	os.Remove(filepath.Join(string(c), key))
+}
+
+func TestCappedCache(t *testing.T) {
+	dir := t.TempDir()
+	cache := newCappedCache(fileCache(dir), dir, 350)
+	data := bytes.Repeat([]byte("a"), 100)
+
+	cache.Set("a", data)
+	cache.Set("b", data)
+	for i, name := range []string{"a", "b"} {
+		mtime := time.Now().Add(time.Duration(i-3) * time.Hour)
+		assert.NoError(t, os.Chtimes(filepath.Join(dir, name), mtime, mtime))
+	}
+
+	// Files written outside the cache aren't seen until the tracked size
+	// passes the limit, as the directory isn't walked on every write.
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "other"), data, 0600))
+	cache.Set("c", data)
+	assert.FileExists(t, filepath.Join(dir, "a"))
+	assert.Equal(t, int64(300), cache.size)
+
+	cache.Set("d", data)
+	assert.NoFileExists(t, filepath.Join(dir, "a"))
+	assert.NoFileExists(t, filepath.Join(dir, "b"))
+	assert.FileExists(t, filepath.Join(dir, "other"))
+	assert.FileExists(t, filepath.Join(dir, "d"))
+	assert.Equal(t, int64(300), cache.size)
+}
@@ -0,0 +1,349 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// savedRequest is a request saved under a name in the `saved` section of the
+// global config so that it can be run again. Headers are `Name: value` pairs
+// and the body is sent exactly as saved.
+type savedRequest struct {
+	Method  string   `json:"method"`
+	URL     string   `json:"url"`
+	Headers []string `json:"headers,omitempty"`
+	Body    string   `json:"body,omitempty"`
+	Filter  string   `json:"filter,omitempty"`
+	Output  string   `json:"output,omitempty"`
+}
+
+// String describes the request like `GET https://example.com/items`.
+func (r savedRequest) String() string {
+	parts := []string{r.Method, r.URL}
+	for _, h := range r.Headers {
+		parts = append(parts, "-H", h)
+	}
+	if r.Filter != "" {
+		parts = append(parts, "-f", r.Filter)
+	}
+	if r.Output != "" {
+		parts = append(parts, "-o", r.Output)
+	}
+	return strings.Join(parts, " ")
+}
+
+// captureRequest describes a request made on behalf of the user so that it
+// can be saved and run again. Header and query param flags are included,
+// while values added from the API config, like auth, are not.
+func captureRequest(req *http.Request) *savedRequest {
+	u := *req.URL
+	if params := viper.GetStringSlice("rsh-query"); len(params) > 0 {
+		query := u.Query()
+		for _, q := range params {
+			name, value, _ := strings.Cut(q, "=")
+			query.Add(name, value)
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	captured := &savedRequest{
+		Method: req.Method,
+		URL:    u.String(),
+		Filter: viper.GetString("rsh-filter"),
+	}
+
+	names := []string{}
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range req.Header[name] {
+			captured.Headers = append(captured.Headers, name+": "+value)
+		}
+	}
+	captured.Headers = append(captured.Headers, viper.GetStringSlice("rsh-header")...)
+
+	if output := viper.GetString("rsh-output-format"); output != "auto" {
+		captured.Output = output
+	}
+
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			if b, err := io.ReadAll(body); err == nil {
+				captured.Body = string(b)
+			}
+		}
+	}
+
+	return captured
+}
+
+// masked returns a copy of the request with secrets in the URL and headers
+// redacted.
+func (r savedRequest) masked() savedRequest {
+	if u, err := url.Parse(r.URL); err == nil {
+		r.URL = maskURL(u)
+	}
+
+	headers := []string{}
+	for _, h := range r.Headers {
+		headers = append(headers, maskParam(h, ":"))
+	}
+	r.Headers = headers
+
+	return r
+}
+
+// withoutSecrets returns a copy of the request without any headers or query
+// params which are likely to hold secrets, along with their names. These
+// belong in an API profile rather than in plain text in the config.
+func (r savedRequest) withoutSecrets() (savedRequest, []string) {
+	removed := []string{}
+
+	if u, err := url.Parse(r.URL); err == nil {
+		query := u.Query()
+		for name := range query {
+			if isSecretName(name) {
+				query.Del(name)
+				removed = append(removed, name)
+			}
+		}
+
+		if len(removed) > 0 {
+			u.RawQuery = query.Encode()
+			r.URL = u.String()
+		}
+	}
+
+	headers := []string{}
+	for _, h := range r.Headers {
+		name, _, _ := strings.Cut(h, ":")
+		if isSecretName(strings.TrimSpace(name)) {
+			removed = append(removed, strings.TrimSpace(name))
+			continue
+		}
+		headers = append(headers, h)
+	}
+	r.Headers = headers
+
+	sort.Strings(removed)
+	return r, removed
+}
+
+// readGlobalConfig reads just the global config file, ignoring any flags and
+// environment variables. The file may not exist yet.
+func readGlobalConfig() (*viper.Viper, error) {
+	filename := globalConfigFile()
+
+	current := viper.New()
+	current.SetConfigFile(filename)
+	if _, err := os.Stat(filename); err == nil {
+		if err := current.ReadInConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	return current, nil
+}
+
+// loadSavedRequests loads the saved requests from the `saved` section of the
+// global config. Names are case-insensitive.
+func loadSavedRequests() (map[string]savedRequest, error) {
+	current, err := readGlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	saved := map[string]savedRequest{}
+	if err := current.UnmarshalKey("saved", &saved); err != nil {
+		return nil, err
+	}
+
+	return saved, nil
+}
+
+// saveRequest saves a request under a name in the global config, replacing
+// any existing request with the same name.
+func saveRequest(name string, request savedRequest) error {
+	if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, ". \t\n") {
+		return fmt.Errorf("invalid name %q", name)
+	}
+
+	if !utf8.ValidString(request.Body) {
+		return errors.New("binary bodies can't be saved")
+	}
+
+	// Store plain values so the config can be written in any format.
+	b, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	value := map[string]interface{}{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return err
+	}
+
+	return withFileLock(globalConfigFile(), func() error {
+		current, err := readGlobalConfig()
+		if err != nil {
+			return err
+		}
+
+		current.Set("saved."+strings.ToLower(name), value)
+		return writeConfigAtomic(current)
+	})
+}
+
+// run makes the saved request and formats the response. Filter and output
+// options passed on the command line take precedence over the saved ones,
+// as do header flags.
+func (r savedRequest) run() error {
+	var body io.Reader
+	if r.Body != "" {
+		body = strings.NewReader(r.Body)
+	}
+
+	req, err := http.NewRequest(r.Method, fixAddress(r.URL), body)
+	if err != nil {
+		return exitError(ExitCodeUsage, err, "invalid URI %s", r.URL)
+	}
+
+	for _, h := range r.Headers {
+		name, value, _ := strings.Cut(h, ":")
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	if r.Filter != "" && viper.GetString("rsh-filter") == "" {
+		viper.Set("rsh-filter", r.Filter)
+	}
+
+	if r.Output != "" && viper.GetString("rsh-output-format") == "auto" {
+		viper.Set("rsh-output-format", r.Output)
+	}
+
+	return MakeRequestAndFormat(req)
+}
+
+// addSavedCommand adds a command to run a request saved as `<api>/<name>`,
+// e.g. by an import, as `<api> <name>` if there is one. It returns whether
+// the command was added.
+func addSavedCommand(parent *cobra.Command, apiName, name string) bool {
+	saved, err := loadSavedRequests()
+	if err != nil {
+		LogWarning("Unable to load saved requests: %v", err)
+		return false
+	}
+
+	request, ok := saved[strings.ToLower(apiName+"/"+name)]
+	if !ok {
+		return false
+	}
+
+	parent.AddCommand(&cobra.Command{
+		Use:   name,
+		Short: request.String(),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return request.run()
+		},
+	})
+
+	return true
+}
+
+func saveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "save name",
+		Short: "Save the last request to run again later",
+		Long:  "Save the last request, including its method, URL, headers, body, and filters, under a name in the `saved` section of the global config. Run it again with the `saved` command. Headers and query params which may hold secrets, like `Authorization`, are not saved and should be set in an API profile instead.",
+		Example: fmt.Sprintf(`  # Save a request
+  $ %s api.example.com/weather -q city=Seattle -f body.temp
+  $ %s save weather
+
+  # Run the saved request
+  $ %s saved weather`, Root.CommandPath(), Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			last, err := loadLastResponse()
+			if err != nil {
+				panic(err)
+			}
+
+			if last.Request == nil {
+				panic(errors.New("the last request can't be saved"))
+			}
+
+			request, removed := last.Request.withoutSecrets()
+			if len(removed) > 0 {
+				LogWarning("Not saving %s as they may contain secrets, set them in an API profile instead", strings.Join(removed, ", "))
+			}
+
+			if err := saveRequest(args[0], request); err != nil {
+				panic(err)
+			}
+
+			LogInfo("Saved %s as %s", request, args[0])
+		},
+	}
+}
+
+func savedCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "saved [name]",
+		Short: "Run or list saved requests",
+		Long:  "Run a request saved via the `save` command. Filter, output, and header flags are applied on top of the saved ones. Without a name, lists the saved requests.",
+		Args:  cobra.MaximumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			saved, _ := loadSavedRequests()
+			names := []string{}
+			for name := range saved {
+				if strings.HasPrefix(name, toComplete) {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			return names, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			saved, err := loadSavedRequests()
+			if err != nil {
+				return err
+			}
+
+			if len(args) > 0 {
+				request, ok := saved[strings.ToLower(args[0])]
+				if !ok {
+					return &ExitError{Code: ExitCodeUsage, Err: fmt.Errorf("no saved request named %s", args[0])}
+				}
+
+				LogDebug("Running saved request %s: %s", args[0], request)
+				return request.run()
+			}
+
+			names := []string{}
+			for name := range saved {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				fmt.Fprintf(Stdout, "%s: %s\n", name, saved[name])
+			}
+
+			return nil
+		},
+	}
+}
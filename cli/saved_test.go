@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestSavedRequests(t *testing.T) {
+	defer gock.Off()
+
+	reset(false)
+	defer os.Remove(globalConfigFile())
+
+	gock.New("http://example.com").Get("/weather").MatchParam("city", "Seattle").Times(4).Reply(200).JSON(map[string]interface{}{
+		"temp":  12,
+		"units": "C",
+	})
+
+	run("http://example.com/weather -q city=Seattle -H X-Units:metric -o json -f body.temp")
+
+	out := run("save weather")
+	assert.Contains(t, out, "Saved GET http://example.com/weather?city=Seattle -H X-Units:metric -f body.temp -o json as weather")
+
+	assert.Equal(t, "12\n", run("saved weather"))
+
+	// Flags override the saved ones, wherever they are placed.
+	assert.Equal(t, "\"C\"\n", run("saved weather -f body.units"))
+	assert.Equal(t, "\"C\"\n", run("-f body.units saved weather"))
+
+	assert.Equal(t, "weather: GET http://example.com/weather?city=Seattle -H X-Units:metric -f body.temp -o json\n", run("saved"))
+	assert.Contains(t, run("saved missing"), "no saved request named missing")
+
+	assert.Error(t, saveRequest("-bad", savedRequest{Method: "GET", URL: "http://example.com"}))
+	assert.Error(t, saveRequest("has.dot", savedRequest{Method: "GET", URL: "http://example.com"}))
+	assert.True(t, gock.IsDone())
+}
+
+func TestSavedRequestStdinBody(t *testing.T) {
+	defer gock.Off()
+
+	reset(false)
+	defer os.Remove(globalConfigFile())
+
+	gock.New("http://example.com").Post("/items").BodyString(`{"name": "book"}`).Times(2).Reply(201).JSON(map[string]interface{}{"id": 1})
+
+	WithFakeStdin([]byte(`{"name": "book"}`), 0, func() {
+		run("post http://example.com/items")
+	})
+	run("save create-item")
+
+	saved, err := loadSavedRequests()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name": "book"}`, saved["create-item"].Body)
+
+	assert.Equal(t, "1\n", run("saved create-item -f body.id"))
+	assert.True(t, gock.IsDone())
+}
+
+func TestSavedRequestSecrets(t *testing.T) {
+	defer gock.Off()
+
+	reset(false)
+	defer os.Remove(globalConfigFile())
+
+	gock.New("http://example.com").Get("/secure").Reply(200).JSON(map[string]interface{}{"ok": true})
+
+	run("http://example.com/secure -H Authorization:abc123 -q api_key=secret -q page=2")
+	out := run("save secure")
+	assert.Contains(t, out, "Not saving Authorization, api_key")
+
+	saved, err := loadSavedRequests()
+	assert.NoError(t, err)
+	assert.Equal(t, savedRequest{Method: "GET", URL: "http://example.com/secure?page=2"}, saved["secure"])
+	assert.True(t, gock.IsDone())
+}
@@ -0,0 +1,256 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// schemaType returns the type of a JSON Schema, inferring objects and arrays
+// from their keywords when no type is given.
+func schemaType(schema map[string]interface{}) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		// OpenAPI 3.1 style list of types, e.g. `[string, null]`.
+		for _, item := range t {
+			if s, ok := item.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+
+	if _, ok := schema["properties"]; ok {
+		return "object"
+	}
+
+	if _, ok := schema["items"]; ok {
+		return "array"
+	}
+
+	return "string"
+}
+
+// schemaMap returns a child schema, or an empty schema if it is missing.
+func schemaMap(v interface{}) map[string]interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m
+	}
+	return map[string]interface{}{}
+}
+
+// askSchemaValue prompts for a value matching the schema. Objects ask for each
+// required property and whether to include each optional one, while arrays
+// first ask how many items to create.
+func askSchemaValue(a asker, name string, schema map[string]interface{}) interface{} {
+	description, _ := schema["description"].(string)
+	typ := schemaType(schema)
+
+	label := name
+	if label == "" {
+		label = "body"
+	}
+	message := fmt.Sprintf("%s (%s)", label, typ)
+
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		options := []string{}
+		for _, v := range enum {
+			options = append(options, fmt.Sprintf("%v", v))
+		}
+
+		selected := a.askSelect(message, options, nil, description)
+		for _, v := range enum {
+			if fmt.Sprintf("%v", v) == selected {
+				return v
+			}
+		}
+		return selected
+	}
+
+	def := ""
+	if d, ok := schema["default"]; ok && d != nil {
+		def = fmt.Sprintf("%v", d)
+	}
+
+	switch typ {
+	case "object":
+		properties := schemaMap(schema["properties"])
+		required := map[string]bool{}
+		if list, ok := schema["required"].([]interface{}); ok {
+			for _, r := range list {
+				if s, ok := r.(string); ok {
+					required[s] = true
+				}
+			}
+		}
+
+		keys := []string{}
+		for k := range properties {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		value := map[string]interface{}{}
+		for _, k := range keys {
+			prop := schemaMap(properties[k])
+			if prop["readOnly"] == true {
+				continue
+			}
+
+			path := k
+			if name != "" {
+				path = name + "." + k
+			}
+
+			if !required[k] {
+				help, _ := prop["description"].(string)
+				if !a.askConfirm(fmt.Sprintf("Include %s?", path), false, help) {
+					continue
+				}
+			}
+
+			value[k] = askSchemaValue(a, path, prop)
+		}
+		return value
+	case "array":
+		count := 0
+		for {
+			input := a.askInput(fmt.Sprintf("How many items in %s?", label), "1", true, description)
+			n, err := strconv.Atoi(input)
+			if err == nil && n >= 0 {
+				count = n
+				break
+			}
+			LogWarning("Invalid number of items %s", input)
+		}
+
+		items := schemaMap(schema["items"])
+		value := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			value = append(value, askSchemaValue(a, fmt.Sprintf("%s[%d]", label, i), items))
+		}
+		return value
+	case "boolean":
+		return a.askConfirm(message, def == "true", description)
+	case "integer", "number":
+		for {
+			input := a.askInput(message, def, true, description)
+			if typ == "integer" {
+				if n, err := strconv.ParseInt(input, 10, 64); err == nil {
+					return n
+				}
+			} else if n, err := strconv.ParseFloat(input, 64); err == nil {
+				return n
+			}
+			LogWarning("Invalid %s %s", typ, input)
+		}
+	}
+
+	return a.askInput(message, def, true, description)
+}
+
+// bodySchema returns the request body schema for an operation, preferring the
+// schema for its default body media type.
+func bodySchema(op Operation) (map[string]interface{}, bool) {
+	s, ok := op.BodySchemas[op.BodyMediaType]
+	if !ok {
+		mediaTypes := []string{}
+		for mt := range op.BodySchemas {
+			mediaTypes = append(mediaTypes, mt)
+		}
+		if len(mediaTypes) == 0 {
+			return nil, false
+		}
+		sort.Strings(mediaTypes)
+		s = op.BodySchemas[mediaTypes[0]]
+	}
+
+	// Schemas loaded from the API cache may use interface keys.
+	schema, ok := makeJSONSafe(s, false).(map[string]interface{})
+	return schema, ok
+}
+
+// schemaInteractive prompts for an operation's request body and, once
+// confirmed, calls the operation with the path params and body.
+func schemaInteractive(a asker, apiName string, op Operation, pathParams []string) {
+	schema, ok := bodySchema(op)
+	if !ok {
+		panic(fmt.Errorf("operation %s does not have a request body schema", slug.Make(op.Name)))
+	}
+
+	body := askSchemaValue(a, "", schema)
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		panic(err)
+	}
+	input := string(b)
+
+	command := append([]string{Root.CommandPath(), apiName, slug.Make(op.Name)}, pathParams...)
+	fmt.Fprintf(Stdout, "%s --rsh-body %s\n", strings.Join(command, " "), shellQuote(input))
+
+	if !a.askConfirm("Send the request?", true, "") {
+		return
+	}
+
+	// The body is sent as-is via the body flag, as shorthand arguments can't
+	// represent every value, e.g. strings containing commas or newlines.
+	viper.Set("rsh-body", input)
+	defer viper.Set("rsh-body", "")
+
+	sub := op.command()
+	if err := sub.RunE(sub, pathParams); err != nil {
+		panic(err)
+	}
+}
+
+func schemaCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Request body schema commands",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "interactive short-name operation [path-params...]",
+		Short: "Build a request body interactively",
+		Long:  "Prompt for each field of an operation's request body schema, including its type and description. Required fields are always asked for, while optional fields are only included if you choose to. Nested objects are prompted for recursively and arrays first ask how many items to create. The resulting body is shown as JSON along with the equivalent command and the request is made once confirmed.",
+		Example: fmt.Sprintf(`  # Build the body to create an item
+  $ %s schema interactive my-api create-item
+
+  # Path params are passed after the operation
+  $ %s schema interactive my-api update-item item1`, Root.CommandPath(), Root.CommandPath()),
+		Args:              cobra.MinimumNArgs(2),
+		ValidArgsFunction: completeAPINames,
+		Run: func(cmd *cobra.Command, args []string) {
+			config := configs[args[0]]
+			if config == nil {
+				panic(fmt.Errorf("API %s not found", args[0]))
+			}
+
+			api, err := Load(config.Base, &cobra.Command{})
+			if err != nil {
+				panic(err)
+			}
+
+			op, ok := findOperation(api, args[1])
+			if !ok {
+				panic(fmt.Errorf("operation %s not found in API %s", args[1], args[0]))
+			}
+
+			if len(args[2:]) != len(op.PathParams) {
+				panic(fmt.Errorf("operation %s requires %d path params", slug.Make(op.Name), len(op.PathParams)))
+			}
+
+			schemaInteractive(defaultAsker{}, args[0], op, args[2:])
+		},
+	})
+
+	return cmd
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestAskSchemaValue(t *testing.T) {
+	reset(false)
+
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"name", "tags"},
+		"properties": map[string]interface{}{
+			"id":   map[string]interface{}{"type": "string", "readOnly": true},
+			"name": map[string]interface{}{"type": "string", "description": "Item name"},
+			"size": map[string]interface{}{"type": "integer"},
+			"kind": map[string]interface{}{"enum": []interface{}{"a", "b"}},
+			"tags": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			},
+			"owner": map[string]interface{}{
+				"required": []interface{}{"email"},
+				"properties": map[string]interface{}{
+					"email":  map[string]interface{}{"type": "string"},
+					"active": map[string]interface{}{"type": "boolean"},
+				},
+			},
+		},
+	}
+
+	a := &mockAsker{t: t, responses: []string{
+		// kind: include, then select.
+		"y", "b",
+		// name
+		"Foo",
+		// owner: include, then include active, true, and email.
+		"y", "y", "y", "me@example.com",
+		// size: include, invalid, then valid.
+		"y", "big", "5",
+		// tags: two items.
+		"2", "one", "two",
+	}}
+
+	assert.Equal(t, map[string]interface{}{
+		"kind": "b",
+		"name": "Foo",
+		"owner": map[string]interface{}{
+			"active": true,
+			"email":  "me@example.com",
+		},
+		"size": int64(5),
+		"tags": []interface{}{"one", "two"},
+	}, askSchemaValue(a, "", schema))
+	assert.Equal(t, len(a.responses), a.pos)
+}
+
+func TestSchemaInteractive(t *testing.T) {
+	defer gock.Off()
+	reset(false)
+
+	gock.New("http://example.com").
+		Put("/items/item1").
+		MatchType("json").
+		BodyString(`{"name": "Foo"}`).
+		Reply(204)
+
+	op := Operation{
+		Name:          "update-item",
+		Method:        "PUT",
+		URITemplate:   "http://example.com/items/{item-id}",
+		PathParams:    []*Param{{Type: "string", Name: "item-id"}},
+		BodyMediaType: "application/json",
+		BodySchemas: map[string]interface{}{
+			"application/json": map[interface{}]interface{}{
+				"type":     "object",
+				"required": []interface{}{"name"},
+				"properties": map[interface{}]interface{}{
+					"name": map[interface{}]interface{}{"type": "string"},
+				},
+			},
+		},
+	}
+
+	WithFakeStdin([]byte{}, fs.ModeCharDevice, func() {
+		// Declining the confirmation does not make the request.
+		schemaInteractive(&mockAsker{t: t, responses: []string{"Foo", "n"}}, "my-api", op, []string{"item1"})
+		assert.False(t, gock.IsDone())
+
+		schemaInteractive(&mockAsker{t: t, responses: []string{"Foo", "y"}}, "my-api", op, []string{"item1"})
+		assert.True(t, gock.IsDone())
+
+		// Values which shorthand can't represent are sent unchanged.
+		long := "true, or a value: longer than fifty characters which has punctuation"
+		gock.New("http://example.com").
+			Put("/items/item1").
+			MatchType("json").
+			JSON(map[string]interface{}{"name": long}).
+			Reply(204)
+		schemaInteractive(&mockAsker{t: t, responses: []string{long, "y"}}, "my-api", op, []string{"item1"})
+		assert.True(t, gock.IsDone())
+	})
+
+	assert.Panics(t, func() {
+		schemaInteractive(&mockAsker{t: t}, "my-api", Operation{Name: "list-items"}, nil)
+	})
+}
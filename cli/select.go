@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// selectPath splits a field like `user.name` into its nested keys.
+func selectPath(field string) ([]string, error) {
+	keys := strings.Split(strings.TrimPrefix(field, "."), ".")
+	for _, k := range keys {
+		if k == "" {
+			return nil, fmt.Errorf("invalid field %q", field)
+		}
+	}
+	return keys, nil
+}
+
+// selectObject returns a new object with only the fields at the given paths,
+// keeping their nesting so `user.name` gives `{"user": {"name": ...}}`.
+// Missing fields are `null`.
+func selectObject(item interface{}, paths [][]string) map[string]interface{} {
+	selected := map[string]interface{}{}
+
+	for _, keys := range paths {
+		var value interface{} = item
+		for _, k := range keys {
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = m[k]
+		}
+
+		target := selected
+		for _, k := range keys[:len(keys)-1] {
+			next, ok := target[k].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				target[k] = next
+			}
+			target = next
+		}
+		target[keys[len(keys)-1]] = value
+	}
+
+	return selected
+}
+
+// selectArgs returns the fields to select and the input filename, which is a
+// last argument of `-` or the name of an existing file.
+func selectArgs(args []string) ([]string, string) {
+	if len(args) > 1 {
+		last := args[len(args)-1]
+		if last == "-" {
+			return args[:len(args)-1], last
+		}
+		if info, err := os.Stat(last); err == nil && !info.IsDir() {
+			return args[:len(args)-1], last
+		}
+	}
+
+	return args, ""
+}
+
+// selectFields picks the given fields from each object in an array, or from a
+// single object, like `SELECT id, user.name FROM items` in SQL.
+func selectFields(data interface{}, fields []string) (interface{}, error) {
+	paths := make([][]string, 0, len(fields))
+	for _, f := range fields {
+		keys, err := selectPath(f)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, keys)
+	}
+
+	switch v := data.(type) {
+	case []interface{}:
+		rows := make([]interface{}, len(v))
+		for i, item := range v {
+			rows[i] = selectObject(item, paths)
+		}
+		return rows, nil
+	case map[string]interface{}:
+		return selectObject(v, paths), nil
+	}
+
+	return nil, errors.New("input must be an array or object")
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectFields(t *testing.T) {
+	data := []interface{}{
+		map[string]interface{}{
+			"id":    1.0,
+			"extra": true,
+			"user":  map[string]interface{}{"name": "kari", "email": "kari@example.com"},
+		},
+		map[string]interface{}{"id": 2.0, "user": "unknown"},
+		"not an object",
+	}
+
+	selected, err := selectFields(data, []string{"id", ".user.name", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": 1.0, "user": map[string]interface{}{"name": "kari"}, "missing": nil},
+		map[string]interface{}{"id": 2.0, "user": map[string]interface{}{"name": nil}, "missing": nil},
+		map[string]interface{}{"id": nil, "user": map[string]interface{}{"name": nil}, "missing": nil},
+	}, selected)
+
+	// The input is not modified.
+	assert.Equal(t, "kari@example.com", data[0].(map[string]interface{})["user"].(map[string]interface{})["email"])
+
+	// A single object works too.
+	selected, err = selectFields(map[string]interface{}{"a": 1.0, "b": 2.0}, []string{"a"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": 1.0}, selected)
+
+	_, err = selectFields(data, []string{"user..name"})
+	assert.EqualError(t, err, `invalid field "user..name"`)
+
+	_, err = selectFields("text", []string{"a"})
+	assert.EqualError(t, err, "input must be an array or object")
+}
+
+func TestFormatSelect(t *testing.T) {
+	filename := path.Join(t.TempDir(), "items.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`[{"id": 1, "name": "a", "tags": ["x"]}, {"id": 2}]`), 0600))
+
+	assert.JSONEq(t, `[{"id": 1, "name": "a"}, {"id": 2, "name": null}]`, run("format select id name "+filename))
+
+	// A field which isn't a file is selected rather than read.
+	assert.JSONEq(t, `[{"id": 1, "missing": {"json": null}}, {"id": 2, "missing": {"json": null}}]`, run("format select id missing.json "+filename))
+
+	WithFakeStdin([]byte("- id: 3\n  owner:\n    name: kari\n"), 0, func() {
+		assert.JSONEq(t, `[{"owner": {"name": "kari"}}]`, run("format select owner.name"))
+	})
+
+	WithFakeStdin([]byte("- id: 4\n"), 0, func() {
+		assert.JSONEq(t, `[{"id": 4}]`, run("format select id -"))
+	})
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	jmespath "github.com/danielgtaylor/go-jmespath-plus"
+)
+
+// sortRank orders values of different types, with `null` first.
+func sortRank(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64:
+		return 2
+	case string:
+		return 3
+	}
+	return 4
+}
+
+// compareValues compares two values returned by a JMESPath expression.
+// Numbers compare numerically and strings which are both RFC 3339 timestamps
+// compare by time. Values of different types are ordered by type.
+func compareValues(a, b interface{}) int {
+	if ra, rb := sortRank(a), sortRank(b); ra != rb {
+		return ra - rb
+	}
+
+	switch av := a.(type) {
+	case bool:
+		bv := b.(bool)
+		if av == bv {
+			return 0
+		} else if !av {
+			return -1
+		}
+		return 1
+	case float64:
+		bv := b.(float64)
+		if av < bv {
+			return -1
+		} else if av > bv {
+			return 1
+		}
+		return 0
+	case string:
+		bv := b.(string)
+		ta, errA := time.Parse(time.RFC3339Nano, av)
+		tb, errB := time.Parse(time.RFC3339Nano, bv)
+		if errA == nil && errB == nil {
+			if ta.Before(tb) {
+				return -1
+			} else if ta.After(tb) {
+				return 1
+			}
+			return 0
+		}
+		return strings.Compare(av, bv)
+	case nil:
+		return 0
+	}
+
+	// Objects and arrays have no natural order, so compare their text.
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+// sortItems stably sorts items by the results of JMESPath expressions, with
+// later expressions breaking ties. A leading `.` is allowed, e.g. `.name`.
+// Without any expressions the items themselves are compared.
+func sortItems(data interface{}, exprs []string, desc bool) ([]interface{}, error) {
+	items, ok := data.([]interface{})
+	if !ok {
+		return nil, errors.New("input must be an array")
+	}
+
+	compiled := make([]*jmespath.JMESPath, len(exprs))
+	for i, expr := range exprs {
+		var err error
+		if compiled[i], err = jmespath.Compile(strings.TrimPrefix(expr, ".")); err != nil {
+			return nil, fmt.Errorf("sort expression %q: %w", expr, err)
+		}
+	}
+
+	// Compute the keys up front rather than on every comparison.
+	keys := make([][]interface{}, len(items))
+	for i, item := range items {
+		item = makeJSONSafe(item, true)
+		if len(compiled) == 0 {
+			keys[i] = []interface{}{item}
+			continue
+		}
+
+		keys[i] = make([]interface{}, len(compiled))
+		for j, c := range compiled {
+			v, err := c.Search(item)
+			if err != nil {
+				return nil, fmt.Errorf("sort expression %q: %w", exprs[j], err)
+			}
+			keys[i][j] = v
+		}
+	}
+
+	indexes := make([]int, len(items))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	sort.SliceStable(indexes, func(x, y int) bool {
+		for j := range keys[indexes[x]] {
+			if c := compareValues(keys[indexes[x]][j], keys[indexes[y]][j]); c != 0 {
+				if desc {
+					return c > 0
+				}
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	sorted := make([]interface{}, len(items))
+	for i, index := range indexes {
+		sorted[i] = items[index]
+	}
+
+	return sorted, nil
+}
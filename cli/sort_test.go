@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareValues(t *testing.T) {
+	assert.Equal(t, -1, compareValues(1.0, 2.0))
+	assert.Equal(t, 1, compareValues(10.0, 9.0))
+	assert.Equal(t, 0, compareValues("a", "a"))
+	assert.Equal(t, -1, compareValues("a", "b"))
+	assert.Equal(t, -1, compareValues(false, true))
+
+	// Timestamps compare by time rather than text, even across time zones.
+	assert.Equal(t, 1, compareValues("2023-01-01T10:00:00+02:00", "2023-01-01T07:30:00Z"))
+	assert.Equal(t, -1, compareValues("2023-01-01T07:30:00Z", "2023-01-01T09:00:00.5+01:00"))
+
+	// Types are ordered with null first.
+	assert.Less(t, compareValues(nil, false), 0)
+	assert.Less(t, compareValues(true, 0.0), 0)
+	assert.Less(t, compareValues(1.0, "1"), 0)
+}
+
+func TestSortItems(t *testing.T) {
+	items := []interface{}{
+		map[string]interface{}{"id": 1.0, "team": "b", "score": 10.0},
+		map[string]interface{}{"id": 2.0, "team": "a", "score": 10.0},
+		map[string]interface{}{"id": 3.0, "team": "b", "score": 5.0},
+		map[string]interface{}{"id": 4.0, "team": "a", "score": 7.0},
+	}
+
+	ids := func(sorted []interface{}) []interface{} {
+		result := []interface{}{}
+		for _, item := range sorted {
+			result = append(result, item.(map[string]interface{})["id"])
+		}
+		return result
+	}
+
+	sorted, err := sortItems(items, []string{".team"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{2.0, 4.0, 1.0, 3.0}, ids(sorted), "stable")
+
+	sorted, err = sortItems(items, []string{"team", "score"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{4.0, 2.0, 3.0, 1.0}, ids(sorted))
+
+	sorted, err = sortItems(items, []string{"score"}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{1.0, 2.0, 4.0, 3.0}, ids(sorted), "stable when descending")
+
+	sorted, err = sortItems([]interface{}{"b", 2.0, nil, "a", 1.0}, nil, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{nil, 1.0, 2.0, "a", "b"}, sorted)
+
+	_, err = sortItems(map[string]interface{}{}, nil, false)
+	assert.Error(t, err)
+}
+
+func TestFormatSort(t *testing.T) {
+	filename := path.Join(t.TempDir(), "events.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`[
+		{"name": "b", "at": "2023-05-01T12:00:00Z"},
+		{"name": "a", "at": "2023-05-01T09:00:00-05:00"},
+		{"name": "c", "at": "2023-04-30T00:00:00Z"}
+	]`), 0600))
+
+	assert.JSONEq(t, `[
+		{"name": "a", "at": "2023-05-01T09:00:00-05:00"},
+		{"name": "b", "at": "2023-05-01T12:00:00Z"},
+		{"name": "c", "at": "2023-04-30T00:00:00Z"}
+	]`, run("format sort --rsh-by .name "+filename))
+
+	// 09:00-05:00 is the latest time even though it sorts first as text.
+	assert.Equal(t, "a\n", run("format sort --rsh-by at --rsh-desc "+filename+" -f body[0].name -r"))
+	assert.Contains(t, run("format sort --rsh-by name -"), "input must be an array")
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// suggestAPI checks whether the host of an unconfigured URL serves an API
+// description and, if so, prints a hint on how to register it to get named
+// commands. Each host is checked at most once a day.
+func suggestAPI(addr string) {
+	if !viper.GetBool("rsh-suggest-api") {
+		return
+	}
+
+	if name, _ := findAPI(addr); name != "" {
+		return
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil || u.Host == "" {
+		return
+	}
+	base := &url.URL{Scheme: u.Scheme, Host: u.Host}
+
+	key := "suggest." + strings.ReplaceAll(strings.ToLower(u.Host), ".", "-")
+	if checked := Cache.GetTime(key); checked.After(time.Now().Add(-24 * time.Hour)) {
+		return
+	}
+	Cache.Set(key, time.Now())
+	SaveCache()
+
+	specs, err := findSpecs("", nil, base, 0)
+	if err != nil || len(specs) == 0 {
+		LogDebug("No API description found for %s: %v", base, err)
+		return
+	}
+
+	title := base.Host
+	if api, err := specs[0].loader.Load(*base, specs[0].location, specs[0].response()); err == nil && api.Short != "" {
+		title = api.Short
+	}
+
+	LogInfo("This looks like API %q; run `%s api configure %s %s` to get named commands.", title, Root.CommandPath(), nameFromHost(u.Hostname()), base)
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestSuggestAPI(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://suggest.example.com").Get("/items").Reply(200).JSON([]interface{}{1, 2})
+	gock.New("https://suggest.example.com").Get("/openapi.json").Reply(200).JSON(map[string]interface{}{
+		"openapi": "3.0.0",
+	})
+	gock.New("https://suggest.example.com").Get("/").Reply(404)
+
+	reset(false)
+	viper.Set("rsh-suggest-api", true)
+	t.Cleanup(func() {
+		viper.Set("rsh-suggest-api", false)
+		loaders = loaders[:len(loaders)-1]
+	})
+	Cache.Set("suggest.suggest-example-com", time.Time{})
+	AddLoader(&testLoader{API: API{Short: "Suggestion API"}})
+
+	out := runNoReset("get https://suggest.example.com/items")
+	assert.Contains(t, out, `This looks like API "Suggestion API"`)
+	assert.Contains(t, out, "api configure suggest https://suggest.example.com")
+
+	// The host is only checked once.
+	gock.New("https://suggest.example.com").Get("/items").Reply(200).JSON([]interface{}{1, 2})
+	out = runNoReset("get https://suggest.example.com/items")
+	assert.NotContains(t, out, "This looks like API")
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// correlationHeaders are response headers commonly used to echo a trace or
+// request ID, which are highlighted when tracing is enabled.
+var correlationHeaders = []string{
+	"traceparent",
+	"traceresponse",
+	"x-request-id",
+	"x-correlation-id",
+	"x-trace-id",
+	"x-amzn-trace-id",
+	"x-b3-traceid",
+	"x-cloud-trace-context",
+}
+
+// traceID is shared by all requests made during this run so they can be
+// correlated with each other in server logs.
+var traceID string
+var traceIDOnce sync.Once
+
+// randomHex returns `n` random bytes encoded as hex.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// traceHeader returns the name of the header used to send trace IDs.
+func traceHeader() string {
+	if name := viper.GetString("rsh-trace-header"); name != "" {
+		return name
+	}
+	return "traceparent"
+}
+
+// traceValue generates the trace header value for a new request. W3C Trace
+// Context `traceparent` values get a new parent ID for each request, while
+// other headers are sent the trace ID as-is.
+func traceValue(header string) string {
+	traceIDOnce.Do(func() {
+		traceID = randomHex(16)
+	})
+
+	if strings.EqualFold(header, "traceparent") {
+		return "00-" + traceID + "-" + randomHex(8) + "-01"
+	}
+
+	return traceID
+}
+
+// addTraceHeader sets the trace header on a request if tracing is enabled and
+// the header isn't already set, printing the value for correlation.
+func addTraceHeader(req *http.Request) {
+	if !viper.GetBool("rsh-trace") {
+		return
+	}
+
+	header := traceHeader()
+	if req.Header.Get(header) != "" {
+		return
+	}
+
+	value := traceValue(header)
+	req.Header.Set(header, value)
+	LogInfo("Trace %s: %s", http.CanonicalHeaderKey(header), value)
+}
+
+// isCorrelationHeader returns whether a response header likely echoes a trace
+// or request ID.
+func isCorrelationHeader(name string) bool {
+	if strings.EqualFold(name, traceHeader()) {
+		return true
+	}
+
+	for _, h := range correlationHeaders {
+		if strings.EqualFold(name, h) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// highlightCorrelationHeaders makes correlation headers stand out when tracing
+// is enabled. The `highlighted` response head must be the syntax highlighted
+// version of `text`, with the same lines.
+func highlightCorrelationHeaders(text string, highlighted []byte) []byte {
+	if !viper.GetBool("rsh-trace") {
+		return highlighted
+	}
+
+	lines := strings.Split(text, "\n")
+	out := strings.Split(string(highlighted), "\n")
+	if len(lines) != len(out) {
+		return highlighted
+	}
+
+	// Skip the status line.
+	for i := 1; i < len(lines); i++ {
+		if parts := strings.SplitN(lines[i], ":", 2); len(parts) == 2 && isCorrelationHeader(parts[0]) {
+			out[i] = au.Index(214, lines[i]).Bold().String()
+		}
+	}
+
+	return []byte(strings.Join(out, "\n"))
+}
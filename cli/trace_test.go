@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestTraceHeader(t *testing.T) {
+	defer gock.Off()
+
+	var sent string
+	gock.New("http://example.com").
+		Get("/traced").
+		AddMatcher(func(req *http.Request, ereq *gock.Request) (bool, error) {
+			sent = req.Header.Get("traceparent")
+			return true, nil
+		}).
+		Reply(http.StatusOK)
+
+	out := run("http://example.com/traced --rsh-trace")
+	assert.Regexp(t, `^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, sent)
+	assert.Contains(t, out, "Trace Traceparent: "+sent)
+
+	// A custom header gets the same trace ID, while headers which are already
+	// set are left alone.
+	gock.New("http://example.com").
+		Get("/traced").
+		MatchHeader("X-Request-Id", "^"+sent[3:35]+"$").
+		Reply(http.StatusOK)
+
+	out = run("http://example.com/traced --rsh-trace --rsh-trace-header x-request-id")
+	assert.Contains(t, out, "HTTP/1.1 200 OK")
+
+	gock.New("http://example.com").
+		Get("/traced").
+		MatchHeader("traceparent", "^custom$").
+		Reply(http.StatusOK)
+
+	out = run("http://example.com/traced --rsh-trace -H traceparent:custom")
+	assert.NotContains(t, out, "Trace Traceparent")
+}
+
+func TestTraceHighlight(t *testing.T) {
+	defer gock.Off()
+
+	for i := 0; i < 2; i++ {
+		gock.New("http://example.com").
+			Get("/traced").
+			Reply(http.StatusOK).
+			SetHeader("X-Request-Id", "abc123")
+	}
+
+	highlighted := regexp.MustCompile(`\x1b\[[0-9;]*mX-Request-Id: abc123\x1b\[0m`)
+	assert.Regexp(t, highlighted, run("http://example.com/traced --rsh-trace", true))
+	assert.NotRegexp(t, highlighted, run("http://example.com/traced", true))
+}
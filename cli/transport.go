@@ -1,15 +1,25 @@
 package cli
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
-	"path"
+	"net/http/httputil"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gbl08ma/httpcache"
 	"github.com/gbl08ma/httpcache/diskcache"
+	"github.com/peterbourgon/diskv"
+	"github.com/spf13/viper"
 )
 
 // cacheKey returns the cache key for req.
@@ -42,19 +52,78 @@ func shouldCache(resp *http.Response) bool {
 	return true
 }
 
-// CachedTransport returns an HTTP transport with caching abilities.
+// CachedTransport returns an HTTP transport with caching abilities. Cached
+// responses are optionally encrypted at rest and capped in total size.
 func CachedTransport() *httpcache.Transport {
-	t := httpcache.NewTransport(diskcache.New(path.Join(cacheDir(), "responses")))
+	// Responses are written to a temporary directory first and then moved
+	// into place, so an interrupted write can't leave a partial entry.
+	var cache httpcache.Cache = diskcache.NewWithDiskv(diskv.New(diskv.Options{
+		BasePath:     responseCacheDir(),
+		TempDir:      responseCacheDir() + ".tmp",
+		CacheSizeMax: 100 * 1024 * 1024,
+	}))
+
+	// The size cap wraps the disk cache directly so that encrypted entries are
+	// counted at their size on disk.
+	max, err := responseCacheMaxSize()
+	if err != nil {
+		panic(err)
+	}
+	if max > 0 {
+		cache = newCappedCache(cache, responseCacheDir(), max)
+	}
+
+	if viper.GetBool("rsh-cache-encrypt") {
+		key, err := responseCacheKey()
+		if err != nil {
+			panic(fmt.Errorf("unable to load cache key: %w", err))
+		}
+
+		if cache, err = newEncryptedCache(cache, key); err != nil {
+			panic(err)
+		}
+	}
+
+	t := httpcache.NewTransport(cache)
+	t.Transport = apiTransport{}
 	t.MarkCachedResponses = false
 	return t
 }
 
+// apiTransportKey is the request context key for the transport with the TLS
+// options of the request's API.
+type apiTransportKey struct{}
+
+// withAPITransport returns a copy of the request which apiTransport sends
+// via the given transport.
+func withAPITransport(req *http.Request, t *http.Transport) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), apiTransportKey{}, t))
+}
+
+// requestTransport returns the transport with the TLS options of the
+// request's API, or otherwise the default transport.
+func requestTransport(req *http.Request) http.RoundTripper {
+	if t, ok := req.Context().Value(apiTransportKey{}).(*http.Transport); ok {
+		return t
+	}
+	return http.DefaultTransport
+}
+
+// apiTransport sends requests via the transport set by MakeRequest with the
+// TLS options of their API, so those options never leak into requests to
+// other APIs.
+type apiTransport struct{}
+
+func (apiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return requestTransport(req).RoundTrip(req)
+}
+
 type minCachedTransport struct {
 	min time.Duration
 }
 
 func (m minCachedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := (&http.Client{Transport: apiTransport{}}).Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -110,3 +179,256 @@ func InvalidateCachedTransport() http.RoundTripper {
 		transport: CachedTransport(),
 	}
 }
+
+// parseCacheControl parses a `Cache-Control` header into a map of lowercase
+// directive names to their (possibly empty) values.
+func parseCacheControl(header string) map[string]string {
+	directives := map[string]string{}
+
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		kv := strings.SplitN(part, "=", 2)
+		value := ""
+		if len(kv) > 1 {
+			value = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		}
+		directives[strings.ToLower(strings.TrimSpace(kv[0]))] = value
+	}
+
+	return directives
+}
+
+// directiveSeconds returns a directive's value as a duration in seconds.
+func directiveSeconds(directives map[string]string, name string) (time.Duration, bool) {
+	if v, ok := directives[name]; ok {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+
+	return 0, false
+}
+
+type revalidation struct {
+	transport http.RoundTripper
+	req       *http.Request
+}
+
+// pendingRevalidations holds requests for stale cached responses which were
+// served and need to be refreshed before the CLI exits.
+var pendingRevalidations []revalidation
+
+type staleWhileRevalidateTransport struct {
+	transport *httpcache.Transport
+}
+
+// staleResponse returns the cached response for a request if it is stale but
+// still within its `stale-while-revalidate` window, otherwise nil.
+func (s staleWhileRevalidateTransport) staleResponse(req *http.Request) *http.Response {
+	cached, ok := s.transport.Cache.Get(cacheKey(req))
+	if !ok {
+		return nil
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(cached)), req)
+	if err != nil {
+		return nil
+	}
+
+	date, err := http.ParseTime(resp.Header.Get("date"))
+	if err != nil {
+		return nil
+	}
+
+	directives := parseCacheControl(resp.Header.Get("cache-control"))
+	if _, ok := directives["no-cache"]; ok {
+		return nil
+	}
+	if _, ok := directives["must-revalidate"]; ok {
+		return nil
+	}
+
+	maxAge, _ := directiveSeconds(directives, "max-age")
+	swr, _ := directiveSeconds(directives, "stale-while-revalidate")
+	if override := viper.GetDuration("rsh-swr"); override > 0 {
+		swr = override
+	}
+
+	age := time.Since(date)
+	if age < maxAge || age >= maxAge+swr {
+		// Either still fresh, so the normal cache handles it, or too stale to
+		// be served without waiting on the origin.
+		return nil
+	}
+
+	// Mark the response as stale per RFC 7234 section 5.5.1.
+	resp.Header.Add("Warning", `110 - "Response is Stale"`)
+	return resp
+}
+
+func (s staleWhileRevalidateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet && req.Header.Get("range") == "" {
+		if resp := s.staleResponse(req); resp != nil {
+			LogDebug("Serving stale cached response while revalidating %s", req.URL)
+			pendingRevalidations = append(pendingRevalidations, revalidation{
+				transport: s.transport,
+				req:       req.Clone(context.Background()),
+			})
+			return resp, nil
+		}
+	}
+
+	return s.transport.RoundTrip(req)
+}
+
+// StaleWhileRevalidateTransport returns an HTTP transport which will serve
+// stale cached responses immediately if they are within their
+// `stale-while-revalidate` window (or the `rsh-swr` override). Call
+// `RevalidateStale` later to refresh those cache entries.
+func StaleWhileRevalidateTransport(t *httpcache.Transport) http.RoundTripper {
+	return &staleWhileRevalidateTransport{
+		transport: t,
+	}
+}
+
+// RevalidateStale refreshes any cache entries which were served stale. It is
+// meant to be run once output has been written. Failures are only logged in
+// verbose mode since the user already has a usable response.
+func RevalidateStale() {
+	pending := pendingRevalidations
+	pendingRevalidations = nil
+
+	for _, r := range pending {
+		LogDebug("Revalidating stale cache entry %s", r.req.URL)
+		resp, err := r.transport.RoundTrip(r.req)
+		if err != nil {
+			LogDebug("Background cache refresh failed for %s: %v", r.req.URL, err)
+			continue
+		}
+
+		// The cache only gets updated once the body has been fully read.
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+// responseCacheHeaders are the request headers which can change the response
+// for a URL, so they are part of the key for forced response caching.
+var responseCacheHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language", "Authorization", "Cookie"}
+
+// responseCacheEntry is a single cached response for a URL and set of request
+// headers.
+type responseCacheEntry struct {
+	Expires  time.Time `json:"expires"`
+	Response []byte    `json:"response"`
+}
+
+type responseCacheTransport struct {
+	transport http.RoundTripper
+	cache     httpcache.Cache
+	enabled   bool
+	bypass    bool
+	ttl       time.Duration
+}
+
+// key returns the cache key for all entries of a URL. Entries are grouped by
+// URL so that they can be invalidated together.
+func (r responseCacheTransport) key(req *http.Request) string {
+	return "rsh-response " + req.URL.String()
+}
+
+// variant returns a hash of the request headers which affect the response.
+// Hashing keeps secrets like tokens out of the cache entries.
+func (r responseCacheTransport) variant(req *http.Request) string {
+	h := sha256.New()
+	for _, name := range responseCacheHeaders {
+		fmt.Fprintf(h, "%s: %s\n", name, strings.Join(req.Header.Values(name), ", "))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// entries loads the unexpired cached responses for a request's URL.
+func (r responseCacheTransport) entries(req *http.Request) map[string]responseCacheEntry {
+	entries := map[string]responseCacheEntry{}
+
+	if b, ok := r.cache.Get(r.key(req)); ok {
+		if err := json.Unmarshal(b, &entries); err != nil {
+			LogDebug("Ignoring invalid response cache entry for %s: %v", req.URL, err)
+		}
+	}
+
+	now := time.Now()
+	for k, e := range entries {
+		if !e.Expires.After(now) {
+			delete(entries, k)
+		}
+	}
+
+	return entries
+}
+
+func (r responseCacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead && req.Method != http.MethodOptions {
+		// The resource may have changed, so drop any cached copies.
+		r.cache.Delete(r.key(req))
+		return r.transport.RoundTrip(req)
+	}
+
+	if !r.enabled || req.Method != http.MethodGet || req.Header.Get("range") != "" {
+		return r.transport.RoundTrip(req)
+	}
+
+	variant := r.variant(req)
+
+	if !r.bypass {
+		if e, ok := r.entries(req)[variant]; ok {
+			resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(e.Response)), req)
+			if err == nil {
+				LogDebug("Using response for %s from the response cache (cached, expires in %s)", req.URL, time.Until(e.Expires).Round(time.Second))
+				return resp, nil
+			}
+		}
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil || resp.StatusCode >= 400 {
+		return resp, err
+	}
+
+	// Reading the full response replaces the body so it can still be used.
+	dumped, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := r.entries(req)
+	entries[variant] = responseCacheEntry{
+		Expires:  time.Now().Add(r.ttl),
+		Response: dumped,
+	}
+
+	if b, err := json.Marshal(entries); err == nil {
+		r.cache.Set(r.key(req), b)
+	}
+
+	return resp, nil
+}
+
+// ResponseCacheTransport returns an HTTP transport which, when enabled, caches
+// successful `GET` responses for `ttl` regardless of their cache headers.
+// Entries are keyed by URL plus the request headers which affect the response
+// and are invalidated by any other request to the same URL. With `bypass` the
+// cache is not read, but is still updated.
+func ResponseCacheTransport(t http.RoundTripper, cache httpcache.Cache, enabled, bypass bool, ttl time.Duration) http.RoundTripper {
+	return &responseCacheTransport{
+		transport: t,
+		cache:     cache,
+		enabled:   enabled,
+		bypass:    bypass,
+		ttl:       ttl,
+	}
+}
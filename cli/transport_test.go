@@ -1,10 +1,16 @@
 package cli
 
 import (
+	"io/ioutil"
 	"net/http"
+	"net/http/httputil"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gbl08ma/httpcache"
+	"github.com/spf13/viper"
+
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/h2non/gock.v1"
 )
@@ -61,3 +67,138 @@ func TestMinCachedTransport(t *testing.T) {
 	assert.Equal(t, resp.StatusCode, 400)
 	assert.Equal(t, resp.Header.Get("cache-control"), "")
 }
+
+type memoryCache map[string][]byte
+
+func (m memoryCache) Get(key string) ([]byte, bool) {
+	b, ok := m[key]
+	return b, ok
+}
+
+func (m memoryCache) Set(key string, b []byte) {
+	m[key] = b
+}
+
+func (m memoryCache) Delete(key string) {
+	delete(m, key)
+}
+
+func cachedResponse(t *testing.T, age time.Duration, cacheControl string) []byte {
+	resp := &http.Response{
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		StatusCode: 200,
+		Header: http.Header{
+			"Date":          []string{time.Now().Add(-age).UTC().Format(http.TimeFormat)},
+			"Cache-Control": []string{cacheControl},
+		},
+		Body: ioutil.NopCloser(strings.NewReader("cached")),
+	}
+	dumped, err := httputil.DumpResponse(resp, true)
+	assert.NoError(t, err)
+	return dumped
+}
+
+func TestParseCacheControl(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"public":                 "",
+		"max-age":                "60",
+		"stale-while-revalidate": "30",
+	}, parseCacheControl(`public, max-age=60, Stale-While-Revalidate="30"`))
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	defer func() {
+		pendingRevalidations = nil
+		viper.Set("rsh-swr", "")
+	}()
+
+	cache := memoryCache{}
+	tx := StaleWhileRevalidateTransport(httpcache.NewTransport(cache)).(*staleWhileRevalidateTransport)
+
+	// Stale but within the window, should be served with a warning.
+	cache["http://example.com/swr"] = cachedResponse(t, 90*time.Second, "max-age=60, stale-while-revalidate=60")
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/swr", nil)
+	resp, err := tx.RoundTrip(req)
+	assert.NoError(t, err)
+	assert.Contains(t, resp.Header.Get("Warning"), "110")
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "cached", string(body))
+	assert.Len(t, pendingRevalidations, 1)
+
+	// Still fresh, so the regular cache should handle it.
+	cache["http://example.com/fresh"] = cachedResponse(t, 10*time.Second, "max-age=60, stale-while-revalidate=60")
+	req, _ = http.NewRequest(http.MethodGet, "http://example.com/fresh", nil)
+	assert.Nil(t, tx.staleResponse(req))
+
+	// Too stale for the window.
+	cache["http://example.com/old"] = cachedResponse(t, 200*time.Second, "max-age=60, stale-while-revalidate=60")
+	req, _ = http.NewRequest(http.MethodGet, "http://example.com/old", nil)
+	assert.Nil(t, tx.staleResponse(req))
+
+	// No directive, but the override allows it.
+	cache["http://example.com/override"] = cachedResponse(t, 70*time.Second, "max-age=60")
+	req, _ = http.NewRequest(http.MethodGet, "http://example.com/override", nil)
+	assert.Nil(t, tx.staleResponse(req))
+	viper.Set("rsh-swr", "30s")
+	assert.NotNil(t, tx.staleResponse(req))
+}
+
+func TestResponseCacheTransport(t *testing.T) {
+	defer gock.Off()
+
+	// Set up the mock first, as gock replaces the default transport.
+	gock.New("http://example.com").Get("/items").Reply(200).BodyString("first")
+
+	cache := memoryCache{}
+	tx := ResponseCacheTransport(http.DefaultTransport, cache, true, false, time.Minute)
+
+	get := func(accept string) string {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/items", nil)
+		req.Header.Set("Accept", accept)
+		resp, err := tx.RoundTrip(req)
+		assert.NoError(t, err)
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	assert.Equal(t, "first", get("application/json"))
+
+	// Served from the cache without hitting the network.
+	assert.Equal(t, "first", get("application/json"))
+	assert.True(t, gock.IsDone())
+
+	// A different accept header may get a different response.
+	gock.New("http://example.com").Get("/items").Reply(200).BodyString("yaml")
+	assert.Equal(t, "yaml", get("application/yaml"))
+	assert.Equal(t, "first", get("application/json"))
+
+	// Any other request to the URL invalidates it.
+	gock.New("http://example.com").Post("/items").Reply(201)
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/items", nil)
+	_, err := tx.RoundTrip(req)
+	assert.NoError(t, err)
+
+	gock.New("http://example.com").Get("/items").Reply(200).BodyString("second")
+	assert.Equal(t, "second", get("application/json"))
+
+	// Bypassing ignores the cached value but updates it.
+	tx = ResponseCacheTransport(http.DefaultTransport, cache, true, true, time.Minute)
+	gock.New("http://example.com").Get("/items").Reply(200).BodyString("third")
+	assert.Equal(t, "third", get("application/json"))
+	assert.True(t, gock.IsDone())
+
+	tx = ResponseCacheTransport(http.DefaultTransport, cache, true, false, time.Minute)
+	assert.Equal(t, "third", get("application/json"))
+
+	// Errors are never cached.
+	gock.New("http://example.com").Get("/missing").Times(2).Reply(404)
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/missing", nil)
+		resp, err := tx.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, 404, resp.StatusCode)
+	}
+	assert.True(t, gock.IsDone())
+}
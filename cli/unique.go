@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	jmespath "github.com/danielgtaylor/go-jmespath-plus"
+)
+
+// uniqueItems removes duplicate items from an array, keeping the last
+// occurrence of each so that newer records win. Items are duplicates if the
+// JMESPath key expression gives structurally equal results, e.g. `.id`, or
+// without an expression if the items themselves are equal. Items where the
+// key is missing or `null` are always kept.
+func uniqueItems(data interface{}, key string) ([]interface{}, error) {
+	items, ok := data.([]interface{})
+	if !ok {
+		return nil, errors.New("input must be an array")
+	}
+
+	var compiled *jmespath.JMESPath
+	if key != "" {
+		var err error
+		if compiled, err = jmespath.Compile(strings.TrimPrefix(key, ".")); err != nil {
+			return nil, fmt.Errorf("unique key %q: %w", key, err)
+		}
+	}
+
+	// Encoding normalized values gives the same text for equal values, since
+	// object keys are sorted and numbers share one representation.
+	ids := make([]string, len(items))
+	last := map[string]int{}
+	for i, item := range items {
+		value := makeJSONSafe(item, true)
+		if compiled != nil {
+			var err error
+			if value, err = compiled.Search(value); err != nil {
+				return nil, fmt.Errorf("unique key %q: %w", key, err)
+			}
+			if value == nil {
+				continue
+			}
+		}
+
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = string(b)
+		last[ids[i]] = i
+	}
+
+	unique := []interface{}{}
+	for i, item := range items {
+		if ids[i] == "" || last[ids[i]] == i {
+			unique = append(unique, item)
+		}
+	}
+
+	return unique, nil
+}
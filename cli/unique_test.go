@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniqueItems(t *testing.T) {
+	items := []interface{}{
+		map[string]interface{}{"id": 1.0, "v": "old"},
+		map[string]interface{}{"id": 2.0},
+		map[string]interface{}{"name": "no id"},
+		map[string]interface{}{"id": nil},
+		map[string]interface{}{"id": 1.0, "v": "new"},
+		map[string]interface{}{"name": "no id"},
+		map[string]interface{}{"id": nil},
+	}
+
+	unique, err := uniqueItems(items, ".id")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": 2.0},
+		map[string]interface{}{"name": "no id"},
+		map[string]interface{}{"id": nil},
+		map[string]interface{}{"id": 1.0, "v": "new"},
+		map[string]interface{}{"name": "no id"},
+		map[string]interface{}{"id": nil},
+	}, unique)
+
+	// Whole items are compared structurally without a key.
+	unique, err = uniqueItems([]interface{}{
+		map[string]interface{}{"a": 1, "b": []interface{}{"x"}},
+		"a",
+		map[string]interface{}{"b": []interface{}{"x"}, "a": 1.0},
+		nil,
+		"a",
+		nil,
+	}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"b": []interface{}{"x"}, "a": 1.0},
+		"a",
+		nil,
+	}, unique)
+
+	unique, err = uniqueItems([]interface{}{}, ".id")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{}, unique)
+
+	_, err = uniqueItems(map[string]interface{}{}, "")
+	assert.Error(t, err)
+}
+
+func TestFormatUnique(t *testing.T) {
+	filename := path.Join(t.TempDir(), "items.json")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(`[{"id": 1, "v": 1}, {"id": 2}, {"id": 1, "v": 2}]`), 0600))
+
+	assert.JSONEq(t, `[{"id": 2}, {"id": 1, "v": 2}]`, run("format unique --rsh-key .id "+filename))
+	assert.Equal(t, "- id: 2\n- id: 1\n  v: 2\n", run("format unique --rsh-key id "+filename+" -o yaml"))
+
+	WithFakeStdin([]byte(`[]`), 0, func() {
+		assert.JSONEq(t, `[]`, run("format unique"))
+	})
+}
@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// templateExpression matches RFC 6570 URI template expressions like `{?a,b}`.
+var templateExpression = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// templateOperator describes how an RFC 6570 expression operator expands its
+// variables.
+type templateOperator struct {
+	first         string
+	sep           string
+	named         bool
+	ifEmpty       string
+	allowReserved bool
+}
+
+var templateOperators = map[byte]templateOperator{
+	'+': {"", ",", false, "", true},
+	'#': {"#", ",", false, "", true},
+	'.': {".", ".", false, "", false},
+	'/': {"/", "/", false, "", false},
+	';': {";", ";", true, "", false},
+	'?': {"?", "&", true, "=", false},
+	'&': {"&", "&", true, "=", false},
+}
+
+// isURITemplate returns whether a URI looks like an RFC 6570 URI template.
+func isURITemplate(uri string) bool {
+	return strings.Contains(uri, "{")
+}
+
+// templateEscape percent-encodes a value for a URI template expansion. Only
+// unreserved characters are kept unless reserved ones are allowed too.
+func templateEscape(value string, allowReserved bool) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', strings.IndexByte("-._~", c) >= 0:
+			b.WriteByte(c)
+		case allowReserved && strings.IndexByte(":/?#[]@!$&'()*+,;=", c) >= 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// templateString converts a scalar variable value to a string. Returns false
+// for values which cannot be used in a URI, like `nil`.
+func templateString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case json.Number:
+		// Decoded with `rsh-precise-numbers`, so keep every digit.
+		return v.String(), true
+	}
+	return "", false
+}
+
+// expandVariable expands a single variable spec like `name`, `name:3`, or
+// `name*` for an operator. Returns false if the variable is undefined.
+func expandVariable(op templateOperator, spec string, lookup func(string) interface{}) (string, bool) {
+	name := spec
+	explode := strings.HasSuffix(name, "*")
+	name = strings.TrimSuffix(name, "*")
+
+	prefix := 0
+	if i := strings.Index(name, ":"); i >= 0 {
+		prefix, _ = strconv.Atoi(name[i+1:])
+		name = name[:i]
+	}
+
+	named := func(value string) string {
+		if !op.named {
+			return value
+		}
+		if value == "" {
+			return name + op.ifEmpty
+		}
+		return name + "=" + value
+	}
+
+	switch v := lookup(name).(type) {
+	case []interface{}:
+		items := []string{}
+		for _, item := range v {
+			if s, ok := templateString(item); ok {
+				escaped := templateEscape(s, op.allowReserved)
+				if explode && op.named {
+					escaped = name + "=" + escaped
+				}
+				items = append(items, escaped)
+			}
+		}
+		if len(items) == 0 {
+			return "", false
+		}
+		if explode {
+			return strings.Join(items, op.sep), true
+		}
+		return named(strings.Join(items, ",")), true
+	case map[string]interface{}:
+		keys := []string{}
+		for k := range v {
+			if _, ok := templateString(v[k]); ok {
+				keys = append(keys, k)
+			}
+		}
+		if len(keys) == 0 {
+			return "", false
+		}
+		sort.Strings(keys)
+
+		pairs := []string{}
+		for _, k := range keys {
+			s, _ := templateString(v[k])
+			k, s = templateEscape(k, op.allowReserved), templateEscape(s, op.allowReserved)
+			if explode {
+				pairs = append(pairs, k+"="+s)
+			} else {
+				pairs = append(pairs, k, s)
+			}
+		}
+		if explode {
+			return strings.Join(pairs, op.sep), true
+		}
+		return named(strings.Join(pairs, ",")), true
+	default:
+		s, ok := templateString(v)
+		if !ok {
+			return "", false
+		}
+		if runes := []rune(s); prefix > 0 && len(runes) > prefix {
+			s = string(runes[:prefix])
+		}
+		return named(templateEscape(s, op.allowReserved)), true
+	}
+}
+
+// expandURITemplate expands an RFC 6570 URI template, looking up variables
+// with the given function. Undefined variables are left out as the RFC
+// describes. Returns false if no variables were defined at all, in which
+// case the template is returned unchanged.
+func expandURITemplate(template string, lookup func(string) interface{}) (string, bool) {
+	defined := false
+
+	expanded := templateExpression.ReplaceAllStringFunc(template, func(expr string) string {
+		expr = expr[1 : len(expr)-1]
+
+		op := templateOperator{sep: ","}
+		if len(expr) > 0 {
+			if o, ok := templateOperators[expr[0]]; ok {
+				op = o
+				expr = expr[1:]
+			}
+		}
+
+		values := []string{}
+		for _, spec := range strings.Split(expr, ",") {
+			if value, ok := expandVariable(op, spec, lookup); ok {
+				values = append(values, value)
+			}
+		}
+
+		if len(values) == 0 {
+			return ""
+		}
+
+		defined = true
+		return op.first + strings.Join(values, op.sep)
+	})
+
+	if !defined {
+		return template, false
+	}
+
+	return expanded, true
+}
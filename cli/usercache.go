@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// userCacheKey returns the cache key for a user value. User values live in
+// their own `user` namespace so they can't collide with internal entries.
+// Keys are case-insensitive.
+func userCacheKey(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, ". \t\n") {
+		return "", fmt.Errorf("invalid cache key %q: must be non-empty and not contain dots or spaces", key)
+	}
+	return strings.ToLower(key), nil
+}
+
+// setUserCache stores a value in the cache, optionally expiring after `ttl`.
+// The value is stored as-is so that e.g. JSON documents round-trip exactly.
+func setUserCache(key, value string, ttl time.Duration) error {
+	k, err := userCacheKey(key)
+	if err != nil {
+		return err
+	}
+
+	entry := map[string]interface{}{"value": value}
+	if ttl > 0 {
+		entry["expires"] = time.Now().Add(ttl)
+	}
+
+	return rewriteCache(func(data map[string]interface{}) bool {
+		subMap(data, "user")[k] = entry
+		return true
+	})
+}
+
+// deleteUserCache removes a value from the cache, returning whether it was
+// present.
+func deleteUserCache(key string) (bool, error) {
+	k, err := userCacheKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	err = rewriteCache(func(data map[string]interface{}) bool {
+		user, ok := data["user"].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, found = user[k]; found {
+			delete(user, k)
+		}
+		return found
+	})
+	return found, err
+}
+
+// getUserCache loads a value from the cache. Expired values are removed and
+// treated as missing.
+func getUserCache(key string) (string, bool, error) {
+	k, err := userCacheKey(key)
+	if err != nil {
+		return "", false, err
+	}
+
+	if !Cache.IsSet("user." + k + ".value") {
+		return "", false, nil
+	}
+
+	expires := Cache.GetTime("user." + k + ".expires")
+	if !expires.IsZero() && expires.Before(time.Now()) {
+		_, err := deleteUserCache(key)
+		return "", false, err
+	}
+
+	return Cache.GetString("user." + k + ".value"), true, nil
+}
+
+// userCacheCommands returns the `cache` sub-commands for storing arbitrary
+// values, e.g. from shell scripts.
+func userCacheCommands() []*cobra.Command {
+	get := &cobra.Command{
+		Use:   "get key",
+		Short: "Get a stored value",
+		Long:  "Print a value previously stored with `cache set`. Missing or expired values result in an error.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			value, ok, err := getUserCache(args[0])
+			if err != nil {
+				panic(err)
+			}
+			if !ok {
+				panic(fmt.Errorf("key %s not found", args[0]))
+			}
+			fmt.Fprintln(Stdout, value)
+		},
+	}
+
+	set := &cobra.Command{
+		Use:   "set key value",
+		Short: "Store a value",
+		Long:  "Store a value in the cache alongside Restish's own cached data. Values are stored as-is, so JSON documents round-trip exactly. Keys are case-insensitive and cannot contain dots or spaces.",
+		Example: fmt.Sprintf(`  # Store a sync cursor for an hour
+  $ %s cache set my-cursor abc123 --ttl 1h
+
+  # Read it back later
+  $ %s cache get my-cursor`, Root.CommandPath(), Root.CommandPath()),
+		Args: cobra.ExactArgs(2),
+	}
+	ttl := set.Flags().Duration("ttl", 0, "Expire the value after this duration, e.g. 1h")
+	set.Run = func(cmd *cobra.Command, args []string) {
+		if err := setUserCache(args[0], args[1], *ttl); err != nil {
+			panic(err)
+		}
+	}
+
+	del := &cobra.Command{
+		Use:   "delete key",
+		Short: "Delete a stored value",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := deleteUserCache(args[0]); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	return []*cobra.Command{get, set, del}
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserCache(t *testing.T) {
+	reset(false)
+
+	doc := `{"Cursor": "abc", "ids": [1, 2.50, 3e2], "nested": {"A": null}}`
+	assert.NoError(t, setUserCache("Sync-State", doc, 0))
+
+	value, ok, err := getUserCache("sync-state")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, doc, value)
+
+	// Survives a reload from disk.
+	assert.NoError(t, loadCache())
+	value, ok, _ = getUserCache("SYNC-STATE")
+	assert.True(t, ok)
+	assert.Equal(t, doc, value)
+
+	// Can't collide with internal keys.
+	assert.False(t, Cache.IsSet("sync-state"))
+	_, err = userCacheKey("apis.example")
+	assert.Error(t, err)
+
+	found, err := deleteUserCache("sync-state")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	_, ok, _ = getUserCache("sync-state")
+	assert.False(t, ok)
+
+	// Expired values are treated as missing and removed.
+	assert.NoError(t, setUserCache("short", "1", time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, ok, err = getUserCache("short")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, Cache.IsSet("user.short.value"))
+}
+
+func TestUserCacheCommands(t *testing.T) {
+	run("cache set cmd-test hello --ttl 1h")
+	assert.Equal(t, "hello\n", runNoReset("cache get cmd-test"))
+
+	runNoReset("cache delete cmd-test")
+	assert.Contains(t, runNoReset("cache get cmd-test"), "not found")
+}
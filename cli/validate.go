@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+)
+
+// validationBodyDecoder decodes request bodies for validation using the
+// registered content types, for formats kin-openapi doesn't know about.
+func validationBodyDecoder(body io.Reader, header http.Header, schema *openapi3.SchemaRef, encFn openapi3filter.EncodingFn) (interface{}, error) {
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := Unmarshal(header.Get("Content-Type"), data, &value); err != nil {
+		return nil, err
+	}
+
+	return makeJSONSafe(value, true), nil
+}
+
+// validationErrors returns a description of each schema violation in err.
+func validationErrors(err error) []string {
+	var multi openapi3.MultiError
+	if errors.As(err, &multi) {
+		messages := []string{}
+		for _, e := range multi {
+			messages = append(messages, validationErrors(e)...)
+		}
+		return messages
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		location := "/" + strings.Join(schemaErr.JSONPointer(), "/")
+		return []string{fmt.Sprintf("%s: %s", location, schemaErr.Reason)}
+	}
+
+	var reqErr *openapi3filter.RequestError
+	if errors.As(err, &reqErr) && reqErr.Err != nil {
+		return validationErrors(reqErr.Err)
+	}
+
+	return []string{err.Error()}
+}
+
+// validateBody checks a request body against the schema for its content type,
+// returning an error listing each schema violation.
+func validateBody(schemas map[string]interface{}, contentType string, body []byte) error {
+	content := openapi3.Content{}
+	for mt, s := range schemas {
+		b, err := json.Marshal(makeJSONSafe(s, false))
+		if err != nil {
+			return err
+		}
+
+		schema := &openapi3.Schema{}
+		if err := json.Unmarshal(b, schema); err != nil {
+			return fmt.Errorf("invalid request schema for %s: %w", mt, err)
+		}
+
+		content[mt] = openapi3.NewMediaType().WithSchema(schema)
+	}
+
+	if content.Get(contentType) == nil {
+		return fmt.Errorf("request body content type %s is not one of the allowed types in the API description", contentType)
+	}
+
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil && openapi3filter.RegisteredBodyDecoder(mt) == nil {
+		openapi3filter.RegisterBodyDecoder(mt, validationBodyDecoder)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	err = openapi3filter.ValidateRequestBody(context.Background(), &openapi3filter.RequestValidationInput{
+		Request: req,
+		Options: &openapi3filter.Options{MultiError: true},
+	}, openapi3.NewRequestBody().WithContent(content))
+	if err != nil {
+		return fmt.Errorf("request body is invalid:\n  %s", strings.Join(validationErrors(err), "\n  "))
+	}
+
+	return nil
+}
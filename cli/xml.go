@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// xmlMaxDepth limits how deeply XML elements may be nested.
+const xmlMaxDepth = 256
+
+// XML describes content types like `application/xml`, `text/xml`, or
+// `application/foo+xml`. Documents are decoded into maps suitable for
+// filtering, where attributes are prefixed with `@`, text content is under
+// `#text`, and repeated elements become lists. Elements with only text content
+// decode to a string. Names are used without their namespace prefix, while
+// namespace declarations are kept as `@xmlns` attributes.
+type XML struct{}
+
+// Detect if the content type is XML.
+func (x XML) Detect(contentType string) bool {
+	first := strings.Split(contentType, ";")[0]
+	if first == "application/xml" || first == "text/xml" || strings.HasSuffix(first, "+xml") {
+		return true
+	}
+
+	return false
+}
+
+// Marshal is not supported for XML.
+func (x XML) Marshal(value interface{}) ([]byte, error) {
+	return nil, errors.New("encoding XML is not supported")
+}
+
+// Unmarshal the value from encoded XML. Document type definitions which
+// declare entities are rejected to prevent entity expansion attacks.
+func (x XML) Unmarshal(data []byte, value interface{}) error {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr {
+		return fmt.Errorf("value must be pointer but found %s", v.Kind())
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = true
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return errors.New("no XML root element found")
+			}
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.Directive:
+			if bytes.Contains(t, []byte("ENTITY")) {
+				return errors.New("XML entity declarations are not supported")
+			}
+		case xml.StartElement:
+			element, err := decodeXMLElement(decoder, t, 1)
+			if err != nil {
+				return err
+			}
+
+			v.Elem().Set(reflect.ValueOf(map[string]interface{}{
+				t.Name.Local: element,
+			}))
+			return nil
+		}
+	}
+}
+
+// xmlAttrName returns the key for an attribute, keeping the prefix for
+// namespace declarations.
+func xmlAttrName(name xml.Name) string {
+	if name.Space == "xmlns" {
+		return "@xmlns:" + name.Local
+	}
+	return "@" + name.Local
+}
+
+// decodeXMLElement decodes an element's attributes and content after its
+// start token has been read.
+func decodeXMLElement(decoder *xml.Decoder, start xml.StartElement, depth int) (interface{}, error) {
+	if depth > xmlMaxDepth {
+		return nil, fmt.Errorf("XML nested deeper than %d elements", xmlMaxDepth)
+	}
+
+	result := map[string]interface{}{}
+	for _, attr := range start.Attr {
+		result[xmlAttrName(attr.Name)] = attr.Value
+	}
+
+	text := strings.Builder{}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(decoder, t, depth+1)
+			if err != nil {
+				return nil, err
+			}
+
+			key := t.Name.Local
+			switch existing := result[key].(type) {
+			case nil:
+				result[key] = child
+			case []interface{}:
+				result[key] = append(existing, child)
+			default:
+				result[key] = []interface{}{existing, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+			if len(result) == 0 {
+				return content, nil
+			}
+			if content != "" {
+				result["#text"] = content
+			}
+			return result, nil
+		}
+	}
+}
@@ -28,10 +28,15 @@ func main() {
 	// Register format loaders to auto-discover API descriptions
 	cli.AddLoader(openapi.New())
 
+	// Register exporters to convert API descriptions to other formats
+	cli.AddExporter("swagger", "Export an OpenAPI 3 API as Swagger 2.0", openapi.NewSwaggerExporter())
+
 	// Register auth schemes
 	cli.AddAuth("oauth-client-credentials", &oauth.ClientCredentialsHandler{})
 	cli.AddAuth("oauth-authorization-code", &oauth.AuthorizationCodeHandler{})
 
 	// Run the CLI, parsing arguments, making requests, and printing responses.
 	cli.Run()
+
+	os.Exit(cli.GetExitCode())
 }
@@ -62,7 +62,7 @@ func TokenHandler(source oauth2.TokenSource, key string, request *http.Request)
 		}
 
 		// Save the cache to disk.
-		if err := cli.Cache.WriteConfig(); err != nil {
+		if err := cli.SaveCache(); err != nil {
 			return err
 		}
 	}
@@ -4,6 +4,12 @@ import "github.com/getkin/kin-openapi/openapi3"
 
 // genExample creates a dummy example from a given schema.
 func genExample(schema *openapi3.Schema) interface{} {
+	return genExampleInternal(schema, map[*openapi3.Schema]bool{})
+}
+
+// genExampleInternal creates a dummy example, tracking the schemas being
+// generated so that recursive schemas stop at the first repeat.
+func genExampleInternal(schema *openapi3.Schema, known map[*openapi3.Schema]bool) interface{} {
 	if schema.Example != nil {
 		return schema.Example
 	}
@@ -12,10 +18,16 @@ func genExample(schema *openapi3.Schema) interface{} {
 		return schema.Default
 	}
 
+	if known[schema] {
+		return nil
+	}
+	known[schema] = true
+	defer delete(known, schema)
+
 	switch schema.Type {
 	case "null":
 		return nil
-	case "bool":
+	case "boolean":
 		return true
 	case "integer":
 		return 1
@@ -24,7 +36,11 @@ func genExample(schema *openapi3.Schema) interface{} {
 	case "string":
 		return "string"
 	case "array":
-		item := genExample(schema.Items.Value)
+		if schema.Items == nil || schema.Items.Value == nil {
+			return []interface{}{}
+		}
+
+		item := genExampleInternal(schema.Items.Value, known)
 		count := 1
 		if schema.MinItems > 0 {
 			count = int(schema.MinItems)
@@ -38,7 +54,7 @@ func genExample(schema *openapi3.Schema) interface{} {
 	case "object":
 		value := map[string]interface{}{}
 		for k, s := range schema.Properties {
-			value[k] = genExample(s.Value)
+			value[k] = genExampleInternal(s.Value, known)
 		}
 		return value
 	}
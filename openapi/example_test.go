@@ -0,0 +1,31 @@
+package openapi
+
+import (
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExampleRecursive(t *testing.T) {
+	s := &openapi3.Schema{
+		Type: "object",
+		Properties: map[string]*openapi3.SchemaRef{
+			"name":    {Value: &openapi3.Schema{Type: "string"}},
+			"enabled": {Value: &openapi3.Schema{Type: "boolean"}},
+			"children": {
+				Value: &openapi3.Schema{
+					Type:  "array",
+					Items: &openapi3.SchemaRef{Ref: "#/components/schemas/foo"},
+				},
+			},
+		},
+	}
+	s.Properties["children"].Value.Items.Value = s
+
+	assert.Equal(t, map[string]interface{}{
+		"name":     "string",
+		"enabled":  true,
+		"children": []interface{}{nil},
+	}, genExample(s))
+}
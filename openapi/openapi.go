@@ -9,6 +9,7 @@ import (
 	"os"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/danielgtaylor/casing"
@@ -128,6 +129,143 @@ func getRequestInfo(op *openapi3.Operation) (string, *openapi3.Schema, []interfa
 	return "", nil, nil
 }
 
+// renderExamples renders the examples of a response for the operation help,
+// using the same readable format as the default response output.
+func renderExamples(item *openapi3.MediaType) string {
+	if item == nil {
+		return ""
+	}
+
+	out := ""
+	render := func(title, summary string, value interface{}) {
+		out += "\n### " + title + "\n"
+		if summary != "" {
+			out += "\n" + summary + "\n"
+		}
+
+		if s, ok := value.(string); ok {
+			out += "\n```\n" + s + "\n```\n"
+			return
+		}
+
+		b, err := cli.MarshalReadable(value)
+		if err != nil {
+			return
+		}
+		out += "\n```readable\n" + string(b) + "\n```\n"
+	}
+
+	if item.Example != nil {
+		render("Example", "", item.Example)
+	}
+
+	names := []string{}
+	for name := range item.Examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if ex := item.Examples[name]; ex != nil && ex.Value != nil && ex.Value.Value != nil {
+			render("Example: "+name, ex.Value.Summary, ex.Value.Value)
+		}
+	}
+
+	return out
+}
+
+// getBodySchemas returns the request body schema for each media type, used to
+// validate request bodies before sending them.
+func getBodySchemas(op *openapi3.Operation) map[string]interface{} {
+	if op.RequestBody == nil || op.RequestBody.Value == nil {
+		return nil
+	}
+
+	schemas := map[string]interface{}{}
+	for mt, item := range op.RequestBody.Value.Content {
+		if item != nil && item.Schema != nil && item.Schema.Value != nil {
+			schemas[mt] = inlineSchema(item.Schema.Value)
+		}
+	}
+
+	if len(schemas) == 0 {
+		return nil
+	}
+
+	return schemas
+}
+
+// getResponses returns the possible responses of an operation along with
+// their examples, for use in mock servers. JSON is preferred when a response
+// has several content types.
+func getResponses(op *openapi3.Operation) []cli.OperationResponse {
+	codes := []string{}
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	responses := []cli.OperationResponse{}
+	for _, code := range codes {
+		if op.Responses[code] == nil || op.Responses[code].Value == nil {
+			continue
+		}
+
+		status := 0
+		if code != "default" {
+			// Ranges like `2XX` use the first status in the range.
+			var err error
+			if status, err = strconv.Atoi(strings.Replace(strings.ToUpper(code), "XX", "00", 1)); err != nil {
+				continue
+			}
+		}
+
+		resp := cli.OperationResponse{Status: status}
+
+		cts := []string{}
+		for ct := range op.Responses[code].Value.Content {
+			cts = append(cts, ct)
+		}
+		sort.Slice(cts, func(i, j int) bool {
+			ji, jj := strings.Contains(cts[i], "json"), strings.Contains(cts[j], "json")
+			if ji != jj {
+				return ji
+			}
+			return cts[i] < cts[j]
+		})
+
+		if len(cts) > 0 {
+			resp.ContentType = cts[0]
+			item := op.Responses[code].Value.Content[cts[0]]
+
+			if item.Example != nil {
+				resp.Example = item.Example
+			} else {
+				names := []string{}
+				for name := range item.Examples {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+
+				for _, name := range names {
+					if ex := item.Examples[name]; ex != nil && ex.Value != nil {
+						resp.Example = ex.Value.Value
+						break
+					}
+				}
+			}
+
+			if item.Schema != nil && item.Schema.Value != nil {
+				resp.Generated = genExample(item.Schema.Value)
+			}
+		}
+
+		responses = append(responses, resp)
+	}
+
+	return responses
+}
+
 func openapiOperation(cmd *cobra.Command, method string, uriTemplate *url.URL, path *openapi3.PathItem, op *openapi3.Operation) cli.Operation {
 	pathParams := []*cli.Param{}
 	queryParams := []*cli.Param{}
@@ -139,6 +277,7 @@ func openapiOperation(cmd *cobra.Command, method string, uriTemplate *url.URL, p
 		if p.Value != nil {
 			var def interface{}
 			var example interface{}
+			var enum []interface{}
 
 			typ := "string"
 			if p.Value.Schema != nil && p.Value.Schema.Value != nil {
@@ -153,6 +292,7 @@ func openapiOperation(cmd *cobra.Command, method string, uriTemplate *url.URL, p
 
 				def = p.Value.Schema.Value.Default
 				example = p.Value.Schema.Value.Example
+				enum = p.Value.Schema.Value.Enum
 			}
 
 			if p.Value.Example != nil {
@@ -188,10 +328,12 @@ func openapiOperation(cmd *cobra.Command, method string, uriTemplate *url.URL, p
 				Name:        p.Value.Name,
 				DisplayName: displayName,
 				Description: description,
+				Required:    p.Value.Required,
 				Style:       style,
 				Explode:     explode,
 				Default:     def,
 				Example:     example,
+				Enum:        enum,
 			}
 
 			switch p.Value.In {
@@ -315,6 +457,8 @@ func openapiOperation(cmd *cobra.Command, method string, uriTemplate *url.URL, p
 				if typeInfo.Schema != nil && typeInfo.Schema.Value != nil {
 					desc += "\n```schema\n" + renderSchema(typeInfo.Schema.Value, "", modeRead) + "\n```\n"
 				}
+
+				desc += renderExamples(typeInfo)
 			}
 		} else {
 			if len(desc) > 0 && !strings.HasSuffix(desc, "\n") {
@@ -344,8 +488,11 @@ func openapiOperation(cmd *cobra.Command, method string, uriTemplate *url.URL, p
 		QueryParams:   queryParams,
 		HeaderParams:  headerParams,
 		BodyMediaType: mediaType,
+		BodySchemas:   getBodySchemas(op),
 		Examples:      examples,
+		Tags:          op.Tags,
 		Hidden:        hidden,
+		Responses:     getResponses(op),
 	}
 }
 
@@ -404,15 +551,30 @@ func getBasePath(location *url.URL, servers openapi3.Servers) (string, error) {
 	return "", nil
 }
 
+// readRef loads external `$ref` documents over HTTP, using the configured
+// auth for hosts which are set up as APIs.
+func readRef(loader *openapi3.Loader, location *url.URL) ([]byte, error) {
+	if location.Scheme == "" || location.Host == "" {
+		return nil, openapi3.ErrURINotSupported
+	}
+
+	return cli.ReadURL(location)
+}
+
 func loadOpenAPI3(cfg Resolver, cmd *cobra.Command, location *url.URL, resp *http.Response) (cli.API, error) {
 	loader := openapi3.NewLoader()
 	loader.IsExternalRefsAllowed = true
+	loader.ReadFromURIFunc = openapi3.URIMapCache(openapi3.ReadFromURIs(readRef, openapi3.ReadFromFile))
 
 	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return cli.API{}, err
 	}
 
+	if err := checkRefs(data); err != nil {
+		return cli.API{}, err
+	}
+
 	swagger, err := loader.LoadFromDataWithPath(data, location)
 	if err != nil {
 		return cli.API{}, err
@@ -603,6 +765,11 @@ func (l *loader) Resolve(relURI string) (*url.URL, error) {
 	return l.base.ResolveReference(parsed), nil
 }
 
+// String describes the kind of API description this loader handles.
+func (l *loader) String() string {
+	return "OpenAPI 3"
+}
+
 func (l *loader) LocationHints() []string {
 	return []string{"/openapi.json", "/openapi.yaml"}
 }
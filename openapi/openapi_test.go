@@ -10,7 +10,9 @@ import (
 
 	"github.com/danielgtaylor/restish/cli"
 	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
 )
 
 var sample = `
@@ -174,6 +176,11 @@ func TestLoadOpenAPI(t *testing.T) {
 				PathParams:   []*cli.Param{},
 				QueryParams:  []*cli.Param{},
 				HeaderParams: []*cli.Param{},
+				Tags:         []string{"pets"},
+				Responses: []cli.OperationResponse{
+					{Status: 201},
+					{Status: 0, ContentType: "application/json", Generated: map[string]interface{}{"code": 1, "message": "string"}},
+				},
 			},
 			{
 				Name:        "list-pets",
@@ -191,6 +198,11 @@ func TestLoadOpenAPI(t *testing.T) {
 					},
 				},
 				HeaderParams: []*cli.Param{},
+				Tags:         []string{"pets"},
+				Responses: []cli.OperationResponse{
+					{Status: 200, ContentType: "application/json", Generated: []interface{}{map[string]interface{}{"id": 1, "name": "string", "tag": "string"}}},
+					{Status: 0, ContentType: "application/json", Generated: map[string]interface{}{"code": 1, "message": "string"}},
+				},
 			},
 			{
 				Name:        "show-pet-by-id",
@@ -204,10 +216,16 @@ func TestLoadOpenAPI(t *testing.T) {
 						Type:        "string",
 						Name:        "petId",
 						Description: "The id of the pet to retrieve",
+						Required:    true,
 					},
 				},
 				QueryParams:  []*cli.Param{},
 				HeaderParams: []*cli.Param{},
+				Tags:         []string{"pets"},
+				Responses: []cli.OperationResponse{
+					{Status: 200, ContentType: "application/json", Generated: map[string]interface{}{"id": 1, "name": "string", "tag": "string"}},
+					{Status: 0, ContentType: "application/json", Generated: map[string]interface{}{"code": 1, "message": "string"}},
+				},
 			},
 		},
 		AutoConfig: cli.AutoConfig{
@@ -362,3 +380,52 @@ func parseURL(s string) *url.URL {
 	output, _ := url.Parse(s)
 	return output
 }
+
+func TestRenderExamples(t *testing.T) {
+	mt := openapi3.NewMediaType()
+	mt.Example = map[string]interface{}{"id": 1, "name": "Rex"}
+	mt.Examples = openapi3.Examples{
+		"plain": &openapi3.ExampleRef{Value: &openapi3.Example{Summary: "A plain text pet", Value: "Rex the dog"}},
+		"empty": &openapi3.ExampleRef{Value: &openapi3.Example{}},
+	}
+
+	assert.Equal(t, "\n### Example\n\n```readable\n{\n  id: 1\n  name: \"Rex\"\n}\n```\n\n### Example: plain\n\nA plain text pet\n\n```\nRex the dog\n```\n", renderExamples(mt))
+	assert.Equal(t, "", renderExamples(openapi3.NewMediaType()))
+}
+
+func TestLoadOpenAPIExternalRef(t *testing.T) {
+	defer gock.Off()
+	viper.Set("nocolor", true)
+	cli.Init("test", "1.0.0")
+	cli.Defaults()
+
+	gock.New("https://schemas.example.com").
+		Get("/item.yaml").
+		Reply(http.StatusOK).
+		BodyString("type: object\nproperties:\n  name:\n    type: string\n")
+
+	entry, _ := url.Parse("http://api.example.com")
+	spec, _ := url.Parse("http://api.example.com/openapi.yaml")
+	resp := &http.Response{
+		Body: ioutil.NopCloser(strings.NewReader(`
+openapi: 3.0.0
+info: {title: Items, version: "1.0"}
+paths:
+  /item:
+    get:
+      operationId: get-item
+      responses:
+        "200":
+          description: An item
+          content:
+            application/json:
+              schema:
+                $ref: "https://schemas.example.com/item.yaml"
+`)),
+	}
+
+	api, err := New().Load(*entry, *spec, resp)
+	assert.NoError(t, err)
+	assert.True(t, gock.IsDone())
+	assert.Contains(t, api.Operations[0].Long, "name: (string)")
+}
@@ -0,0 +1,155 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// refUse is a `$ref` along with the JSON pointer of where it is used.
+type refUse struct {
+	ref string
+	at  string
+}
+
+// escapePointer escapes a JSON pointer token as described in RFC 6901.
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// unescapePointer reverses `escapePointer`.
+func unescapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
+
+// collectRefs walks a document and returns all the `$ref` values in it.
+func collectRefs(node interface{}, at string, refs []refUse) []refUse {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok {
+			refs = append(refs, refUse{ref: ref, at: at})
+		}
+
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			refs = collectRefs(v[k], at+"/"+escapePointer(k), refs)
+		}
+	case []interface{}:
+		for i, item := range v {
+			refs = collectRefs(item, fmt.Sprintf("%s/%d", at, i), refs)
+		}
+	}
+
+	return refs
+}
+
+// resolvePointer finds the value a local `$ref` like `#/components/schemas/Foo`
+// points to within the document.
+func resolvePointer(doc interface{}, ref string) (interface{}, bool) {
+	pointer := strings.TrimPrefix(ref, "#")
+	if pointer == "" {
+		return doc, true
+	}
+
+	current := doc
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = unescapePointer(token)
+
+		switch v := current.(type) {
+		case map[string]interface{}:
+			value, ok := v[token]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			i := -1
+			fmt.Sscanf(token, "%d", &i)
+			if i < 0 || i >= len(v) {
+				return nil, false
+			}
+			current = v[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// checkRefs looks for local `$ref` values which can't be resolved, as well as
+// references which only point to themselves via other references and so can
+// never be resolved. The loader otherwise fails with an error that doesn't say
+// where the reference is used, or never finishes. Cycles through properties or
+// items are fine and are handled when rendering schemas.
+func checkRefs(data []byte) error {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		// Let the loader report invalid documents.
+		return nil
+	}
+
+	problems := []string{}
+	reported := map[string]bool{}
+
+	for _, use := range collectRefs(doc, "#", nil) {
+		if !strings.HasPrefix(use.ref, "#") {
+			// External references are loaded and reported by the loader.
+			continue
+		}
+
+		chain := []string{use.ref}
+		for ref := use.ref; ; {
+			target, ok := resolvePointer(doc, ref)
+			if !ok {
+				if !reported[ref] {
+					reported[ref] = true
+					problems = append(problems, fmt.Sprintf("unresolved $ref %s used at %s", ref, use.at))
+				}
+				break
+			}
+
+			m, ok := target.(map[string]interface{})
+			if !ok {
+				break
+			}
+
+			next, ok := m["$ref"].(string)
+			if !ok || !strings.HasPrefix(next, "#") {
+				break
+			}
+
+			cycle := false
+			for _, seen := range chain {
+				if seen == next {
+					cycle = true
+				}
+			}
+
+			chain = append(chain, next)
+			if cycle {
+				if !reported[next] {
+					reported[next] = true
+					problems = append(problems, fmt.Sprintf("circular $ref %s used at %s: %s", next, use.at, strings.Join(chain, " -> ")))
+				}
+				break
+			}
+
+			ref = next
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid API description:\n  " + strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
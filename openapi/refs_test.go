@@ -0,0 +1,54 @@
+package openapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRefs(t *testing.T) {
+	err := checkRefs([]byte(`
+openapi: 3.0.0
+paths:
+  /items/{id}:
+    get:
+      parameters:
+        - $ref: "#/components/parameters/Missing"
+      responses:
+        "200":
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Alias"
+components:
+  schemas:
+    Alias:
+      $ref: "#/components/schemas/Self"
+    Self:
+      $ref: "#/components/schemas/Self"
+    Node:
+      type: object
+      properties:
+        child:
+          $ref: "#/components/schemas/Node"
+        other:
+          $ref: "other.yaml#/components/schemas/Missing"
+`))
+
+	assert.EqualError(t, err, `invalid API description:
+  circular $ref #/components/schemas/Self used at #/components/schemas/Alias: #/components/schemas/Self -> #/components/schemas/Self
+  unresolved $ref #/components/parameters/Missing used at #/paths/~1items~1{id}/get/parameters/0`)
+
+	// Recursion through properties is fine.
+	assert.NoError(t, checkRefs([]byte(`
+components:
+  schemas:
+    Node:
+      type: object
+      properties:
+        children:
+          type: array
+          items:
+            $ref: "#/components/schemas/Node"
+`)))
+}
@@ -1,6 +1,7 @@
 package openapi
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
@@ -15,8 +16,24 @@ const (
 	modeWrite
 )
 
+// renderSchema renders a schema in a compact human-readable format. Schemas
+// which contain themselves, e.g. via `$ref`, are rendered once and then shown
+// as `(recursive)`.
 func renderSchema(s *openapi3.Schema, indent string, mode schemaMode) string {
-	return renderSchemaInternal(s, indent, mode, map[*openapi3.Schema]bool{})
+	return renderSchemaInternal(s, indent, mode, map[*openapi3.Schema]bool{s: true})
+}
+
+// renderChild renders a nested schema, with `known` holding the schemas being
+// rendered above it so that cycles can be detected.
+func renderChild(s *openapi3.Schema, indent string, mode schemaMode, known map[*openapi3.Schema]bool) string {
+	if known[s] {
+		return "(recursive)"
+	}
+
+	known[s] = true
+	defer delete(known, s)
+
+	return renderSchemaInternal(s, indent, mode, known)
 }
 
 func renderSchemaInternal(s *openapi3.Schema, indent string, mode schemaMode, known map[*openapi3.Schema]bool) string {
@@ -37,7 +54,6 @@ func renderSchemaInternal(s *openapi3.Schema, indent string, mode schemaMode, kn
 	}
 
 	// TODO: handle one-of, all-of, not
-	// TODO: detect circular references
 
 	switch s.Type {
 	case "boolean", "integer", "number", "string":
@@ -104,12 +120,10 @@ func renderSchemaInternal(s *openapi3.Schema, indent string, mode schemaMode, kn
 
 		return fmt.Sprintf("(%s%s) %s", s.Type, tagStr, doc)
 	case "array":
-		if !known[s.Items.Value] {
-			known[s.Items.Value] = true
-			arr := "[\n  " + indent + renderSchemaInternal(s.Items.Value, indent+"  ", mode, known) + "\n" + indent + "]"
-			return arr
+		if s.Items == nil || s.Items.Value == nil {
+			return "[<any>]"
 		}
-		return "[<recursive ref>]"
+		return "[\n  " + indent + renderChild(s.Items.Value, indent+"  ", mode, known) + "\n" + indent + "]"
 	case "object":
 		// Special case: object with nothing defined
 		if len(s.Properties) == 0 && (s.AdditionalProperties == nil || s.AdditionalProperties.Value == nil) && (s.AdditionalPropertiesAllowed == nil || !*s.AdditionalPropertiesAllowed) {
@@ -144,21 +158,11 @@ func renderSchemaInternal(s *openapi3.Schema, indent string, mode schemaMode, kn
 				}
 			}
 
-			if !known[prop] {
-				known[prop] = true
-				obj += indent + "  " + name + ": " + renderSchemaInternal(prop, indent+"  ", mode, known) + "\n"
-			} else {
-				obj += indent + "  " + name + ": <rescurive ref>\n"
-			}
+			obj += indent + "  " + name + ": " + renderChild(prop, indent+"  ", mode, known) + "\n"
 		}
 
 		if s.AdditionalProperties != nil && s.AdditionalProperties.Value != nil && s.AdditionalProperties.Value.Type != "" {
-			if !known[s.AdditionalProperties.Value] {
-				known[s.AdditionalProperties.Value] = true
-				obj += indent + "  " + "<any>: " + renderSchemaInternal(s.AdditionalProperties.Value, indent+"  ", mode, known) + "\n"
-			} else {
-				obj += indent + "  <any>: <rescurive ref>\n"
-			}
+			obj += indent + "  " + "<any>: " + renderChild(s.AdditionalProperties.Value, indent+"  ", mode, known) + "\n"
 		} else if s.AdditionalPropertiesAllowed != nil && *s.AdditionalPropertiesAllowed {
 			obj += indent + "  <any>: <any>\n"
 		}
@@ -169,3 +173,70 @@ func renderSchemaInternal(s *openapi3.Schema, indent string, mode schemaMode, kn
 
 	return ""
 }
+
+// inlineSchema converts a schema to a plain JSON Schema document with all
+// references replaced by the schema they point to, so it can be cached and
+// used for validation without the rest of the API description. Recursive
+// references allow any value.
+func inlineSchema(s *openapi3.Schema) map[string]interface{} {
+	return inlineSchemaInternal(s, map[*openapi3.Schema]bool{})
+}
+
+func inlineSchemaInternal(s *openapi3.Schema, known map[*openapi3.Schema]bool) map[string]interface{} {
+	result := map[string]interface{}{}
+	if s == nil || known[s] {
+		return result
+	}
+	known[s] = true
+	defer delete(known, s)
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return result
+	}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return map[string]interface{}{}
+	}
+
+	inline := func(ref *openapi3.SchemaRef) map[string]interface{} {
+		if ref == nil {
+			return map[string]interface{}{}
+		}
+		return inlineSchemaInternal(ref.Value, known)
+	}
+
+	if s.Items != nil {
+		result["items"] = inline(s.Items)
+	}
+
+	if s.Not != nil {
+		result["not"] = inline(s.Not)
+	}
+
+	if s.AdditionalProperties != nil {
+		result["additionalProperties"] = inline(s.AdditionalProperties)
+	}
+
+	if len(s.Properties) > 0 {
+		props := map[string]interface{}{}
+		for name, prop := range s.Properties {
+			props[name] = inline(prop)
+		}
+		result["properties"] = props
+	}
+
+	for key, refs := range map[string]openapi3.SchemaRefs{"allOf": s.AllOf, "anyOf": s.AnyOf, "oneOf": s.OneOf} {
+		if len(refs) > 0 {
+			items := make([]interface{}, len(refs))
+			for i, ref := range refs {
+				items[i] = inline(ref)
+			}
+			result[key] = items
+		}
+	}
+
+	// Discriminator mappings use references, which no longer exist.
+	delete(result, "discriminator")
+
+	return result
+}
@@ -190,7 +190,7 @@ func TestSchemaRecursiveObject(t *testing.T) {
 	s.Properties["paths"].Value = s
 
 	out := renderSchema(s, "", modeRead)
-	assert.Equal(t, "{\n  paths: {\n    paths: <rescurive ref>\n  }\n}", out)
+	assert.Equal(t, "{\n  paths: (recursive)\n}", out)
 }
 
 func TestSchemaRecursiveArray(t *testing.T) {
@@ -203,7 +203,7 @@ func TestSchemaRecursiveArray(t *testing.T) {
 	s.Items.Value = s
 
 	out := renderSchema(s, "", modeRead)
-	assert.Equal(t, "[\n  [<recursive ref>]\n]", out)
+	assert.Equal(t, "[\n  (recursive)\n]", out)
 }
 
 func TestSchemaRecursiveAdditional(t *testing.T) {
@@ -216,5 +216,45 @@ func TestSchemaRecursiveAdditional(t *testing.T) {
 	s.AdditionalProperties.Value = s
 
 	out := renderSchema(s, "", modeRead)
-	assert.Equal(t, "{\n  <any>: {\n    <any>: <rescurive ref>\n  }\n}", out)
+	assert.Equal(t, "{\n  <any>: (recursive)\n}", out)
+}
+
+func TestInlineSchemaRecursive(t *testing.T) {
+	node := &openapi3.Schema{
+		Type:     "object",
+		Required: []string{"name"},
+		Properties: openapi3.Schemas{
+			"name": &openapi3.SchemaRef{Value: openapi3.NewStringSchema()},
+		},
+	}
+	node.Properties["children"] = &openapi3.SchemaRef{
+		Ref:   "#/components/schemas/Node",
+		Value: &openapi3.Schema{Type: "array", Items: &openapi3.SchemaRef{Ref: "#/components/schemas/Node", Value: node}},
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"name"},
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+			"children": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{},
+			},
+		},
+	}, inlineSchema(node))
+}
+
+func TestSchemaRepeatedNotRecursive(t *testing.T) {
+	shared := &openapi3.Schema{Type: "string"}
+	s := &openapi3.Schema{
+		Type: "object",
+		Properties: map[string]*openapi3.SchemaRef{
+			"a": {Ref: "#/components/schemas/shared", Value: shared},
+			"b": {Ref: "#/components/schemas/shared", Value: shared},
+		},
+	}
+
+	out := renderSchema(s, "", modeRead)
+	assert.Equal(t, "{\n  a: (string) \n  b: (string) \n}", out)
 }
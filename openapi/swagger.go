@@ -0,0 +1,158 @@
+package openapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/danielgtaylor/restish/cli"
+	"github.com/getkin/kin-openapi/openapi2conv"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// swaggerWarnings returns a list of features used by the OpenAPI 3 document
+// which cannot be represented in Swagger 2.0 and will be lost or approximated
+// during conversion.
+func swaggerWarnings(doc *openapi3.T) []string {
+	warnings := []string{}
+	seen := map[*openapi3.Schema]bool{}
+
+	var walk func(location string, s *openapi3.SchemaRef)
+	walk = func(location string, s *openapi3.SchemaRef) {
+		if s == nil || s.Value == nil || seen[s.Value] {
+			return
+		}
+		seen[s.Value] = true
+
+		if len(s.Value.OneOf) > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s uses oneOf, which is not supported", location))
+		}
+		if len(s.Value.AnyOf) > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s uses anyOf, which is not supported", location))
+		}
+
+		for _, sub := range s.Value.OneOf {
+			walk(location, sub)
+		}
+		for _, sub := range s.Value.AnyOf {
+			walk(location, sub)
+		}
+		for _, sub := range s.Value.AllOf {
+			walk(location, sub)
+		}
+		walk(location, s.Value.Not)
+		walk(location, s.Value.Items)
+		walk(location, s.Value.AdditionalProperties)
+
+		props := []string{}
+		for name := range s.Value.Properties {
+			props = append(props, name)
+		}
+		sort.Strings(props)
+		for _, name := range props {
+			walk(location+"."+name, s.Value.Properties[name])
+		}
+	}
+
+	if len(doc.Servers) > 1 {
+		warnings = append(warnings, "only the first server is used for host and basePath")
+	}
+
+	schemaNames := []string{}
+	for name := range doc.Components.Schemas {
+		schemaNames = append(schemaNames, name)
+	}
+	sort.Strings(schemaNames)
+	for _, name := range schemaNames {
+		walk("schema "+name, doc.Components.Schemas[name])
+	}
+
+	paths := []string{}
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		item := doc.Paths[path]
+		if item == nil {
+			continue
+		}
+
+		methods := []string{}
+		for method := range item.Operations() {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			op := item.GetOperation(method)
+			location := method + " " + path
+
+			if len(op.Callbacks) > 0 {
+				warnings = append(warnings, fmt.Sprintf("%s has callbacks, which are not supported", location))
+			}
+
+			if op.RequestBody != nil && op.RequestBody.Value != nil {
+				for ct, mt := range op.RequestBody.Value.Content {
+					walk(location+" request "+ct, mt.Schema)
+				}
+			}
+
+			for code, resp := range op.Responses {
+				if resp == nil || resp.Value == nil {
+					continue
+				}
+
+				if len(resp.Value.Links) > 0 {
+					warnings = append(warnings, fmt.Sprintf("%s response %s has links, which are not supported", location, code))
+				}
+
+				for ct, mt := range resp.Value.Content {
+					walk(location+" response "+code+" "+ct, mt.Schema)
+				}
+			}
+		}
+	}
+
+	return warnings
+}
+
+type swaggerExporter struct{}
+
+func (e *swaggerExporter) Detect(resp *http.Response) bool {
+	return (&loader{}).Detect(resp)
+}
+
+func (e *swaggerExporter) Export(spec url.URL, resp *http.Response) (interface{}, error) {
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := loader.LoadFromDataWithPath(data, &spec)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range swaggerWarnings(doc) {
+		cli.LogWarning("Lossy conversion: %s", w)
+	}
+
+	if doc.Info == nil {
+		doc.Info = &openapi3.Info{}
+	}
+
+	return openapi2conv.FromV3(doc)
+}
+
+// NewSwaggerExporter creates a new exporter which downconverts OpenAPI 3
+// descriptions to Swagger 2.0.
+func NewSwaggerExporter() cli.Exporter {
+	return &swaggerExporter{}
+}
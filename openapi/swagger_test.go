@@ -0,0 +1,79 @@
+package openapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+)
+
+var lossySample = `
+openapi: "3.0.0"
+info:
+  version: 1.0.0
+  title: Lossy
+servers:
+  - url: https://api.example.com/v1
+  - url: https://staging.example.com/v1
+paths:
+  /items:
+    post:
+      operationId: createItem
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Item"
+      responses:
+        '201':
+          description: Created
+          links:
+            GetItem:
+              operationId: getItem
+components:
+  schemas:
+    Item:
+      type: object
+      properties:
+        value:
+          oneOf:
+            - type: string
+            - type: integer
+`
+
+func TestSwaggerWarnings(t *testing.T) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData([]byte(lossySample))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"only the first server is used for host and basePath",
+		"schema Item.value uses oneOf, which is not supported",
+		"POST /items response 201 has links, which are not supported",
+	}, swaggerWarnings(doc))
+}
+
+func TestSwaggerExport(t *testing.T) {
+	resp := &http.Response{
+		Body: ioutil.NopCloser(strings.NewReader(sample)),
+	}
+
+	e := NewSwaggerExporter()
+	assert.True(t, e.Detect(resp))
+
+	resp.Body = ioutil.NopCloser(strings.NewReader(sample))
+	exported, err := e.Export(*parseURL("https://api.example.com/openapi.yaml"), resp)
+	assert.NoError(t, err)
+
+	doc := exported.(*openapi2.T)
+	assert.Equal(t, "2.0", doc.Swagger)
+	assert.Equal(t, "petstore.swagger.io", doc.Host)
+	assert.Equal(t, "/v1", doc.BasePath)
+	assert.Contains(t, doc.Paths, "/pets")
+	assert.Contains(t, doc.Paths, "/pets/{petId}")
+	assert.Contains(t, doc.Definitions, "Pet")
+}